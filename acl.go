@@ -0,0 +1,52 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnauthorized is returned if the caller isn't authorized to perform an operation on a job.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// The admin operations on jobs which are authorized by the Authorizer.
+const (
+	OpCancel = "cancel"
+	OpRetry  = "retry"
+	OpResume = "resume"
+	OpPrune  = "prune"
+)
+
+// AuthorizerFunc adapts a function to an Authorizer.
+type AuthorizerFunc func(ctx context.Context, caller, op string, msg JobMessage) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, caller, op string, msg JobMessage) error {
+	return f(ctx, caller, op, msg)
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of the context with the identity of the caller of the operations
+// performed with it, which is passed to the server's Authorizer.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the identity of the caller set on the context, or an empty string.
+func CallerFrom(ctx context.Context) string {
+	c, _ := ctx.Value(callerKey{}).(string)
+	return c
+}
+
+// authorize checks that the context's caller may perform the operation on the job. Errors
+// of the authorizer are wrapped with ErrUnauthorized.
+func (s *Server) authorize(ctx context.Context, op string, msg JobMessage) error {
+	if s.authorizer == nil {
+		return nil
+	}
+	if err := s.authorizer.Authorize(ctx, CallerFrom(ctx), op, msg); err != nil {
+		return fmt.Errorf("could not %s job %s : %v : %w", op, msg.UUID, err, ErrUnauthorized)
+	}
+
+	return nil
+}
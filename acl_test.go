@@ -0,0 +1,69 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+	)
+	// Teams may only mutate the jobs of their tenant.
+	srv, err := NewServer(ServerOpts{
+		Broker:  broker,
+		Results: NewMockResults(),
+		Authorizer: AuthorizerFunc(func(ctx context.Context, caller, op string, msg JobMessage) error {
+			if caller != msg.Tenant {
+				return errors.New("not the job's owner")
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue := func(tenant string) string {
+		job, err := NewJob(taskName, nil, JobOpts{Tenant: tenant, Tags: []string{"batch"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuid
+	}
+	a, b := enqueue("team-a"), enqueue("team-b")
+
+	if err := srv.Cancel(WithCaller(ctx, "team-b"), a); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected another team's job not to be cancellable, got %v", err)
+	}
+	if err := srv.Cancel(ctx, a); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected an anonymous caller not to cancel the job, got %v", err)
+	}
+	if err := srv.Retry(WithCaller(ctx, "team-b"), a); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected another team's job not to be retryable, got %v", err)
+	}
+
+	// Bulk operations skip the jobs the caller isn't authorized for.
+	n, err := srv.CancelByTag(WithCaller(ctx, "team-a"), "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 job to be cancelled, got %d", n)
+	}
+	for uuid, status := range map[string]string{a: StatusCancelled, b: StatusStarted} {
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != status {
+			t.Fatalf("expected job %s to be %s, got %s", uuid, status, msg.Status)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package admin provides the HTTP handler of a server's runtime config, to inspect and reload it
+// (eg: its log level, concurrency, rate limits and paused queues) without restarting it.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+// Response is the JSON body of the handler's errors.
+type Response struct {
+	Error string `json:"error"`
+}
+
+// Config returns a handler which responds to GET with the server's runtime config, and reloads
+// the server with the runtime config in the body of a PUT (see tasqueue.RuntimeConfig), responding
+// with the reloaded config. The handler isn't authenticated, hence it should be wrapped with the
+// application's auth, which can set the caller (tasqueue.WithCaller()) for the audit log.
+func Config(srv *tasqueue.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var c tasqueue.RuntimeConfig
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				respond(w, http.StatusBadRequest, Response{Error: "invalid config : " + err.Error()})
+				return
+			}
+			if err := srv.Reload(r.Context(), c); err != nil {
+				respond(w, http.StatusBadRequest, Response{Error: err.Error()})
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			respond(w, http.StatusMethodNotAllowed, Response{Error: "method not allowed"})
+			return
+		}
+
+		respond(w, http.StatusOK, srv.RuntimeConfig())
+	})
+}
+
+func respond(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,157 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultArchiveInterval = time.Hour
+
+// JobRecord is a job message along with the results saved by the job. It is the
+// format in which jobs are archived and exported.
+type JobRecord struct {
+	JobMessage
+	Results      [][]byte
+	NamedResults map[string][]byte
+	Chunks       [][]byte
+}
+
+// Archive is an append-only store (eg: NDJSON files, S3) for records of completed jobs.
+type Archive interface {
+	Write(ctx context.Context, records []JobRecord) error
+}
+
+// ArchiverOpts configures the archiver.
+type ArchiverOpts struct {
+	Archive Archive
+	// Retention is the duration after completion for which jobs are kept in the results store.
+	Retention time.Duration
+	// Interval is the duration between archival runs. Defaults to an hour.
+	Interval time.Duration
+}
+
+// ArchiveJobs() moves the jobs that completed (with any final status) before the retention
+// window from the results store into the archive, and returns the number of jobs archived.
+// Jobs are deleted from the results store only after they're written to the archive. Jobs
+// that can't be looked up, eg: as they were deleted meanwhile, are skipped.
+func (s *Server) ArchiveJobs(ctx context.Context, a Archive, retention time.Duration) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+
+	// Jobs complete after they're enqueued, hence the jobs enqueued within the retention
+	// window are retained.
+	var (
+		cutoff = s.clock.Now().Add(-retention)
+		n      int
+	)
+	for offset := 0; ; {
+		uuids, err := queryJobs(ctx, s.results, "", time.Time{}, cutoff, offset, queryBatchSize, false)
+		if err != nil {
+			return n, err
+		}
+
+		var records []JobRecord
+		for _, uuid := range uuids {
+			msg, err := s.getJob(ctx, uuid, false)
+			if err != nil {
+				s.log.Error("could not get job to archive", "uuid", uuid, "error", err)
+				continue
+			}
+			if !IsFinal(msg.Status) || msg.ProcessedAt.After(cutoff) {
+				continue
+			}
+
+			records = append(records, s.jobRecord(ctx, msg))
+		}
+
+		if len(records) > 0 {
+			if err := a.Write(ctx, records); err != nil {
+				return n, fmt.Errorf("could not write to archive : %w", err)
+			}
+			for _, r := range records {
+				if err := s.deleteJob(ctx, r.JobMessage); err != nil {
+					return n, err
+				}
+				n++
+			}
+			s.metrics.GetOrCreateCounter(metricJobsArchived).Add(len(records))
+		}
+
+		if len(uuids) < queryBatchSize {
+			break
+		}
+		// The archived jobs are no longer in the index.
+		offset += len(uuids) - len(records)
+	}
+
+	return n, nil
+}
+
+// RunArchiver() periodically archives jobs. It is a blocking function.
+func (s *Server) RunArchiver(ctx context.Context, o ArchiverOpts) {
+	if o.Interval == 0 {
+		o.Interval = defaultArchiveInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(o.Interval):
+			n, err := s.ArchiveJobs(ctx, o.Archive, o.Retention)
+			if err != nil {
+				s.log.Error("error archiving jobs", "error", err)
+				continue
+			}
+			s.log.Debug("archived jobs", "count", n)
+		}
+	}
+}
+
+// jobRecord returns the job message along with the job's saved results (if any).
+func (s *Server) jobRecord(ctx context.Context, msg JobMessage) JobRecord {
+	rec := JobRecord{JobMessage: msg}
+
+	// Jobs which don't save any results have no results in the store.
+	if res, err := s.GetResult(ctx, msg.UUID); err == nil {
+		rec.Results = res
+	}
+	if res, err := s.getNamedResults(ctx, msg.UUID); err == nil {
+		rec.NamedResults = res
+	}
+	if res, err := getChunks(ctx, s.results, streamPrefix+msg.UUID, 0); err == nil {
+		rec.Chunks = res
+	}
+
+	return rec
+}
+
+// deleteJob deletes the job message, results and tag references from the results store.
+func (s *Server) deleteJob(ctx context.Context, msg JobMessage) error {
+	for _, tag := range msg.Tags {
+		if err := deleteTag(ctx, s.results, tag, msg.UUID); err != nil {
+			return fmt.Errorf("could not delete job tag %s : %w", tag, err)
+		}
+	}
+	if err := unindexJob(ctx, s.results, msg.UUID, indexKeys(msg)); err != nil {
+		return fmt.Errorf("could not unindex job : %w", err)
+	}
+	if err := s.results.Delete(ctx, resultsPrefix+msg.UUID); err != nil {
+		return fmt.Errorf("could not delete job results : %w", err)
+	}
+	if err := s.results.Delete(ctx, namedResultsPrefix+msg.UUID); err != nil {
+		return fmt.Errorf("could not delete job results : %w", err)
+	}
+	if err := s.results.Delete(ctx, streamPrefix+msg.UUID); err != nil {
+		return fmt.Errorf("could not delete job chunks : %w", err)
+	}
+	if err := s.results.Delete(ctx, msg.UUID); err != nil {
+		return fmt.Errorf("could not delete job : %w", err)
+	}
+	s.cache.remove(msg.UUID)
+	s.cache.remove(resultsPrefix + msg.UUID)
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type MockArchive struct {
+	mu      sync.Mutex
+	records []JobRecord
+}
+
+func (m *MockArchive) Write(_ context.Context, records []JobRecord) error {
+	m.mu.Lock()
+	m.records = append(m.records, records...)
+	m.mu.Unlock()
+	return nil
+}
+
+func TestArchiveJobs(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+		arc = &MockArchive{}
+	)
+	go srv.Start(ctx)
+
+	var uuids []string
+	for _, f := range []bool{false, true} {
+		uuid, err := srv.Enqueue(ctx, makeJob(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	// Wait for jobs to be consumed & processed.
+	time.Sleep(time.Second)
+
+	// Jobs within the retention window are retained.
+	if n, err := srv.ArchiveJobs(ctx, arc, time.Hour); err != nil || n != 0 {
+		t.Fatalf("expected no jobs archived, got %d, err %v", n, err)
+	}
+
+	n, err := srv.ArchiveJobs(ctx, arc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(uuids) || len(arc.records) != len(uuids) {
+		t.Fatalf("expected %d jobs archived, got %d", len(uuids), n)
+	}
+
+	for _, uuid := range uuids {
+		if _, err := srv.GetJob(ctx, uuid); err == nil {
+			t.Fatalf("archived job %s was not deleted from the results store", uuid)
+		}
+	}
+}
+
+func TestArchiveFinalStatuses(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+		clock   = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		arc     = &MockArchive{}
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	var uuids []string
+	for i := 0; i < 3; i++ {
+		uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+	// The first job is cancelled, and the second one is deleted from under its index.
+	if err := srv.Cancel(ctx, uuids[0]); err != nil {
+		t.Fatal(err)
+	}
+	for len(broker.data) > 0 {
+		srv.Process(ctx, <-broker.data)
+	}
+	if err := results.Delete(ctx, uuids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.advance(time.Minute)
+	n, err := srv.ArchiveJobs(ctx, arc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(arc.records) != 2 {
+		t.Fatalf("expected the cancelled and done jobs to be archived, got %d", n)
+	}
+	if arc.records[0].UUID != uuids[0] || arc.records[0].Status != StatusCancelled {
+		t.Fatalf("expected the cancelled job to be archived, got %s : %s", arc.records[0].UUID, arc.records[0].Status)
+	}
+}
@@ -0,0 +1,78 @@
+// Package ndjson is an archive that appends job records as newline delimited JSON
+// to daily files in a directory.
+package ndjson
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+const defaultPrefix = "tasqueue-"
+
+type Options struct {
+	// Dir is the directory where archive files are written. It is created if it doesn't exist.
+	Dir string
+	// Prefix is prefixed to the file names, which are suffixed with the date (eg: tasqueue-2022-08-01.ndjson).
+	Prefix string
+}
+
+// Archive appends job records to daily NDJSON files.
+type Archive struct {
+	opt Options
+	mu  sync.Mutex
+}
+
+// New() returns a new instance of the NDJSON archive.
+func New(o Options) (*Archive, error) {
+	if o.Dir == "" {
+		return nil, fmt.Errorf("dir missing in options")
+	}
+	if o.Prefix == "" {
+		o.Prefix = defaultPrefix
+	}
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating archive directory : %w", err)
+	}
+
+	return &Archive{opt: o}, nil
+}
+
+func (a *Archive) Write(_ context.Context, records []tasqueue.JobRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name := filepath.Join(a.opt.Dir, a.opt.Prefix+time.Now().UTC().Format("2006-01-02")+".ndjson")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	// Sync before returning, as the jobs are deleted from the results store after they are archived.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
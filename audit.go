@@ -0,0 +1,91 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const auditKey = "tasqueue:audit"
+
+// The admin actions which are audited, besides the operations on jobs (OpCancel, OpRetry,
+// OpResume, OpPrune).
+const (
+	OpDrain        = "drain"
+	OpResumeQueue  = "resume_queue"
+	OpPauseQueue   = "pause_queue"
+	OpUnpauseQueue = "unpause_queue"
+	OpReload       = "reload"
+	OpSchedule     = "schedule"
+)
+
+// ErrNoAuditLog is returned on listing the audit log if the server's audit sink can't list
+// the recorded actions, or auditing isn't configured.
+var ErrNoAuditLog = errors.New("audit log not readable")
+
+// AuditEntry is an admin action performed on the server.
+type AuditEntry struct {
+	Time time.Time
+	// Actor is the caller set on the action's context with WithCaller(), if any.
+	Actor  string
+	Action string
+	// Target is the job UUID, queue, or schedule UUID the action was performed on, and
+	// Detail any details of the action (eg: the cron spec of a schedule).
+	Target string
+	Detail string `json:",omitempty"`
+}
+
+// resultsAuditSink records the admin actions onto a list on the results store.
+type resultsAuditSink struct {
+	results Results
+}
+
+func (r resultsAuditSink) Record(ctx context.Context, e AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return appendChunk(ctx, r.results, auditKey, b)
+}
+
+func (r resultsAuditSink) Entries(ctx context.Context, offset int) ([]AuditEntry, error) {
+	chunks, err := getChunks(ctx, r.results, auditKey, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]AuditEntry, 0, len(chunks))
+	for _, b := range chunks {
+		var e AuditEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// GetAuditLog() returns the admin actions recorded by the server's audit sink, from the
+// offset, oldest first.
+func (s *Server) GetAuditLog(ctx context.Context, offset int) ([]AuditEntry, error) {
+	r, ok := s.audit.(AuditReader)
+	if !ok {
+		return nil, ErrNoAuditLog
+	}
+	return r.Entries(ctx, offset)
+}
+
+// recordAudit records the admin action performed with the context. Failures are logged, as
+// the action has already been performed.
+func (s *Server) recordAudit(ctx context.Context, action, target, detail string) {
+	if s.audit == nil {
+		return
+	}
+
+	e := AuditEntry{Time: s.clock.Now(), Actor: CallerFrom(ctx), Action: action, Target: target, Detail: detail}
+	if err := s.audit.Record(ctx, e); err != nil {
+		s.log.Error("error recording audit entry", "action", action, "target", target, "error", err)
+	}
+}
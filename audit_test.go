@@ -0,0 +1,58 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := WithCaller(context.Background(), "alice")
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), AuditLog: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := NewJob(taskName, nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Cancel(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	// Failed actions aren't recorded.
+	if err := srv.Retry(ctx, uuid); !errors.Is(err, ErrJobNotRetryable) {
+		t.Fatalf("expected the cancelled job not to be retryable, got %v", err)
+	}
+	srv.ResumeQueue("default")
+
+	entries, err := srv.GetAuditLog(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Actor != "alice" || e.Action != OpCancel || e.Target != uuid || e.Time.IsZero() {
+		t.Fatalf("expected the cancellation to be recorded, got %+v", e)
+	}
+	if e := entries[1]; e.Actor != "" || e.Action != OpResumeQueue || e.Target != "default" {
+		t.Fatalf("expected the resumed queue to be recorded, got %+v", e)
+	}
+	if entries, err = srv.GetAuditLog(ctx, 1); err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry from the offset, got %+v, %v", entries, err)
+	}
+
+	// Servers without auditing have no log.
+	srv, err = NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.GetAuditLog(ctx, 0); !errors.Is(err, ErrNoAuditLog) {
+		t.Fatalf("expected no audit log, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package auth holds the TLS and authentication options shared by the broker,
+// results and rate limiter backends.
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Options configures the authentication and TLS of a backend's connection.
+type Options struct {
+	Username string
+	Password string
+	// Token authenticates with a token on nats. On redis, which only supports passwords,
+	// it is used as the password if Password isn't set.
+	Token string
+
+	// TLS enables TLS with the config. LoadTLS() builds a config from files, including a
+	// client certificate for mTLS.
+	TLS *tls.Config
+}
+
+// LoadTLS returns a TLS config which verifies the server with the CA certificate (or the
+// system's roots, if caFile is empty) and presents the client certificate if the cert and
+// key files are set, for mTLS.
+func LoadTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate : %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate : %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// RedisPassword returns the password to authenticate with on redis.
+func (o Options) RedisPassword() string {
+	if o.Password == "" {
+		return o.Token
+	}
+	return o.Password
+}
@@ -0,0 +1,75 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// backpressurePoll is the interval at which the depth of a full queue is checked, while
+// waiting for it to have room.
+const backpressurePoll = time.Millisecond * 100
+
+// ErrQueueFull is returned by EnqueueWithBackpressure() if the job's queue is at its max
+// depth, and doesn't have room within the wait.
+var ErrQueueFull = errors.New("queue is full")
+
+// maxDepth returns the max depth of the queue, or zero if it doesn't have one.
+func (c *Client) maxDepth(queue string) int64 {
+	if d, ok := c.queueMaxDepths[queue]; ok {
+		return d
+	}
+	return c.defaultMaxDepth
+}
+
+// EnqueueWithBackpressure() enqueues the job if its queue is below its max depth
+// (ClientOpts.MaxDepth and QueueMaxDepths), so that producers can shed load instead of
+// building an unbounded backlog. If the queue is full, it waits upto the wait for the queue
+// to have room, and returns ErrQueueFull if it doesn't. The depth is checked before
+// enqueuing, hence concurrent producers can overshoot the max depth by their number.
+func (c *Client) EnqueueWithBackpressure(ctx context.Context, j Job, wait time.Duration) (string, error) {
+	if j.Opts.Schedule != "" {
+		return "", fmt.Errorf("scheduled jobs can not be enqueued on a client")
+	}
+	t, err := c.srv.prepareJob(j)
+	if err != nil {
+		return "", err
+	}
+
+	if limit := c.maxDepth(t.Opts.Queue); limit > 0 {
+		if err := c.waitForRoom(ctx, t.Opts.Queue, limit, wait); err != nil {
+			return "", err
+		}
+	}
+
+	return c.srv.enqueueWithMeta(ctx, t, DefaultMeta(t.Opts))
+}
+
+// waitForRoom waits upto the wait for the depth of the queue to be below the limit.
+func (c *Client) waitForRoom(ctx context.Context, queue string, limit int64, wait time.Duration) error {
+	deadline := c.srv.clock.Now().Add(wait)
+	for {
+		d, err := c.srv.QueueDepth(ctx, queue)
+		if err != nil {
+			return fmt.Errorf("could not check depth of queue %s : %w", queue, err)
+		}
+		if d < limit {
+			return nil
+		}
+
+		left := deadline.Sub(c.srv.clock.Now())
+		if left <= 0 {
+			return fmt.Errorf("could not enqueue onto queue %s with %d jobs : %w", queue, d, ErrQueueFull)
+		}
+		if left > backpressurePoll {
+			left = backpressurePoll
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.srv.clock.After(left):
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+)
+
+func TestEnqueueWithBackpressure(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = mb.New()
+	)
+	cl, err := NewClient(ClientOpts{Broker: broker, Results: NewMockResults(), MaxDepth: 2, QueueMaxDepths: map[string]int64{"bulk": 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cl.EnqueueWithBackpressure(ctx, makeJob(t, false), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The queue is at its max depth.
+	if _, err := cl.EnqueueWithBackpressure(ctx, makeJob(t, false), 0); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// Queues without a max depth aren't capped.
+	j, err := NewJob(taskName, nil, JobOpts{Queue: "bulk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cl.EnqueueWithBackpressure(ctx, j, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The enqueue waits for the queue to have room.
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	work := make(chan []byte)
+	go broker.Consume(cctx, work, DefaultQueue)
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		<-work
+	}()
+	if _, err := cl.EnqueueWithBackpressure(ctx, makeJob(t, false), time.Second); err != nil {
+		t.Fatalf("expected the job to be enqueued once the queue had room, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Blobs is a filesystem based blob store. Each blob is stored as a file in the directory.
+type Blobs struct {
+	dir string
+}
+
+type Options struct {
+	// Dir is the directory where blobs are stored. It is created if it doesn't exist.
+	Dir string
+}
+
+// New() returns a new instance of the filesystem blob store.
+func New(o Options) (*Blobs, error) {
+	if o.Dir == "" {
+		return nil, fmt.Errorf("dir missing in options")
+	}
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating blob directory : %w", err)
+	}
+
+	return &Blobs{dir: o.Dir}, nil
+}
+
+func (b *Blobs) Put(ctx context.Context, key string, data []byte) error {
+	return b.PutStream(ctx, key, bytes.NewReader(data))
+}
+
+// PutStream writes the blob from r, without holding it in memory.
+func (b *Blobs) PutStream(_ context.Context, key string, r io.Reader) error {
+	// Write to a temporary file and rename it, so that readers never see a partial blob.
+	tmp, err := ioutil.TempFile(b.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.path(key))
+}
+
+func (b *Blobs) Get(_ context.Context, key string) ([]byte, error) {
+	return ioutil.ReadFile(b.path(key))
+}
+
+// GetStream returns a reader of the blob's file.
+func (b *Blobs) GetStream(_ context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(b.path(key))
+}
+
+func (b *Blobs) Delete(_ context.Context, key string) error {
+	if err := os.Remove(b.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns the file path of a blob. Keys are sanitized so that they can't escape the directory.
+func (b *Blobs) path(key string) string {
+	return filepath.Join(b.dir, strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key))
+}
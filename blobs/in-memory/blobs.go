@@ -0,0 +1,45 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type Blobs struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func New() *Blobs {
+	return &Blobs{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (r *Blobs) Put(_ context.Context, key string, b []byte) error {
+	r.mu.Lock()
+	r.blobs[key] = b
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Blobs) Get(_ context.Context, key string) ([]byte, error) {
+	r.mu.Lock()
+	b, ok := r.blobs[key]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("blob not found")
+	}
+
+	return b, nil
+}
+
+func (r *Blobs) Delete(_ context.Context, key string) error {
+	r.mu.Lock()
+	delete(r.blobs, key)
+	r.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,422 @@
+// Package brokertest is a conformance suite for tasqueue.Broker implementations. Backend
+// authors can run it from a test against their broker to validate it against the contract
+// expected by the server:
+//
+//	func TestBroker(t *testing.T) {
+//		brokertest.Run(t, func(t *testing.T) tasqueue.Broker {
+//			return mybroker.New(...)
+//		})
+//	}
+package brokertest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+const (
+	// timeout is the duration within which an enqueued message is expected to be consumed.
+	timeout = time.Second * 10
+	// quiet is the duration for which no message is expected, when none should be consumed.
+	quiet = time.Millisecond * 500
+)
+
+// Factory returns the broker under test. It's called once per test, and the brokers it
+// returns may share their storage, as each test uses its own queues.
+type Factory func(t *testing.T) tasqueue.Broker
+
+// Run runs the conformance tests against the brokers returned by the factory.
+func Run(t *testing.T, f Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, tasqueue.Broker, string)
+	}{
+		{"EnqueueConsume", testEnqueueConsume},
+		{"Ordering", testOrdering},
+		{"QueueIsolation", testQueueIsolation},
+		{"Redelivery", testRedelivery},
+		{"ConcurrentConsumers", testConcurrentConsumers},
+		{"StopConsumer", testStopConsumer},
+		{"Queues", testQueues},
+		{"GetPending", testGetPending},
+		{"PriorityQueues", testPriorityQueues},
+		{"Trim", testTrim},
+		{"PriorityTrim", testPriorityTrim},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Prefix the queues with a unique name, as brokers may share their storage.
+			prefix := fmt.Sprintf("brokertest:%d:", time.Now().UnixNano())
+			tc.fn(t, f(t), prefix)
+		})
+	}
+}
+
+// messages returns n distinct messages.
+func messages(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = []byte(fmt.Sprintf("message-%03d", i))
+	}
+
+	return out
+}
+
+func enqueue(t *testing.T, b tasqueue.Broker, queue string, msgs [][]byte) {
+	t.Helper()
+
+	for _, m := range msgs {
+		if err := b.Enqueue(context.Background(), m, queue); err != nil {
+			t.Fatalf("error enqueuing message: %v", err)
+		}
+	}
+}
+
+// consumer is a running consumer of a queue.
+type consumer struct {
+	work   chan []byte
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func consume(b tasqueue.Broker, queue string) *consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &consumer{work: make(chan []byte), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		b.Consume(ctx, c.work, queue)
+		close(c.done)
+	}()
+
+	return c
+}
+
+// receive receives n messages from the work channel, failing the test on a timeout.
+func receive(t *testing.T, work chan []byte, n int) [][]byte {
+	t.Helper()
+
+	out := make([][]byte, 0, n)
+	for len(out) < n {
+		select {
+		case m := <-work:
+			out = append(out, m)
+		case <-time.After(timeout):
+			t.Fatalf("timed out waiting for messages, received %d of %d", len(out), n)
+		}
+	}
+
+	return out
+}
+
+// expectNone fails the test if a message is received on the work channel.
+func expectNone(t *testing.T, work chan []byte) {
+	t.Helper()
+
+	select {
+	case m := <-work:
+		t.Fatalf("expected no message, received %q", m)
+	case <-time.After(quiet):
+	}
+}
+
+// stop stops the consumer, failing the test if Consume doesn't return.
+func (c *consumer) stop(t *testing.T) {
+	t.Helper()
+
+	c.cancel()
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for Consume to return after the context was cancelled")
+	}
+}
+
+func sorted(msgs [][]byte) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = string(m)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// testEnqueueConsume checks that an enqueued message is consumed unaltered.
+func testEnqueueConsume(t *testing.T, b tasqueue.Broker, prefix string) {
+	q := prefix + "q"
+	c := consume(b, q)
+	defer c.stop(t)
+
+	msg := []byte("\x00binary\xffpayload")
+	enqueue(t, b, q, [][]byte{msg})
+	if got := receive(t, c.work, 1)[0]; string(got) != string(msg) {
+		t.Fatalf("expected message %q, received %q", msg, got)
+	}
+}
+
+// testOrdering checks that a single consumer receives a queue's messages in the order
+// they were enqueued.
+func testOrdering(t *testing.T, b tasqueue.Broker, prefix string) {
+	var (
+		q    = prefix + "q"
+		msgs = messages(20)
+	)
+	enqueue(t, b, q, msgs)
+
+	c := consume(b, q)
+	defer c.stop(t)
+	if got := receive(t, c.work, len(msgs)); !reflect.DeepEqual(got, msgs) {
+		t.Fatalf("expected messages in order %q, received %q", msgs, got)
+	}
+}
+
+// testQueueIsolation checks that messages are only consumed from the queue they were
+// enqueued on.
+func testQueueIsolation(t *testing.T, b tasqueue.Broker, prefix string) {
+	var (
+		qa = prefix + "a"
+		qb = prefix + "b"
+	)
+	cb := consume(b, qb)
+	defer cb.stop(t)
+
+	enqueue(t, b, qa, messages(1))
+	expectNone(t, cb.work)
+
+	ca := consume(b, qa)
+	defer ca.stop(t)
+	receive(t, ca.work, 1)
+}
+
+// testRedelivery checks that messages left in the queue by a stopped consumer, and messages
+// enqueued while no consumer is running, are delivered to the next consumer.
+func testRedelivery(t *testing.T, b tasqueue.Broker, prefix string) {
+	var (
+		q    = prefix + "q"
+		msgs = messages(10)
+	)
+	c := consume(b, q)
+	enqueue(t, b, q, msgs[:5])
+	got := receive(t, c.work, 5)
+	c.stop(t)
+
+	enqueue(t, b, q, msgs[5:])
+	c = consume(b, q)
+	defer c.stop(t)
+	got = append(got, receive(t, c.work, 5)...)
+
+	if !reflect.DeepEqual(sorted(got), sorted(msgs)) {
+		t.Fatalf("expected messages %q, received %q", sorted(msgs), sorted(got))
+	}
+}
+
+// testConcurrentConsumers checks that messages enqueued concurrently are each delivered once
+// to a pool of consumers of the queue.
+func testConcurrentConsumers(t *testing.T, b tasqueue.Broker, prefix string) {
+	var (
+		q    = prefix + "q"
+		msgs = messages(100)
+		work = make(chan []byte)
+		wg   sync.WaitGroup
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		go b.Consume(ctx, work, q)
+	}
+
+	errs := make(chan error, len(msgs))
+	for _, m := range msgs {
+		wg.Add(1)
+		go func(m []byte) {
+			defer wg.Done()
+			if err := b.Enqueue(context.Background(), m, q); err != nil {
+				errs <- err
+			}
+		}(m)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("error enqueuing message: %v", err)
+	}
+
+	got := receive(t, work, len(msgs))
+	if !reflect.DeepEqual(sorted(got), sorted(msgs)) {
+		t.Fatalf("expected each message to be delivered once, received %q", sorted(got))
+	}
+	expectNone(t, work)
+}
+
+// testStopConsumer checks that Consume returns once its context is cancelled.
+func testStopConsumer(t *testing.T, b tasqueue.Broker, prefix string) {
+	c := consume(b, prefix+"q")
+	time.Sleep(quiet)
+	c.stop(t)
+}
+
+// testQueues checks that the queues holding messages are looked up by pattern. It's skipped
+// if the broker doesn't implement tasqueue.QueueLister.
+func testQueues(t *testing.T, b tasqueue.Broker, prefix string) {
+	l, ok := b.(tasqueue.QueueLister)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.QueueLister")
+	}
+	for _, q := range []string{"emails:a", "emails:b", "sms"} {
+		enqueue(t, b, prefix+q, messages(1))
+	}
+
+	got, err := l.Queues(context.Background(), prefix+"emails:*")
+	if err != nil {
+		t.Fatalf("error looking up queues: %v", err)
+	}
+	sort.Strings(got)
+	if exp := []string{prefix + "emails:a", prefix + "emails:b"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected queues %v, got %v", exp, got)
+	}
+
+	if _, err := l.Queues(context.Background(), prefix+"["); err == nil {
+		t.Fatal("expected an error looking up queues with a malformed pattern")
+	}
+}
+
+// testGetPending checks that pending messages are peeked at in order, without being consumed.
+// It's skipped if the broker doesn't implement tasqueue.Peeker.
+func testGetPending(t *testing.T, b tasqueue.Broker, prefix string) {
+	p, ok := b.(tasqueue.Peeker)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.Peeker")
+	}
+	var (
+		q    = prefix + "q"
+		msgs = messages(3)
+	)
+	enqueue(t, b, q, msgs)
+
+	got, err := p.GetPending(context.Background(), q, 2)
+	if err != nil {
+		t.Fatalf("error peeking at messages: %v", err)
+	}
+	if !reflect.DeepEqual(got, msgs[:2]) {
+		t.Fatalf("expected pending messages %q, got %q", msgs[:2], got)
+	}
+
+	c := consume(b, q)
+	defer c.stop(t)
+	if got := receive(t, c.work, len(msgs)); !reflect.DeepEqual(got, msgs) {
+		t.Fatalf("expected pending messages to be consumed, received %q", got)
+	}
+}
+
+// testPriorityQueues checks that the queues holding only prioritized messages are looked up.
+// It's skipped if the broker doesn't implement tasqueue.PriorityBroker and tasqueue.QueueLister.
+func testPriorityQueues(t *testing.T, b tasqueue.Broker, prefix string) {
+	p, ok := b.(tasqueue.PriorityBroker)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.PriorityBroker")
+	}
+	l, ok := b.(tasqueue.QueueLister)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.QueueLister")
+	}
+	if err := p.EnqueuePriority(context.Background(), messages(1)[0], prefix+"urgent", 1); err != nil {
+		t.Fatalf("error enqueuing message: %v", err)
+	}
+
+	got, err := l.Queues(context.Background(), prefix+"*")
+	if err != nil {
+		t.Fatalf("error looking up queues: %v", err)
+	}
+	if exp := []string{prefix + "urgent"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected queues %v, got %v", exp, got)
+	}
+}
+
+// testTrim checks that the oldest messages are trimmed off a queue, and the newest ones are
+// kept. It's skipped if the broker doesn't implement tasqueue.Trimmer.
+func testTrim(t *testing.T, b tasqueue.Broker, prefix string) {
+	tr, ok := b.(tasqueue.Trimmer)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.Trimmer")
+	}
+	var (
+		q    = prefix + "q"
+		msgs = messages(5)
+	)
+	enqueue(t, b, q, msgs)
+
+	got, err := tr.Trim(context.Background(), q, 2)
+	if err != nil {
+		t.Fatalf("error trimming queue: %v", err)
+	}
+	if exp := msgs[:3]; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected trimmed messages %q, got %q", exp, got)
+	}
+
+	c := consume(b, q)
+	defer c.stop(t)
+	if got := receive(t, c.work, 2); !reflect.DeepEqual(got, msgs[3:]) {
+		t.Fatalf("expected the newest messages to be kept, received %q", got)
+	}
+	expectNone(t, c.work)
+}
+
+// testPriorityTrim checks that the prioritized messages are kept ahead of the others on
+// trimming a queue. It's skipped if the broker doesn't implement tasqueue.PriorityBroker and
+// tasqueue.Trimmer.
+func testPriorityTrim(t *testing.T, b tasqueue.Broker, prefix string) {
+	p, ok := b.(tasqueue.PriorityBroker)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.PriorityBroker")
+	}
+	tr, ok := b.(tasqueue.Trimmer)
+	if !ok {
+		t.Skip("the broker doesn't implement tasqueue.Trimmer")
+	}
+
+	var (
+		q    = prefix + "q"
+		msgs = messages(5)
+	)
+	enqueue(t, b, q, msgs[:2])
+	for i, m := range msgs[2:] {
+		// The first prioritized message has a higher priority than the others.
+		priority := uint8(1)
+		if i == 0 {
+			priority = 2
+		}
+		if err := p.EnqueuePriority(context.Background(), m, q, priority); err != nil {
+			t.Fatalf("error enqueuing message: %v", err)
+		}
+	}
+
+	// The oldest message without a priority is trimmed first.
+	got, err := tr.Trim(context.Background(), q, 4)
+	if err != nil {
+		t.Fatalf("error trimming queue: %v", err)
+	}
+	if exp := msgs[:1]; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected trimmed messages %q, got %q", exp, got)
+	}
+
+	// The oldest message of the lowest priority is trimmed once there's no room for it.
+	if got, err = tr.Trim(context.Background(), q, 2); err != nil {
+		t.Fatalf("error trimming queue: %v", err)
+	}
+	if exp := [][]byte{msgs[1], msgs[3]}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected trimmed messages %q, got %q", exp, got)
+	}
+
+	c := consume(b, q)
+	defer c.stop(t)
+	if got := receive(t, c.work, 2); !reflect.DeepEqual(got, [][]byte{msgs[2], msgs[4]}) {
+		t.Fatalf("expected the highest priority and newest messages to be kept, received %q", got)
+	}
+	expectNone(t, c.work)
+}
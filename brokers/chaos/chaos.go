@@ -0,0 +1,519 @@
+// Package chaos wraps a broker and results store, injecting faults (delays, duplicate
+// deliveries, dropped acks and connection errors) to test that handlers are idempotent
+// and that the server recovers from failures. It is meant for tests and staging, not production.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+const defaultRedeliverAfter = time.Second
+
+// ErrInjected is returned by operations failed by the wrapper.
+var ErrInjected = errors.New("chaos: injected connection error")
+
+type Options struct {
+	// Delay is the maximum random delay added to each operation and delivery.
+	Delay time.Duration
+
+	// ErrorRate is the probability (0-1) of an operation failing with ErrInjected.
+	ErrorRate float64
+
+	// DuplicateRate is the probability of a consumed message being delivered twice.
+	DuplicateRate float64
+
+	// DropAckRate is the probability of a consumed message's ack being lost, in which case
+	// the message is delivered again after RedeliverAfter (1s by default), as a broker would.
+	DropAckRate    float64
+	RedeliverAfter time.Duration
+
+	// Seed seeds the random faults, so that a run can be reproduced. Defaults to the current time.
+	Seed int64
+}
+
+// faults decides which faults are injected. It is shared by a broker and results store
+// created with the same options.
+type faults struct {
+	opt Options
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newFaults(o Options) *faults {
+	if o.RedeliverAfter == 0 {
+		o.RedeliverAfter = defaultRedeliverAfter
+	}
+	if o.Seed == 0 {
+		o.Seed = time.Now().UnixNano()
+	}
+
+	return &faults{opt: o, rnd: rand.New(rand.NewSource(o.Seed))}
+}
+
+// chance returns true with the probability p.
+func (f *faults) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Float64() < p
+}
+
+// delay sleeps for a random duration upto Options.Delay, or until the context is done.
+func (f *faults) delay(ctx context.Context) error {
+	if f.opt.Delay <= 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	d := time.Duration(f.rnd.Int63n(int64(f.opt.Delay)))
+	f.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// inject delays the operation and returns ErrInjected if it should fail.
+func (f *faults) inject(ctx context.Context) error {
+	if err := f.delay(ctx); err != nil {
+		return err
+	}
+	if f.chance(f.opt.ErrorRate) {
+		return ErrInjected
+	}
+
+	return nil
+}
+
+// Broker wraps a broker, injecting faults into its operations and deliveries.
+type Broker struct {
+	tasqueue.Broker
+	f *faults
+}
+
+// NewBroker() returns a broker which wraps b.
+func NewBroker(b tasqueue.Broker, o Options) *Broker {
+	return &Broker{Broker: b, f: newFaults(o)}
+}
+
+// Results() returns a results store which wraps r, sharing the broker's options and
+// random source.
+func (b *Broker) Results(r tasqueue.Results) *Results {
+	return &Results{Results: r, f: b.f}
+}
+
+func (b *Broker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	if err := b.f.inject(ctx); err != nil {
+		return err
+	}
+
+	return b.Broker.Enqueue(ctx, msg, queue)
+}
+
+// EnqueuePriority injects faults into enqueuing the message with the priority on the wrapped
+// broker if it implements tasqueue.PriorityBroker, or else in order.
+func (b *Broker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	if err := b.f.inject(ctx); err != nil {
+		return err
+	}
+	if p, ok := b.Broker.(tasqueue.PriorityBroker); ok {
+		return p.EnqueuePriority(ctx, msg, queue, priority)
+	}
+
+	return b.Broker.Enqueue(ctx, msg, queue)
+}
+
+// Consume consumes from the wrapped broker, delaying, duplicating and redelivering messages.
+func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
+	in := make(chan []byte)
+	go b.Broker.Consume(ctx, in, queue)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-in:
+			if err := b.f.delay(ctx); err != nil {
+				return
+			}
+			if !deliver(ctx, work, msg) {
+				return
+			}
+			if b.f.chance(b.f.opt.DuplicateRate) && !deliver(ctx, work, msg) {
+				return
+			}
+			if b.f.chance(b.f.opt.DropAckRate) {
+				go func(msg []byte) {
+					select {
+					case <-ctx.Done():
+					case <-time.After(b.f.opt.RedeliverAfter):
+						deliver(ctx, work, msg)
+					}
+				}(msg)
+			}
+		}
+	}
+}
+
+// Queues injects faults into looking up the queues of the wrapped broker, if it implements
+// tasqueue.QueueLister.
+func (b *Broker) Queues(ctx context.Context, pattern string) ([]string, error) {
+	if err := b.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if l, ok := b.Broker.(tasqueue.QueueLister); ok {
+		return l.Queues(ctx, pattern)
+	}
+
+	return nil, tasqueue.ErrQueuesUnsupported
+}
+
+// GetPending injects faults into peeking at the wrapped broker, if it implements
+// tasqueue.Peeker.
+func (b *Broker) GetPending(ctx context.Context, queue string, n int) ([][]byte, error) {
+	if err := b.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if p, ok := b.Broker.(tasqueue.Peeker); ok {
+		return p.GetPending(ctx, queue, n)
+	}
+
+	return nil, tasqueue.ErrPendingUnsupported
+}
+
+// Ping injects faults into pinging the wrapped broker, if it implements tasqueue.Pinger.
+func (b *Broker) Ping(ctx context.Context) error {
+	if err := b.f.inject(ctx); err != nil {
+		return err
+	}
+	if p, ok := b.Broker.(tasqueue.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// Depth injects faults into looking up the depth of the queue on the wrapped broker, if it
+// implements tasqueue.Depther.
+func (b *Broker) Depth(ctx context.Context, queue string) (int64, error) {
+	if err := b.f.inject(ctx); err != nil {
+		return 0, err
+	}
+	if d, ok := b.Broker.(tasqueue.Depther); ok {
+		return d.Depth(ctx, queue)
+	}
+
+	return 0, tasqueue.ErrDepthUnsupported
+}
+
+// Trim injects faults into trimming the queue on the wrapped broker, if it implements
+// tasqueue.Trimmer.
+func (b *Broker) Trim(ctx context.Context, queue string, n int64) ([][]byte, error) {
+	if err := b.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if t, ok := b.Broker.(tasqueue.Trimmer); ok {
+		return t.Trim(ctx, queue, n)
+	}
+
+	return nil, tasqueue.ErrTrimUnsupported
+}
+
+// deliver sends the message to the work channel, and returns false if the context is done.
+func deliver(ctx context.Context, work chan []byte, msg []byte) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case work <- msg:
+		return true
+	}
+}
+
+// Results wraps a results store, injecting delays and errors into its reads and writes.
+type Results struct {
+	tasqueue.Results
+	f *faults
+}
+
+// NewResults() returns a results store which wraps r.
+func NewResults(r tasqueue.Results, o Options) *Results {
+	return &Results{Results: r, f: newFaults(o)}
+}
+
+// Ping injects faults into pinging the wrapped store, if it implements tasqueue.Pinger.
+func (r *Results) Ping(ctx context.Context) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if p, ok := r.Results.(tasqueue.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+// Watch injects faults into watching the key on the wrapped store. If the store doesn't
+// implement tasqueue.Watcher, it returns a nil channel.
+func (r *Results) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if w, ok := r.Results.(tasqueue.Watcher); ok {
+		return w.Watch(ctx, key)
+	}
+
+	return nil, nil
+}
+
+// IncrCounters injects faults into incrementing the counters on the wrapped store, if it
+// implements tasqueue.Counter.
+func (r *Results) IncrCounters(ctx context.Context, key string, fields map[string]int64, ttl time.Duration) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if c, ok := r.Results.(tasqueue.Counter); ok {
+		return c.IncrCounters(ctx, key, fields, ttl)
+	}
+
+	return tasqueue.ErrStatsUnsupported
+}
+
+// GetCounters injects faults into getting the counters from the wrapped store, if it
+// implements tasqueue.Counter.
+func (r *Results) GetCounters(ctx context.Context, key string) (map[string]int64, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if c, ok := r.Results.(tasqueue.Counter); ok {
+		return c.GetCounters(ctx, key)
+	}
+
+	return nil, tasqueue.ErrStatsUnsupported
+}
+
+// AddDelayed injects faults into adding the delayed job on the wrapped store, if it
+// implements tasqueue.Delayer.
+func (r *Results) AddDelayed(ctx context.Context, key, uuid string, at time.Time) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if d, ok := r.Results.(tasqueue.Delayer); ok {
+		return d.AddDelayed(ctx, key, uuid, at)
+	}
+
+	return tasqueue.ErrDelayUnsupported
+}
+
+// PopDue injects faults into popping the due jobs from the wrapped store, if it implements
+// tasqueue.Delayer.
+func (r *Results) PopDue(ctx context.Context, key string, t time.Time, n int) ([]string, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if d, ok := r.Results.(tasqueue.Delayer); ok {
+		return d.PopDue(ctx, key, t, n)
+	}
+
+	return nil, tasqueue.ErrDelayUnsupported
+}
+
+// Lease injects faults into adding the lease on the wrapped store, if it implements
+// tasqueue.Leaser.
+func (r *Results) Lease(ctx context.Context, key, member string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if l, ok := r.Results.(tasqueue.Leaser); ok {
+		return l.Lease(ctx, key, member)
+	}
+
+	return tasqueue.ErrLeaseUnsupported
+}
+
+// Unlease injects faults into removing the lease on the wrapped store, if it implements
+// tasqueue.Leaser.
+func (r *Results) Unlease(ctx context.Context, key, member string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if l, ok := r.Results.(tasqueue.Leaser); ok {
+		return l.Unlease(ctx, key, member)
+	}
+
+	return tasqueue.ErrLeaseUnsupported
+}
+
+// GetLeases injects faults into getting the leases from the wrapped store, if it implements
+// tasqueue.Leaser.
+func (r *Results) GetLeases(ctx context.Context, key string) ([]string, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if l, ok := r.Results.(tasqueue.Leaser); ok {
+		return l.GetLeases(ctx, key)
+	}
+
+	return nil, tasqueue.ErrLeaseUnsupported
+}
+
+func (r *Results) Get(ctx context.Context, uuid string) ([]byte, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return r.Results.Get(ctx, uuid)
+}
+
+func (r *Results) Set(ctx context.Context, uuid string, b []byte) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+
+	return r.Results.Set(ctx, uuid, b)
+}
+
+func (r *Results) SetFailed(ctx context.Context, uuid string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+
+	return r.Results.SetFailed(ctx, uuid)
+}
+
+// DeleteFailed injects faults into removing the job from the failed list of the wrapped
+// store, if it implements tasqueue.FailedDeleter.
+func (r *Results) DeleteFailed(ctx context.Context, uuid string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if d, ok := r.Results.(tasqueue.FailedDeleter); ok {
+		return d.DeleteFailed(ctx, uuid)
+	}
+
+	return nil
+}
+
+func (r *Results) SetSuccess(ctx context.Context, uuid string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+
+	return r.Results.SetSuccess(ctx, uuid)
+}
+
+// SetTag injects faults into tagging the job on the wrapped store, if it implements
+// tasqueue.Tagger.
+func (r *Results) SetTag(ctx context.Context, tag, uuid string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if t, ok := r.Results.(tasqueue.Tagger); ok {
+		return t.SetTag(ctx, tag, uuid)
+	}
+
+	return tasqueue.ErrTagsUnsupported
+}
+
+// GetTag injects faults into getting the jobs of the tag from the wrapped store, if it
+// implements tasqueue.Tagger.
+func (r *Results) GetTag(ctx context.Context, tag string) ([]string, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if t, ok := r.Results.(tasqueue.Tagger); ok {
+		return t.GetTag(ctx, tag)
+	}
+
+	return nil, tasqueue.ErrTagsUnsupported
+}
+
+// DeleteTag injects faults into untagging the job on the wrapped store, if it implements
+// tasqueue.Tagger.
+func (r *Results) DeleteTag(ctx context.Context, tag, uuid string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if t, ok := r.Results.(tasqueue.Tagger); ok {
+		return t.DeleteTag(ctx, tag, uuid)
+	}
+
+	return tasqueue.ErrTagsUnsupported
+}
+
+// IndexJob injects faults into indexing the job on the wrapped store, if it implements
+// tasqueue.Indexer.
+func (r *Results) IndexJob(ctx context.Context, uuid string, t time.Time, keys []string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if i, ok := r.Results.(tasqueue.Indexer); ok {
+		return i.IndexJob(ctx, uuid, t, keys)
+	}
+
+	return tasqueue.ErrIndexUnsupported
+}
+
+// UnindexJob injects faults into unindexing the job on the wrapped store, if it implements
+// tasqueue.Indexer.
+func (r *Results) UnindexJob(ctx context.Context, uuid string, keys []string) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if i, ok := r.Results.(tasqueue.Indexer); ok {
+		return i.UnindexJob(ctx, uuid, keys)
+	}
+
+	return tasqueue.ErrIndexUnsupported
+}
+
+// QueryJobs injects faults into querying the job indexes of the wrapped store, if it
+// implements tasqueue.Indexer.
+func (r *Results) QueryJobs(ctx context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if i, ok := r.Results.(tasqueue.Indexer); ok {
+		return i.QueryJobs(ctx, key, from, to, offset, limit, desc)
+	}
+
+	return nil, tasqueue.ErrIndexUnsupported
+}
+
+// AppendChunk injects faults into appending the chunk on the wrapped store, if it implements
+// tasqueue.Chunker.
+func (r *Results) AppendChunk(ctx context.Context, key string, b []byte) error {
+	if err := r.f.inject(ctx); err != nil {
+		return err
+	}
+	if c, ok := r.Results.(tasqueue.Chunker); ok {
+		return c.AppendChunk(ctx, key, b)
+	}
+
+	return tasqueue.ErrChunksUnsupported
+}
+
+// GetChunks injects faults into getting the chunks from the wrapped store, if it implements
+// tasqueue.Chunker.
+func (r *Results) GetChunks(ctx context.Context, key string, offset int) ([][]byte, error) {
+	if err := r.f.inject(ctx); err != nil {
+		return nil, err
+	}
+	if c, ok := r.Results.(tasqueue.Chunker); ok {
+		return c.GetChunks(ctx, key, offset)
+	}
+
+	return nil, tasqueue.ErrChunksUnsupported
+}
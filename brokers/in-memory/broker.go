@@ -3,35 +3,98 @@ package inmemory
 import (
 	"context"
 	"fmt"
+	"path"
 	"sync"
 )
 
+// queueSize is the number of messages a queue holds before Enqueue blocks.
+const queueSize = 100
+
 type Broker struct {
 	mu     sync.Mutex
-	queues map[string][][]byte
-	data   chan []byte
+	queues map[string]chan []byte
 }
 
 func New() *Broker {
 	return &Broker{
-		queues: make(map[string][][]byte),
-		data:   make(chan []byte, 100),
+		queues: make(map[string]chan []byte),
 	}
 }
 
 func (r *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
+	data := r.queue(queue)
 	for {
 		select {
 		case <-ctx.Done():
 			fmt.Println("stopping consumer")
 			return
-		case d := <-r.data:
+		case d := <-data:
 			work <- d
 		}
 	}
 }
 
 func (r *Broker) Enqueue(ctx context.Context, msg []byte, queue string) error {
-	r.data <- msg
+	r.queue(queue) <- msg
 	return nil
 }
+
+// queue returns the queue's channel, creating it if it doesn't exist.
+func (r *Broker) queue(queue string) chan []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	q, ok := r.queues[queue]
+	if !ok {
+		q = make(chan []byte, queueSize)
+		r.queues[queue] = q
+	}
+
+	return q
+}
+
+// Queues returns the queues which have been enqueued onto or consumed from, matching the pattern.
+func (r *Broker) Queues(_ context.Context, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	for q := range r.queues {
+		ok, err := path.Match(pattern, q)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, q)
+		}
+	}
+
+	return out, nil
+}
+
+// Depth returns the number of messages in the queue.
+func (r *Broker) Depth(_ context.Context, queue string) (int64, error) {
+	return int64(len(r.queue(queue))), nil
+}
+
+// Trim pops the oldest messages of the queue, until it has n messages.
+func (r *Broker) Trim(_ context.Context, queue string, n int64) ([][]byte, error) {
+	var (
+		q   = r.queue(queue)
+		out [][]byte
+	)
+	for int64(len(q)) > n {
+		select {
+		case m := <-q:
+			out = append(out, m)
+		default:
+			return out, nil
+		}
+	}
+
+	return out, nil
+}
@@ -2,8 +2,13 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
+	"strings"
 
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/natsconn"
 	"github.com/nats-io/nats.go"
 	"github.com/zerodha/logf"
 )
@@ -16,7 +21,10 @@ type Broker struct {
 }
 
 type Options struct {
-	URL         string
+	URL  string
+	Auth auth.Options
+
+	// Deprecated: use Auth. Username and Password are used if EnabledAuth is set.
 	EnabledAuth bool
 	Username    string
 	Password    string
@@ -27,13 +35,7 @@ type Options struct {
 
 // New() returns a new instance of nats-jetstream broker.
 func New(cfg Options, lo logf.Logger) (*Broker, error) {
-	opt := []nats.Option{}
-
-	if cfg.EnabledAuth {
-		opt = append(opt, nats.UserInfo(cfg.Username, cfg.Password))
-	}
-
-	conn, err := nats.Connect(cfg.URL, opt...)
+	conn, err := natsconn.Conn(connOptions(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to nats : %w", err)
 	}
@@ -95,6 +97,108 @@ func (b *Broker) Enqueue(_ context.Context, msg []byte, queue string) error {
 	return nil
 }
 
+// Queues returns the subjects of the configured streams matching the pattern.
+// Wildcard subjects are skipped as they don't name a single queue.
+func (b *Broker) Queues(_ context.Context, pattern string) ([]string, error) {
+	var out []string
+	for _, subjects := range b.opt.Streams {
+		for _, sub := range subjects {
+			if strings.ContainsAny(sub, "*>") {
+				continue
+			}
+			ok, err := path.Match(pattern, sub)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, sub)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// Depth returns the number of the queue's messages that are pending or unacknowledged on the
+// queue's durable consumer. Until the consumer is created, it returns the number of messages
+// in the queue's stream.
+func (b *Broker) Depth(_ context.Context, queue string) (int64, error) {
+	stream, ok := b.stream(queue)
+	if !ok {
+		return 0, fmt.Errorf("no stream configured for queue %s", queue)
+	}
+
+	c, err := b.conn.ConsumerInfo(stream, queue)
+	if err == nil {
+		return int64(c.NumPending) + int64(c.NumAckPending), nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return 0, err
+	}
+
+	info, err := b.conn.StreamInfo(stream)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(info.State.Msgs), nil
+}
+
+// GetPending returns upto n messages published to the queue after the ack floor of the
+// queue's durable consumer. Messages delivered but not yet acknowledged are included.
+func (b *Broker) GetPending(_ context.Context, queue string, n int) ([][]byte, error) {
+	stream, ok := b.stream(queue)
+	if !ok {
+		return nil, fmt.Errorf("no stream configured for queue %s", queue)
+	}
+
+	info, err := b.conn.StreamInfo(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	seq := info.State.FirstSeq
+	c, err := b.conn.ConsumerInfo(stream, queue)
+	switch {
+	case err == nil:
+		if c.AckFloor.Stream >= seq {
+			seq = c.AckFloor.Stream + 1
+		}
+	case !errors.Is(err, nats.ErrConsumerNotFound):
+		return nil, err
+	}
+
+	var out [][]byte
+	for ; seq <= info.State.LastSeq && len(out) < n; seq++ {
+		msg, err := b.conn.GetMsg(stream, seq)
+		if err != nil {
+			// Messages may have been deleted from the stream.
+			if errors.Is(err, nats.ErrMsgNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if msg.Subject == queue {
+			out = append(out, msg.Data)
+		}
+	}
+
+	return out, nil
+}
+
+// stream returns the name of the stream the queue subject is configured on.
+func (b *Broker) stream(queue string) (string, bool) {
+	for name, subjects := range b.opt.Streams {
+		for _, sub := range subjects {
+			if sub == queue {
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
 func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
 	_, err := b.conn.Subscribe(queue, func(msg *nats.Msg) {
 		work <- msg.Data
@@ -106,3 +210,25 @@ func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
 	<-ctx.Done()
 	b.log.Debug("shutting down consumer..")
 }
+
+// connOptions returns the connection options for the auth options. Backends with the same
+// options share a connection.
+func connOptions(cfg Options) natsconn.Options {
+	o := natsconn.Options{URL: cfg.URL, TLS: cfg.Auth.TLS}
+	switch {
+	case cfg.Auth.Token != "":
+		o.Token = cfg.Auth.Token
+	case cfg.Auth.Username != "":
+		o.Username, o.Password = cfg.Auth.Username, cfg.Auth.Password
+	case cfg.EnabledAuth:
+		o.Username, o.Password = cfg.Username, cfg.Password
+	}
+
+	return o
+}
+
+// Ping checks the connection to the nats server.
+func (b *Broker) Ping(_ context.Context) error {
+	_, err := b.conn.AccountInfo()
+	return err
+}
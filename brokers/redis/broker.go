@@ -4,19 +4,63 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/redisconn"
 	"github.com/zerodha/logf"
 )
 
 const (
 	DefaultPollPeriod = time.Second
+
+	// prioritySuffix suffixes the queue name to form the key of the sorted set holding the
+	// queue's prioritized messages.
+	prioritySuffix = ":priority"
+	// priorityBand spaces the scores of the priorities in the sorted set, above the times
+	// (in milliseconds) at which messages are enqueued.
+	priorityBand = 1e13
 )
 
+// trimScript trims the oldest messages off the head of the queue's list (as messages are
+// pushed onto the tail), so that ARGV[1] messages remain, and returns the trimmed messages.
+var trimScript = redis.NewScript(`
+local over = redis.call("LLEN", KEYS[1]) - tonumber(ARGV[1])
+if over <= 0 then
+	return {}
+end
+local out = redis.call("LRANGE", KEYS[1], 0, over - 1)
+redis.call("LTRIM", KEYS[1], over, -1)
+return out
+`)
+
+// trimPriorityScript trims the queue's sorted set of prioritized messages, so that ARGV[1]
+// messages remain, and returns the trimmed messages. The messages of the lowest priority are
+// trimmed first, the oldest of them first, ie: the lowest scores within the highest band of
+// ARGV[2] (the priorities' spacing of the scores).
+var trimPriorityScript = redis.NewScript(`
+local over = redis.call("ZCARD", KEYS[1]) - tonumber(ARGV[1])
+local band = tonumber(ARGV[2])
+local out = {}
+while over > 0 do
+	local last = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+	local min = math.floor(tonumber(last[2]) / band) * band
+	local ms = redis.call("ZRANGEBYSCORE", KEYS[1], string.format("%.0f", min), string.format("(%.0f", min + band), "LIMIT", 0, over)
+	for _, m in ipairs(ms) do
+		table.insert(out, m)
+		redis.call("ZREM", KEYS[1], m)
+	end
+	over = over - #ms
+end
+return out
+`)
+
 type Options struct {
 	Addrs        []string
-	Password     string
 	DB           int
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
@@ -24,6 +68,24 @@ type Options struct {
 	IdleTimeout  time.Duration
 	MinIdleConns int
 	PollPeriod   time.Duration
+
+	// PoolSize is the maximum number of connections (default: 10 per CPU), and PoolTimeout
+	// is the time to wait for a connection when all are busy (default: ReadTimeout + 1s).
+	PoolSize    int
+	PoolTimeout time.Duration
+	// MaxRetries is the number of times failed commands are retried (default: 3, -1 disables),
+	// with a backoff between MinRetryBackoff and MaxRetryBackoff.
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Client, if set, is used instead of connecting with the options. Backends with the
+	// same options (eg: a broker and results store on the same redis) share a client.
+	Client redis.UniversalClient
+
+	Auth auth.Options
+	// Deprecated: use Auth.Password.
+	Password string
 }
 
 type Broker struct {
@@ -37,24 +99,49 @@ func New(o Options, lo logf.Logger) *Broker {
 	if o.PollPeriod == 0 {
 		pollPeriod = DefaultPollPeriod
 	}
+	pass := o.Auth.RedisPassword()
+	if pass == "" {
+		pass = o.Password
+	}
+	conn := o.Client
+	if conn == nil {
+		conn = redisconn.Client(&redis.UniversalOptions{
+			Addrs:           o.Addrs,
+			DB:              o.DB,
+			Username:        o.Auth.Username,
+			Password:        pass,
+			TLSConfig:       o.Auth.TLS,
+			DialTimeout:     o.DialTimeout,
+			ReadTimeout:     o.ReadTimeout,
+			WriteTimeout:    o.WriteTimeout,
+			MinIdleConns:    o.MinIdleConns,
+			IdleTimeout:     o.IdleTimeout,
+			PoolSize:        o.PoolSize,
+			PoolTimeout:     o.PoolTimeout,
+			MaxRetries:      o.MaxRetries,
+			MinRetryBackoff: o.MinRetryBackoff,
+			MaxRetryBackoff: o.MaxRetryBackoff,
+		})
+	}
 	return &Broker{
-		log: lo,
-		conn: redis.NewUniversalClient(&redis.UniversalOptions{
-			Addrs:        o.Addrs,
-			DB:           o.DB,
-			Password:     o.Password,
-			DialTimeout:  o.DialTimeout,
-			ReadTimeout:  o.ReadTimeout,
-			WriteTimeout: o.WriteTimeout,
-			MinIdleConns: o.MinIdleConns,
-			IdleTimeout:  o.IdleTimeout,
-		}),
+		log:        lo,
+		conn:       conn,
 		pollPeriod: pollPeriod,
 	}
 }
 
+// Enqueue pushes the message onto the tail of the queue's list. Messages are popped from the
+// head, hence they're consumed in the order they were enqueued.
 func (b *Broker) Enqueue(ctx context.Context, msg []byte, queue string) error {
-	return b.conn.LPush(ctx, queue, msg).Err()
+	return b.conn.RPush(ctx, queue, msg).Err()
+}
+
+// EnqueuePriority adds the message to the queue's sorted set of prioritized messages, which
+// are consumed ahead of the queue's list. The score orders the messages by their priority
+// (highest first) and then by the time they were enqueued.
+func (b *Broker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	score := float64(math.MaxUint8-priority)*priorityBand + float64(time.Now().UnixMilli())
+	return b.conn.ZAdd(ctx, queue+prioritySuffix, &redis.Z{Score: score, Member: msg}).Err()
 }
 
 func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
@@ -65,6 +152,19 @@ func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
 			return
 		default:
 			b.log.Debug("receiving from consumer..")
+			// Prioritized messages are consumed first. They aren't waited on, hence one
+			// enqueued while the consumer is blocked on the list is consumed within the
+			// poll period.
+			zs, err := b.conn.ZPopMin(ctx, queue+prioritySuffix).Result()
+			if err != nil {
+				b.log.Error("error consuming from redis priority queue", "error", err)
+			} else if len(zs) > 0 {
+				if msg, ok := zs[0].Member.(string); ok {
+					work <- []byte(msg)
+				}
+				continue
+			}
+
 			res, err := b.conn.BLPop(ctx, b.pollPeriod, queue).Result()
 			if err != nil && err.Error() != "redis: nil" {
 				b.log.Error("error consuming from redis queue", "error", err)
@@ -82,6 +182,134 @@ func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
 	}
 }
 
+// Queues scans the redis lists, and the sorted sets of prioritized messages, of the queues
+// matching the pattern.
+func (b *Broker) Queues(ctx context.Context, pattern string) ([]string, error) {
+	// Validate the pattern, as redis silently ignores malformed patterns.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	lists, err := b.scan(ctx, pattern, "list")
+	if err != nil {
+		return nil, err
+	}
+	sets, err := b.scan(ctx, pattern+prioritySuffix, "zset")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  []string
+		seen = make(map[string]bool)
+	)
+	for i, k := range append(lists, sets...) {
+		if i >= len(lists) {
+			k = strings.TrimSuffix(k, prioritySuffix)
+		}
+		// Redis glob patterns are a superset of path.Match, filter for consistency across brokers.
+		if ok, _ := path.Match(pattern, k); ok && !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+
+	return out, nil
+}
+
+// scan returns the keys of the type matching the pattern.
+func (b *Broker) scan(ctx context.Context, pattern, typ string) ([]string, error) {
+	var (
+		out    []string
+		cursor uint64
+	)
+	for {
+		keys, next, err := b.conn.ScanType(ctx, cursor, pattern, 100, typ).Result()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, keys...)
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// GetPending returns upto n messages which are popped next by the consumers, the
+// prioritized messages followed by the ones from the head of the queue list.
+func (b *Broker) GetPending(ctx context.Context, queue string, n int) ([][]byte, error) {
+	ps, err := b.conn.ZRange(ctx, queue+prioritySuffix, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]byte, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, []byte(p))
+	}
+	if len(out) >= n {
+		return out, nil
+	}
+
+	rs, err := b.conn.LRange(ctx, queue, 0, int64(n-len(out)-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		out = append(out, []byte(r))
+	}
+
+	return out, nil
+}
+
+// Depth returns the length of the queue's list, including its prioritized messages.
+func (b *Broker) Depth(ctx context.Context, queue string) (int64, error) {
+	n, err := b.conn.LLen(ctx, queue).Result()
+	if err != nil {
+		return 0, err
+	}
+	p, err := b.conn.ZCard(ctx, queue+prioritySuffix).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return n + p, nil
+}
+
+// Trim trims the oldest messages of the queue, so that n messages remain. The prioritized
+// messages are kept ahead of the queue's list: the oldest messages are trimmed off the head of
+// the list first, and then the oldest messages of the lowest priority off the sorted set. Each
+// of the key's trims is atomic.
+func (b *Broker) Trim(ctx context.Context, queue string, n int64) ([][]byte, error) {
+	p, err := b.conn.ZCard(ctx, queue+prioritySuffix).Result()
+	if err != nil {
+		return nil, err
+	}
+	keep := n - p
+	if keep < 0 {
+		keep = 0
+	}
+	rs, err := trimScript.Run(ctx, b.conn, []string{queue}, keep).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	// The sorted set is only trimmed once there's no room left for the list.
+	ps, err := trimPriorityScript.Run(ctx, b.conn, []string{queue + prioritySuffix}, n-keep, priorityBand).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]byte, 0, len(rs)+len(ps))
+	for _, r := range append(rs, ps...) {
+		out = append(out, []byte(r))
+	}
+
+	return out, nil
+}
+
 func blpopResult(rs []string) (string, error) {
 	if len(rs) != 2 {
 		return "", fmt.Errorf("BLPop result should have exactly 2 strings. Got : %v", rs)
@@ -89,3 +317,8 @@ func blpopResult(rs []string) (string, error) {
 
 	return rs[1], nil
 }
+
+// Ping checks the connection to redis.
+func (b *Broker) Ping(ctx context.Context) error {
+	return b.conn.Ping(ctx).Err()
+}
@@ -0,0 +1,217 @@
+// Package tee wraps a primary and a shadow broker, enqueuing every job onto both, to validate
+// a broker migration under real load. Jobs are consumed from the primary broker, while a
+// separate server (with its own results store) processes the shadow's jobs. Compare() reports
+// on how the outcomes of the jobs on the two sides differ.
+package tee
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const defaultTrack = 1000
+
+type Options struct {
+	// Track is the number of the most recently enqueued jobs tracked for Compare().
+	// Defaults to 1000.
+	Track int
+
+	// OnShadowError, if set, is called with the errors enqueuing onto the shadow broker.
+	// They don't fail the enqueue, as the primary broker has the job.
+	OnShadowError func(queue string, err error)
+}
+
+// Broker enqueues onto both the primary and the shadow broker, and otherwise acts as
+// the primary broker.
+type Broker struct {
+	tasqueue.Broker
+	shadow tasqueue.Broker
+	opt    Options
+
+	mu       sync.Mutex
+	enqueued int
+	failed   int
+	// uuids is a ring of the UUIDs of the most recently enqueued jobs, from next.
+	uuids []string
+	next  int
+}
+
+// New() returns a broker which enqueues onto the primary and shadow brokers.
+func New(primary, shadow tasqueue.Broker, o Options) *Broker {
+	if o.Track <= 0 {
+		o.Track = defaultTrack
+	}
+
+	return &Broker{Broker: primary, shadow: shadow, opt: o}
+}
+
+// Enqueue enqueues the message onto the primary broker, and then onto the shadow broker.
+func (b *Broker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	if err := b.Broker.Enqueue(ctx, msg, queue); err != nil {
+		return err
+	}
+
+	b.tee(msg, queue, b.shadow.Enqueue(ctx, msg, queue))
+	return nil
+}
+
+// EnqueuePriority enqueues the message with the priority onto both the brokers, on those of
+// them that implement tasqueue.PriorityBroker.
+func (b *Broker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	if err := enqueuePriority(ctx, b.Broker, msg, queue, priority); err != nil {
+		return err
+	}
+
+	b.tee(msg, queue, enqueuePriority(ctx, b.shadow, msg, queue, priority))
+	return nil
+}
+
+// Ping pings both the brokers, if they implement tasqueue.Pinger.
+func (b *Broker) Ping(ctx context.Context) error {
+	for _, br := range []tasqueue.Broker{b.Broker, b.shadow} {
+		if p, ok := br.(tasqueue.Pinger); ok {
+			if err := p.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Depth returns the depth of the queue on the primary broker, if it implements tasqueue.Depther.
+func (b *Broker) Depth(ctx context.Context, queue string) (int64, error) {
+	if d, ok := b.Broker.(tasqueue.Depther); ok {
+		return d.Depth(ctx, queue)
+	}
+
+	return 0, tasqueue.ErrDepthUnsupported
+}
+
+// tee records the outcome of enqueuing the message onto the shadow broker.
+func (b *Broker) tee(msg []byte, queue string, err error) {
+	if err != nil && b.opt.OnShadowError != nil {
+		b.opt.OnShadowError(queue, err)
+	}
+
+	// Messages that aren't job messages (eg: signed envelopes) aren't tracked.
+	var m tasqueue.JobMessage
+	uerr := msgpack.Unmarshal(msg, &m)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.enqueued++
+	if err != nil {
+		b.failed++
+		return
+	}
+	if uerr != nil || m.UUID == "" {
+		return
+	}
+	if len(b.uuids) < b.opt.Track {
+		b.uuids = append(b.uuids, m.UUID)
+		return
+	}
+	b.uuids[b.next] = m.UUID
+	b.next = (b.next + 1) % len(b.uuids)
+}
+
+// tracked returns the UUIDs of the tracked jobs, oldest first.
+func (b *Broker) tracked() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]string, 0, len(b.uuids))
+	out = append(out, b.uuids[b.next:]...)
+	return append(out, b.uuids[:b.next]...)
+}
+
+// JobGetter looks up job messages, eg: a tasqueue.Server or tasqueue.Client.
+type JobGetter interface {
+	GetJob(ctx context.Context, uuid string) (tasqueue.JobMessage, error)
+}
+
+// Mismatch is a job whose final status differs between the primary and the shadow.
+type Mismatch struct {
+	UUID    string
+	Task    string
+	Primary string
+	Shadow  string
+}
+
+// Report compares the outcomes of the jobs enqueued onto the primary and shadow brokers.
+type Report struct {
+	// Enqueued is the number of jobs enqueued, and ShadowFailed the number of them that
+	// couldn't be enqueued onto the shadow broker.
+	Enqueued     int
+	ShadowFailed int
+
+	// Compared is the number of tracked jobs that reached a final status on both the sides,
+	// and Matched the number of them whose status is the same.
+	Compared int
+	Matched  int
+	// Pending is the number of tracked jobs that are yet to reach a final status on a side,
+	// and Missing the number of them that aren't found on a side (eg: as the shadow server
+	// is yet to process them).
+	Pending int
+	Missing int
+
+	Mismatches []Mismatch
+}
+
+// Compare() looks up the tracked jobs on the primary and shadow sides (eg: the servers
+// processing the primary and the shadow's jobs), and reports the jobs whose outcomes differ.
+func (b *Broker) Compare(ctx context.Context, primary, shadow JobGetter) (Report, error) {
+	b.mu.Lock()
+	r := Report{Enqueued: b.enqueued, ShadowFailed: b.failed}
+	b.mu.Unlock()
+
+	for _, uuid := range b.tracked() {
+		p, err := primary.GetJob(ctx, uuid)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Report{}, err
+			}
+			r.Missing++
+			continue
+		}
+		s, err := shadow.GetJob(ctx, uuid)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Report{}, err
+			}
+			r.Missing++
+			continue
+		}
+		if !tasqueue.IsFinal(p.Status) || !tasqueue.IsFinal(s.Status) {
+			r.Pending++
+			continue
+		}
+
+		r.Compared++
+		if p.Status == s.Status {
+			r.Matched++
+			continue
+		}
+		m := Mismatch{UUID: uuid, Primary: p.Status, Shadow: s.Status}
+		if p.Job != nil {
+			m.Task = p.Job.Task
+		}
+		r.Mismatches = append(r.Mismatches, m)
+	}
+
+	return r, nil
+}
+
+// enqueuePriority enqueues the message with the priority if the broker implements
+// tasqueue.PriorityBroker, or else in order.
+func enqueuePriority(ctx context.Context, b tasqueue.Broker, msg []byte, queue string, priority uint8) error {
+	if p, ok := b.(tasqueue.PriorityBroker); ok {
+		return p.EnqueuePriority(ctx, msg, queue, priority)
+	}
+	return b.Enqueue(ctx, msg, queue)
+}
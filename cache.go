@@ -0,0 +1,129 @@
+package tasqueue
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultCacheTTL = time.Second * 5
+
+// CacheOpts configures the in-process LRU cache of job messages and results read from
+// the results store. Writes made by the server update the cache, while writes made by other
+// servers are picked up after the TTL.
+type CacheOpts struct {
+	// Size is the maximum number of cached values. If it is zero, reads aren't cached.
+	Size int
+	// TTL is the duration for which a value is cached. Defaults to 5s.
+	TTL time.Duration
+}
+
+// lruCache is an LRU cache of values in the results store, with a TTL. Its methods are
+// no-ops on a nil cache, so that callers don't have to check whether caching is enabled.
+type lruCache struct {
+	size  int
+	ttl   time.Duration
+	clock Clock
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	val any
+	exp time.Time
+}
+
+func newLRUCache(o CacheOpts, c Clock) *lruCache {
+	if o.Size <= 0 {
+		return nil
+	}
+	if o.TTL == 0 {
+		o.TTL = defaultCacheTTL
+	}
+
+	return &lruCache{
+		size:  o.Size,
+		ttl:   o.TTL,
+		clock: c,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) ([]byte, bool) {
+	v, ok := c.load(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+func (c *lruCache) set(key string, val []byte) {
+	c.store(key, val)
+}
+
+// load returns the value cached at the key, if it hasn't expired.
+func (c *lruCache) load(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.clock.Now().After(e.exp) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+
+	return e.val, true
+}
+
+// store caches the value at the key, evicting the least recently used value if the cache
+// is full.
+func (c *lruCache) store(key string, val any) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exp := c.clock.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.val, e.exp = val, exp
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, val: val, exp: exp})
+	if c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,78 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+	)
+	cached, err := NewServer(ServerOpts{Broker: broker, Results: results, Cache: CacheOpts{Size: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewServer(ServerOpts{Broker: broker, Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuid, err := cached.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := other.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.statusFailed(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes by other servers are not seen until the cached value expires.
+	if msg, err = cached.GetJob(ctx, uuid); err != nil || msg.Status != StatusStarted {
+		t.Fatalf("expected cached status %s, got %s (%v)", StatusStarted, msg.Status, err)
+	}
+	if msg, err = cached.getJob(ctx, uuid, false); err != nil || msg.Status != StatusFailed {
+		t.Fatalf("expected fresh status %s, got %s (%v)", StatusFailed, msg.Status, err)
+	}
+	// The fresh read updates the cache.
+	if msg, err = cached.GetJob(ctx, uuid); err != nil || msg.Status != StatusFailed {
+		t.Fatalf("expected cached status %s, got %s (%v)", StatusFailed, msg.Status, err)
+	}
+
+	// Writes by the server update the cache.
+	if err := cached.statusDone(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = cached.GetJob(ctx, uuid); err != nil || msg.Status != StatusDone {
+		t.Fatalf("expected cached status %s, got %s (%v)", StatusDone, msg.Status, err)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(CacheOpts{Size: 2}, systemClock{})
+	c.set("a", []byte("1"))
+	c.set("b", []byte("2"))
+	c.get("a")
+	c.set("c", []byte("3"))
+
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expected the least recently used value to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.get(k); !ok {
+			t.Fatalf("expected %s to be cached", k)
+		}
+	}
+
+	// A nil cache is a no-op.
+	var nc *lruCache
+	nc.set("a", []byte("1"))
+	if _, ok := nc.get("a"); ok {
+		t.Fatal("expected nil cache to not cache values")
+	}
+}
@@ -0,0 +1,100 @@
+package tasqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CalendarWeekdays is the builtin calendar of the days from Monday to Friday.
+const CalendarWeekdays = "weekdays"
+
+// calendarHorizon is how far ahead the runs of a schedule are looked up for one that its
+// calendar allows, after which the schedule stops.
+const calendarHorizon = time.Hour * 24 * 366
+
+// Calendar decides the days on which schedules run, eg: only on business days, or not on
+// holidays. Scheduled jobs (JobOpts.Calendar), chains (Chain.Calendar) and groups
+// (Group.Calendar) refer to a calendar registered on the server (ServerOpts.Calendars).
+type Calendar interface {
+	// Runs returns true if the schedule's run at the time should run. Otherwise, the run is
+	// skipped.
+	Runs(t time.Time) bool
+}
+
+// CalendarFunc adapts a function to a Calendar.
+type CalendarFunc func(t time.Time) bool
+
+func (f CalendarFunc) Runs(t time.Time) bool {
+	return f(t)
+}
+
+// BusinessDays is a calendar of the days that aren't weekends or holidays.
+type BusinessDays struct {
+	// Weekend are the days of the week that aren't business days. Defaults to Saturday and
+	// Sunday.
+	Weekend []time.Weekday
+	// Holidays are the dates that aren't business days. Only their dates are compared, in the
+	// location of the run's time.
+	Holidays []time.Time
+}
+
+func (b BusinessDays) Runs(t time.Time) bool {
+	weekend := b.Weekend
+	if weekend == nil {
+		weekend = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	for _, d := range weekend {
+		if t.Weekday() == d {
+			return false
+		}
+	}
+
+	y, m, d := t.Date()
+	for _, h := range b.Holidays {
+		if hy, hm, hd := h.Date(); hy == y && hm == m && hd == d {
+			return false
+		}
+	}
+
+	return true
+}
+
+// builtinCalendars are the calendars available on every server, which ServerOpts.Calendars
+// can't override.
+var builtinCalendars = map[string]Calendar{
+	CalendarWeekdays: BusinessDays{},
+}
+
+// calendar returns the named calendar, or nil if the name is empty.
+func (s *Server) calendar(name string) (Calendar, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if c, ok := builtinCalendars[name]; ok {
+		return c, nil
+	}
+	if c, ok := s.calendars[name]; ok {
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("calendar %s not registered", name)
+}
+
+// nextRun returns the schedule's next run after the time, that the calendar (if any) allows.
+// It returns a zero time if there's none within the calendar horizon.
+func nextRun(sch cron.Schedule, cal Calendar, t time.Time) time.Time {
+	var (
+		next    = sch.Next(t)
+		horizon = t.Add(calendarHorizon)
+	)
+	for cal != nil && !next.IsZero() && !cal.Runs(next) {
+		if next.After(horizon) {
+			return time.Time{}
+		}
+		next = sch.Next(next)
+	}
+
+	return next
+}
@@ -0,0 +1,65 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestCalendar(t *testing.T) {
+	var (
+		ctx = context.Background()
+		// 2024-01-01 is a Monday.
+		day = func(d int) time.Time { return time.Date(2024, 1, d, 9, 0, 0, 0, time.UTC) }
+		cal = BusinessDays{Holidays: []time.Time{day(3)}}
+	)
+
+	// Runs on weekends and holidays are skipped.
+	sch, err := cron.ParseStandard("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		runs []time.Time
+		now  = day(1)
+	)
+	for i := 0; i < 4; i++ {
+		now = nextRun(sch, cal, now)
+		runs = append(runs, now)
+	}
+	for i, d := range []int{2, 4, 5, 8} {
+		if !runs[i].Equal(day(d)) {
+			t.Fatalf("expected run %d on %v, got %v", i, day(d), runs[i])
+		}
+	}
+
+	// A calendar that never runs stops the schedule.
+	if next := nextRun(sch, CalendarFunc(func(time.Time) bool { return false }), now); !next.IsZero() {
+		t.Fatalf("expected no runs, got %v", next)
+	}
+
+	// Calendars have to be registered, and jobs on a calendar have to be scheduled.
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), Calendars: map[string]Calendar{"india": cal}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		opts  JobOpts
+		valid bool
+	}{
+		{JobOpts{Schedule: "0 9 * * *", Calendar: "india"}, true},
+		{JobOpts{Schedule: "0 9 * * *", Calendar: CalendarWeekdays}, true},
+		{JobOpts{Schedule: "0 9 * * *", Calendar: "mars"}, false},
+		{JobOpts{Calendar: "india"}, false},
+	} {
+		job, err := NewJob(taskName, nil, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); (err == nil) != tc.valid {
+			t.Fatalf("expected the job on %q to be valid: %v, got %v", tc.opts.Calendar, tc.valid, err)
+		}
+	}
+}
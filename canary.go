@@ -0,0 +1,133 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// CanaryOpts registers a version of a task as a canary, which processes a sample of the
+// task's jobs, to validate the version against production traffic before rolling it out.
+type CanaryOpts struct {
+	// Sample is the fraction (0 to 1) of the task's unversioned jobs processed by the canary.
+	// Jobs are sampled by their UUID, so that all the attempts of a job are sampled, or none
+	// of them. If it is zero, the version isn't a canary.
+	Sample float64
+
+	// Shadow runs the canary on the sampled jobs after the task's handler has processed them,
+	// with the canary's results discarded, instead of processing the jobs in its place.
+	Shadow bool
+}
+
+// canary returns the task's canary version, if the job is an unversioned job sampled for
+// it. The job is processed by the canary in place of the task unless the canary is a
+// shadow, in which case it's returned as the shadow.
+func (s *Server) canary(msg JobMessage, task Task) (Task, *Task) {
+	if msg.Version != "" || task.opts.Canary.Sample > 0 {
+		return task, nil
+	}
+
+	s.p.RLock()
+	var (
+		canary Task
+		found  bool
+	)
+	for _, t := range s.tasks {
+		if t.name == task.name && t.opts.Canary.Sample > 0 && (!found || versionLess(canary.opts.Version, t.opts.Version)) {
+			canary, found = t, true
+		}
+	}
+	s.p.RUnlock()
+
+	if !found {
+		return task, nil
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte("canary:" + msg.UUID))
+	if float64(h.Sum64())/math.MaxUint64 >= canary.opts.Canary.Sample {
+		return task, nil
+	}
+	if canary.opts.Canary.Shadow {
+		return task, &canary
+	}
+
+	return canary, nil
+}
+
+// shadowJob runs the handler of the shadow canary on the job, with the results it saves
+// discarded. The job's status isn't updated, and it isn't retried.
+func (s *Server) shadowJob(ctx context.Context, msg JobMessage, canary Task) {
+	if msg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, msg.Timeout)
+		defer cancel()
+	}
+
+	dir, cleanup, err := s.sandbox(canary, msg.UUID)
+	defer cleanup()
+
+	var store Results
+	if s.results != nil {
+		store = shadowResults{Results: s.results}
+	}
+	taskCtx := JobCtx{Context: ctx, Meta: msg.Meta, job: *msg.Job, store: store, handlerCache: s.handlerCache, deps: s.deps, codec: s.codec, dir: dir, state: canary.state, next: &continuations{}}
+
+	var payload []byte
+	if err == nil {
+		payload, err = s.jobPayload(ctx, msg, canary, &taskCtx)
+	}
+	if err == nil {
+		err = runHandler(canary, payload, taskCtx)
+	}
+	if err != nil {
+		s.log.Error("shadow canary failed", "uuid", msg.UUID, "task", canary.name, "version", canary.opts.Version, "error", err)
+	}
+	s.countCanary(canary, err)
+}
+
+// countCanary counts the jobs processed by the canary, by their outcome.
+func (s *Server) countCanary(task Task, err error) {
+	if task.opts.Canary.Sample <= 0 {
+		return
+	}
+
+	status := StatusDone
+	if err != nil {
+		status = StatusFailed
+	}
+	s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",version="%s",status="%s"}`, metricCanaryJobs, task.name, task.opts.Version, status)).Inc()
+}
+
+// shadowResults reads from the results store, and discards the writes of the handlers of
+// shadow canaries.
+type shadowResults struct {
+	Results
+}
+
+func (shadowResults) Set(context.Context, string, []byte) error          { return nil }
+func (shadowResults) SetFailed(context.Context, string) error            { return nil }
+func (shadowResults) SetSuccess(context.Context, string) error           { return nil }
+func (shadowResults) DeleteFailed(context.Context, string) error         { return nil }
+func (shadowResults) SetTag(context.Context, string, string) error       { return nil }
+func (shadowResults) DeleteTag(context.Context, string, string) error    { return nil }
+func (shadowResults) Delete(context.Context, string) error               { return nil }
+func (shadowResults) AppendChunk(context.Context, string, []byte) error  { return nil }
+func (shadowResults) UnindexJob(context.Context, string, []string) error { return nil }
+func (shadowResults) IndexJob(context.Context, string, time.Time, []string) error {
+	return nil
+}
+
+func (r shadowResults) GetTag(ctx context.Context, tag string) ([]string, error) {
+	return getTag(ctx, r.Results, tag)
+}
+
+func (r shadowResults) QueryJobs(ctx context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	return queryJobs(ctx, r.Results, key, from, to, offset, limit, desc)
+}
+
+func (r shadowResults) GetChunks(ctx context.Context, key string, offset int) ([][]byte, error) {
+	return getChunks(ctx, r.Results, key, offset)
+}
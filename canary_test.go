@@ -0,0 +1,103 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCanary(t *testing.T) {
+	for _, shadow := range []bool{false, true} {
+		var (
+			ctx     = context.Background()
+			broker  = NewMockBroker()
+			results = NewMockResults()
+			ran     []string
+		)
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: results})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []string{"v1", "v2"} {
+			v := v
+			opts := TaskOpts{Version: v}
+			if v == "v2" {
+				opts.Canary = CanaryOpts{Sample: 1, Shadow: shadow}
+			}
+			srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+				ran = append(ran, v)
+				return c.Save([]byte(v))
+			}, opts)
+		}
+
+		job, err := NewJob(taskName, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+
+		res, err := srv.GetResult(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shadow {
+			// The shadow canary runs after the task's handler, and its results are discarded.
+			if strings.Join(ran, ",") != "v1,v2" {
+				t.Fatalf("expected the job to be shadowed by the canary, got %v", ran)
+			}
+			if len(res) != 1 || string(res[0]) != "v1" {
+				t.Fatalf("expected the canary's results to be discarded, got %q", res)
+			}
+		} else {
+			// The sampled job is processed by the canary in place of the latest version.
+			if strings.Join(ran, ",") != "v2" {
+				t.Fatalf("expected the job to be processed by the canary, got %v", ran)
+			}
+			if len(res) != 1 || string(res[0]) != "v2" {
+				t.Fatalf("expected the canary's results, got %q", res)
+			}
+		}
+
+		var b bytes.Buffer
+		srv.WriteMetrics(&b)
+		if !strings.Contains(b.String(), `tasqueue_canary_jobs_total{task="mock_handler",version="v2",status="successful"} 1`) {
+			t.Fatalf("expected the canary's job to be counted, got %s", b.String())
+		}
+	}
+}
+
+func TestCanarySample(t *testing.T) {
+	srv := newServer(t)
+	srv.RegisterTask("sampled", func(b []byte, c JobCtx) error { return nil }, TaskOpts{})
+	srv.RegisterTask("sampled", func(b []byte, c JobCtx) error { return nil }, TaskOpts{Version: "v2", Canary: CanaryOpts{Sample: 0.2}})
+
+	task, err := srv.getHandler("sampled", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.opts.Version != "" {
+		t.Fatalf("expected unversioned jobs to not fall back to the canary, got %s", task.opts.Version)
+	}
+
+	var n int
+	for i := 0; i < 1000; i++ {
+		msg := JobMessage{Meta: Meta{UUID: srv.ids.NewID(srv.clock.Now())}, Job: &Job{Task: "sampled"}}
+		if c, _ := srv.canary(msg, task); c.opts.Version == "v2" {
+			n++
+		}
+	}
+	if n < 150 || n > 250 {
+		t.Fatalf("expected about a fifth of the jobs to be sampled, got %d of 1000", n)
+	}
+
+	// Jobs of a version aren't sampled.
+	msg := JobMessage{Meta: Meta{UUID: "uuid", Version: "v1"}, Job: &Job{Task: "sampled"}}
+	if c, shadow := srv.canary(msg, task); c.opts.Version != "" || shadow != nil {
+		t.Fatal("expected a job of a version to not be sampled")
+	}
+}
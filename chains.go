@@ -3,11 +3,14 @@ package tasqueue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-
-	"github.com/google/uuid"
+	"time"
 )
 
+// ErrChainNotResumable is returned on resuming a chain that hasn't failed.
+var ErrChainNotResumable = errors.New("chain can not be resumed")
+
 // ChainMeta contains fields related to a chain job.
 type ChainMeta struct {
 	UUID string
@@ -17,6 +20,8 @@ type ChainMeta struct {
 	JobUUID string
 	// List of UUIDs of completed jobs
 	PrevJobs []string
+	// FailedStep is the position (from 0) of the step the chain failed on, if it failed.
+	FailedStep int
 }
 
 // ChainMessage is a wrapper over Chain, containing meta info such as status, uuid.
@@ -28,6 +33,16 @@ type ChainMessage struct {
 
 type Chain struct {
 	Jobs []Job
+	// Deadline, if set, is the time by which the chain has to complete. Steps picked up
+	// after it are expired instead of being run, and the chain is marked as expired. A step
+	// that is running at the deadline isn't interrupted.
+	Deadline time.Time
+	// Reduce, if set, is the name of the reducer which combines the results of the chain's
+	// steps into the chain's result, returned by GetResult() with the chain's UUID.
+	Reduce string
+	// Calendar, if set, is the name of the calendar (ServerOpts.Calendars) on whose days the
+	// chain runs, if it's scheduled with ScheduleChain().
+	Calendar string
 }
 
 // NewChain() accepts a list of Tasks and creates a chain by setting the
@@ -48,20 +63,39 @@ func NewChain(j ...Job) (Chain, error) {
 }
 
 // message() converts a group into a group message, ready to be enqueued/stored.
-func (c *Chain) message() ChainMessage {
+func (c *Chain) message(uuid string) ChainMessage {
 	return ChainMessage{
 		ChainMeta: ChainMeta{
-			UUID:   uuid.NewString(),
+			UUID:   uuid,
 			Status: StatusProcessing,
 		},
 		Chain: c,
 	}
 }
 
+// EnqueueChain() validates all the jobs in the chain and enqueues the first job.
+// The subsequent jobs are enqueued as each job in the chain succeeds.
 func (s *Server) EnqueueChain(ctx context.Context, c Chain) (string, error) {
-	msg := c.message()
-	root := c.Jobs[0]
-	jobUUID, err := s.Enqueue(ctx, root)
+	// The chain's state is tracked on the results store.
+	if s.results == nil {
+		return "", ErrNoResults
+	}
+
+	for _, j := range c.Jobs {
+		if err := s.validateJob(j); err != nil {
+			return "", fmt.Errorf("could not enqueue chain : %w", err)
+		}
+	}
+	if err := s.validateReducer(c.Reduce); err != nil {
+		return "", fmt.Errorf("could not enqueue chain : %w", err)
+	}
+
+	msg := c.message(s.ids.NewID(s.clock.Now()))
+	root, err := s.prepareJob(c.Jobs[0])
+	if err != nil {
+		return "", err
+	}
+	jobUUID, err := s.enqueueWithMeta(ctx, root, chainStepMeta(DefaultMeta(root.Opts), msg.UUID, c.Deadline))
 	if err != nil {
 		return "", err
 	}
@@ -80,7 +114,7 @@ func (s *Server) GetChain(ctx context.Context, uuid string) (ChainMessage, error
 		return ChainMessage{}, err
 	}
 
-	if c.Status == StatusDone || c.Status == StatusFailed {
+	if c.Status == StatusDone || c.Status == StatusFailed || c.Status == StatusExpired {
 		return c, nil
 	}
 
@@ -91,15 +125,22 @@ func (s *Server) GetChain(ctx context.Context, uuid string) (ChainMessage, error
 	}
 
 checkJobs:
+	// Track the current job, so that the walk resumes from it on the next lookup.
+	c.JobUUID = currJob.UUID
 	switch currJob.Status {
 	//If the current job failed, add it to previous jobs list
 	// Set the chain status to failed
 	case StatusFailed:
 		c.PrevJobs = append(c.PrevJobs, currJob.UUID)
 		c.Status = StatusFailed
+		c.FailedStep = len(c.PrevJobs) - 1
+	// If the current job expired past the chain's deadline, the chain is expired.
+	case StatusExpired:
+		c.PrevJobs = append(c.PrevJobs, currJob.UUID)
+		c.Status = StatusExpired
 	// If the current job status is an intermediatery status
 	// Set the chain status as processing.
-	case StatusStarted, StatusProcessing, StatusRetrying:
+	case StatusStarted, StatusProcessing, StatusRetrying, StatusHeld:
 		c.Status = StatusProcessing
 	// If the current job status is done, check the next job id.
 	// If there is no next job id, the chain is complete, set overall status
@@ -117,13 +158,117 @@ checkJobs:
 		}
 	}
 
+	// Expire the chain if it's still running past its deadline, cancelling its current step.
+	if c.Status == StatusProcessing && c.Chain != nil && !c.Chain.Deadline.IsZero() && s.clock.Now().After(c.Chain.Deadline) {
+		if err := s.cancelJob(ctx, currJob); err != nil && !errors.Is(err, ErrJobNotCancellable) {
+			return ChainMessage{}, err
+		}
+		c.Status = StatusExpired
+	}
+
 	if err = s.setChainMessage(ctx, c); err != nil {
 		return ChainMessage{}, nil
 	}
 
+	if c.Status == StatusExpired {
+		s.chainExpired(c)
+	}
+
 	return c, nil
 }
 
+// ResumeChain() re-runs a failed chain from the step it failed on, with the step's original
+// payload, instead of restarting the chain. The step gets the results of the previous step,
+// and the chain continues with the subsequent steps as they succeed.
+func (s *Server) ResumeChain(ctx context.Context, uuid string) error {
+	return s.resumeChain(ctx, uuid, nil)
+}
+
+// ResumeChainWithPayload() re-runs a failed chain from the step it failed on, like
+// ResumeChain(), with the payload overriding the step's original payload.
+func (s *Server) ResumeChainWithPayload(ctx context.Context, uuid string, payload []byte) error {
+	return s.resumeChain(ctx, uuid, &payload)
+}
+
+func (s *Server) resumeChain(ctx context.Context, uuid string, payload *[]byte) error {
+	c, err := s.GetChain(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if c.Status != StatusFailed {
+		return fmt.Errorf("could not resume chain with status %s : %w", c.Status, ErrChainNotResumable)
+	}
+
+	failed, err := s.getJob(ctx, c.PrevJobs[c.FailedStep], false)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, OpResume, failed); err != nil {
+		return err
+	}
+
+	// The failed step's job carries the subsequent steps of the chain.
+	j := *failed.Job
+	if payload != nil {
+		j.Payload = *payload
+	} else if j.Payload, err = s.decodePayload(ctx, failed); err != nil {
+		return err
+	}
+	nj, err := s.prepareJob(j)
+	if err != nil {
+		return fmt.Errorf("could not resume chain : %w", err)
+	}
+	meta := chainStepMeta(DefaultMeta(nj.Opts), failed.ChainUUID, failed.ChainDeadline)
+	meta.PrevJobResults = failed.PrevJobResults
+	meta.TraceLink = failed.TraceLink
+	jobUUID, err := s.enqueueWithMeta(ctx, nj, meta)
+	if err != nil {
+		return err
+	}
+
+	// The resumed step replaces the failed one in the chain.
+	c.PrevJobs = c.PrevJobs[:c.FailedStep]
+	c.JobUUID = jobUUID
+	c.Status = StatusProcessing
+	c.FailedStep = 0
+	if err := s.setChainMessage(ctx, c); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, OpResume, uuid, jobUUID)
+
+	return nil
+}
+
+// OnChainExpired registers a function that is called with the chains which expire past their
+// deadline, eg: to alert on a missed SLA. Functions are called synchronously.
+func (s *Server) OnChainExpired(fn func(ChainMessage)) {
+	s.lmu.Lock()
+	s.expiredCBs = append(s.expiredCBs, fn)
+	s.lmu.Unlock()
+}
+
+// chainExpired calls the chain expiry callbacks with the chain.
+func (s *Server) chainExpired(c ChainMessage) {
+	s.lmu.RLock()
+	cbs := s.expiredCBs
+	s.lmu.RUnlock()
+
+	for _, fn := range cbs {
+		fn(c)
+	}
+}
+
+// chainStepMeta links the meta of a step to its chain, and expires the step at the
+// chain's deadline, unless it expires earlier.
+func chainStepMeta(meta Meta, uuid string, deadline time.Time) Meta {
+	meta.ChainUUID = uuid
+	meta.ChainDeadline = deadline
+	if !deadline.IsZero() && (meta.ExpiresAt.IsZero() || deadline.Before(meta.ExpiresAt)) {
+		meta.ExpiresAt = deadline
+	}
+	return meta
+}
+
 func (s *Server) setChainMessage(ctx context.Context, c ChainMessage) error {
 	b, err := json.Marshal(c)
 	if err != nil {
@@ -133,6 +278,10 @@ func (s *Server) setChainMessage(ctx context.Context, c ChainMessage) error {
 }
 
 func (s *Server) getChainMessage(ctx context.Context, uuid string) (ChainMessage, error) {
+	if s.results == nil {
+		return ChainMessage{}, ErrNoResults
+	}
+
 	b, err := s.results.Get(ctx, uuid)
 	if err != nil {
 		return ChainMessage{}, err
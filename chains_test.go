@@ -2,6 +2,8 @@ package tasqueue
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,3 +65,157 @@ func makeChain(t *testing.T, fs ...bool) Chain {
 
 	return chn
 }
+
+func TestResumeChain(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		ran    []string
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range []string{"extract", "transform", "load"} {
+		task := task
+		srv.RegisterTask(task, func(b []byte, c JobCtx) error {
+			ran = append(ran, task+":"+string(b))
+			if string(b) == "bad" {
+				return errors.New("failed")
+			}
+			return nil
+		}, TaskOpts{})
+	}
+
+	var jobs []Job
+	for _, task := range []string{"extract", "transform", "load"} {
+		p := "ok"
+		if task == "transform" {
+			p = "bad"
+		}
+		j, err := NewJob(task, []byte(p), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, j)
+	}
+	chn, err := NewChain(jobs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.EnqueueChain(ctx, chn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.ResumeChain(ctx, uuid); !errors.Is(err, ErrChainNotResumable) {
+		t.Fatalf("expected a running chain not to be resumable, got %v", err)
+	}
+
+	// The chain fails on its second step.
+	for i := 0; i < 2; i++ {
+		srv.Process(ctx, <-broker.data)
+	}
+	msg, err := srv.GetChain(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusFailed || msg.FailedStep != 1 {
+		t.Fatalf("expected the chain to fail on its second step, got %+v", msg.ChainMeta)
+	}
+
+	// The resumed chain re-runs the failed step with the overridden payload, and continues.
+	if err := srv.ResumeChainWithPayload(ctx, uuid, []byte("fixed")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		srv.Process(ctx, <-broker.data)
+	}
+	if msg, err = srv.GetChain(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone || len(msg.PrevJobs) != 3 {
+		t.Fatalf("expected the resumed chain to complete, got %+v", msg.ChainMeta)
+	}
+	want := []string{"extract:ok", "transform:bad", "transform:fixed", "load:ok"}
+	if strings.Join(ran, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the steps %v to run, got %v", want, ran)
+	}
+}
+
+func TestChainDeadline(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		clock   = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker  = NewMockBroker()
+		ran     []string
+		expired []string
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.OnChainExpired(func(c ChainMessage) {
+		expired = append(expired, c.UUID)
+	})
+	for _, task := range []string{"extract", "transform", "load"} {
+		task := task
+		srv.RegisterTask(task, func(b []byte, c JobCtx) error {
+			ran = append(ran, task)
+			return nil
+		}, TaskOpts{})
+	}
+
+	enqueue := func() string {
+		var jobs []Job
+		for _, task := range []string{"extract", "transform", "load"} {
+			j, err := NewJob(task, nil, JobOpts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobs = append(jobs, j)
+		}
+		chn, err := NewChain(jobs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chn.Deadline = clock.Now().Add(time.Hour)
+		uuid, err := srv.EnqueueChain(ctx, chn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuid
+	}
+
+	// A step picked up past the deadline is expired, and so is the chain.
+	uuid := enqueue()
+	srv.Process(ctx, <-broker.data)
+	clock.advance(time.Hour * 2)
+	srv.Process(ctx, <-broker.data)
+	if strings.Join(ran, ",") != "extract" {
+		t.Fatalf("expected the steps past the deadline not to run, got %v", ran)
+	}
+	msg, err := srv.GetChain(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusExpired || len(expired) != 1 || expired[0] != uuid {
+		t.Fatalf("expected the chain to expire once, got %s %v", msg.Status, expired)
+	}
+
+	// A chain whose step is queued past the deadline is expired on lookup, cancelling the step.
+	uuid = enqueue()
+	clock.advance(time.Hour * 2)
+	if msg, err = srv.GetChain(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusExpired || len(expired) != 2 {
+		t.Fatalf("expected the chain to expire, got %s %v", msg.Status, expired)
+	}
+	job, err := srv.GetJob(ctx, msg.JobUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusCancelled {
+		t.Fatalf("expected the queued step to be cancelled, got %s", job.Status)
+	}
+}
@@ -0,0 +1,144 @@
+package tasqueue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/kalbhor/tasqueue/brokers/chaos"
+	bi "github.com/kalbhor/tasqueue/brokers/in-memory"
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+// consumeChaos consumes the queue from the broker, and returns the messages received within
+// the duration.
+func consumeChaos(b tasqueue.Broker, queue string, d time.Duration) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	work := make(chan []byte)
+	go b.Consume(ctx, work, queue)
+
+	var (
+		msgs  []string
+		until = time.After(d)
+	)
+	for {
+		select {
+		case msg := <-work:
+			msgs = append(msgs, string(msg))
+		case <-until:
+			return msgs
+		}
+	}
+}
+
+func TestChaosDuplicate(t *testing.T) {
+	b := chaos.NewBroker(bi.New(), chaos.Options{DuplicateRate: 1})
+	if err := b.Enqueue(context.Background(), []byte("1"), "chaos"); err != nil {
+		t.Fatal(err)
+	}
+
+	if msgs := consumeChaos(b, "chaos", time.Millisecond*100); len(msgs) != 2 || msgs[0] != "1" || msgs[1] != "1" {
+		t.Fatalf("expected the message to be delivered twice, got %v", msgs)
+	}
+}
+
+func TestChaosDropAck(t *testing.T) {
+	b := chaos.NewBroker(bi.New(), chaos.Options{DropAckRate: 1, RedeliverAfter: time.Millisecond * 50})
+	if err := b.Enqueue(context.Background(), []byte("1"), "chaos"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message is redelivered once, after RedeliverAfter.
+	if msgs := consumeChaos(b, "chaos", time.Millisecond*200); len(msgs) != 2 || msgs[0] != "1" || msgs[1] != "1" {
+		t.Fatalf("expected the message to be redelivered, got %v", msgs)
+	}
+}
+
+func TestChaosErrorRate(t *testing.T) {
+	var (
+		ctx = context.Background()
+		b   = chaos.NewBroker(bi.New(), chaos.Options{ErrorRate: 1})
+		r   = b.Results(rr.New())
+	)
+	if err := b.Enqueue(ctx, []byte("1"), "chaos"); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected %v enqueuing, got %v", chaos.ErrInjected, err)
+	}
+	if err := b.EnqueuePriority(ctx, []byte("1"), "chaos", 1); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected %v enqueuing with a priority, got %v", chaos.ErrInjected, err)
+	}
+	if err := r.Set(ctx, "1", []byte("1")); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected %v setting, got %v", chaos.ErrInjected, err)
+	}
+	if err := r.Lease(ctx, "workers", "1"); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("expected %v leasing, got %v", chaos.ErrInjected, err)
+	}
+
+	// No operation fails without an error rate.
+	b = chaos.NewBroker(bi.New(), chaos.Options{})
+	if err := b.Enqueue(ctx, []byte("1"), "chaos"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Results(rr.New()).Set(ctx, "1", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// plainStore is a results store which implements none of the optional interfaces.
+type plainStore struct {
+	tasqueue.Results
+}
+
+func TestChaosForwarding(t *testing.T) {
+	var (
+		ctx = context.Background()
+		b   = chaos.NewBroker(bi.New(), chaos.Options{})
+		r   = b.Results(rr.New())
+	)
+
+	// The message enqueued with a priority is enqueued in order on a broker without priorities.
+	if err := b.EnqueuePriority(ctx, []byte("1"), "chaos", 1); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := consumeChaos(b, "chaos", time.Millisecond*50); len(msgs) != 1 {
+		t.Fatalf("expected the message to be enqueued, got %v", msgs)
+	}
+
+	// The optional interfaces are forwarded to the wrapped store.
+	if err := r.IncrCounters(ctx, "stats", map[string]int64{"done": 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := r.GetCounters(ctx, "stats"); err != nil || c["done"] != 1 {
+		t.Fatalf("expected the counters to be forwarded, got %v, %v", c, err)
+	}
+	if err := r.AddDelayed(ctx, "delayed", "1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := r.PopDue(ctx, "delayed", time.Now(), 10); err != nil || len(due) != 1 {
+		t.Fatalf("expected the delayed jobs to be forwarded, got %v, %v", due, err)
+	}
+	if err := r.Lease(ctx, "workers", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := r.GetLeases(ctx, "workers"); err != nil || len(l) != 1 {
+		t.Fatalf("expected the leases to be forwarded, got %v, %v", l, err)
+	}
+	if err := r.Unlease(ctx, "workers", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// They aren't supported if the wrapped store doesn't implement them.
+	r = b.Results(plainStore{rr.New()})
+	if err := r.IncrCounters(ctx, "stats", nil, 0); !errors.Is(err, tasqueue.ErrStatsUnsupported) {
+		t.Fatalf("expected %v, got %v", tasqueue.ErrStatsUnsupported, err)
+	}
+	if _, err := r.PopDue(ctx, "delayed", time.Now(), 10); !errors.Is(err, tasqueue.ErrDelayUnsupported) {
+		t.Fatalf("expected %v, got %v", tasqueue.ErrDelayUnsupported, err)
+	}
+	if _, err := r.GetLeases(ctx, "workers"); !errors.Is(err, tasqueue.ErrLeaseUnsupported) {
+		t.Fatalf("expected %v, got %v", tasqueue.ErrLeaseUnsupported, err)
+	}
+}
@@ -0,0 +1,268 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zerodha/logf"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Client enqueues and inspects jobs without registering handlers or running workers.
+// It is meant for producers that only push jobs onto the broker.
+type Client struct {
+	srv *Server
+
+	defaultMaxDepth int64
+	queueMaxDepths  map[string]int64
+}
+
+// ClientOpts holds the options to configure a client.
+type ClientOpts struct {
+	Broker        Broker
+	Results       Results
+	Logger        logf.Logger
+	TraceProvider *trace.TracerProvider
+
+	// MaxPayloadSize is the maximum size (in bytes) of a job's payload. Larger payloads are
+	// handled at enqueue according to the PayloadPolicy. If it is zero, payloads are not size checked.
+	MaxPayloadSize int
+	PayloadPolicy  PayloadPolicy
+	BlobStore      BlobStore
+
+	// TenantQueues enqueues jobs with a tenant onto the tenant's namespaced queue.
+	TenantQueues bool
+
+	// ResultCodec decodes the named results saved by jobs. Defaults to JSON.
+	ResultCodec Codec
+
+	// Propagator carries values from the context of Enqueue into the handler's context.
+	Propagator Propagator
+
+	// Namespace prefixes the queue names and results keys. It should match the servers'.
+	Namespace string
+
+	// Cache caches the job messages and results read from the results store.
+	Cache CacheOpts
+
+	// Signer signs the messages enqueued onto the broker. It should match the servers' verifier.
+	Signer Signer
+
+	// Cipher encrypts the messages enqueued onto the broker. It should match the servers' cipher.
+	Cipher Cipher
+
+	// IDGenerator generates the IDs of the enqueued jobs, groups and chains. Defaults to UUIDs.
+	IDGenerator IDGenerator
+
+	// MaxDepth is the maximum number of jobs waiting in a queue, and QueueMaxDepths is a map
+	// of queue -> maximum overriding it, enforced by EnqueueWithBackpressure(). The broker
+	// has to implement Depther. If it is zero, queues aren't capped.
+	MaxDepth       int64
+	QueueMaxDepths map[string]int64
+
+	// ResultSchemas is a map of task -> the version of the shape of the task's results, and
+	// the migrations which GetResult() upgrades older results with. It should match the servers'.
+	ResultSchemas map[string]ResultSchema
+
+	// Stats records the jobs enqueued by the client onto the results store, for Stats().
+	Stats bool
+
+	// Reducers is a map of name -> custom reducer of the results of chains and groups, which
+	// GetResult() combines their jobs' results with. It should match the servers'.
+	Reducers map[string]Reducer
+
+	// Authorizer, if set, authorizes the client's admin operations on jobs (cancel, retry)
+	// with the caller set on the operation's context (WithCaller()).
+	Authorizer Authorizer
+
+	// AuditSink, if set, records the client's admin actions (cancelling and retrying jobs).
+	// AuditLog records them onto the results store instead (see GetAuditLog()).
+	AuditSink AuditSink
+	AuditLog  bool
+
+	// Locker provides the locks of the jobs' statuses, which Cancel() holds while cancelling a
+	// job, and the dedup windows. It should be shared with the servers (eg: locks/redis), so that
+	// a job is either cancelled or started by a worker. Defaults to a locker local to the client,
+	// with which jobs can't be deduplicated.
+	Locker Locker
+}
+
+// NewClient() returns a new instance of client.
+func NewClient(o ClientOpts) (*Client, error) {
+	srv, err := NewServer(ServerOpts{
+		Broker:         o.Broker,
+		Results:        o.Results,
+		Logger:         o.Logger,
+		TraceProvider:  o.TraceProvider,
+		MaxPayloadSize: o.MaxPayloadSize,
+		PayloadPolicy:  o.PayloadPolicy,
+		BlobStore:      o.BlobStore,
+		TenantQueues:   o.TenantQueues,
+		ResultCodec:    o.ResultCodec,
+		Propagator:     o.Propagator,
+		Namespace:      o.Namespace,
+		Cache:          o.Cache,
+		Signer:         o.Signer,
+		Cipher:         o.Cipher,
+		IDGenerator:    o.IDGenerator,
+		Reducers:       o.Reducers,
+		ResultSchemas:  o.ResultSchemas,
+		Stats:          o.Stats,
+		Authorizer:     o.Authorizer,
+		AuditSink:      o.AuditSink,
+		AuditLog:       o.AuditLog,
+		Locker:         o.Locker,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{srv: srv, defaultMaxDepth: o.MaxDepth, queueMaxDepths: o.QueueMaxDepths}, nil
+}
+
+// Enqueue() accepts a job and returns the assigned UUID.
+// Scheduled jobs require a running cron scheduler and can only be enqueued on a server.
+func (c *Client) Enqueue(ctx context.Context, j Job) (string, error) {
+	if err := unscheduled(j); err != nil {
+		return "", err
+	}
+
+	return c.srv.Enqueue(ctx, j)
+}
+
+// EnqueueStream() enqueues the job with the payload read from r, streamed onto the blob store.
+// See Server.EnqueueStream().
+func (c *Client) EnqueueStream(ctx context.Context, j Job, r io.Reader) (string, error) {
+	if err := unscheduled(j); err != nil {
+		return "", err
+	}
+
+	return c.srv.EnqueueStream(ctx, j, r)
+}
+
+// EnqueueBatch() enqueues the jobs and returns the assigned UUIDs in the same order.
+func (c *Client) EnqueueBatch(ctx context.Context, jobs []Job) ([]string, error) {
+	if err := unscheduled(jobs...); err != nil {
+		return nil, err
+	}
+
+	return c.srv.EnqueueBatch(ctx, jobs)
+}
+
+// EnqueueGroup() enqueues a group and returns the assigned UUID.
+func (c *Client) EnqueueGroup(ctx context.Context, g Group) (string, error) {
+	if err := unscheduled(g.Jobs...); err != nil {
+		return "", err
+	}
+
+	return c.srv.EnqueueGroup(ctx, g)
+}
+
+// EnqueueChain() enqueues a chain and returns the assigned UUID.
+func (c *Client) EnqueueChain(ctx context.Context, ch Chain) (string, error) {
+	if err := unscheduled(ch.Jobs...); err != nil {
+		return "", err
+	}
+
+	return c.srv.EnqueueChain(ctx, ch)
+}
+
+// unscheduled returns an error if any of the jobs is scheduled, as scheduled jobs require a
+// running cron scheduler and can only be enqueued on a server.
+func unscheduled(jobs ...Job) error {
+	for _, j := range jobs {
+		if j.Opts.Schedule != "" {
+			return fmt.Errorf("scheduled jobs can not be enqueued on a client")
+		}
+	}
+
+	return nil
+}
+
+// GetJob() returns the job message of the job in the results store.
+func (c *Client) GetJob(ctx context.Context, uuid string) (JobMessage, error) {
+	return c.srv.GetJob(ctx, uuid)
+}
+
+// GetJobBlocking() waits upto the timeout for the job to reach a final status, and returns
+// its job message. See Server.GetJobBlocking().
+func (c *Client) GetJobBlocking(ctx context.Context, uuid string, timeout time.Duration) (JobMessage, error) {
+	return c.srv.GetJobBlocking(ctx, uuid, timeout)
+}
+
+// GetResult() returns the results saved by the job.
+func (c *Client) GetResult(ctx context.Context, uuid string) ([][]byte, error) {
+	return c.srv.GetResult(ctx, uuid)
+}
+
+// GetNamedResult() decodes the result saved by the job under the key into v.
+func (c *Client) GetNamedResult(ctx context.Context, uuid, key string, v any) error {
+	return c.srv.GetNamedResult(ctx, uuid, key, v)
+}
+
+// StreamResult() returns a channel on which the chunks appended by the job are sent.
+func (c *Client) StreamResult(ctx context.Context, uuid string) (<-chan []byte, error) {
+	return c.srv.StreamResult(ctx, uuid)
+}
+
+// GetPending() returns upto n job messages waiting in the queue on the broker.
+func (c *Client) GetPending(ctx context.Context, queue string, n int) ([]JobMessage, error) {
+	return c.srv.GetPending(ctx, queue, n)
+}
+
+// Retry() re-enqueues a failed job.
+func (c *Client) Retry(ctx context.Context, uuid string) error {
+	return c.srv.Retry(ctx, uuid)
+}
+
+// GetJobsByTag() returns the jobs with the tag, optionally filtered by status.
+func (c *Client) GetJobsByTag(ctx context.Context, tag, status string) ([]JobMessage, error) {
+	return c.srv.GetJobsByTag(ctx, tag, status)
+}
+
+// CancelByTag() cancels all the queued jobs with the tag.
+func (c *Client) CancelByTag(ctx context.Context, tag string) (int, error) {
+	return c.srv.CancelByTag(ctx, tag)
+}
+
+// RetryByTag() re-enqueues all the failed jobs with the tag.
+func (c *Client) RetryByTag(ctx context.Context, tag string) (int, error) {
+	return c.srv.RetryByTag(ctx, tag)
+}
+
+// ExportJobs() streams the records of completed jobs matching the filter to the writer.
+func (c *Client) ExportJobs(ctx context.Context, f JobFilter, w io.Writer, format ExportFormat) (int, error) {
+	return c.srv.ExportJobs(ctx, f, w, format)
+}
+
+// Replay() re-enqueues the jobs of the NDJSON job records read from r.
+func (c *Client) Replay(ctx context.Context, r io.Reader, o ReplayOpts) (int, error) {
+	return c.srv.Replay(ctx, r, o)
+}
+
+// OpenGate() enqueues the jobs held on the gate.
+func (c *Client) OpenGate(ctx context.Context, gate string) (int, error) {
+	return c.srv.OpenGate(ctx, gate)
+}
+
+// Stats() returns the counts and rates of the jobs per task and per queue.
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	return c.srv.Stats(ctx)
+}
+
+// GetJobs() returns the job messages matching the filter.
+func (c *Client) GetJobs(ctx context.Context, f JobFilter) ([]JobMessage, error) {
+	return c.srv.GetJobs(ctx, f)
+}
+
+// Cancel() cancels a queued job.
+func (c *Client) Cancel(ctx context.Context, uuid string) error {
+	return c.srv.Cancel(ctx, uuid)
+}
+
+// GetAuditLog() returns the admin actions recorded on the results store, from the offset.
+func (c *Client) GetAuditLog(ctx context.Context, offset int) ([]AuditEntry, error) {
+	return c.srv.GetAuditLog(ctx, offset)
+}
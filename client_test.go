@@ -0,0 +1,158 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, srv *Server) *Client {
+	c, err := NewClient(ClientOpts{
+		Broker:  srv.broker,
+		Results: srv.results,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestClientEnqueueBatch(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		srv  = newServer(t)
+		cl   = newClient(t, srv)
+		jobs = []Job{makeJob(t, false), makeJob(t, true)}
+	)
+	go srv.Start(ctx)
+
+	uuids, err := cl.EnqueueBatch(ctx, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for jobs to be consumed & processed.
+	time.Sleep(time.Second)
+	for i, status := range []string{StatusDone, StatusFailed} {
+		msg, err := cl.GetJob(ctx, uuids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != status {
+			t.Fatalf("incorrect job status, expected %s, got %s", status, msg.Status)
+		}
+	}
+}
+
+func TestClientScheduled(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cl  = newClient(t, newServer(t))
+		job = makeJob(t, false)
+	)
+	job.Opts.Schedule = "@hourly"
+	jobs := []Job{makeJob(t, false), job}
+
+	// Scheduled jobs are rejected by all the enqueues, wherever they are in a group or chain.
+	if _, err := cl.Enqueue(ctx, job); err == nil {
+		t.Fatal("expected error enqueuing a scheduled job")
+	}
+	if _, err := cl.EnqueueBatch(ctx, jobs); err == nil {
+		t.Fatal("expected error enqueuing a batch with a scheduled job")
+	}
+	if _, err := cl.EnqueueGroup(ctx, Group{Jobs: jobs}); err == nil {
+		t.Fatal("expected error enqueuing a group with a scheduled job")
+	}
+	if _, err := cl.EnqueueChain(ctx, Chain{Jobs: jobs}); err == nil {
+		t.Fatal("expected error enqueuing a chain with a scheduled job")
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+		cl  = newClient(t, srv)
+	)
+
+	// Cancel the job before the server starts consuming.
+	uuid, err := cl.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Cancel(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start(ctx)
+
+	// Wait for the job to be consumed.
+	time.Sleep(time.Second)
+	msg, err := cl.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusCancelled {
+		t.Fatalf("incorrect job status, expected %s, got %s", StatusCancelled, msg.Status)
+	}
+
+	if err := cl.Cancel(ctx, uuid); err == nil {
+		t.Fatal("expected error cancelling a cancelled job")
+	}
+}
+
+func TestBrokerOnlyProducer(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		ran    = make(chan string, 2)
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"v1", "v2"} {
+		v := v
+		srv.RegisterTask("resize", func(b []byte, c JobCtx) error {
+			ran <- v + ":" + string(b)
+			return nil
+		}, TaskOpts{Version: v, Queue: "images"})
+	}
+
+	// The producer only has the broker, and the task's contract.
+	cl, err := NewClient(ClientOpts{Broker: broker})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := TaskRef{Name: "resize", Version: "v1", Queue: "images"}
+	job, err := ref.NewJob([]byte("a.png"), JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.GetJob(ctx, "uuid"); err != ErrNoResults {
+		t.Fatalf("expected job lookups to require a results store, got %v", err)
+	}
+
+	// Messages can also be enqueued onto the broker directly.
+	job, err = ref.NewJob([]byte("b.png"), JobOpts{Version: "v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncodeJobMessage(NewJobMessage(job))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Enqueue(ctx, b, ref.Queue); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"v1:a.png", "v2:b.png"} {
+		srv.Process(ctx, <-broker.data)
+		if got := <-ran; got != want {
+			t.Fatalf("expected %s to be processed, got %s", want, got)
+		}
+	}
+}
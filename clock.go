@@ -0,0 +1,14 @@
+package tasqueue
+
+import "time"
+
+// systemClock is the default Clock, which tells the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
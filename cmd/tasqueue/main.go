@@ -0,0 +1,185 @@
+// Command tasqueue is a CLI to inspect and manage tasqueue jobs on a redis backend, and
+// to migrate jobs between backends.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/kalbhor/tasqueue/auth"
+	rb "github.com/kalbhor/tasqueue/brokers/redis"
+	"github.com/kalbhor/tasqueue/config"
+	rr "github.com/kalbhor/tasqueue/results/redis"
+	"github.com/zerodha/logf"
+)
+
+const usage = `usage: tasqueue <command> [flags]
+
+commands:
+  export    export completed jobs as ndjson or csv
+  migrate   move job states and pending jobs between backends
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// redisFlags registers the flags to connect to redis on the flag set.
+func redisFlags(f *flag.FlagSet) (*string, *string, *int) {
+	return f.String("redis-addr", "127.0.0.1:6379", "comma separated redis addresses"),
+		f.String("redis-password", "", "redis password"),
+		f.Int("redis-db", 0, "redis db")
+}
+
+func newClient(addrs, pass string, db int) (*tasqueue.Client, error) {
+	lo := logf.New(logf.Opts{Level: logf.ErrorLevel})
+	return tasqueue.NewClient(tasqueue.ClientOpts{
+		Broker:  rb.New(rb.Options{Addrs: strings.Split(addrs, ","), Auth: auth.Options{Password: pass}, DB: db}, lo),
+		Results: rr.New(rr.Options{Addrs: strings.Split(addrs, ","), Auth: auth.Options{Password: pass}, DB: db}, lo),
+		Logger:  lo,
+	})
+}
+
+func export(args []string) error {
+	var (
+		f               = flag.NewFlagSet("export", flag.ExitOnError)
+		addrs, pass, db = redisFlags(f)
+		status          = f.String("status", "", "status of jobs to export (successful or failed)")
+		task            = f.String("task", "", "task name of jobs to export")
+		queue           = f.String("queue", "", "queue of jobs to export")
+		since           = f.Duration("since", 0, "export jobs processed within this duration (eg: 24h)")
+		format          = f.String("format", string(tasqueue.ExportNDJSON), "export format (ndjson or csv)")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	cl, err := newClient(*addrs, *pass, *db)
+	if err != nil {
+		return err
+	}
+
+	filter := tasqueue.JobFilter{
+		Status: *status,
+		Task:   *task,
+		Queue:  *queue,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	n, err := cl.ExportJobs(context.Background(), filter, os.Stdout, tasqueue.ExportFormat(*format))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d jobs\n", n)
+
+	return nil
+}
+
+func migrate(args []string) error {
+	var (
+		f      = flag.NewFlagSet("migrate", flag.ExitOnError)
+		from   = f.String("from", "", "source backend url (redis://[[user]:password@]host:port[/db] or nats://host:port)")
+		to     = f.String("to", "", "destination backend url")
+		queues = f.String("queues", "", "comma separated queues to migrate")
+		prefix = f.String("queue-prefix", "", "prefix of the source's queues to migrate, if --queues isn't set")
+		stream = f.String("nats-stream", "", "nats-jetstream stream to create on the destination, with the queues as subjects")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+
+	var (
+		ctx = context.Background()
+		lo  = logf.New(logf.Opts{Level: logf.ErrorLevel})
+	)
+	fb, fr, err := backend(*from, "", nil, lo)
+	if err != nil {
+		return err
+	}
+	// Only the queues that are named or prefixed are migrated, so that other lists on the
+	// source aren't consumed.
+	var qs []string
+	if *queues != "" {
+		qs = strings.Split(*queues, ",")
+	} else if *prefix == "" {
+		return fmt.Errorf("--queues or --queue-prefix is required")
+	} else if l, ok := fb.(tasqueue.QueueLister); !ok {
+		return fmt.Errorf("--queues is required, as the source can't look up its queues")
+	} else if qs, err = l.Queues(ctx, *prefix+"*"); err != nil {
+		return fmt.Errorf("could not look up queues : %w", err)
+	}
+	tb, tr, err := backend(*to, *stream, qs, lo)
+	if err != nil {
+		return err
+	}
+
+	src, err := tasqueue.NewServer(tasqueue.ServerOpts{Broker: fb, Results: fr, Logger: lo})
+	if err != nil {
+		return err
+	}
+	dst, err := tasqueue.NewServer(tasqueue.ServerOpts{Broker: tb, Results: tr, Logger: lo})
+	if err != nil {
+		return err
+	}
+
+	st, err := tasqueue.Migrate(ctx, src, dst, tasqueue.MigrateOpts{Queues: qs})
+	fmt.Fprintf(os.Stderr, "migrated %d jobs and %d pending messages\n", st.Jobs, st.Messages)
+
+	return err
+}
+
+// backend returns the broker and results store for the url. The nats-jetstream stream, if
+// set, is created with the queues as its subjects.
+func backend(u, stream string, queues []string, lo logf.Logger) (tasqueue.Broker, tasqueue.Results, error) {
+	b, err := config.ParseURL(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.Type == config.TypeMemory {
+		return nil, nil, fmt.Errorf("the memory backend is local to a process, expected redis or nats")
+	}
+	if stream != "" && len(queues) > 0 {
+		b.Streams = map[string][]string{stream: queues}
+	}
+
+	br, err := config.NewBroker(b, lo)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The results store doesn't take the broker's streams.
+	b.Streams = nil
+	r, err := config.NewResults(b, lo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return br, r, nil
+}
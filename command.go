@@ -0,0 +1,171 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultMaxOutput is the default number of bytes of a command's stdout and stderr saved.
+const defaultMaxOutput = 64 << 10
+
+// CommandOpts configures a handler that runs an external command.
+type CommandOpts struct {
+	// Args is the command and its arguments. Each is a text/template executed with the
+	// job's payload decoded as JSON, eg: []string{"convert", "{{.input}}", "{{.output}}"}.
+	Args []string
+	// Env is the environment of the command, in addition to the server's.
+	Env []string
+	// Stdin, if set, passes the job's payload on the command's standard input. The payload
+	// doesn't have to be JSON then, unless the arguments are templated with it.
+	Stdin bool
+
+	// Timeout, if set, kills the command after the duration. The command is also killed
+	// when the job's timeout elapses.
+	Timeout time.Duration
+
+	// RetryCodes are the exit codes of the failures that are retried. The job fails without
+	// retrying on other exit codes. If it is empty, all the failures are retried.
+	RetryCodes []int
+
+	// MaxOutput is the number of bytes of the command's stdout and stderr saved as the job's
+	// "stdout" and "stderr" named results. Defaults to 64KiB.
+	MaxOutput int
+}
+
+// Command returns a handler that runs the external command for each job, so that existing
+// scripts can be run as tasks without Go handlers. The command's output is saved as the
+// named results "stdout", "stderr" and "exit_code". If the task is sandboxed, the command
+// runs in the job's directory.
+func Command(o CommandOpts) (func([]byte, JobCtx) error, error) {
+	if len(o.Args) == 0 {
+		return nil, errors.New("command not set")
+	}
+	if o.MaxOutput == 0 {
+		o.MaxOutput = defaultMaxOutput
+	}
+
+	args := make([]*template.Template, len(o.Args))
+	for i, a := range o.Args {
+		t, err := template.New("").Option("missingkey=error").Parse(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command argument %q : %w", a, err)
+		}
+		args[i] = t
+	}
+	retry := make(map[int]struct{}, len(o.RetryCodes))
+	for _, c := range o.RetryCodes {
+		retry[c] = struct{}{}
+	}
+
+	return func(payload []byte, c JobCtx) error {
+		argv, err := commandArgs(args, payload, o.Stdin)
+		if err != nil {
+			// The payload won't change on a retry.
+			return fmt.Errorf("%v : %w", err, ErrSkipRetry)
+		}
+
+		var (
+			ctx    context.Context = c
+			cancel                 = func() {}
+		)
+		if o.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		}
+		defer cancel()
+
+		var (
+			cmd    = exec.CommandContext(ctx, argv[0], argv[1:]...)
+			stdout = &cappedBuffer{max: o.MaxOutput}
+			stderr = &cappedBuffer{max: o.MaxOutput}
+		)
+		cmd.Dir = c.Dir()
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if len(o.Env) > 0 {
+			cmd.Env = append(os.Environ(), o.Env...)
+		}
+		if o.Stdin {
+			cmd.Stdin = bytes.NewReader(payload)
+		}
+
+		runErr := cmd.Run()
+		code := -1
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+
+		// The output is saved for failed runs too, to debug them.
+		if c.store != nil {
+			for k, v := range map[string]any{"stdout": stdout.String(), "stderr": stderr.String(), "exit_code": code} {
+				if err := c.SaveNamed(k, v); err != nil {
+					return fmt.Errorf("could not save command output : %w", err)
+				}
+			}
+		}
+
+		if runErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("command timed out : %w", ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return fmt.Errorf("could not run command : %w", runErr)
+		}
+		if _, ok := retry[code]; len(retry) > 0 && !ok {
+			return fmt.Errorf("command exited with %d : %w", code, ErrSkipRetry)
+		}
+		return fmt.Errorf("command exited with %d", code)
+	}, nil
+}
+
+// commandArgs executes the argument templates with the payload.
+func commandArgs(args []*template.Template, payload []byte, stdin bool) ([]string, error) {
+	var data any
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &data); err != nil && !stdin {
+			return nil, fmt.Errorf("could not decode payload : %w", err)
+		}
+	}
+
+	argv := make([]string, len(args))
+	for i, t := range args {
+		var b strings.Builder
+		if err := t.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("could not template command argument : %w", err)
+		}
+		argv[i] = b.String()
+	}
+
+	return argv, nil
+}
+
+// cappedBuffer is a buffer that discards the writes beyond its max size. The buffer isn't
+// embedded, so that its ReadFrom doesn't bypass the cap.
+type cappedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.buf.Len(); n < len(p) {
+		if n > 0 {
+			b.buf.Write(p[:n])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,96 @@
+//go:build !windows
+
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	ctx := context.Background()
+	run := func(o CommandOpts, payload string) (*Server, JobMessage) {
+		broker := NewMockBroker()
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := Command(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask("cmd", h, TaskOpts{})
+
+		job, err := NewJob("cmd", []byte(payload), JobOpts{MaxRetries: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return srv, msg
+	}
+
+	// The arguments are templated with the payload, and the output is saved.
+	srv, msg := run(CommandOpts{Args: []string{"echo", "hello {{.name}}"}}, `{"name": "world"}`)
+	if msg.Status != StatusDone {
+		t.Fatalf("expected status %s, got %s", StatusDone, msg.Status)
+	}
+	var stdout string
+	if err := srv.GetNamedResult(ctx, msg.UUID, "stdout", &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "hello world\n" {
+		t.Fatalf("expected the output to be saved, got %q", stdout)
+	}
+
+	// Exit codes that aren't retried fail the job without retrying it.
+	o := CommandOpts{Args: []string{"sh", "-c", "exit {{.code}}"}, RetryCodes: []int{75}}
+	if _, msg = run(o, `{"code": 1}`); msg.Status != StatusFailed {
+		t.Fatalf("expected status %s, got %s", StatusFailed, msg.Status)
+	}
+	if _, msg = run(o, `{"code": 75}`); msg.Status != StatusRetrying {
+		t.Fatalf("expected status %s, got %s", StatusRetrying, msg.Status)
+	}
+
+	// Payloads that don't template the arguments fail the job without retrying it.
+	if _, msg = run(o, `{}`); msg.Status != StatusFailed {
+		t.Fatalf("expected status %s, got %s", StatusFailed, msg.Status)
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	h, err := Command(CommandOpts{Args: []string{"sh", "-c", "cat; echo oops >&2; exit 2"}, Stdin: true, MaxOutput: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := JobCtx{Context: context.Background(), store: NewMockResults(), codec: JSONCodec{}, named: make(map[string][]byte)}
+	if err := h([]byte("payload"), c); err == nil || errors.Is(err, ErrSkipRetry) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+	if string(c.named["stdout"]) != `"payl"` || string(c.named["stderr"]) != `"oops"` || string(c.named["exit_code"]) != "2" {
+		t.Fatalf("expected the capped output to be saved, got %s", c.named)
+	}
+
+	// The command is killed after the timeout.
+	h, err = Command(CommandOpts{Args: []string{"sleep", "5"}, Timeout: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := h(nil, JobCtx{Context: context.Background()}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the command to time out, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected the command to be killed on timeout")
+	}
+}
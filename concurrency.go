@@ -0,0 +1,31 @@
+package tasqueue
+
+import "context"
+
+// newConcurrencyGroups returns the semaphores of the concurrency groups, of group -> limit.
+func newConcurrencyGroups(limits map[string]int) map[string]chan struct{} {
+	out := make(map[string]chan struct{}, len(limits))
+	for g, n := range limits {
+		out[g] = make(chan struct{}, n)
+	}
+
+	return out
+}
+
+// acquireGroup waits for a slot of the concurrency group, and returns the function that
+// releases it. If the context is cancelled meanwhile, the job is pushed back onto the queue
+// and it returns false. Tasks without a group, or with a group that isn't configured, aren't limited.
+func (s *Server) acquireGroup(ctx context.Context, group string, work []byte, queue string) (func(), bool) {
+	sem, ok := s.cgroups[group]
+	if !ok {
+		return func() {}, true
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	case <-ctx.Done():
+		s.pushBack(work, queue)
+		return nil, false
+	}
+}
@@ -0,0 +1,71 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyGroups(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:            NewMockBroker(),
+		Results:           NewMockResults(),
+		ConcurrencyGroups: map[string]int{"reporting": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+		wg      sync.WaitGroup
+	)
+	handler := func(b []byte, _ JobCtx) error {
+		defer wg.Done()
+
+		mu.Lock()
+		if running++; running > max {
+			max = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 50)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+	// The tasks share the group's limit, irrespective of their concurrency.
+	srv.RegisterTask("daily", handler, TaskOpts{Concurrency: 3, Queue: "daily", ConcurrencyGroup: "reporting"})
+	srv.RegisterTask("weekly", handler, TaskOpts{Concurrency: 3, Queue: "weekly", ConcurrencyGroup: "reporting"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		task := "daily"
+		if i%2 == 0 {
+			task = "weekly"
+		}
+		job, err := NewJob(task, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if max > 2 {
+		t.Fatalf("expected at most 2 concurrent jobs in the group, got %d", max)
+	}
+}
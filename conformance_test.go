@@ -0,0 +1,38 @@
+package tasqueue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/kalbhor/tasqueue/brokers/brokertest"
+	bi "github.com/kalbhor/tasqueue/brokers/in-memory"
+	br "github.com/kalbhor/tasqueue/brokers/redis"
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+	"github.com/kalbhor/tasqueue/results/resultstest"
+	"github.com/zerodha/logf"
+)
+
+func TestInMemoryBrokerConformance(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) tasqueue.Broker {
+		return bi.New()
+	})
+}
+
+// TestRedisBrokerConformance is skipped if redis isn't running.
+func TestRedisBrokerConformance(t *testing.T) {
+	b := br.New(br.Options{Addrs: []string{"127.0.0.1:6379"}}, logf.New(logf.Opts{Level: logf.FatalLevel}))
+	if err := b.Ping(context.Background()); err != nil {
+		t.Skipf("redis isn't available: %v", err)
+	}
+
+	brokertest.Run(t, func(t *testing.T) tasqueue.Broker {
+		return b
+	})
+}
+
+func TestInMemoryResultsConformance(t *testing.T) {
+	resultstest.Run(t, func(t *testing.T) tasqueue.Results {
+		return rr.New()
+	})
+}
@@ -0,0 +1,49 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// continuations holds the jobs appended by a handler with JobCtx.Then().
+type continuations struct {
+	mu   sync.Mutex
+	jobs []Job
+}
+
+// Then() appends jobs to be enqueued once the current job succeeds, eg: for pipelines whose
+// next steps depend on the job's data. Jobs appended by an attempt that fails are discarded,
+// hence a retried attempt should append them again. Their UUIDs are recorded on the job's
+// meta (Meta.ContinuationUUIDs).
+func (c *JobCtx) Then(jobs ...Job) {
+	if c.next == nil {
+		return
+	}
+	c.next.mu.Lock()
+	c.next.jobs = append(c.next.jobs, jobs...)
+	c.next.mu.Unlock()
+}
+
+// enqueueContinuations() enqueues the jobs appended by the handler of the succeeded job,
+// linked to the span the job was enqueued with, and records their UUIDs on the job.
+func (s *Server) enqueueContinuations(ctx context.Context, msg *JobMessage, next *continuations) error {
+	next.mu.Lock()
+	jobs := next.jobs
+	next.mu.Unlock()
+
+	for _, j := range jobs {
+		nj, err := s.prepareJob(j)
+		if err != nil {
+			return err
+		}
+		meta := DefaultMeta(nj.Opts)
+		meta.TraceLink = msg.TraceLink
+		uuid, err := s.enqueueWithMeta(ctx, nj, meta)
+		if err != nil {
+			return err
+		}
+		msg.ContinuationUUIDs = append(msg.ContinuationUUIDs, uuid)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestThen(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("split", func(b []byte, c JobCtx) error {
+		for _, p := range []string{"a", "b"} {
+			j, err := NewJob("part", []byte(p), JobOpts{})
+			if err != nil {
+				return err
+			}
+			c.Then(j)
+		}
+		if string(b) == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{})
+
+	// The jobs appended by a succeeded job are enqueued.
+	job, err := NewJob("split", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone || len(msg.ContinuationUUIDs) != 2 {
+		t.Fatalf("expected the job to succeed with 2 continuations, got %s %v", msg.Status, msg.ContinuationUUIDs)
+	}
+	for i, p := range []string{"a", "b"} {
+		next, err := srv.GetJob(ctx, msg.ContinuationUUIDs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next.Job.Task != "part" || string(next.Job.Payload) != p || next.Status != StatusStarted {
+			t.Fatalf("expected the continuation %s to be queued, got %+v", p, next)
+		}
+		<-broker.data
+	}
+
+	// The jobs appended by a failed job are discarded.
+	job, err = NewJob("split", []byte("fail"), JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuid, err = srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	if msg, err = srv.GetJob(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusFailed || len(msg.ContinuationUUIDs) != 0 {
+		t.Fatalf("expected the job to fail without continuations, got %s %v", msg.Status, msg.ContinuationUUIDs)
+	}
+	select {
+	case <-broker.data:
+		t.Fatal("expected the continuations of the failed job not to be enqueued")
+	default:
+	}
+}
@@ -0,0 +1,188 @@
+// Package email provides a task that sends emails, with templates, the classification of
+// failures into retried (temporary) and bounced (permanent) ones, and a bounce hook.
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/textproto"
+	"text/template"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+// ErrBounced, wrapped in the error returned by a Sender, marks the failure as permanent
+// (eg: a rejected recipient), so that the email isn't retried.
+var ErrBounced = errors.New("email bounced")
+
+// Message is the payload of an email job. If Template is set, the subject and bodies are
+// rendered from the template with Data.
+type Message struct {
+	From     string            `json:"from,omitempty"`
+	To       []string          `json:"to"`
+	Cc       []string          `json:"cc,omitempty"`
+	Bcc      []string          `json:"bcc,omitempty"`
+	Subject  string            `json:"subject,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	HTML     string            `json:"html,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Template string            `json:"template,omitempty"`
+	Data     map[string]any    `json:"data,omitempty"`
+}
+
+// Sender sends rendered emails, eg: over SMTP, or an API such as SES.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Template is a named email template. The subject and text body are text/templates, and
+// the HTML body is an html/template.
+type Template struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Options struct {
+	Sender Sender
+	// From is the sender of the messages that don't set one.
+	From string
+	// Templates is a map of template name -> template.
+	Templates map[string]Template
+
+	// OnBounce, if set, is called with the messages that bounced, eg: to suppress
+	// sending to the recipients.
+	OnBounce func(m Message, err error)
+}
+
+// Mailer sends the emails of the jobs of the email task.
+type Mailer struct {
+	opt       Options
+	templates map[string]templates
+}
+
+type templates struct {
+	subject, text *template.Template
+	html          *htmltemplate.Template
+}
+
+// New() returns a new instance of the mailer, whose Handler should be registered as the
+// email task's handler.
+func New(o Options) (*Mailer, error) {
+	if o.Sender == nil {
+		return nil, errors.New("sender not set")
+	}
+
+	m := &Mailer{opt: o, templates: make(map[string]templates, len(o.Templates))}
+	for name, t := range o.Templates {
+		var (
+			tpl templates
+			err error
+		)
+		if tpl.subject, err = template.New(name).Option("missingkey=error").Parse(t.Subject); err != nil {
+			return nil, fmt.Errorf("invalid subject of template %s : %w", name, err)
+		}
+		if t.Text != "" {
+			if tpl.text, err = template.New(name).Option("missingkey=error").Parse(t.Text); err != nil {
+				return nil, fmt.Errorf("invalid text of template %s : %w", name, err)
+			}
+		}
+		if t.HTML != "" {
+			if tpl.html, err = htmltemplate.New(name).Option("missingkey=error").Parse(t.HTML); err != nil {
+				return nil, fmt.Errorf("invalid html of template %s : %w", name, err)
+			}
+		}
+		m.templates[name] = tpl
+	}
+
+	return m, nil
+}
+
+// NewJob() returns a job of the email task which sends the message.
+func NewJob(task string, m Message, opts tasqueue.JobOpts) (tasqueue.Job, error) {
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return tasqueue.Job{}, errors.New("email recipients not set")
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return tasqueue.Job{}, err
+	}
+
+	return tasqueue.NewJob(task, b, opts)
+}
+
+// Handler renders and sends the job's message. Temporary failures are retried with the
+// task's retries, while messages that bounce fail the job without retrying it.
+func (m *Mailer) Handler(b []byte, c tasqueue.JobCtx) error {
+	var msg Message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return fmt.Errorf("could not decode email : %v : %w", err, tasqueue.ErrSkipRetry)
+	}
+	if msg.From == "" {
+		msg.From = m.opt.From
+	}
+	if err := m.render(&msg); err != nil {
+		return fmt.Errorf("%v : %w", err, tasqueue.ErrSkipRetry)
+	}
+
+	err := m.opt.Sender.Send(c, msg)
+	if err == nil {
+		return nil
+	}
+	if !isBounce(err) {
+		return fmt.Errorf("could not send email : %w", err)
+	}
+
+	if m.opt.OnBounce != nil {
+		m.opt.OnBounce(msg, err)
+	}
+	return fmt.Errorf("email bounced : %v : %w", err, tasqueue.ErrSkipRetry)
+}
+
+// render renders the message's template, if it is set.
+func (m *Mailer) render(msg *Message) error {
+	if msg.Template == "" {
+		return nil
+	}
+	t, ok := m.templates[msg.Template]
+	if !ok {
+		return fmt.Errorf("email template %s not found", msg.Template)
+	}
+
+	var b bytes.Buffer
+	if err := t.subject.Execute(&b, msg.Data); err != nil {
+		return fmt.Errorf("could not render subject : %w", err)
+	}
+	msg.Subject = b.String()
+
+	if t.text != nil {
+		b.Reset()
+		if err := t.text.Execute(&b, msg.Data); err != nil {
+			return fmt.Errorf("could not render text : %w", err)
+		}
+		msg.Text = b.String()
+	}
+	if t.html != nil {
+		b.Reset()
+		if err := t.html.Execute(&b, msg.Data); err != nil {
+			return fmt.Errorf("could not render html : %w", err)
+		}
+		msg.HTML = b.String()
+	}
+
+	return nil
+}
+
+// isBounce returns true if the failure is permanent: either marked as such by the sender,
+// or a permanent (5xx) SMTP reply.
+func isBounce(err error) bool {
+	if errors.Is(err, ErrBounced) {
+		return true
+	}
+	var e *textproto.Error
+	return errors.As(err, &e) && e.Code >= 500 && e.Code < 600
+}
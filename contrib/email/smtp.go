@@ -0,0 +1,125 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMTP sends emails over SMTP, upgrading the connection with STARTTLS if the server
+// supports it.
+type SMTP struct {
+	// Addr is the host:port of the SMTP server.
+	Addr     string
+	Username string
+	Password string
+}
+
+func (s SMTP) Send(ctx context.Context, m Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp address %s : %w", s.Addr, err)
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	body, err := compose(m)
+	if err != nil {
+		return err
+	}
+
+	rcpts := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+	rcpts = append(append(append(rcpts, m.To...), m.Cc...), m.Bcc...)
+
+	// net/smtp doesn't take a context, hence the send is abandoned if the context is done.
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(s.Addr, auth, m.From, rcpts, body)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// compose returns the MIME message of the email. Messages with both a text and an HTML body
+// are sent as multipart/alternative.
+func compose(m Message) ([]byte, error) {
+	var (
+		b = &bytes.Buffer{}
+		h = textproto.MIMEHeader{}
+	)
+	h.Set("From", m.From)
+	h.Set("To", strings.Join(m.To, ", "))
+	if len(m.Cc) > 0 {
+		h.Set("Cc", strings.Join(m.Cc, ", "))
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("MIME-Version", "1.0")
+	for k, v := range m.Headers {
+		h.Set(k, v)
+	}
+
+	// Values with line breaks would inject headers.
+	for k, v := range h {
+		if strings.ContainsAny(k+v[0], "\r\n") {
+			return nil, fmt.Errorf("invalid email header %s : %w", k, ErrBounced)
+		}
+	}
+
+	switch {
+	case m.Text != "" && m.HTML != "":
+		mw := multipart.NewWriter(b)
+		h.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		writeHeader(b, h)
+
+		for _, p := range []struct{ typ, body string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+			w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {p.typ + "; charset=utf-8"}})
+			if err != nil {
+				return nil, err
+			}
+			w.Write([]byte(p.body))
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	case m.HTML != "":
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		writeHeader(b, h)
+		b.WriteString(m.HTML)
+	default:
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		writeHeader(b, h)
+		b.WriteString(m.Text)
+	}
+
+	return b.Bytes(), nil
+}
+
+func writeHeader(b *bytes.Buffer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s: %s\r\n", k, h.Get(k))
+	}
+	b.WriteString("\r\n")
+}
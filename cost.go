@@ -0,0 +1,145 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// costLimiter caps the total cost of the jobs being processed by the server. Waiters are
+// served in FIFO order, so that costly jobs aren't starved by cheaper ones.
+type costLimiter struct {
+	max int
+
+	mu      sync.Mutex
+	used    int
+	waiters []*costWaiter
+}
+
+type costWaiter struct {
+	cost     int
+	priority int
+	ready    chan struct{}
+}
+
+func newCostLimiter(max int) *costLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &costLimiter{max: max}
+}
+
+// acquire waits until the cost fits within the limit, or returns false if the context is
+// cancelled meanwhile. A cost larger than the limit is acquired once no other cost is acquired.
+func (l *costLimiter) acquire(ctx context.Context, cost int) bool {
+	return l.acquirePriority(ctx, cost, 0)
+}
+
+// acquirePriority is acquire, where the waiters of a higher priority are served before the
+// waiters of a lower priority.
+func (l *costLimiter) acquirePriority(ctx context.Context, cost, priority int) bool {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.fits(cost) {
+		l.used += cost
+		l.mu.Unlock()
+		return true
+	}
+
+	w := &costWaiter{cost: cost, priority: priority, ready: make(chan struct{})}
+	i := len(l.waiters)
+	for i > 0 && l.waiters[i-1].priority < priority {
+		i--
+	}
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[i+1:], l.waiters[i:])
+	l.waiters[i] = w
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.ready:
+			// The cost was acquired meanwhile.
+			l.used -= cost
+			l.notify()
+		default:
+			for i, o := range l.waiters {
+				if o == w {
+					l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+					break
+				}
+			}
+			// The waiters behind may fit now.
+			l.notify()
+		}
+		return false
+	}
+}
+
+func (l *costLimiter) release(cost int) {
+	l.mu.Lock()
+	l.used -= cost
+	l.notify()
+	l.mu.Unlock()
+}
+
+// shortfall returns the cost that has to be released for the cost to fit.
+func (l *costLimiter) shortfall(cost int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.fits(cost) {
+		return 0
+	}
+	return l.used + cost - l.max
+}
+
+func (l *costLimiter) fits(cost int) bool {
+	return l.used+cost <= l.max || l.used == 0
+}
+
+// notify acquires the costs of the waiters at the front of the queue which fit. It should be
+// called with the lock held.
+func (l *costLimiter) notify() {
+	for len(l.waiters) > 0 {
+		w := l.waiters[0]
+		if !l.fits(w.cost) {
+			return
+		}
+		l.used += w.cost
+		l.waiters = l.waiters[1:]
+		close(w.ready)
+	}
+}
+
+// cost returns the cost of the task's jobs.
+func (t Task) cost() int {
+	if t.opts.Cost == 0 {
+		return 1
+	}
+	return int(t.opts.Cost)
+}
+
+// acquireCost waits for the job's cost to fit within the server's max cost, and returns the
+// function that releases it. If the context is cancelled meanwhile, the job is pushed back
+// onto the queue and it returns false.
+func (s *Server) acquireCost(ctx context.Context, task Task, work []byte, queue string) (func(), bool) {
+	if s.costs == nil {
+		return func() {}, true
+	}
+
+	// Jobs of a higher priority preempt the running preemptible jobs of a lower priority.
+	cost := task.cost()
+	if task.opts.Priority > 0 {
+		s.preempt(task.opts.Priority, cost)
+	}
+	if !s.costs.acquirePriority(ctx, cost, task.opts.Priority) {
+		s.pushBack(work, queue)
+		return nil, false
+	}
+
+	return func() { s.costs.release(cost) }, true
+}
@@ -0,0 +1,97 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxCost(t *testing.T) {
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), MaxCost: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu   sync.Mutex
+		cost uint32
+		wg   sync.WaitGroup
+	)
+	handler := func(c uint32) func([]byte, JobCtx) error {
+		return func(b []byte, _ JobCtx) error {
+			defer wg.Done()
+
+			mu.Lock()
+			if cost += c; cost > 4 {
+				t.Errorf("expected the cost of the running jobs to be at most 4, got %d", cost)
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond * 20)
+
+			mu.Lock()
+			cost -= c
+			mu.Unlock()
+			return nil
+		}
+	}
+	srv.RegisterTask("heavy", handler(3), TaskOpts{Concurrency: 4, Queue: "heavy", Cost: 3})
+	srv.RegisterTask("light", handler(1), TaskOpts{Concurrency: 4, Queue: "light"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 12; i++ {
+		task := "light"
+		if i%3 == 0 {
+			task = "heavy"
+		}
+		job, err := NewJob(task, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+	wg.Wait()
+}
+
+func TestCostLimiter(t *testing.T) {
+	var (
+		ctx = context.Background()
+		l   = newCostLimiter(4)
+	)
+	if !l.acquire(ctx, 3) {
+		t.Fatal("expected cost within the limit to be acquired")
+	}
+
+	// A cost that doesn't fit waits for a release.
+	cctx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	if l.acquire(cctx, 2) {
+		t.Fatal("expected cost over the limit to not be acquired")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.acquire(ctx, 2)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	l.release(3)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected waiting cost to be acquired after the release")
+	}
+	l.release(2)
+
+	// A cost larger than the limit is acquired on its own.
+	if !l.acquire(ctx, 10) {
+		t.Fatal("expected cost larger than the limit to be acquired")
+	}
+}
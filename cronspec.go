@@ -0,0 +1,112 @@
+package tasqueue
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrInvalidSchedule is matched (errors.Is) by the *ScheduleError returned for invalid cron specs.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// scheduleFields are the fields of the standard cron syntax, in order, with the parsers of
+// each field on its own.
+var scheduleFields = []struct {
+	name   string
+	parser cron.Parser
+}{
+	{"minute", cron.NewParser(cron.Minute)},
+	{"hour", cron.NewParser(cron.Hour)},
+	{"day of month", cron.NewParser(cron.Dom)},
+	{"month", cron.NewParser(cron.Month)},
+	{"day of week", cron.NewParser(cron.Dow)},
+}
+
+// ScheduleError is returned for an invalid cron spec, with the field of the spec that's
+// invalid, if it's a field that's invalid.
+type ScheduleError struct {
+	Spec string
+	// Field is the invalid field (eg: "minute", "day of week", "timezone"), or empty if the
+	// spec as a whole is invalid (eg: it doesn't have 5 fields, or is an unknown descriptor).
+	// Value is the field's value.
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *ScheduleError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid schedule %q : %v", e.Spec, e.Err)
+	}
+	return fmt.Sprintf("invalid %s %q of schedule %q : %v", e.Field, e.Value, e.Spec, e.Err)
+}
+
+func (e *ScheduleError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ScheduleError) Is(target error) bool {
+	return target == ErrInvalidSchedule
+}
+
+// ValidateSchedule() validates the cron spec (the standard 5 field syntax, optionally prefixed
+// with CRON_TZ=<zone>, or a descriptor like @daily or @every 1h), eg: a user supplied one,
+// before it's used to schedule jobs. An invalid spec returns a *ScheduleError.
+func ValidateSchedule(spec string) error {
+	_, err := parseSchedule(spec)
+	return err
+}
+
+// parseSchedule parses the cron spec, returning a *ScheduleError with the invalid field, if
+// the spec is invalid.
+func parseSchedule(spec string) (cron.Schedule, error) {
+	sch, err := cron.ParseStandard(spec)
+	if err == nil {
+		return sch, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		zone := fields[0][strings.Index(fields[0], "=")+1:]
+		if _, lerr := time.LoadLocation(zone); lerr != nil {
+			return nil, &ScheduleError{Spec: spec, Field: "timezone", Value: zone, Err: lerr}
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != len(scheduleFields) || strings.HasPrefix(fields[0], "@") {
+		return nil, &ScheduleError{Spec: spec, Err: err}
+	}
+	for i, f := range scheduleFields {
+		if _, ferr := f.parser.Parse(fields[i]); ferr != nil {
+			return nil, &ScheduleError{Spec: spec, Field: f.name, Value: fields[i], Err: ferr}
+		}
+	}
+
+	return nil, &ScheduleError{Spec: spec, Err: err}
+}
+
+// NextRuns() returns the next n times at which the cron spec fires (after the server's
+// current time), to preview a schedule before creating it. An invalid spec returns a
+// *ScheduleError.
+func (s *Server) NextRuns(spec string, n int) ([]time.Time, error) {
+	sch, err := parseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out = make([]time.Time, 0, n)
+		t   = s.clock.Now()
+	)
+	for i := 0; i < n; i++ {
+		if t = sch.Next(t); t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package tasqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		spec    string
+		invalid bool
+		field   string
+		value   string
+	}{
+		{"*/5 * * * *", false, "", ""},
+		{"@every 1h", false, "", ""},
+		{"CRON_TZ=Asia/Kolkata 0 9 * * 1-5", false, "", ""},
+		{"61 * * * *", true, "minute", "61"},
+		{"0 9 * * MON-FOO", true, "day of week", "MON-FOO"},
+		{"CRON_TZ=Nowhere/Else 0 9 * * *", true, "timezone", "Nowhere/Else"},
+		// Specs that are invalid as a whole have no field.
+		{"* * *", true, "", ""},
+	} {
+		err := ValidateSchedule(tc.spec)
+		if !tc.invalid {
+			if err != nil {
+				t.Fatalf("expected %q to be valid, got %v", tc.spec, err)
+			}
+			continue
+		}
+
+		var serr *ScheduleError
+		if !errors.As(err, &serr) || !errors.Is(err, ErrInvalidSchedule) {
+			t.Fatalf("expected a schedule error for %q, got %v", tc.spec, err)
+		}
+		if serr.Field != tc.field || serr.Value != tc.value {
+			t.Fatalf("expected %q to be invalid on %q (%q), got %q (%q)", tc.spec, tc.field, tc.value, serr.Field, serr.Value)
+		}
+	}
+}
+
+func TestNextRuns(t *testing.T) {
+	clock := newMockClock(time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC))
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := srv.NextRuns("0 9 * * *", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range runs {
+		if exp := time.Date(2024, 1, 2+i, 9, 0, 0, 0, time.UTC); !r.Equal(exp) {
+			t.Fatalf("expected run %d at %v, got %v", i, exp, r)
+		}
+	}
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 runs, got %d", len(runs))
+	}
+
+	if _, err := srv.NextRuns("0 25 * * *", 3); !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("expected an invalid schedule, got %v", err)
+	}
+}
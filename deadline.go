@@ -0,0 +1,127 @@
+package tasqueue
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel"
+	spans "go.opentelemetry.io/otel/trace"
+)
+
+// deadlineKey is the part of a job message decoded to order it by its priority and deadline.
+type deadlineKey struct {
+	Deadline time.Time
+	Priority uint8
+}
+
+// missedDeadline returns true if the job message has a deadline set and it has passed.
+func (m JobMessage) missedDeadline(now time.Time) bool {
+	return !m.Deadline.IsZero() && now.After(m.Deadline)
+}
+
+// prefetched is a message buffered by the prefetcher.
+type prefetched struct {
+	priority uint8
+	deadline time.Time
+	seq      uint64
+	b        []byte
+}
+
+// deadlineHeap orders the messages by their priority (highest first), and then by their
+// deadline (earliest first), with the messages without a deadline after them, in the order
+// they were consumed.
+type deadlineHeap []prefetched
+
+func (h deadlineHeap) Len() int { return len(h) }
+
+func (h deadlineHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	switch {
+	case a.priority != b.priority:
+		return a.priority > b.priority
+	case a.deadline.IsZero() != b.deadline.IsZero():
+		return !a.deadline.IsZero()
+	case !a.deadline.Equal(b.deadline):
+		return a.deadline.Before(b.deadline)
+	}
+	return a.seq < b.seq
+}
+
+func (h deadlineHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *deadlineHeap) Push(x any) { *h = append(*h, x.(prefetched)) }
+
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// prefetch buffers up to n of the messages received on work, and sends them to out in the
+// order of their priorities and deadlines. The buffered messages are pushed back onto the
+// queue once the server stops, or the consumer exits.
+func (s *Server) prefetch(ctx context.Context, work <-chan []byte, out chan<- []byte, n int, queue string, done <-chan struct{}) {
+	var (
+		h   deadlineHeap
+		seq uint64
+	)
+	defer func() {
+		for _, m := range h {
+			s.pushBack(m.b, queue)
+		}
+	}()
+
+	for {
+		var (
+			in   = work
+			send chan<- []byte
+			next []byte
+		)
+		if len(h) >= n {
+			in = nil
+		}
+		if len(h) > 0 {
+			send, next = out, h[0].b
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case b := <-in:
+			var k deadlineKey
+			msgpack.Unmarshal(b, &k)
+			seq++
+			heap.Push(&h, prefetched{priority: k.Priority, deadline: k.Deadline, seq: seq, b: b})
+		case send <- next:
+			heap.Pop(&h)
+		}
+	}
+}
+
+func (s *Server) statusMissed(ctx context.Context, t JobMessage) error {
+	var span spans.Span
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "status_missed")
+		defer span.End()
+	}
+
+	t.ProcessedAt = s.clock.Now()
+	t.Status = StatusMissed
+
+	if err := s.setJobMessage(ctx, t); err != nil {
+		s.spanError(span, err)
+		return err
+	}
+
+	s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",queue="%s"}`, metricJobsMissed, t.Job.Task, t.Queue)).Inc()
+
+	s.deletePayload(ctx, t)
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPrefetchDeadlines(t *testing.T) {
+	var (
+		now    = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := func(uuid string, deadline time.Time) []byte {
+		b, err := msgpack.Marshal(JobMessage{Meta: Meta{UUID: uuid, Deadline: deadline}, Job: &Job{Task: taskName}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	uuid := func(b []byte) string {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.UUID
+	}
+
+	var (
+		ctx  = context.Background()
+		work = make(chan []byte)
+		out  = make(chan []byte)
+		done = make(chan struct{})
+		exit = make(chan struct{})
+	)
+	go func() {
+		srv.prefetch(ctx, work, out, 4, DefaultQueue, done)
+		close(exit)
+	}()
+
+	// The prefetched jobs are processed by the earliest deadline, and the jobs without a
+	// deadline after them.
+	work <- message("none", time.Time{})
+	work <- message("later", now.Add(time.Hour))
+	work <- message("sooner", now.Add(time.Minute))
+	work <- message("none-2", time.Time{})
+	for _, u := range []string{"sooner", "later", "none"} {
+		if got := uuid(<-out); got != u {
+			t.Fatalf("expected %s, got %s", u, got)
+		}
+	}
+
+	// The buffered jobs are pushed back once the consumer exits.
+	close(done)
+	<-exit
+	if got := uuid(<-broker.data); got != "none-2" {
+		t.Fatalf("expected the buffered job to be pushed back, got %s", got)
+	}
+}
+
+func TestMissedDeadline(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		ran    bool
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		ran = true
+		return nil
+	}, TaskOpts{})
+
+	job, err := NewJob(taskName, nil, JobOpts{Deadline: clock.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job is picked up after its deadline.
+	clock.advance(time.Minute * 2)
+	srv.Process(ctx, <-broker.data)
+	if ran {
+		t.Fatal("expected the job not to be executed after its deadline")
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusMissed {
+		t.Fatalf("expected status %s, got %s", StatusMissed, msg.Status)
+	}
+}
@@ -0,0 +1,64 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+)
+
+// debouncePrefix prefixes the gate on which the jobs of a debounce key are held.
+const debouncePrefix = "tasqueue:debounce:"
+
+// debounce coalesces a job with the job of the same debounce key that's held for the debounce
+// window, if any, by replacing the held job's payload. It returns the held job's UUID and true
+// if the job was coalesced. Otherwise, the job is set to be held on the key's gate until the
+// window passes. The key's gate is locked on the server's Locker, and the returned function
+// releases it, once the job is held.
+func (s *Server) debounce(ctx context.Context, msg *JobMessage, key string) (string, bool, func(), error) {
+	if s.results == nil {
+		return "", false, nil, ErrNoResults
+	}
+
+	gate := debouncePrefix + key
+	unlock, err := s.lockGate(ctx, gate)
+	if err != nil {
+		return "", false, nil, err
+	}
+
+	uuid, ok, err := s.coalesce(ctx, msg, gate)
+	if err != nil {
+		unlock()
+		return "", false, nil, err
+	}
+
+	return uuid, ok, unlock, nil
+}
+
+// coalesce replaces the payload of the job held on the gate with the job's, if any.
+func (s *Server) coalesce(ctx context.Context, msg *JobMessage, gate string) (string, bool, error) {
+	uuids, err := getTag(ctx, s.results, gatePrefix+gate)
+	if err != nil {
+		return "", false, err
+	}
+	for _, uuid := range uuids {
+		held, err := s.getJob(ctx, uuid, false)
+		if err != nil {
+			return "", false, err
+		}
+		if held.Status != StatusHeld {
+			continue
+		}
+
+		s.log.Debug("coalescing debounced job", "uuid", uuid, "gate", gate)
+		s.deletePayload(ctx, held)
+		held.Job.Payload, held.PayloadEncoding = msg.Job.Payload, msg.PayloadEncoding
+		if err := s.setJobMessage(ctx, held); err != nil {
+			return "", false, fmt.Errorf("could not coalesce debounced job : %w", err)
+		}
+		return uuid, true, nil
+	}
+
+	msg.Gate = gate
+	msg.HeldUntil = msg.EnqueuedAt.Add(msg.Job.Opts.DebounceWindow)
+
+	return "", false, nil
+}
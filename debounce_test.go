@@ -0,0 +1,123 @@
+package tasqueue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestDebounce(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		clock = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go srv.runReleases(rctx)
+
+	enqueue := func(payload string) string {
+		job, err := NewJob(taskName, []byte(payload), JobOpts{DebounceKey: "doc:1", DebounceWindow: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuid
+	}
+
+	// The jobs enqueued within the window are coalesced into the first.
+	uuid := enqueue("1")
+	for _, p := range []string{"2", "3"} {
+		if u := enqueue(p); u != uuid {
+			t.Fatalf("expected debounced job to be coalesced into %s, got %s", uuid, u)
+		}
+	}
+	msgs, err := srv.GetPending(ctx, DefaultQueue, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("expected no pending jobs within the window, got %d", len(msgs))
+	}
+
+	// The job is enqueued with the latest payload after the window.
+	clock.wait(t)
+	clock.advance(time.Minute)
+	for i := 0; i < 100 && len(msgs) == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		if msgs, err = srv.GetPending(ctx, DefaultQueue, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(msgs) != 1 || msgs[0].UUID != uuid || string(msgs[0].Job.Payload) != "3" {
+		t.Fatalf("expected the debounced job to be enqueued with the latest payload, got %v", msgs)
+	}
+
+	// Jobs enqueued after the window are debounced anew.
+	if u := enqueue("4"); u == uuid {
+		t.Fatal("expected a new job to be debounced after the window")
+	}
+}
+
+// slowResults is a results store whose tag lookups are slow.
+type slowResults struct {
+	*rr.Results
+}
+
+func (r slowResults) GetTag(ctx context.Context, tag string) ([]string, error) {
+	time.Sleep(time.Millisecond * 10)
+	return r.Results.GetTag(ctx, tag)
+}
+
+func TestDebounceConcurrent(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: slowResults{NewMockResults()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs of the key enqueued concurrently are coalesced into one.
+	var (
+		wg    sync.WaitGroup
+		uuids = make([]string, 10)
+	)
+	for i := range uuids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			job, err := NewJob(taskName, []byte(strconv.Itoa(i)), JobOpts{DebounceKey: "doc:1", DebounceWindow: time.Minute})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if uuids[i], err = srv.Enqueue(ctx, job); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, u := range uuids {
+		if u != uuids[0] {
+			t.Fatalf("expected the jobs to be coalesced into one, got %v", uuids)
+		}
+	}
+	held, err := getTag(ctx, srv.results, gatePrefix+debouncePrefix+"doc:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 1 {
+		t.Fatalf("expected one held job, got %v", held)
+	}
+}
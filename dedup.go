@@ -0,0 +1,78 @@
+package tasqueue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// dedupPrefix prefixes the key of the lock claimed by a job for its dedup window, and the
+// tag that holds its UUID.
+const dedupPrefix = "tasqueue:dedup:"
+
+var (
+	// ErrDuplicateJob is returned on enqueuing a job identical to a job enqueued within its
+	// dedup window, whose UUID couldn't be looked up.
+	ErrDuplicateJob = errors.New("duplicate job")
+	// ErrDedupLocker is returned on enqueuing a job with a dedup window, if the server's Locker
+	// isn't set, as the windows claimed on a local locker aren't seen by other servers.
+	ErrDedupLocker = errors.New("deduplication requires a shared locker")
+)
+
+// dedupKey returns the key identical jobs are deduplicated by, ie: the hash of the job's task
+// and payload.
+func dedupKey(t Job) string {
+	h := sha256.New()
+	h.Write([]byte(t.Task))
+	h.Write([]byte{0})
+	h.Write(t.Payload)
+
+	return dedupPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// dedup returns the UUID of the identical job enqueued within the job's dedup window and true,
+// if there's one. Otherwise, it claims the window for the job, and returns the function that
+// releases the claim, if the job couldn't be enqueued. The window is claimed atomically on the
+// server's Locker, which has to be shared by the servers enqueuing the job.
+func (s *Server) dedup(ctx context.Context, msg JobMessage, key string) (string, func(), bool, error) {
+	if s.results == nil {
+		return "", nil, false, ErrNoResults
+	}
+	if !s.sharedLocker {
+		return "", nil, false, ErrDedupLocker
+	}
+
+	ok, err := s.locker.Lock(ctx, key, msg.UUID, msg.Job.Opts.DedupWindow)
+	if err != nil {
+		return "", nil, false, err
+	}
+	uuids, err := getTag(ctx, s.results, key)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if !ok {
+		if len(uuids) == 0 {
+			return "", nil, false, ErrDuplicateJob
+		}
+		s.log.Debug("deduplicating job", "uuid", uuids[len(uuids)-1], "task", msg.Job.Task)
+		return uuids[len(uuids)-1], nil, true, nil
+	}
+
+	// The tag only holds the UUID of the job that claimed the latest window.
+	for _, uuid := range uuids {
+		if err := deleteTag(ctx, s.results, key, uuid); err != nil {
+			return "", nil, false, err
+		}
+	}
+	if err := setTag(ctx, s.results, key, msg.UUID); err != nil {
+		return "", nil, false, err
+	}
+
+	return "", func() {
+		// The enqueue's context may be cancelled, hence a new one is used.
+		if err := s.locker.Unlock(context.Background(), key, msg.UUID); err != nil {
+			s.log.Error("could not release dedup window", "uuid", msg.UUID, "error", err)
+		}
+	}, false, nil
+}
@@ -0,0 +1,97 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, Locker: newLocalLocker(clock)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error { return nil }, TaskOpts{DedupWindow: time.Minute})
+
+	enqueue := func(payload string) string {
+		job, err := NewJob(taskName, []byte(payload), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuid
+	}
+
+	// Identical jobs within the window are deduplicated, others aren't.
+	uuid := enqueue("a")
+	if u := enqueue("a"); u != uuid {
+		t.Fatalf("expected the identical job to be deduplicated into %s, got %s", uuid, u)
+	}
+	if u := enqueue("b"); u == uuid {
+		t.Fatal("expected a job with another payload to be enqueued")
+	}
+	if pending := len(broker.data); pending != 2 {
+		t.Fatalf("expected 2 enqueued jobs, got %d", pending)
+	}
+
+	// Once the window passes, the identical job is enqueued again.
+	clock.advance(time.Minute + time.Second)
+	u := enqueue("a")
+	if u == uuid {
+		t.Fatal("expected the identical job to be enqueued after the window")
+	}
+	if d := enqueue("a"); d != u {
+		t.Fatalf("expected the identical job to be deduplicated into %s, got %s", u, d)
+	}
+}
+
+// failBroker is a broker whose enqueues fail with err.
+type failBroker struct {
+	Broker
+	err error
+}
+
+func (b failBroker) Enqueue(context.Context, []byte, string) error {
+	return b.err
+}
+
+func TestDedupEnqueueError(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		errDown = errors.New("down")
+		broker  = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: failBroker{Broker: broker, err: errDown}, Results: NewMockResults(), Locker: newLocalLocker(systemClock{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{DedupWindow: time.Minute})
+
+	// The window claimed by a job that couldn't be enqueued is released.
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); !errors.Is(err, errDown) {
+		t.Fatalf("expected %v, got %v", errDown, err)
+	}
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); !errors.Is(err, errDown) {
+		t.Fatalf("expected the identical job to be enqueued again, got %v", err)
+	}
+}
+
+func TestDedupLocker(t *testing.T) {
+	srv := newServer(t)
+	job := makeJob(t, false)
+	job.Opts.DedupWindow = time.Minute
+
+	// Windows aren't claimed on a locker local to the server.
+	if _, err := srv.Enqueue(context.Background(), job); !errors.Is(err, ErrDedupLocker) {
+		t.Fatalf("expected %v, got %v", ErrDedupLocker, err)
+	}
+}
@@ -0,0 +1,172 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// delayedKey is the index of the delayed jobs on the results store, by the times they're due.
+	delayedKey = "tasqueue:delayed"
+	// delayedBatch is the max number of due jobs moved onto their queues at a time.
+	delayedBatch = 100
+
+	defaultDelayedPeriod   = time.Second
+	defaultMaxRetryBackoff = time.Hour
+)
+
+// ErrDelayUnsupported is returned on enqueuing a job with an ETA, if the results store doesn't
+// implement Delayer.
+var ErrDelayUnsupported = errors.New("results store doesn't support delayed jobs")
+
+// validateETA checks that the job's ETA can be held on the results store.
+func (s *Server) validateETA(o JobOpts) error {
+	if o.ETA.IsZero() {
+		return nil
+	}
+	if o.Gate != "" || o.Schedule != "" || o.DebounceKey != "" {
+		return errors.New("delayed jobs can not be gated, scheduled or debounced")
+	}
+	if s.results == nil {
+		return ErrNoResults
+	}
+	if _, ok := s.results.(Delayer); !ok {
+		return ErrDelayUnsupported
+	}
+
+	return nil
+}
+
+// retryBackoff returns the delay before the job's next retry, which doubles from the task's
+// RetryBackoff with each retry, upto its MaxRetryBackoff.
+func (t Task) retryBackoff(retried uint32) time.Duration {
+	d := t.opts.RetryBackoff
+	for i := uint32(0); i < retried && d < t.opts.MaxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > t.opts.MaxRetryBackoff {
+		d = t.opts.MaxRetryBackoff
+	}
+
+	return d
+}
+
+// delayJob holds the job on the delayed index until its ETA, after which it's moved onto its
+// queue by runDelayed(). The job's status is set by the caller.
+func (s *Server) delayJob(ctx context.Context, msg JobMessage) error {
+	d, ok := s.results.(Delayer)
+	if !ok {
+		return ErrDelayUnsupported
+	}
+	s.log.Debug("delaying job", "uuid", msg.UUID, "eta", msg.ETA)
+
+	return d.AddDelayed(ctx, delayedKey, msg.UUID, msg.ETA)
+}
+
+// retryLater delays the retry of the job until its ETA. Without a results store that
+// implements Delayer, the retry isn't delayed, as it can't be held anywhere it outlives the
+// server.
+func (s *Server) retryLater(ctx context.Context, msg JobMessage, b []byte) error {
+	err := ErrDelayUnsupported
+	if s.results != nil {
+		err = s.delayJob(ctx, msg)
+	}
+	if errors.Is(err, ErrDelayUnsupported) {
+		s.log.Warn("results store doesn't support delays, retrying job without its backoff", "uuid", msg.UUID)
+		return enqueuePriority(ctx, s.broker, b, msg.Queue, msg.Priority)
+	}
+
+	return err
+}
+
+// MoveDelayed() moves the delayed jobs (and retries) that are due onto their queues, and
+// returns the number of jobs moved. It's run periodically by the started server (see
+// ServerOpts.DelayedPeriod). Delayed jobs which were cancelled or no longer exist are dropped.
+func (s *Server) MoveDelayed(ctx context.Context) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+	d, ok := s.results.(Delayer)
+	if !ok {
+		return 0, ErrDelayUnsupported
+	}
+
+	var (
+		now = s.clock.Now()
+		n   int
+	)
+	for {
+		uuids, err := d.PopDue(ctx, delayedKey, now, delayedBatch)
+		if err != nil {
+			return n, err
+		}
+
+		for _, uuid := range uuids {
+			msg, err := s.getJob(ctx, uuid, false)
+			if errors.Is(err, ErrNotFound) {
+				s.log.Error("dropping delayed job that no longer exists", "uuid", uuid)
+				continue
+			}
+			if err != nil {
+				// The job is put back on the index, due after the delayed period so that it
+				// isn't popped again by this run, to be moved on a later run.
+				s.log.Error("could not get delayed job", "uuid", uuid, "error", err)
+				if err := d.AddDelayed(ctx, delayedKey, uuid, now.Add(s.delayedPeriod)); err != nil {
+					return n, err
+				}
+				continue
+			}
+			if msg.Status == StatusCancelled {
+				continue
+			}
+
+			s.log.Debug("moving delayed job", "uuid", uuid, "queue", msg.Queue)
+			b, err := msgpack.Marshal(msg)
+			if err == nil {
+				err = enqueuePriority(ctx, s.broker, b, msg.Queue, msg.Priority)
+			}
+			if err != nil {
+				// The job is put back on the index, to be moved on the next run.
+				if derr := d.AddDelayed(ctx, delayedKey, uuid, msg.ETA); derr != nil {
+					s.log.Error("could not restore delayed job", "uuid", uuid, "error", derr)
+				}
+				return n, err
+			}
+			// Retries were counted when they failed.
+			if msg.Status != StatusRetrying {
+				s.countStat(ctx, msg, statEnqueued, 0)
+			}
+			n++
+		}
+
+		if len(uuids) < delayedBatch {
+			return n, nil
+		}
+	}
+}
+
+// runDelayed periodically moves the delayed jobs that are due onto their queues until the
+// context is cancelled. It is a blocking function.
+func (s *Server) runDelayed(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.delayedPeriod):
+			n, err := s.MoveDelayed(ctx)
+			// A namespaced store implements Delayer regardless of the store it wraps.
+			if errors.Is(err, ErrDelayUnsupported) {
+				return
+			}
+			if err != nil {
+				s.log.Error("error moving delayed jobs", "error", err)
+			}
+			if n > 0 {
+				s.log.Debug("moved delayed jobs", "count", n)
+			}
+		}
+	}
+}
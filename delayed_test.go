@@ -0,0 +1,190 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestDelayedJob(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	job, err := NewJob(taskName, nil, JobOpts{ETA: clock.Now().Add(time.Minute), Gate: "approval"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err == nil {
+		t.Fatal("expected a gated job with an ETA to be rejected")
+	}
+
+	job = makeJob(t, false)
+	job.Opts.ETA = clock.Now().Add(time.Minute)
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.data) != 0 {
+		t.Fatal("expected the job to be held until its ETA")
+	}
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no jobs to be due, got %d : %v", n, err)
+	}
+
+	clock.advance(time.Minute)
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the job to be moved onto its queue, got %d : %v", n, err)
+	}
+	srv.Process(ctx, <-broker.data)
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone {
+		t.Fatalf("expected the delayed job to be done, got %s", msg.Status)
+	}
+
+	// Without a store that implements Delayer, delayed jobs are rejected.
+	srv, err = NewServer(ServerOpts{Broker: broker})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err = NewJob(taskName, nil, JobOpts{ETA: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); !errors.Is(err, ErrNoResults) {
+		t.Fatalf("expected %v, got %v", ErrNoResults, err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{RetryBackoff: time.Second * 10, MaxRetryBackoff: time.Second * 30})
+
+	task, err := srv.getHandler(taskName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for retried, d := range []time.Duration{time.Second * 10, time.Second * 20, time.Second * 30, time.Second * 30} {
+		if b := task.retryBackoff(uint32(retried)); b != d {
+			t.Fatalf("expected a backoff of %v after %d retries, got %v", d, retried, b)
+		}
+	}
+
+	job := makeJob(t, true)
+	job.Opts.MaxRetries = 2
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusRetrying || !msg.ETA.Equal(clock.Now().Add(time.Second*10)) {
+		t.Fatalf("expected the retry to be delayed by the backoff, got %s at %v", msg.Status, msg.ETA)
+	}
+	if len(broker.data) != 0 {
+		t.Fatal("expected the retry to be held until its backoff passes")
+	}
+
+	clock.advance(time.Second * 10)
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the retry to be moved onto its queue, got %d : %v", n, err)
+	}
+	srv.Process(ctx, <-broker.data)
+	if msg, err = srv.GetJob(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Retried != 2 || !msg.ETA.Equal(clock.Now().Add(time.Second*20)) {
+		t.Fatalf("expected the second retry to be delayed by twice the backoff, got %v", msg.ETA)
+	}
+}
+
+// flakyResults is a results store whose gets fail while err is set.
+type flakyResults struct {
+	*rr.Results
+	err error
+}
+
+func (r *flakyResults) Get(ctx context.Context, uuid string) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.Results.Get(ctx, uuid)
+}
+
+func TestMoveDelayedGetError(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = &flakyResults{Results: NewMockResults()}
+		clock   = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	job := makeJob(t, false)
+	job.Opts.ETA = clock.Now().Add(time.Minute)
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Minute)
+
+	// A job that can't be looked up is kept on the index, and moved on a run after the
+	// delayed period.
+	results.err = errors.New("down")
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no jobs to be moved, got %d : %v", n, err)
+	}
+	results.err = nil
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 0 {
+		t.Fatalf("expected the job to be held for the delayed period, got %d : %v", n, err)
+	}
+	clock.advance(defaultDelayedPeriod)
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the job to be moved onto its queue, got %d : %v", n, err)
+	}
+
+	// A job that no longer exists is dropped from the index.
+	job = makeJob(t, false)
+	job.Opts.ETA = clock.Now().Add(time.Minute)
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := results.Delete(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Minute)
+	if n, err := srv.MoveDelayed(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no jobs to be moved, got %d : %v", n, err)
+	}
+	if due, err := results.PopDue(ctx, delayedKey, clock.Now().Add(time.Hour), delayedBatch); err != nil || len(due) != 0 {
+		t.Fatalf("expected the job to be dropped from the index, got %v (%v)", due, err)
+	}
+}
@@ -0,0 +1,25 @@
+package tasqueue
+
+import "fmt"
+
+// demote moves the job being retried onto the task's retry queue, once it has been retried
+// DemoteAfter times, so that a flood of failing retries doesn't starve the task's fresh jobs.
+func (s *Server) demote(task Task, msg JobMessage) JobMessage {
+	if task.opts.RetryQueue == "" || msg.Retried < task.opts.DemoteAfter || msg.Queue == task.opts.RetryQueue {
+		return msg
+	}
+
+	s.log.Debug("demoting job onto retry queue", "uuid", msg.UUID, "queue", msg.Queue, "retry_queue", task.opts.RetryQueue)
+	s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",queue="%s"}`, metricJobsDemoted, msg.Job.Task, msg.Queue)).Inc()
+	msg.Queue = task.opts.RetryQueue
+
+	return msg
+}
+
+// concurrency returns the number of processors of the task's queue.
+func (t Task) concurrency(queue string) uint32 {
+	if t.opts.RetryQueue != "" && queue == t.opts.RetryQueue {
+		return t.opts.RetryConcurrency
+	}
+	return t.opts.Concurrency
+}
@@ -0,0 +1,66 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDemoteRetries(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{RetryQueue: "retries", DemoteAfter: 1})
+
+	// The task consumes its retry queue as well.
+	srv.p.RLock()
+	task := srv.tasks[taskName]
+	srv.p.RUnlock()
+	if q := task.queues(); !reflect.DeepEqual(q, []string{DefaultQueue, "retries"}) {
+		t.Fatalf("unexpected queues %v", q)
+	}
+	if task.concurrency("retries") != 1 {
+		t.Fatalf("expected the retry queue to default to one processor, got %d", task.concurrency("retries"))
+	}
+
+	b, err := json.Marshal(MockPayload{ShouldErr: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewJob(taskName, b, JobOpts{MaxRetries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first retry stays on the job's queue, the later ones are demoted.
+	for i, queue := range []string{DefaultQueue, "retries", "retries"} {
+		m := <-broker.data
+		broker.remove(m)
+		srv.Process(ctx, m)
+
+		msgs, err := srv.GetPending(ctx, queue, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || msgs[0].UUID != uuid {
+			t.Fatalf("expected retry %d on queue %s, got %d jobs", i+1, queue, len(msgs))
+		}
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Queue != queue || msg.Status != StatusRetrying {
+			t.Fatalf("expected the job to be retrying on %s, got %s on %s", queue, msg.Status, msg.Queue)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrNoProvider is returned on resolving a dependency whose type isn't provided on the server.
+var ErrNoProvider = errors.New("dependency not provided")
+
+// providers is the registry of the dependencies provided to the handlers on a server, by type.
+type providers struct {
+	mu    sync.RWMutex
+	provs map[reflect.Type]*provider
+}
+
+// provider lazily loads a dependency, once it's first resolved. Errors aren't cached.
+type provider struct {
+	fn func(context.Context) (any, error)
+
+	mu     sync.Mutex
+	loaded bool
+	val    any
+}
+
+func newProviders() *providers {
+	return &providers{provs: make(map[reflect.Type]*provider)}
+}
+
+// Provide registers fn as the provider of the dependencies of type T on the server, which are
+// resolved by Resolve() and Inject() handlers. The dependency is loaded once, with the context
+// of the job which first resolves it, and is shared by the handlers, hence it should be safe
+// for concurrent use. Errors aren't cached, and are returned to the resolving handlers.
+// Providing a type again replaces its provider.
+func Provide[T any](s *Server, fn func(ctx context.Context) (T, error)) {
+	s.deps.mu.Lock()
+	s.deps.provs[typeOf[T]()] = &provider{fn: func(ctx context.Context) (any, error) {
+		return fn(ctx)
+	}}
+	s.deps.mu.Unlock()
+}
+
+// Resolve returns the dependency of type T provided on the job's server.
+func Resolve[T any](c JobCtx) (T, error) {
+	var d T
+	v, err := c.deps.resolve(c, typeOf[T]())
+	if err != nil {
+		return d, err
+	}
+	return v.Interface().(T), nil
+}
+
+// Inject returns a handler which resolves the handler's dependencies, of type D, and decodes the
+// job's payload (JSON) into P (unless it's a []byte) before calling fn. If D isn't provided on the
+// server and is a struct, each of its exported fields is resolved by its type instead, so that
+// handlers can declare their dependencies in a struct. Payloads that don't decode fail the job
+// without retries, while failures to resolve the dependencies are retried.
+func Inject[D, P any](fn func(deps D, payload P, c JobCtx) error) func([]byte, JobCtx) error {
+	return func(b []byte, c JobCtx) error {
+		var p P
+		if raw, ok := any(&p).(*[]byte); ok {
+			*raw = b
+		} else if len(b) > 0 {
+			if err := json.Unmarshal(b, &p); err != nil {
+				return fmt.Errorf("could not decode payload : %v : %w", err, ErrSkipRetry)
+			}
+		}
+
+		deps, err := Resolve[D](c)
+		if err != nil {
+			return err
+		}
+
+		return fn(deps, p, c)
+	}
+}
+
+// resolve returns the dependency of the type, or a struct of the type with its exported
+// fields resolved, if the type isn't provided.
+func (p *providers) resolve(ctx context.Context, t reflect.Type) (reflect.Value, error) {
+	if v, ok, err := p.load(ctx, t); ok || err != nil {
+		return v, err
+	}
+	if t.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%v : %w", t, ErrNoProvider)
+	}
+
+	s := reflect.New(t).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		v, ok, err := p.load(ctx, f.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("error resolving %v.%s : %w", t, f.Name, err)
+		}
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%v.%s (%v) : %w", t, f.Name, f.Type, ErrNoProvider)
+		}
+		s.Field(i).Set(v)
+	}
+
+	return s, nil
+}
+
+// load returns the dependency of the type, loading it if it isn't loaded, and whether the
+// type is provided.
+func (p *providers) load(ctx context.Context, t reflect.Type) (reflect.Value, bool, error) {
+	if p == nil {
+		return reflect.Value{}, false, nil
+	}
+	p.mu.RLock()
+	prov, ok := p.provs[t]
+	p.mu.RUnlock()
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if !prov.loaded {
+		v, err := prov.fn(ctx)
+		if err != nil {
+			return reflect.Value{}, true, err
+		}
+		prov.val, prov.loaded = v, true
+	}
+
+	// A nil dependency (eg: a nil interface) is resolved to the type's zero value.
+	if prov.val == nil {
+		return reflect.Zero(t), true, nil
+	}
+	return reflect.ValueOf(prov.val), true, nil
+}
+
+// typeOf returns the type T, including interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,103 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type greeter interface {
+	Greet(string) string
+}
+
+type prefixGreeter string
+
+func (g prefixGreeter) Greet(name string) string {
+	return string(g) + " " + name
+}
+
+func TestInject(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		loads  int
+		greets = make(chan string, 1)
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Provide(srv, func(ctx context.Context) (greeter, error) {
+		loads++
+		return prefixGreeter("hello"), nil
+	})
+	Provide(srv, func(ctx context.Context) (int, error) {
+		return 0, errors.New("unavailable")
+	})
+
+	type deps struct {
+		Greeter greeter
+	}
+	type payload struct {
+		Name string `json:"name"`
+	}
+	srv.RegisterTask("greet", Inject(func(d deps, p payload, c JobCtx) error {
+		greets <- d.Greeter.Greet(p.Name)
+		return nil
+	}), TaskOpts{})
+
+	// Dependencies are loaded once, and shared by the jobs.
+	for i := 0; i < 2; i++ {
+		job, err := NewJob("greet", []byte(`{"name": "gopher"}`), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+		if g := <-greets; g != "hello gopher" {
+			t.Fatalf("expected the injected greeter to be called, got %q", g)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected the dependency to be loaded once, got %d", loads)
+	}
+
+	// Dependencies that aren't provided, or fail to load, fail the job.
+	var errs []error
+	srv.RegisterTask("missing", Inject(func(d struct{ Name string }, b []byte, c JobCtx) error {
+		return nil
+	}), TaskOpts{})
+	srv.RegisterTask("failing", func(b []byte, c JobCtx) error {
+		_, err := Resolve[int](c)
+		errs = append(errs, err)
+		return err
+	}, TaskOpts{})
+	srv.RegisterTask("unresolved", func(b []byte, c JobCtx) error {
+		_, err := Resolve[string](c)
+		errs = append(errs, err)
+		return err
+	}, TaskOpts{})
+	for _, task := range []string{"missing", "failing", "unresolved"} {
+		job, err := NewJob(task, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != StatusFailed {
+			t.Fatalf("expected the job of %s to fail, got %s", task, msg.Status)
+		}
+	}
+	if len(errs) != 2 || errs[0] == nil || !errors.Is(errs[1], ErrNoProvider) {
+		t.Fatalf("expected the dependencies not to resolve, got %v", errs)
+	}
+}
@@ -0,0 +1,90 @@
+package tasqueue
+
+import (
+	"context"
+	"time"
+)
+
+// drainPollPeriod is the period at which a draining queue is checked for pending jobs.
+const drainPollPeriod = time.Second
+
+// DrainQueue() stops new jobs from being enqueued onto the queue (with ErrQueueDraining),
+// while the jobs already in the queue are processed. It blocks until the queue is empty and the
+// server isn't processing (or holding back) any of its jobs, eg: to migrate the queue to another
+// broker or retire its task. Only the jobs enqueued through this server are rejected, so
+// other producers of the queue should be stopped. The queue stays closed to new jobs until
+// ResumeQueue() is called. Draining requires the broker to implement Peeker.
+func (s *Server) DrainQueue(ctx context.Context, queue string) error {
+	s.qmu.Lock()
+	s.draining[queue] = struct{}{}
+	s.qmu.Unlock()
+	s.recordAudit(ctx, OpDrain, queue, "")
+
+	tk := time.NewTicker(drainPollPeriod)
+	defer tk.Stop()
+
+	// The queue is considered drained once it's empty on two consecutive checks, as a
+	// job may be in transit between the broker's consumer and a processor on one check.
+	empty := 0
+	for {
+		ok, err := s.isDrained(ctx, queue)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			empty = 0
+		} else if empty++; empty == 2 {
+			s.log.Info("queue drained", "queue", queue)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tk.C:
+		}
+	}
+}
+
+// ResumeQueue() allows jobs to be enqueued onto a queue that was drained.
+func (s *Server) ResumeQueue(queue string) {
+	s.qmu.Lock()
+	delete(s.draining, queue)
+	s.qmu.Unlock()
+	s.recordAudit(context.Background(), OpResumeQueue, queue, "")
+}
+
+// isDrained returns true if the queue has no pending jobs, and none of its jobs are
+// being processed or held back by the server.
+func (s *Server) isDrained(ctx context.Context, queue string) (bool, error) {
+	s.qmu.Lock()
+	n := s.inflight[queue]
+	s.qmu.Unlock()
+	if n > 0 {
+		return false, nil
+	}
+
+	msgs, err := peek(ctx, s.broker, queue, 1)
+	if err != nil {
+		return false, err
+	}
+
+	return len(msgs) == 0, nil
+}
+
+func (s *Server) isDraining(queue string) bool {
+	s.qmu.Lock()
+	_, ok := s.draining[queue]
+	s.qmu.Unlock()
+
+	return ok
+}
+
+// track adds n to the number of the queue's jobs that are being processed or held back.
+func (s *Server) track(queue string, n int) {
+	s.qmu.Lock()
+	if s.inflight[queue] += n; s.inflight[queue] == 0 {
+		delete(s.inflight, queue)
+	}
+	s.qmu.Unlock()
+}
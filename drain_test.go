@@ -0,0 +1,53 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrainQueue(t *testing.T) {
+	var (
+		srv = newServer(t)
+		n   int32
+	)
+	srv.RegisterTask("slow", func(b []byte, _ JobCtx) error {
+		time.Sleep(time.Millisecond * 100)
+		atomic.AddInt32(&n, 1)
+		return nil
+	}, TaskOpts{Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		job, err := NewJob("slow", nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+
+	dctx, dcancel := context.WithTimeout(ctx, time.Second*10)
+	defer dcancel()
+	if err := srv.DrainQueue(dctx, DefaultQueue); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&n); n != 5 {
+		t.Fatalf("expected the queue's 5 jobs to be processed, got %d", n)
+	}
+
+	// The drained queue is closed to new jobs until it's resumed.
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); !errors.Is(err, ErrQueueDraining) {
+		t.Fatalf("expected %v enqueuing onto a drained queue, got %v", ErrQueueDraining, err)
+	}
+	srv.ResumeQueue(DefaultQueue)
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,46 @@
+package tasqueue
+
+import "time"
+
+// Event is emitted whenever a job's status changes.
+type Event struct {
+	Job  JobMessage
+	Time time.Time
+}
+
+// OnEvent registers a function that is called on each job status change. Functions are
+// called synchronously, hence they should hand off slow work (eg: network calls) to a goroutine.
+func (s *Server) OnEvent(fn func(Event)) {
+	s.lmu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.lmu.Unlock()
+}
+
+// emit calls the registered listeners with the job message, and wakes its waiters.
+func (s *Server) emit(msg JobMessage) {
+	s.wake(msg.UUID)
+
+	s.lmu.RLock()
+	listeners := s.listeners
+	s.lmu.RUnlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	e := Event{Job: msg, Time: s.clock.Now()}
+	for _, fn := range listeners {
+		fn(e)
+	}
+}
+
+// IsFinal returns true if the status is a final state, ie: the job won't be processed further
+// (unless it is explicitly retried).
+func IsFinal(status string) bool {
+	switch status {
+	case StatusDone, StatusFailed, StatusCancelled, StatusExpired, StatusMissed, StatusDropped:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOnEvent(t *testing.T) {
+	var (
+		srv    = newServer(t)
+		events = make(chan Event, 10)
+	)
+	srv.OnEvent(func(e Event) {
+		events <- e
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []string{StatusStarted, StatusProcessing, StatusDone} {
+		select {
+		case e := <-events:
+			if e.Job.UUID != uuid || e.Job.Status != status {
+				t.Fatalf("incorrect event, expected %s, got %s", status, e.Job.Status)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event for status %s not received", status)
+		}
+	}
+
+	if !IsFinal(StatusDone) || IsFinal(StatusRetrying) {
+		t.Fatal("incorrect final statuses")
+	}
+}
+
+func TestCancelledEvent(t *testing.T) {
+	var (
+		srv    = newServer(t)
+		cl     = newClient(t, srv)
+		events = make(chan Event, 10)
+	)
+	srv.OnEvent(func(e Event) {
+		events <- e
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The job is cancelled by the client, and the server emits the cancellation as it skips it.
+	uuid, err := cl.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Cancel(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start(ctx)
+
+	select {
+	case e := <-events:
+		if e.Job.UUID != uuid || e.Job.Status != StatusCancelled {
+			t.Fatalf("incorrect event, expected %s, got %s", StatusCancelled, e.Job.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled event not received")
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %s", e.Job.Status)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/kalbhor/tasqueue"
@@ -74,7 +73,7 @@ func main() {
 	}()
 	otel.SetTracerProvider(tp)
 
-	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	ctx := context.Background()
 	srv, err := tasqueue.NewServer(tasqueue.ServerOpts{
 		Broker:        rb.New(),
 		Results:       rr.New(),
@@ -108,7 +107,8 @@ func main() {
 			}
 		}
 	}()
-	srv.Start(ctx)
+	// Drains the server on SIGINT/SIGTERM, and dumps the in-flight jobs on SIGUSR1.
+	tasqueue.Run(srv, tasqueue.RunOpts{Dump: os.Stderr})
 
 	// Create a task payload.
 	fmt.Println("exit..")
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
 
 	"github.com/kalbhor/tasqueue"
 	nats_broker "github.com/kalbhor/tasqueue/brokers/nats-js"
@@ -16,11 +15,10 @@ import (
 )
 
 func main() {
-	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	ctx := context.Background()
 	lo := logf.New(logf.Opts{})
 	brkr, err := nats_broker.New(nats_broker.Options{
-		URL:         "localhost:4222",
-		EnabledAuth: false,
+		URL: "localhost:4222",
 		Streams: map[string][]string{
 			"default": {tasqueue.DefaultQueue},
 		},
@@ -30,8 +28,7 @@ func main() {
 	}
 
 	res, err := nats_result.New(nats_result.Options{
-		URL:         "localhost:4222",
-		EnabledAuth: false,
+		URL: "localhost:4222",
 	}, lo)
 	if err != nil {
 		log.Fatal(err)
@@ -64,7 +61,8 @@ func main() {
 	t, _ := tasqueue.NewChain(chain...)
 	srv.EnqueueChain(ctx, t)
 
-	srv.Start(ctx)
+	// Drains the server on SIGINT/SIGTERM, and dumps the in-flight jobs on SIGUSR1.
+	tasqueue.Run(srv, tasqueue.RunOpts{Dump: os.Stderr})
 
 	// Create a task payload.
 	fmt.Println("exit..")
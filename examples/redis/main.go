@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/kalbhor/tasqueue"
@@ -17,18 +16,16 @@ import (
 )
 
 func main() {
-	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	ctx := context.Background()
 	lo := logf.New(logf.Opts{})
 	srv, err := tasqueue.NewServer(tasqueue.ServerOpts{
 		Broker: rb.New(rb.Options{
-			Addrs:    []string{"127.0.0.1:6379"},
-			Password: "",
-			DB:       0,
+			Addrs: []string{"127.0.0.1:6379"},
+			DB:    0,
 		}, lo),
 		Results: rr.New(rr.Options{
-			Addrs:    []string{"127.0.0.1:6379"},
-			Password: "",
-			DB:       0,
+			Addrs: []string{"127.0.0.1:6379"},
+			DB:    0,
 		}, lo),
 		Logger: lo,
 	})
@@ -61,7 +58,8 @@ func main() {
 			}
 		}
 	}()
-	srv.Start(ctx)
+	// Drains the server on SIGINT/SIGTERM, and dumps the in-flight jobs on SIGUSR1.
+	tasqueue.Run(srv, tasqueue.RunOpts{Dump: os.Stderr})
 
 	// Create a task payload.
 	fmt.Println("exit..")
@@ -0,0 +1,117 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportFormat is the format in which job records are exported.
+type ExportFormat string
+
+const (
+	// ExportNDJSON exports each job record (including the payload and results) as a JSON line.
+	ExportNDJSON ExportFormat = "ndjson"
+	// ExportCSV exports the job meta as CSV rows, with a header row.
+	ExportCSV ExportFormat = "csv"
+)
+
+var csvHeader = []string{"uuid", "task", "queue", "status", "tenant", "max_retry", "retried", "error", "processed_at"}
+
+// ExportJobs() streams the records of completed jobs matching the filter to the writer
+// in the format, and returns the number of jobs exported.
+func (s *Server) ExportJobs(ctx context.Context, f JobFilter, w io.Writer, format ExportFormat) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+
+	var lists []func(context.Context) ([]string, error)
+	switch f.Status {
+	case StatusDone:
+		lists = append(lists, s.results.GetSuccess)
+	case StatusFailed:
+		lists = append(lists, s.results.GetFailed)
+	case "":
+		lists = append(lists, s.results.GetSuccess, s.results.GetFailed)
+	default:
+		return 0, fmt.Errorf("jobs can only be exported by status %s or %s", StatusDone, StatusFailed)
+	}
+
+	var (
+		enc  *json.Encoder
+		cw   *csv.Writer
+		n    int
+		save func(JobRecord) error
+	)
+	switch format {
+	case ExportNDJSON:
+		enc = json.NewEncoder(w)
+		save = func(r JobRecord) error {
+			return enc.Encode(r)
+		}
+	case ExportCSV:
+		cw = csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		save = func(r JobRecord) error {
+			return cw.Write(csvRow(r.JobMessage))
+		}
+	default:
+		return 0, fmt.Errorf("unknown export format %s", format)
+	}
+
+	for _, list := range lists {
+		uuids, err := list(ctx)
+		if err != nil {
+			return n, err
+		}
+
+		for _, uuid := range uuids {
+			msg, err := s.GetJob(ctx, uuid)
+			if err != nil {
+				return n, err
+			}
+			if !f.match(msg) {
+				continue
+			}
+
+			if err := save(s.jobRecord(ctx, msg)); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func csvRow(m JobMessage) []string {
+	var task string
+	if m.Job != nil {
+		task = m.Job.Task
+	}
+
+	return []string{
+		m.UUID,
+		task,
+		m.Queue,
+		m.Status,
+		m.Tenant,
+		strconv.FormatUint(uint64(m.MaxRetry), 10),
+		strconv.FormatUint(uint64(m.Retried), 10),
+		m.PrevErr,
+		m.ProcessedAt.Format(time.RFC3339),
+	}
+}
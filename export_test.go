@@ -0,0 +1,45 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportJobs(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+	go srv.Start(ctx)
+
+	for _, f := range []bool{false, true, true} {
+		if _, err := srv.Enqueue(ctx, makeJob(t, f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wait for jobs to be consumed & processed.
+	time.Sleep(time.Second)
+
+	var buf bytes.Buffer
+	n, err := srv.ExportJobs(ctx, JobFilter{Status: StatusFailed}, &buf, ExportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The header and a row for each failed job.
+	if lines := strings.Count(buf.String(), "\n"); n != 2 || lines != 3 {
+		t.Fatalf("expected 2 jobs exported in 3 lines, got %d jobs in %d lines", n, lines)
+	}
+
+	buf.Reset()
+	n, err = srv.ExportJobs(ctx, JobFilter{Since: time.Now().Add(-time.Minute)}, &buf, ExportNDJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); n != 3 || lines != 3 {
+		t.Fatalf("expected 3 jobs exported in 3 lines, got %d jobs in %d lines", n, lines)
+	}
+}
@@ -0,0 +1,173 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// defaultFairBuffer is the number of jobs buffered per consumer of a fair queue, if
+// ServerOpts.FairBuffer isn't set.
+const defaultFairBuffer = 16
+
+// fairShare accounts the worker time spent on the jobs of each task on the fair queues, by
+// which their consumers interleave the jobs of the tasks sharing a queue.
+type fairShare struct {
+	queues map[string]struct{}
+	buffer int
+
+	mu   sync.Mutex
+	used map[string]map[string]time.Duration
+}
+
+func newFairShare(queues []string, buffer int) *fairShare {
+	if len(queues) == 0 {
+		return nil
+	}
+	if buffer <= 0 {
+		buffer = defaultFairBuffer
+	}
+
+	f := &fairShare{queues: make(map[string]struct{}, len(queues)), buffer: buffer, used: make(map[string]map[string]time.Duration)}
+	for _, q := range queues {
+		f.queues[q] = struct{}{}
+		f.used[q] = make(map[string]time.Duration)
+	}
+
+	return f
+}
+
+// isFair returns true if the queue's jobs are dispatched fairly among its tasks.
+func (f *fairShare) isFair(queue string) bool {
+	if f == nil {
+		return false
+	}
+	_, ok := f.queues[queue]
+	return ok
+}
+
+// add accounts the worker time spent on a job of the task on the queue.
+func (f *fairShare) add(queue, task string, d time.Duration) {
+	if !f.isFair(queue) {
+		return
+	}
+
+	f.mu.Lock()
+	f.used[queue][task] += d
+	f.mu.Unlock()
+}
+
+// activate catches the worker time of a task that has just got jobs waiting up with the least
+// of the tasks (active) already waiting, so that it doesn't monopolize the workers with the
+// time it didn't use while it was idle.
+func (f *fairShare) activate(queue, task string, active []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(active) == 0 {
+		return
+	}
+
+	used := f.used[queue]
+	least := used[active[0]]
+	for _, t := range active[1:] {
+		if used[t] < least {
+			least = used[t]
+		}
+	}
+	if used[task] < least {
+		used[task] = least
+	}
+}
+
+// pick returns the position of the task with the least worker time among the tasks, the first
+// of them if they're tied.
+func (f *fairShare) pick(queue string, tasks []string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var (
+		used = f.used[queue]
+		out  = 0
+	)
+	for i, t := range tasks {
+		if used[t] < used[tasks[out]] {
+			out = i
+		}
+	}
+
+	return out
+}
+
+// fairKey is the part of a job message decoded to dispatch it by its task.
+type fairKey struct {
+	Job struct {
+		Task string
+	}
+}
+
+// dispatchFair buffers up to n of the messages received on work, and sends them to out by
+// their task, the task with the least worker time on the queue first, and the tasks in turns
+// if they're tied. The buffered messages are pushed back onto the queue once the server stops,
+// or the consumer exits.
+func (s *Server) dispatchFair(ctx context.Context, work <-chan []byte, out chan<- []byte, n int, queue string, done <-chan struct{}) {
+	var (
+		// pending holds the buffered messages of each task, in the order they were consumed,
+		// and order the tasks with buffered messages, in their turns.
+		pending  = make(map[string][][]byte)
+		order    []string
+		buffered int
+	)
+	defer func() {
+		for _, t := range order {
+			for _, b := range pending[t] {
+				s.pushBack(b, queue)
+			}
+		}
+	}()
+
+	for {
+		var (
+			in   = work
+			send chan<- []byte
+			next []byte
+			i    int
+		)
+		if buffered >= n {
+			in = nil
+		}
+		if buffered > 0 {
+			i = s.fair.pick(queue, order)
+			send, next = out, pending[order[i]][0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case b := <-in:
+			var k fairKey
+			msgpack.Unmarshal(b, &k)
+			t := k.Job.Task
+			if len(pending[t]) == 0 {
+				s.fair.activate(queue, t, order)
+				order = append(order, t)
+			}
+			pending[t] = append(pending[t], b)
+			buffered++
+		case send <- next:
+			t := order[i]
+			order = append(order[:i], order[i+1:]...)
+			if pending[t] = pending[t][1:]; len(pending[t]) == 0 {
+				delete(pending, t)
+			} else {
+				// The task takes its next turn after the others'.
+				order = append(order, t)
+			}
+			buffered--
+		}
+	}
+}
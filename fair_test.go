@@ -0,0 +1,132 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestDispatchFair(t *testing.T) {
+	broker := NewMockBroker()
+	srv, err := NewServer(ServerOpts{Broker: broker, FairQueues: []string{DefaultQueue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := func(uuid, task string) []byte {
+		b, err := msgpack.Marshal(JobMessage{Meta: Meta{UUID: uuid}, Job: &Job{Task: task}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	uuid := func(b []byte) string {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.UUID
+	}
+	dispatch := func(msgs [][]byte, expected []string) {
+		var (
+			ctx  = context.Background()
+			work = make(chan []byte)
+			out  = make(chan []byte)
+			done = make(chan struct{})
+			exit = make(chan struct{})
+		)
+		go func() {
+			srv.dispatchFair(ctx, work, out, len(msgs), DefaultQueue, done)
+			close(exit)
+		}()
+
+		for _, m := range msgs {
+			work <- m
+		}
+		for _, u := range expected {
+			if got := uuid(<-out); got != u {
+				t.Fatalf("expected %s, got %s", u, got)
+			}
+		}
+		close(done)
+		<-exit
+	}
+
+	// Without any worker time spent, the tasks take turns.
+	dispatch([][]byte{
+		message("chatty-1", "chatty"),
+		message("chatty-2", "chatty"),
+		message("chatty-3", "chatty"),
+		message("quiet-1", "quiet"),
+	}, []string{"chatty-1", "quiet-1", "chatty-2", "chatty-3"})
+
+	// The task which has had the least worker time goes first, instead of taking turns.
+	srv.fair.add(DefaultQueue, "chatty", time.Second)
+	dispatch([][]byte{
+		message("quiet-1", "quiet"),
+		message("chatty-1", "chatty"),
+		message("quiet-2", "quiet"),
+	}, []string{"quiet-1", "quiet-2", "chatty-1"})
+
+	// A task that gets jobs after being idle catches up with the waiting tasks' worker time,
+	// instead of holding up the workers until it makes up the time.
+	dispatch([][]byte{
+		message("chatty-1", "chatty"),
+		message("quiet-1", "quiet"),
+		message("chatty-2", "chatty"),
+		message("quiet-2", "quiet"),
+	}, []string{"chatty-1", "quiet-1", "chatty-2", "quiet-2"})
+
+	// Jobs on other queues aren't accounted.
+	srv.fair.add("other", "chatty", time.Hour)
+	if _, ok := srv.fair.used["other"]; ok {
+		t.Fatal("expected the worker time on a queue that isn't fair to not be accounted")
+	}
+}
+
+func TestFairQueueProcessing(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		broker      = NewMockBroker()
+		clock       = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		ran         = make(chan string, 10)
+	)
+	defer cancel()
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, FairQueues: []string{DefaultQueue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"chatty", "quiet"} {
+		name := name
+		srv.RegisterTask(name, func(b []byte, _ JobCtx) error {
+			ran <- name
+			return nil
+		}, TaskOpts{})
+	}
+
+	for _, name := range []string{"chatty", "chatty", "quiet"} {
+		j, err := NewJob(name, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go srv.Start(ctx)
+	seen := make(map[string]int)
+	for i := 0; i < 3; i++ {
+		select {
+		case name := <-ran:
+			seen[name]++
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected the jobs to be processed, got %v", seen)
+		}
+	}
+	if seen["chatty"] != 2 || seen["quiet"] != 1 {
+		t.Fatalf("expected all the jobs of the fair queue to be processed, got %v", seen)
+	}
+}
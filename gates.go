@@ -0,0 +1,261 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// gatePrefix prefixes the tag indexing the jobs held on a gate.
+	gatePrefix = "tasqueue:gate:"
+	// gatesTag indexes the ID's of the gates which have held jobs.
+	gatesTag = "tasqueue:gates"
+	// gateLockPrefix prefixes the key of a gate's lock.
+	gateLockPrefix = "tasqueue:gate-lock:"
+	// gateLockTTL is the duration after which the lock of a gate expires, if it isn't released.
+	gateLockTTL = time.Second * 10
+
+	defaultGateInterval = time.Minute
+)
+
+// gateRelease is a release of the jobs held on a gate, scheduled at the time their TTL passes.
+type gateRelease struct {
+	gate string
+	at   time.Time
+}
+
+// holdJob holds the job on its gate, instead of enqueuing it onto the broker.
+func (s *Server) holdJob(ctx context.Context, msg JobMessage) error {
+	if s.results == nil {
+		return ErrNoResults
+	}
+
+	// The gate is registered before the job is indexed, so that the gates of all the
+	// held jobs are registered.
+	if err := setTag(ctx, s.results, gatesTag, msg.Gate); err != nil {
+		return fmt.Errorf("could not register gate %s : %w", msg.Gate, err)
+	}
+	if err := setTag(ctx, s.results, gatePrefix+msg.Gate, msg.UUID); err != nil {
+		return fmt.Errorf("could not hold job on gate %s : %w", msg.Gate, err)
+	}
+
+	return nil
+}
+
+// OpenGate() enqueues the jobs held on the gate onto their queues, eg: once an approval is
+// given or an external event occurs, and returns the number of jobs released. Jobs held on the
+// gate afterwards are held until the gate is opened again.
+func (s *Server) OpenGate(ctx context.Context, gate string) (int, error) {
+	return s.releaseGate(ctx, gate, time.Time{})
+}
+
+// ReleaseGates() enqueues the held jobs whose gate TTL has passed onto their queues, and
+// returns the number of jobs released.
+func (s *Server) ReleaseGates(ctx context.Context) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+
+	gates, err := getTag(ctx, s.results, gatesTag)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		now = s.clock.Now()
+		n   int
+	)
+	for _, g := range gates {
+		r, err := s.releaseGate(ctx, g, now)
+		n += r
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// RunGates() periodically releases the held jobs whose gate TTL has passed. If interval
+// is zero, it defaults to a minute. It is a blocking function.
+func (s *Server) RunGates(ctx context.Context, interval time.Duration) {
+	if interval == 0 {
+		interval = defaultGateInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(interval):
+			n, err := s.ReleaseGates(ctx)
+			if err != nil {
+				s.log.Error("error releasing gated jobs", "error", err)
+				continue
+			}
+			s.log.Debug("released gated jobs", "count", n)
+		}
+	}
+}
+
+// releaseGate enqueues the jobs held on the gate. If expiry isn't zero, only the jobs whose
+// TTL passed by then are released. Held jobs which were cancelled are removed from the gate.
+func (s *Server) releaseGate(ctx context.Context, gate string, expiry time.Time) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+	// The gate is locked, so that concurrent releases don't enqueue its jobs more than once,
+	// and jobs aren't coalesced with a debounced job being released.
+	unlock, err := s.lockGate(ctx, gate)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	uuids, err := getTag(ctx, s.results, gatePrefix+gate)
+	if err != nil {
+		return 0, err
+	}
+
+	var n, held int
+	for _, uuid := range uuids {
+		msg, err := s.getJob(ctx, uuid, false)
+		if err != nil {
+			return n, err
+		}
+
+		if msg.Status == StatusHeld {
+			if !expiry.IsZero() && (msg.HeldUntil.IsZero() || msg.HeldUntil.After(expiry)) {
+				held++
+				continue
+			}
+
+			ok, err := s.releaseJob(ctx, uuid, gate)
+			if err != nil {
+				return n, err
+			}
+			if ok {
+				n++
+			}
+		}
+
+		if err := deleteTag(ctx, s.results, gatePrefix+gate, uuid); err != nil {
+			return n, err
+		}
+	}
+
+	if held == 0 {
+		if err := deleteTag(ctx, s.results, gatesTag, gate); err != nil {
+			return n, err
+		}
+	}
+	s.metrics.GetOrCreateCounter(metricJobsReleased).Add(n)
+
+	return n, nil
+}
+
+// releaseJob enqueues the job held on the gate, and returns true if it was released. The job's
+// status is re-read under its lock, so that a job cancelled while the gate is being released
+// isn't enqueued.
+func (s *Server) releaseJob(ctx context.Context, uuid, gate string) (bool, error) {
+	unlock, err := s.lockJob(ctx, uuid)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	msg, err := s.getJob(ctx, uuid, false)
+	if err != nil {
+		return false, err
+	}
+	if msg.Status != StatusHeld {
+		return false, nil
+	}
+
+	s.log.Debug("releasing gated job", "uuid", uuid, "gate", gate)
+	if err := s.statusStarted(ctx, msg); err != nil {
+		return false, err
+	}
+	if err := s.enqueueMessage(ctx, msg); err != nil {
+		return false, err
+	}
+	s.countStat(ctx, msg, statEnqueued, 0)
+
+	return true, nil
+}
+
+// lockGate acquires the gate's lock, waiting for it if it's held, and returns the function
+// that releases it.
+func (s *Server) lockGate(ctx context.Context, gate string) (func(), error) {
+	return s.waitLock(ctx, gateLockPrefix+gate, gateLockTTL)
+}
+
+// scheduleRelease schedules the release of the jobs held on the gate at the time, on the
+// server's run loop. A release scheduled on the gate for the same time is only run once, and
+// the releases pending when the server stops are left to RunGates().
+func (s *Server) scheduleRelease(gate string, at time.Time) {
+	s.gmu.Lock()
+	s.releases[gateRelease{gate: gate, at: at}] = struct{}{}
+	s.gmu.Unlock()
+
+	select {
+	case s.released <- struct{}{}:
+	default:
+	}
+}
+
+// dueReleases removes the releases due by the time from the schedule, and returns them along
+// with the time the next release is due, if any.
+func (s *Server) dueReleases(now time.Time) ([]gateRelease, time.Time) {
+	s.gmu.Lock()
+	defer s.gmu.Unlock()
+
+	var (
+		due  []gateRelease
+		next time.Time
+	)
+	for r := range s.releases {
+		if !r.at.After(now) {
+			due = append(due, r)
+			delete(s.releases, r)
+			continue
+		}
+		if next.IsZero() || r.at.Before(next) {
+			next = r.at
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+
+	return due, next
+}
+
+// runReleases releases the gates on their scheduled times, until the context is cancelled. A
+// release which fails is retried after the default gate interval.
+func (s *Server) runReleases(ctx context.Context) {
+	for {
+		now := s.clock.Now()
+		due, next := s.dueReleases(now)
+		for _, r := range due {
+			n, err := s.releaseGate(ctx, r.gate, now)
+			if err != nil {
+				s.log.Error("error releasing gated jobs", "gate", r.gate, "error", err)
+				s.scheduleRelease(r.gate, now.Add(defaultGateInterval))
+				continue
+			}
+			s.log.Debug("released gated jobs", "gate", r.gate, "count", n)
+		}
+
+		var after <-chan time.Time
+		if !next.IsZero() {
+			after = s.clock.After(next.Sub(now))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-after:
+		case <-s.released:
+		}
+	}
+}
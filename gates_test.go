@@ -0,0 +1,156 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestGates(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue := func(opts JobOpts) string {
+		job, err := NewJob(taskName, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uuid
+	}
+	status := func(uuid, exp string) {
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != exp {
+			t.Fatalf("expected status %s, got %s", exp, msg.Status)
+		}
+	}
+	pending := func(exp int) {
+		msgs, err := srv.GetPending(ctx, DefaultQueue, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != exp {
+			t.Fatalf("expected %d pending jobs, got %d", exp, len(msgs))
+		}
+	}
+
+	var (
+		held      = enqueue(JobOpts{Gate: "approval"})
+		cancelled = enqueue(JobOpts{Gate: "approval"})
+		ttl       = enqueue(JobOpts{Gate: "event", GateTTL: time.Hour})
+	)
+	status(held, StatusHeld)
+	pending(0)
+
+	// Cancelled jobs are not released.
+	if err := srv.Cancel(ctx, cancelled); err != nil {
+		t.Fatal(err)
+	}
+	n, err := srv.OpenGate(ctx, "approval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 job released, got %d", n)
+	}
+	status(held, StatusStarted)
+	status(cancelled, StatusCancelled)
+	pending(1)
+
+	// Gated jobs are released after their TTL.
+	if n, err := srv.ReleaseGates(ctx); err != nil || n != 0 {
+		t.Fatalf("expected no jobs released before the TTL, got %d (%v)", n, err)
+	}
+	clock.advance(time.Hour * 2)
+	if n, err := srv.ReleaseGates(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 job released after the TTL, got %d (%v)", n, err)
+	}
+	status(ttl, StatusStarted)
+	pending(2)
+
+	job := makeJob(t, false)
+	job.Opts.Gate, job.Opts.Schedule = "approval", "@hourly"
+	if _, err := srv.Enqueue(ctx, job); !errors.Is(err, ErrGatedSchedule) {
+		t.Fatalf("expected %v enqueuing a scheduled job with a gate, got %v", ErrGatedSchedule, err)
+	}
+}
+
+// countingBroker counts the messages enqueued onto the broker.
+type countingBroker struct {
+	Broker
+	n int32
+}
+
+func (b *countingBroker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	atomic.AddInt32(&b.n, 1)
+	return b.Broker.Enqueue(ctx, msg, queue)
+}
+
+// slowGetResults is a results store whose reads are slow to return.
+type slowGetResults struct {
+	*rr.Results
+}
+
+func (r slowGetResults) Get(ctx context.Context, uuid string) ([]byte, error) {
+	b, err := r.Results.Get(ctx, uuid)
+	time.Sleep(time.Millisecond * 10)
+	return b, err
+}
+
+func TestGateConcurrentRelease(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = &countingBroker{Broker: NewMockBroker()}
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: slowGetResults{NewMockResults()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := NewJob(taskName, nil, JobOpts{Gate: "approval"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job held on a gate opened concurrently is enqueued once.
+	var (
+		wg       sync.WaitGroup
+		released int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := srv.OpenGate(ctx, "approval")
+			if err != nil {
+				t.Error(err)
+			}
+			atomic.AddInt32(&released, int32(n))
+		}()
+	}
+	wg.Wait()
+
+	if released != 1 || atomic.LoadInt32(&broker.n) != 1 {
+		t.Fatalf("expected the job to be released and enqueued once, got %d released, %d enqueued", released, broker.n)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-
-	"github.com/google/uuid"
 )
 
 type Group struct {
 	Jobs []Job
+	// Reduce, if set, is the name of the reducer which combines the results of the group's
+	// jobs into the group's result, returned by GetResult() with the group's UUID.
+	Reduce string
+	// Calendar, if set, is the name of the calendar (ServerOpts.Calendars) on whose days the
+	// group runs, if it's scheduled with ScheduleGroup().
+	Calendar string
 }
 
 // GroupMeta contains fields related to a group job. These are updated when a task is consumed.
@@ -18,6 +22,8 @@ type GroupMeta struct {
 	Status string
 	// JobStatus is a map of job uuid -> status
 	JobStatus map[string]string
+	// JobUUIDs are the UUIDs of the group's jobs, in order.
+	JobUUIDs []string
 }
 
 // GroupMessage is a wrapper over Group, containing meta info such as status, uuid.
@@ -28,11 +34,11 @@ type GroupMessage struct {
 }
 
 // message() converts a group into a group message, ready to be enqueued/stored.
-func (t *Group) message() GroupMessage {
+func (t *Group) message(uuid string) GroupMessage {
 	return GroupMessage{
 		GroupMeta: GroupMeta{
 			JobStatus: make(map[string]string),
-			UUID:      uuid.NewString(),
+			UUID:      uuid,
 			Status:    StatusProcessing,
 		},
 		Group: t,
@@ -58,13 +64,29 @@ func NewGroup(j ...Job) (Group, error) {
 // 3. Loops over all jobs part of the group and enqueues the job each job.
 // 4. The job status map is updated with the uuids of each enqueued job.
 func (s *Server) EnqueueGroup(ctx context.Context, t Group) (string, error) {
-	msg := t.message()
+	// The group's state is tracked on the results store.
+	if s.results == nil {
+		return "", ErrNoResults
+	}
+
+	// Validate all the jobs before enqueuing any, so that a group isn't partially enqueued.
+	for _, v := range t.Jobs {
+		if err := s.validateJob(v); err != nil {
+			return "", fmt.Errorf("could not enqueue group : %w", err)
+		}
+	}
+	if err := s.validateReducer(t.Reduce); err != nil {
+		return "", fmt.Errorf("could not enqueue group : %w", err)
+	}
+
+	msg := t.message(s.ids.NewID(s.clock.Now()))
 	for _, v := range t.Jobs {
 		uid, err := s.Enqueue(ctx, v)
 		if err != nil {
 			return "", fmt.Errorf("could not enqueue group : %w", err)
 		}
 		msg.JobStatus[uid] = StatusStarted
+		msg.JobUUIDs = append(msg.JobUUIDs, uid)
 	}
 
 	if err := s.setGroupMessage(ctx, msg); err != nil {
@@ -94,7 +116,7 @@ func (s *Server) GetGroup(ctx context.Context, uuid string) (GroupMessage, error
 		case StatusFailed, StatusDone:
 			jobStatus[uuid] = status
 		// Re-look the jobs where the status is an intermediatery state (processing, retrying, etc).
-		case StatusStarted, StatusProcessing, StatusRetrying:
+		case StatusStarted, StatusProcessing, StatusRetrying, StatusHeld:
 			j, err := s.GetJob(ctx, uuid)
 			if err != nil {
 				return GroupMessage{}, err
@@ -139,6 +161,10 @@ func (s *Server) setGroupMessage(ctx context.Context, g GroupMessage) error {
 }
 
 func (s *Server) getGroupMessage(ctx context.Context, uuid string) (GroupMessage, error) {
+	if s.results == nil {
+		return GroupMessage{}, ErrNoResults
+	}
+
 	b, err := s.results.Get(ctx, uuid)
 	if err != nil {
 		return GroupMessage{}, err
@@ -0,0 +1,95 @@
+package tasqueue
+
+import "sync"
+
+// HandlerCache is an LRU cache with a TTL, shared by the handlers on a server, so that
+// handlers processing many similar jobs can memoize expensive lookups (eg: config, templates)
+// without global variables. Its methods are safe for concurrent use, and are no-ops on a nil
+// cache, ie: if ServerOpts.HandlerCache isn't set.
+type HandlerCache struct {
+	lru *lruCache
+
+	mu    sync.Mutex
+	loads map[string]*cacheLoad
+}
+
+// cacheLoad is a load of a key in progress, which concurrent callers wait on.
+type cacheLoad struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+func newHandlerCache(o CacheOpts, c Clock) *HandlerCache {
+	lru := newLRUCache(o, c)
+	if lru == nil {
+		return nil
+	}
+
+	return &HandlerCache{lru: lru, loads: make(map[string]*cacheLoad)}
+}
+
+// Get returns the value cached at the key.
+func (c *HandlerCache) Get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.lru.load(key)
+}
+
+// Set caches the value at the key, for the cache's TTL.
+func (c *HandlerCache) Set(key string, v any) {
+	if c == nil {
+		return
+	}
+	c.lru.store(key, v)
+}
+
+// Delete removes the value cached at the key.
+func (c *HandlerCache) Delete(key string) {
+	if c == nil {
+		return
+	}
+	c.lru.remove(key)
+}
+
+// GetOrLoad returns the value cached at the key, or else loads it with fn and caches it.
+// Concurrent calls for a key share a single load. Errors aren't cached. On a nil cache, fn
+// is called every time.
+func (c *HandlerCache) GetOrLoad(key string, fn func() (any, error)) (any, error) {
+	if c == nil {
+		return fn()
+	}
+	if v, ok := c.lru.load(key); ok {
+		return v, nil
+	}
+
+	c.mu.Lock()
+	if l, ok := c.loads[key]; ok {
+		c.mu.Unlock()
+		l.wg.Wait()
+		return l.val, l.err
+	}
+	l := &cacheLoad{}
+	l.wg.Add(1)
+	c.loads[key] = l
+	c.mu.Unlock()
+
+	l.val, l.err = fn()
+	if l.err == nil {
+		c.lru.store(key, l.val)
+	}
+
+	c.mu.Lock()
+	delete(c.loads, key)
+	c.mu.Unlock()
+	l.wg.Done()
+
+	return l.val, l.err
+}
+
+// Cache() returns the cache shared by the handlers on the server (ServerOpts.HandlerCache).
+// It is nil if the cache isn't configured, on which its methods are no-ops.
+func (c *JobCtx) Cache() *HandlerCache {
+	return c.handlerCache
+}
@@ -0,0 +1,92 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandlerCache(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Now())
+		loads  int32
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, HandlerCache: CacheOpts{Size: 2, TTL: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		_, err := c.Cache().GetOrLoad("config", func() (any, error) {
+			atomic.AddInt32(&loads, 1)
+			return "config", nil
+		})
+		return err
+	}, TaskOpts{})
+
+	// The handlers share the loaded value until it expires.
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			clock.advance(time.Minute * 2)
+		}
+		if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+	}
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Fatalf("expected the value to be loaded twice, got %d", n)
+	}
+
+	// The least recently used value is evicted.
+	c := srv.handlerCache
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("config"); ok {
+		t.Fatal("expected the least recently used value to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected the cached value, got %v", v)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected the value to be deleted")
+	}
+
+	// Concurrent loads of a key share a single load.
+	var (
+		wg      sync.WaitGroup
+		started = make(chan struct{})
+	)
+	atomic.StoreInt32(&loads, 0)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-started
+			v, err := c.GetOrLoad("slow", func() (any, error) {
+				atomic.AddInt32(&loads, 1)
+				time.Sleep(time.Millisecond * 50)
+				return "slow", nil
+			})
+			if err != nil || v != "slow" {
+				t.Errorf("unexpected value %v, %v", v, err)
+			}
+		}()
+	}
+	close(started)
+	wg.Wait()
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expected a single load, got %d", n)
+	}
+
+	// Without a cache, values are loaded on every call.
+	var nilCache *HandlerCache
+	nilCache.Set("a", 1)
+	if v, err := nilCache.GetOrLoad("a", func() (any, error) { return 2, nil }); err != nil || v != 2 {
+		t.Fatalf("expected the loaded value, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,128 @@
+package tasqueue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+const (
+	// ringPrefix prefixes the set of leases of the workers consuming a queue pattern, across
+	// which the matching queues are hashed.
+	ringPrefix = "tasqueue:ring:"
+	// ringReplicas is the number of points of each worker on the ring, which spread the queues
+	// evenly across the workers.
+	ringReplicas = 64
+	// ringBeats is the number of heartbeat periods after which a worker that hasn't heartbeat
+	// is left out of the ring.
+	ringBeats = 3
+)
+
+// hashRing consistently hashes keys to workers, so that a worker joining or leaving only
+// moves the keys hashed to it.
+type hashRing struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+func newHashRing(workers []string) hashRing {
+	r := hashRing{owners: make(map[uint64]string, len(workers)*ringReplicas)}
+	for _, w := range workers {
+		for i := 0; i < ringReplicas; i++ {
+			p := ringHash(w + "#" + strconv.Itoa(i))
+			r.points = append(r.points, p)
+			r.owners[p] = w
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+// owner returns the worker the key is hashed to, ie: the worker of the first point on the ring
+// after the key's hash.
+func (r hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	h := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]]
+}
+
+// ringHash hashes the key onto the ring. FNV isn't used, as it doesn't spread similar keys
+// (eg: the points of a worker) evenly.
+func ringHash(s string) uint64 {
+	h := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(h[:8])
+}
+
+// ownedQueues joins the worker to the ring of the workers consuming the pattern, and returns
+// the queues hashed to it among the live workers on the ring. The workers whose heartbeats
+// are stale are removed from the ring.
+func (s *Server) ownedQueues(ctx context.Context, pattern string, queues []string) ([]string, error) {
+	key := ringPrefix + pattern
+	if err := s.leaser.Lease(ctx, key, s.workerID); err != nil {
+		return nil, err
+	}
+	ids, err := s.leaser.GetLeases(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cutoff  = s.clock.Now().Add(-s.heartbeat * ringBeats)
+		workers = []string{s.workerID}
+	)
+	for _, id := range ids {
+		if id == s.workerID {
+			continue
+		}
+		// A worker which joined the ring before its first heartbeat is left out until then.
+		b, err := s.results.Get(ctx, workerPrefix+id)
+		if err != nil {
+			continue
+		}
+		var w worker
+		if err := json.Unmarshal(b, &w); err != nil {
+			return nil, err
+		}
+		if w.Heartbeat.Before(cutoff) {
+			s.log.Debug("removing dead worker from queue ring", "worker", id, "pattern", pattern)
+			if err := s.leaser.Unlease(ctx, key, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		workers = append(workers, id)
+	}
+
+	var (
+		ring  = newHashRing(workers)
+		owned = make([]string, 0, len(queues)/len(workers)+1)
+	)
+	for _, q := range queues {
+		if ring.owner(q) == s.workerID {
+			owned = append(owned, q)
+		}
+	}
+
+	return owned, nil
+}
+
+// leaveRing removes the worker from the ring of the workers consuming the pattern, so that its
+// queues are hashed to the other workers.
+func (s *Server) leaveRing(pattern string) {
+	// The consumer's context is cancelled, hence a new one is used.
+	if err := s.leaser.Unlease(context.Background(), ringPrefix+pattern, s.workerID); err != nil {
+		s.log.Error("error leaving queue ring", "pattern", pattern, "error", err)
+	}
+}
@@ -0,0 +1,93 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHashRing(t *testing.T) {
+	var (
+		keys   = make([]string, 1000)
+		before = newHashRing([]string{"a", "b", "c"})
+		after  = newHashRing([]string{"a", "b"})
+		counts = make(map[string]int)
+	)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("queue:%d", i)
+		counts[before.owner(keys[i])]++
+	}
+	for _, w := range []string{"a", "b", "c"} {
+		if counts[w] < 200 {
+			t.Fatalf("expected the keys to be spread across the workers, got %v", counts)
+		}
+	}
+
+	// Only the keys of the worker that left move.
+	for _, k := range keys {
+		if o := before.owner(k); o != "c" && after.owner(k) != o {
+			t.Fatalf("expected %s to stay on %s, got %s", k, o, after.owner(k))
+		}
+	}
+}
+
+func TestOwnedQueues(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		results = NewMockResults()
+		clock   = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		queues  = []string{"emails.1", "emails.2", "emails.3", "emails.4", "emails.5", "emails.6"}
+		srvs    = make([]*Server, 2)
+	)
+	for i := range srvs {
+		srv, err := NewServer(ServerOpts{
+			Broker:          NewMockBroker(),
+			Results:         results,
+			Clock:           clock,
+			WorkerID:        fmt.Sprintf("worker-%d", i),
+			HeartbeatPeriod: time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.beat(ctx); err != nil {
+			t.Fatal(err)
+		}
+		srvs[i] = srv
+	}
+
+	// Once both the workers have joined the ring, each queue is owned by exactly one of them.
+	owners := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		for _, srv := range srvs {
+			owned, err := srv.ownedQueues(ctx, "emails.*", queues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, q := range owned {
+				if i > 0 {
+					owners[q]++
+				}
+			}
+		}
+	}
+	for _, q := range queues {
+		if owners[q] != 1 {
+			t.Fatalf("expected %s to be owned by one worker, got %v", q, owners)
+		}
+	}
+
+	// The queues of a worker that stops heartbeating move to the live workers.
+	clock.advance(time.Second * ringBeats * 2)
+	if err := srvs[0].beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	owned, err := srvs[0].ownedQueues(ctx, "emails.*", queues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != len(queues) {
+		t.Fatalf("expected the live worker to own all the queues, got %v", owned)
+	}
+}
@@ -0,0 +1,64 @@
+package tasqueue
+
+import "context"
+
+// Health is the health of the server, as checked by Health().
+type Health struct {
+	Mode Mode
+	// Started is true while the server is started.
+	Started bool
+	// Scheduler is true if the cron scheduler is running. It only runs in the modes that
+	// schedule jobs.
+	Scheduler bool
+
+	// Broker and Results are the errors of pinging the backends, if any. Backends that
+	// don't implement Pinger aren't pinged.
+	Broker  error
+	Results error
+
+	// Consumers is a map of started task -> number of its consumers that are running.
+	Consumers map[string]int
+}
+
+// Health() checks the connectivity of the server's backends and the state of its consumers
+// and scheduler, eg: for liveness and readiness probes (see the health package).
+func (s *Server) Health(ctx context.Context) Health {
+	h := Health{Mode: s.mode, Broker: ping(ctx, s.broker), Scheduler: s.sched.running()}
+	if s.results != nil {
+		h.Results = ping(ctx, s.results)
+	}
+
+	s.p.RLock()
+	h.Started = s.runCtx != nil
+	keys := make([]string, 0, len(s.stops))
+	for k := range s.stops {
+		keys = append(keys, k)
+	}
+	s.p.RUnlock()
+
+	s.qmu.Lock()
+	h.Consumers = make(map[string]int, len(keys))
+	for _, k := range keys {
+		h.Consumers[k] = s.consumers[k]
+	}
+	s.qmu.Unlock()
+
+	return h
+}
+
+// ping pings the backend, if it implements Pinger.
+func ping(ctx context.Context, backend any) error {
+	if p, ok := backend.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// trackConsumer adds n to the number of the task's running consumers.
+func (s *Server) trackConsumer(key string, n int) {
+	s.qmu.Lock()
+	if s.consumers[key] += n; s.consumers[key] == 0 {
+		delete(s.consumers, key)
+	}
+	s.qmu.Unlock()
+}
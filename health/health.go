@@ -0,0 +1,103 @@
+// Package health provides the liveness and readiness HTTP handlers of a server, eg: for
+// Kubernetes probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+const defaultTimeout = time.Second * 5
+
+type Options struct {
+	// Timeout is the timeout of the checks, eg: pinging the backends. Defaults to 5s.
+	Timeout time.Duration
+}
+
+// Response is the JSON body of the handlers' responses. Checks is a map of check -> "ok",
+// or the reason it failed.
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// Live returns a handler which fails (with a 503) if the server has to be restarted: the
+// consumers of a started task have exited, or the cron scheduler isn't running on a started
+// server that schedules jobs. The backends aren't checked, so that an outage of the broker
+// doesn't restart all the workers.
+func Live(srv *tasqueue.Server, o Options) http.Handler {
+	return handler(srv, o, live)
+}
+
+// Ready returns a handler which fails (with a 503) if the server can't process jobs: it
+// isn't started, the broker or results store can't be reached, or it isn't live.
+func Ready(srv *tasqueue.Server, o Options) http.Handler {
+	return handler(srv, o, func(h tasqueue.Health, checks map[string]string) {
+		checks["started"] = statusOK
+		if !h.Started {
+			checks["started"] = "server not started"
+		}
+		checks["broker"] = errCheck(h.Broker)
+		checks["results"] = errCheck(h.Results)
+		live(h, checks)
+	})
+}
+
+func live(h tasqueue.Health, checks map[string]string) {
+	if h.Started && (h.Mode == tasqueue.ModeAll || h.Mode == tasqueue.ModeScheduler) {
+		checks["scheduler"] = statusOK
+		if !h.Scheduler {
+			checks["scheduler"] = "scheduler not running"
+		}
+	}
+
+	for t, n := range h.Consumers {
+		checks["consumer:"+t] = statusOK
+		if n == 0 {
+			checks["consumer:"+t] = "consumers not running"
+		}
+	}
+}
+
+func handler(srv *tasqueue.Server, o Options, check func(tasqueue.Health, map[string]string)) http.Handler {
+	if o.Timeout == 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), o.Timeout)
+		defer cancel()
+
+		resp := Response{Status: statusOK, Checks: make(map[string]string)}
+		check(srv.Health(ctx), resp.Checks)
+
+		code := http.StatusOK
+		for _, c := range resp.Checks {
+			if c != statusOK {
+				resp.Status = statusFail
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+func errCheck(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return statusOK
+}
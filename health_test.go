@@ -0,0 +1,67 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pingBroker is a broker whose ping fails with err.
+type pingBroker struct {
+	Broker
+	err error
+}
+
+func (b pingBroker) Ping(context.Context) error {
+	return b.err
+}
+
+func TestHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errDown := errors.New("broker down")
+	srv, err := NewServer(ServerOpts{
+		Broker:    pingBroker{Broker: NewMockBroker(), err: errDown},
+		Results:   NewMockResults(),
+		Namespace: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	h := srv.Health(ctx)
+	if h.Started || h.Scheduler || len(h.Consumers) != 0 {
+		t.Fatalf("expected the server not to be started, got %+v", h)
+	}
+	// The broker is pinged through the namespace.
+	if !errors.Is(h.Broker, errDown) || h.Results != nil {
+		t.Fatalf("unexpected backend health %v, %v", h.Broker, h.Results)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		srv.Start(ctx)
+		close(exited)
+	}()
+	for i := 0; ; i++ {
+		if h = srv.Health(ctx); h.Started && h.Consumers[taskName] == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("expected the server to be started with a consumer, got %+v", h)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !h.Scheduler {
+		t.Fatal("expected the scheduler to be running")
+	}
+
+	cancel()
+	<-exited
+	if h = srv.Health(context.Background()); h.Started || len(h.Consumers) != 0 {
+		t.Fatalf("expected the server to be stopped, got %+v", h)
+	}
+}
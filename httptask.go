@@ -0,0 +1,147 @@
+package tasqueue
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// HTTPSignatureHeader holds the hex encoded HMAC-SHA256 signature of the request body,
+	// if HTTPOpts.Secret is set.
+	HTTPSignatureHeader = "X-Tasqueue-Signature"
+	// HTTPIdempotencyHeader holds the job's UUID, which is the same across retries, so that
+	// the receiver can deduplicate the requests.
+	HTTPIdempotencyHeader = "Idempotency-Key"
+
+	defaultHTTPTimeout = time.Second * 30
+	defaultMaxHTTPBody = 64 << 10
+)
+
+// HTTPRequest is the payload of an HTTP task's job, which describes the request to make.
+type HTTPRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+}
+
+// HTTPResponse is the response to an HTTP task's request, saved as the job's "response"
+// named result.
+type HTTPResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+}
+
+// HTTPOpts configures a handler that makes HTTP requests.
+type HTTPOpts struct {
+	// Client makes the requests. Defaults to a client with a 30s timeout.
+	Client *http.Client
+
+	// RetryStatuses are the response statuses of the failures that are retried. The job fails
+	// without retrying on other non 2xx statuses. Defaults to 408, 429 and 5xx. Requests that
+	// fail without a response (eg: on a network error) are always retried.
+	RetryStatuses []int
+
+	// Secret, if set, signs the request body (see HTTPSignatureHeader).
+	Secret string
+
+	// MaxBody is the number of bytes of the response body saved. Defaults to 64KiB.
+	MaxBody int
+}
+
+// NewHTTPJob returns a job of the HTTP task which makes the request.
+func NewHTTPJob(task string, r HTTPRequest, opts JobOpts) (Job, error) {
+	if r.URL == "" {
+		return Job{}, errors.New("request url not set")
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return Job{}, err
+	}
+
+	return NewJob(task, b, opts)
+}
+
+// HTTP returns a handler that makes the HTTP request described by each job's payload
+// (HTTPRequest), eg: to dispatch webhooks durably, with the task's retries.
+func HTTP(o HTTPOpts) func([]byte, JobCtx) error {
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	if o.MaxBody == 0 {
+		o.MaxBody = defaultMaxHTTPBody
+	}
+	retry := make(map[int]struct{}, len(o.RetryStatuses))
+	for _, s := range o.RetryStatuses {
+		retry[s] = struct{}{}
+	}
+
+	return func(payload []byte, c JobCtx) error {
+		var r HTTPRequest
+		if err := json.Unmarshal(payload, &r); err != nil {
+			return fmt.Errorf("could not decode request : %v : %w", err, ErrSkipRetry)
+		}
+		if r.Method == "" {
+			r.Method = http.MethodPost
+		}
+
+		req, err := http.NewRequestWithContext(c, r.Method, r.URL, bytes.NewReader(r.Body))
+		if err != nil {
+			return fmt.Errorf("invalid request : %v : %w", err, ErrSkipRetry)
+		}
+		for k, v := range r.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set(HTTPIdempotencyHeader, c.Meta.UUID)
+		if o.Secret != "" {
+			req.Header.Set(HTTPSignatureHeader, hex.EncodeToString(hmacSum([]byte(o.Secret), r.Body)))
+		}
+
+		resp, err := o.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("error making request : %w", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, int64(o.MaxBody)))
+		if err != nil {
+			return fmt.Errorf("error reading response : %w", err)
+		}
+
+		// The response is saved for failed requests too, to debug them.
+		if c.store != nil {
+			res := HTTPResponse{Status: resp.StatusCode, Headers: make(map[string]string, len(resp.Header)), Body: body}
+			for k := range resp.Header {
+				res.Headers[k] = resp.Header.Get(k)
+			}
+			if err := c.SaveNamed("response", res); err != nil {
+				return fmt.Errorf("could not save response : %w", err)
+			}
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		if !retryStatus(retry, resp.StatusCode) {
+			return fmt.Errorf("request failed with status %d : %w", resp.StatusCode, ErrSkipRetry)
+		}
+		return fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+}
+
+// retryStatus returns true if the failed request with the status should be retried.
+func retryStatus(retry map[int]struct{}, status int) bool {
+	if len(retry) > 0 {
+		_, ok := retry[status]
+		return ok
+	}
+
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,89 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPTask(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		status = http.StatusOK
+		got    *http.Request
+		body   []byte
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	broker := NewMockBroker()
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("http", HTTP(HTTPOpts{Secret: "secret"}), TaskOpts{})
+
+	run := func() JobMessage {
+		job, err := NewHTTPJob("http", HTTPRequest{
+			Method:  http.MethodPut,
+			URL:     ts.URL,
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    []byte(`{"id": 1}`),
+		}, JobOpts{MaxRetries: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	// The request is made, signed, and its response is saved.
+	msg := run()
+	if msg.Status != StatusDone {
+		t.Fatalf("expected status %s, got %s", StatusDone, msg.Status)
+	}
+	if got.Method != http.MethodPut || got.Header.Get("Content-Type") != "application/json" || string(body) != `{"id": 1}` {
+		t.Fatalf("expected the request to be made as described, got %s %v %s", got.Method, got.Header, body)
+	}
+	if got.Header.Get(HTTPIdempotencyHeader) != msg.UUID {
+		t.Fatalf("expected the idempotency key to be the job's uuid, got %s", got.Header.Get(HTTPIdempotencyHeader))
+	}
+	if got.Header.Get(HTTPSignatureHeader) != hex.EncodeToString(hmacSum([]byte("secret"), body)) {
+		t.Fatal("expected the request body to be signed")
+	}
+	var resp HTTPResponse
+	if err := srv.GetNamedResult(ctx, msg.UUID, "response", &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != http.StatusOK || string(resp.Body) != "ok" {
+		t.Fatalf("expected the response to be saved, got %+v", resp)
+	}
+
+	// Server errors are retried, while client errors fail the job.
+	status = http.StatusServiceUnavailable
+	if msg := run(); msg.Status != StatusRetrying {
+		t.Fatalf("expected status %s, got %s", StatusRetrying, msg.Status)
+	}
+	<-broker.data
+	status = http.StatusBadRequest
+	if msg := run(); msg.Status != StatusFailed {
+		t.Fatalf("expected status %s, got %s", StatusFailed, msg.Status)
+	}
+}
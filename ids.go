@@ -0,0 +1,69 @@
+package tasqueue
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// uuidGenerator is the default ID generator, which generates random (v4) UUIDs.
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID(time.Time) string {
+	return uuid.NewString()
+}
+
+// crockford is the base32 alphabet of ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates ULIDs, which are 26 character IDs that sort by the time they're generated at
+// (to the millisecond). IDs generated within the same millisecond are monotonic, hence job IDs
+// sort in the order the jobs were enqueued by a producer. The zero value is ready to use.
+type ULID struct {
+	mu      sync.Mutex
+	ms      uint64
+	entropy [10]byte
+}
+
+func (u *ULID) NewID(t time.Time) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ms := uint64(t.UnixMilli())
+	if ms == u.ms {
+		// Increment the entropy of the last ID, so that the IDs within a millisecond sort.
+		for i := len(u.entropy) - 1; i >= 0; i-- {
+			u.entropy[i]++
+			if u.entropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		u.ms = ms
+		if _, err := rand.Read(u.entropy[:]); err != nil {
+			panic(err)
+		}
+	}
+
+	var b [26]byte
+	// 48 bits of time as 10 characters.
+	for i := 9; i >= 0; i-- {
+		b[i] = crockford[ms&31]
+		ms >>= 5
+	}
+	// 80 bits of entropy as 16 characters, 40 bits at a time.
+	for h := 0; h < 2; h++ {
+		var v uint64
+		for _, c := range u.entropy[h*5 : h*5+5] {
+			v = v<<8 | uint64(c)
+		}
+		for i := 7; i >= 0; i-- {
+			b[10+h*8+i] = crockford[v&31]
+			v >>= 5
+		}
+	}
+
+	return string(b[:])
+}
@@ -0,0 +1,71 @@
+package tasqueue
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestULID(t *testing.T) {
+	var (
+		u   ULID
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		ids []string
+	)
+
+	// IDs sort by time, and in order within a millisecond.
+	for i := 0; i < 100; i++ {
+		ids = append(ids, u.NewID(now.Add(time.Duration(i/10)*time.Millisecond)))
+	}
+	for _, id := range ids {
+		if len(id) != 26 {
+			t.Fatalf("expected 26 character ID, got %s", id)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatalf("expected IDs to sort in the order they were generated, got %v", ids)
+	}
+	if ids[0][:10] != "01HK153X00" {
+		t.Fatalf("expected the time to be encoded as 01HK153X00, got %s", ids[0][:10])
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), IDGenerator: &ULID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.UUID != uuid || len(uuid) != 26 {
+			t.Fatalf("expected the job to be assigned a ULID, got %s", msg.UUID)
+		}
+		ids = append(ids, uuid)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatalf("expected IDs to sort by enqueue time, got %v", ids)
+	}
+
+	group, err := NewGroup(makeJob(t, false), makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.EnqueueGroup(ctx, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uuid) != 26 {
+		t.Fatalf("expected the group to be assigned a ULID, got %s", uuid)
+	}
+}
@@ -1,6 +1,10 @@
 package tasqueue
 
-import "context"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 type Results interface {
 	Get(ctx context.Context, uuid string) ([]byte, error)
@@ -9,6 +13,144 @@ type Results interface {
 	GetSuccess(ctx context.Context) ([]string, error)
 	SetFailed(ctx context.Context, uuid string) error
 	SetSuccess(ctx context.Context, uuid string) error
+	// Delete deletes the value and removes the uuid from the success/failed lists.
+	Delete(ctx context.Context, uuid string) error
+}
+
+// FailedDeleter is implemented by results stores that can remove a job from the failed list,
+// eg: as the job is retried. Stores which don't implement it don't keep a failed list.
+type FailedDeleter interface {
+	// DeleteFailed removes the uuid from the failed list.
+	DeleteFailed(ctx context.Context, uuid string) error
+}
+
+// Tagger is implemented by results stores that can keep sets of jobs by tag, for job tags,
+// gates, debounced and deduplicated jobs, and jobs pinned to workers.
+type Tagger interface {
+	// SetTag adds the job's uuid to the tag's index.
+	SetTag(ctx context.Context, tag, uuid string) error
+	// GetTag returns the uuid's of the jobs with the tag.
+	GetTag(ctx context.Context, tag string) ([]string, error)
+	// DeleteTag removes the job's uuid from the tag's index.
+	DeleteTag(ctx context.Context, tag, uuid string) error
+}
+
+// Indexer is implemented by results stores that can keep the jobs indexed by time, for
+// GetJobs(), retention, archiving and migrations.
+type Indexer interface {
+	// IndexJob adds the job's uuid, ordered by time, to the index of all jobs and to the indexes of the keys.
+	IndexJob(ctx context.Context, uuid string, t time.Time, keys []string) error
+	// UnindexJob removes the job's uuid from the index of all jobs and from the indexes of the keys.
+	UnindexJob(ctx context.Context, uuid string, keys []string) error
+	// QueryJobs returns the uuid's in the index of the key (or of all jobs if the key is empty), between
+	// from and to (zero values are unbounded), ordered by time. Offset and limit page through the results.
+	QueryJobs(ctx context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error)
+}
+
+// Chunker is implemented by results stores that can append to lists of chunks, for streamed
+// results, usage records and the audit log.
+type Chunker interface {
+	// AppendChunk appends the chunk to the list of chunks at the key. The list is deleted by Delete.
+	AppendChunk(ctx context.Context, key string, b []byte) error
+	// GetChunks returns the chunks at the key, from the offset.
+	GetChunks(ctx context.Context, key string, offset int) ([][]byte, error)
+}
+
+// Pinger is implemented by brokers and results stores that can check their connection to
+// the backend, eg: for health checks.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Depther is implemented by brokers that can count the messages waiting in a queue, eg: for
+// snapshots and autoscaling.
+type Depther interface {
+	Depth(ctx context.Context, queue string) (int64, error)
+}
+
+// Trimmer is implemented by brokers that can natively cap the length of a queue, for the
+// OverflowDropOldest policy.
+type Trimmer interface {
+	// Trim removes the oldest messages of the queue, so that n messages remain, and returns
+	// them. Prioritized messages are removed after the others, lowest priority first.
+	Trim(ctx context.Context, queue string, n int64) ([][]byte, error)
+}
+
+// Watcher is implemented by results stores that can notify of changes to a key, so that
+// GetJobBlocking() doesn't poll the store.
+type Watcher interface {
+	// Watch returns a channel which receives on changes to the value at the key, until the
+	// context is done.
+	Watch(ctx context.Context, key string) (<-chan struct{}, error)
+}
+
+// Counter is implemented by results stores that can increment counters atomically, for the
+// job stats (see Stats()).
+type Counter interface {
+	// IncrCounters increments the fields of the hash at the key by their values, and sets
+	// the key to expire after the ttl, if it's set.
+	IncrCounters(ctx context.Context, key string, fields map[string]int64, ttl time.Duration) error
+	// GetCounters returns the fields of the hash at the key, or an empty map if there's none.
+	GetCounters(ctx context.Context, key string) (map[string]int64, error)
+}
+
+// Delayer is implemented by results stores that can keep an index of jobs sorted by the
+// times they're due, which the delayed jobs (JobOpts.ETA) and the retries with a backoff
+// (TaskOpts.RetryBackoff) are held on until they're moved onto their queues, on any broker.
+type Delayer interface {
+	// AddDelayed adds the job's uuid to the index at the key, due at the time.
+	AddDelayed(ctx context.Context, key, uuid string, at time.Time) error
+	// PopDue removes and returns upto n uuid's from the index at the key that are due by the
+	// time, earliest first. A uuid is only returned to one of the concurrent callers.
+	PopDue(ctx context.Context, key string, t time.Time, n int) ([]string, error)
+}
+
+// Leaser is implemented by results stores that can keep sets of leases apart from the jobs'
+// tags, for the registry of the workers which heartbeat and the jobs leased to them (see
+// RecoverJobs()).
+type Leaser interface {
+	// Lease adds the member to the set at the key.
+	Lease(ctx context.Context, key, member string) error
+	// Unlease removes the member from the set at the key.
+	Unlease(ctx context.Context, key, member string) error
+	// GetLeases returns the members of the set at the key.
+	GetLeases(ctx context.Context, key string) ([]string, error)
+}
+
+// Codec encodes and decodes the named results saved by jobs.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(b []byte, v any) error
+}
+
+// Propagator carries values (eg: correlation ID, user ID, locale) from the context a job is
+// enqueued with, into the context of the job's handler on the worker.
+type Propagator interface {
+	// Inject sets the values to be propagated from the context onto the carrier.
+	Inject(ctx context.Context, carrier map[string]string)
+	// Extract returns a copy of the context with the values from the carrier.
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+// RateLimiter limits the rate at which jobs are started. A distributed implementation
+// (eg: limiters/redis) enforces the rate across all the servers sharing it.
+type RateLimiter interface {
+	// Take takes a token from the key's bucket, refilled at the rate (per second). If the
+	// bucket is empty, it returns the duration after which a token is available.
+	Take(ctx context.Context, key string, rate float64) (time.Duration, error)
+	// Refund returns a token taken from the key's bucket, eg: if the job it was taken for
+	// is held back by another limit.
+	Refund(ctx context.Context, key string, rate float64) error
+}
+
+// Locker provides locks that expire unless they're refreshed. A distributed implementation
+// (eg: locks/redis) provides locks across all the servers sharing it.
+type Locker interface {
+	// Lock acquires the key's lock for the owner, or extends it if the owner holds it, for
+	// the ttl. It returns false if the lock is held by another owner.
+	Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the key's lock, if the owner holds it.
+	Unlock(ctx context.Context, key, owner string) error
 }
 
 type Broker interface {
@@ -19,8 +161,103 @@ type Broker interface {
 	Consume(ctx context.Context, work chan []byte, queue string)
 }
 
+// QueueLister is implemented by brokers that can look up their queues, for the tasks that
+// consume a pattern (TaskOpts.QueuePattern) and for snapshots and migrations.
+type QueueLister interface {
+	// Queues returns the names of the queues matching the pattern (path.Match syntax).
+	Queues(ctx context.Context, pattern string) ([]string, error)
+}
+
+// Peeker is implemented by brokers that can peek at the messages waiting in a queue, eg: to
+// inspect or drain it.
+type Peeker interface {
+	// GetPending returns upto n messages waiting in the queue, in the order they will
+	// be consumed, without consuming them.
+	GetPending(ctx context.Context, queue string, n int) ([][]byte, error)
+}
+
+// BlobStore is a generic interface to store job payloads outside of the broker.
+type BlobStore interface {
+	Put(ctx context.Context, key string, b []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// BlobStreamer is implemented by blob stores that can write and read blobs as streams, so
+// that large payloads (see EnqueueStream() and TaskOpts.StreamPayload) aren't held in memory.
+type BlobStreamer interface {
+	PutStream(ctx context.Context, key string, r io.Reader) error
+	GetStream(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Signer signs the messages enqueued onto the broker, so that workers can reject tampered
+// or foreign messages on a shared broker.
+type Signer interface {
+	// Sign returns the signature of the message, and the ID of the key that signed it.
+	Sign(msg []byte) (keyID string, sig []byte, err error)
+}
+
+// Verifier verifies the signatures of the messages consumed from the broker. Verifiers
+// which accept multiple keys (by ID) allow the signing key to be rotated.
+type Verifier interface {
+	Verify(keyID string, msg, sig []byte) error
+}
+
+// Cipher encrypts the messages enqueued onto the broker and decrypts the messages consumed
+// from it, so that a shared (or untrusted) broker doesn't see the jobs. Ciphers which
+// decrypt with multiple keys (by ID) allow the encryption key to be rotated.
+type Cipher interface {
+	// Seal encrypts the message, binding it to the additional data, and returns the ID of
+	// the key that encrypted it.
+	Seal(msg, ad []byte) (keyID string, sealed []byte, err error)
+	// Open decrypts the message sealed with the key of the ID and the additional data.
+	Open(keyID string, sealed, ad []byte) ([]byte, error)
+}
+
+// IDGenerator generates the IDs of jobs, groups and chains, eg: time sortable IDs (ULID,
+// Snowflake, KSUID) instead of the default random UUIDs. It is passed the enqueue time.
+type IDGenerator interface {
+	NewID(t time.Time) string
+}
+
+// Clock tells the time and times the server's schedules, delays and intervals. It can be
+// replaced (eg: with tasqueuetest.Clock) to fast-forward time in tests.
+type Clock interface {
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the channel.
+	After(d time.Duration) <-chan time.Time
+}
+
 // Opts is an interface to define arbitratry options.
 type Opts interface {
 	Name() string
 	Value() interface{}
 }
+
+// Authorizer authorizes the admin operations on jobs (eg: cancel, retry), so that deployments
+// shared by multiple teams can restrict who may mutate whose jobs, eg: by the job's tenant or
+// labels. The caller is the identity set on the operation's context with WithCaller().
+type Authorizer interface {
+	// Authorize returns an error if the caller may not perform the operation on the job.
+	Authorize(ctx context.Context, caller, op string, msg JobMessage) error
+}
+
+// AuditSink records the admin actions performed on the server, eg: onto a SIEM or a log
+// shipper. Sinks which can also list the recorded actions implement AuditReader.
+type AuditSink interface {
+	Record(ctx context.Context, e AuditEntry) error
+}
+
+// AuditReader is implemented by audit sinks that can list the recorded actions.
+type AuditReader interface {
+	// Entries returns the recorded actions from the offset, oldest first.
+	Entries(ctx context.Context, offset int) ([]AuditEntry, error)
+}
+
+// PriorityBroker is implemented by brokers that can order the messages of a queue by their
+// priority, so that jobs of a higher priority (JobOpts.Priority) are consumed first.
+type PriorityBroker interface {
+	// EnqueuePriority places the message in the queue, ahead of the messages of a lower
+	// priority. Messages of the same priority are consumed in the order they were enqueued.
+	EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error
+}
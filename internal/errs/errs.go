@@ -0,0 +1,8 @@
+// Package errs holds the errors shared by the server and the backends, which can't import the
+// server's package.
+package errs
+
+import "errors"
+
+// ErrNotFound is returned by results stores on getting a key that doesn't exist.
+var ErrNotFound = errors.New("not found")
@@ -0,0 +1,54 @@
+// Package natsconn shares nats connections between the nats backends, so that a broker and
+// results store (of one or more servers) connecting with the same options use a single connection.
+package natsconn
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Options are the connection options set by the backends. They're comparable, to key the
+// shared connections.
+type Options struct {
+	URL                string
+	Username, Password string
+	Token              string
+	TLS                *tls.Config
+}
+
+var (
+	mu    sync.Mutex
+	conns = make(map[Options]*nats.Conn)
+)
+
+// Conn returns the shared connection for the options, connecting if there isn't one.
+func Conn(o Options) (*nats.Conn, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Closed connections (eg: closed by the application) are replaced.
+	if c, ok := conns[o]; ok && !c.IsClosed() {
+		return c, nil
+	}
+
+	var opt []nats.Option
+	switch {
+	case o.Token != "":
+		opt = append(opt, nats.Token(o.Token))
+	case o.Username != "":
+		opt = append(opt, nats.UserInfo(o.Username, o.Password))
+	}
+	if o.TLS != nil {
+		opt = append(opt, nats.Secure(o.TLS))
+	}
+
+	c, err := nats.Connect(o.URL, opt...)
+	if err != nil {
+		return nil, err
+	}
+	conns[o] = c
+
+	return c, nil
+}
@@ -0,0 +1,63 @@
+// Package redisconn shares redis clients between the redis backends, so that a broker, results
+// store and rate limiter connecting to the same redis with the same options use a single pool.
+package redisconn
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// key is the comparable subset of the options set by the backends.
+type key struct {
+	addrs              string
+	db                 int
+	username, password string
+	tls                *tls.Config
+
+	dialTimeout, readTimeout, writeTimeout time.Duration
+	poolSize, minIdleConns                 int
+	poolTimeout, idleTimeout               time.Duration
+	maxRetries                             int
+	minRetryBackoff, maxRetryBackoff       time.Duration
+}
+
+var (
+	mu      sync.Mutex
+	clients = make(map[key]redis.UniversalClient)
+)
+
+// Client returns the shared client for the options, creating it if there isn't one.
+func Client(o *redis.UniversalOptions) redis.UniversalClient {
+	k := key{
+		addrs:           strings.Join(o.Addrs, ","),
+		db:              o.DB,
+		username:        o.Username,
+		password:        o.Password,
+		tls:             o.TLSConfig,
+		dialTimeout:     o.DialTimeout,
+		readTimeout:     o.ReadTimeout,
+		writeTimeout:    o.WriteTimeout,
+		poolSize:        o.PoolSize,
+		minIdleConns:    o.MinIdleConns,
+		poolTimeout:     o.PoolTimeout,
+		idleTimeout:     o.IdleTimeout,
+		maxRetries:      o.MaxRetries,
+		minRetryBackoff: o.MinRetryBackoff,
+		maxRetryBackoff: o.MaxRetryBackoff,
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	c, ok := clients[k]
+	if !ok {
+		c = redis.NewUniversalClient(o)
+		clients[k] = c
+	}
+
+	return c
+}
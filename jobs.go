@@ -3,15 +3,59 @@ package tasqueue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kalbhor/tasqueue/internal/errs"
 	"github.com/vmihailenco/msgpack/v5"
 	"go.opentelemetry.io/otel"
 	spans "go.opentelemetry.io/otel/trace"
 )
 
+const (
+	// jobLockPrefix prefixes the key of the lock held while a job's status is checked and set,
+	// by Cancel() and by the worker starting the job.
+	jobLockPrefix = "tasqueue:job:"
+	// jobLockTTL is the duration after which the lock of a job expires, if it isn't released.
+	jobLockTTL = time.Second * 10
+	// lockRetryDelay is the delay after which a lock that's held is tried again.
+	lockRetryDelay = time.Millisecond * 10
+)
+
+var (
+	// ErrTaskNotRegistered is returned on enqueuing a job of an unregistered task in strict mode.
+	ErrTaskNotRegistered = errors.New("task not registered")
+	// ErrPayloadTooLarge is returned on enqueuing a job whose payload exceeds the max payload size.
+	ErrPayloadTooLarge = errors.New("payload too large")
+	// ErrNoResults is returned by operations that require a results store, when the
+	// server (or client) is configured without one.
+	ErrNoResults = errors.New("results store not configured")
+	// ErrJobNotCancellable is returned on cancelling a job that is already being processed or is complete.
+	ErrJobNotCancellable = errors.New("job can not be cancelled")
+	// ErrJobNotRetryable is returned on retrying a job that hasn't failed.
+	ErrJobNotRetryable = errors.New("job can not be retried")
+	// ErrNotFound is returned by results stores on getting a key (eg: a job) that doesn't
+	// exist. Stores should return it (or wrap it), so that it's told apart from other errors.
+	ErrNotFound = errs.ErrNotFound
+	// ErrResultNotFound is returned on getting a named result that wasn't saved by the job.
+	ErrResultNotFound = errors.New("result not found")
+	// ErrQueueDraining is returned on enqueuing a job onto a queue that is being drained.
+	ErrQueueDraining = errors.New("queue is draining")
+	// ErrInvalidSignature is returned by verifiers for messages whose signature doesn't match.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrDecrypt is returned by ciphers for messages that can't be decrypted.
+	ErrDecrypt = errors.New("message can not be decrypted")
+	// ErrGatedSchedule is returned on enqueuing a scheduled job with a gate.
+	ErrGatedSchedule = errors.New("scheduled jobs can not be gated")
+	// ErrSkipRetry, wrapped in the error returned by a handler, fails the job without
+	// retrying it, eg: for invalid payloads.
+	ErrSkipRetry = errors.New("job failed without retry")
+)
+
 const (
 	resultsPrefix          = "tasqueue:result:"
 	DefaultQueue           = "tasqueue:tasks"
@@ -27,13 +71,94 @@ type Job struct {
 	Payload   []byte
 
 	Opts JobOpts
+
+	// stream, if set, is the reader of the payload of a job enqueued with EnqueueStream().
+	stream io.Reader
 }
 
+// NoRetries, set as a job's MaxRetries, disables the job's retries, overriding the default
+// MaxRetries of its task.
+const NoRetries uint32 = math.MaxUint32
+
 // JobOpts holds the various options available to configure a job.
 type JobOpts struct {
 	Queue      string
 	MaxRetries uint32
 	Schedule   string
+	// Calendar, if set, is the name of the calendar (ServerOpts.Calendars) on whose days the
+	// scheduled job runs.
+	Calendar string
+	// Timeout is the maximum duration a job's handler is allowed to run for.
+	// If it is zero, the handler is not timed out.
+	Timeout time.Duration
+
+	// Tenant is the ID of the tenant the job belongs to.
+	Tenant string
+	// Tags are indexed in the results store, to look up and manage jobs as a unit.
+	Tags []string
+	// Labels are arbitrary key/values attached to the job, which are available on the job meta.
+	Labels map[string]string
+
+	// ExpiresAt is the time after which the job is no longer useful. If a worker
+	// picks the job up after this time, it is marked as expired instead of being executed.
+	ExpiresAt time.Time
+	// Deadline is the time before which the job has to start. Prefetched jobs are processed
+	// in the order of their deadlines, and a job picked up after its deadline is marked as
+	// missed instead of being executed.
+	Deadline time.Time
+	// ETA, if set, delays the job until the time, after which it's moved onto its queue. The
+	// job is held on the results store, which has to implement Delayer, hence it's supported
+	// on any broker.
+	ETA time.Time
+
+	// NotBefore and NotAfter are the times of day (as the durations since midnight, in the
+	// WindowZone, UTC by default) between which the job can run, eg: customer SMS only during
+	// the day. A job picked up outside of its window is delayed until the window opens. If
+	// NotAfter is zero, the window lasts until midnight, and if it's before NotBefore, the
+	// window is open overnight.
+	NotBefore  time.Duration
+	NotAfter   time.Duration
+	WindowZone string
+
+	// Version is the version of the task's handler that processes the job.
+	Version string
+
+	// Gate, if set, holds the job until the gate is opened with OpenGate(), eg: for an
+	// approval step. If GateTTL is set, the job is released after it passes regardless.
+	Gate    string
+	GateTTL time.Duration
+
+	// DebounceKey, if set, coalesces the jobs enqueued with the same key within the
+	// DebounceWindow into one job, which is enqueued with the latest payload after the window.
+	DebounceKey    string
+	DebounceWindow time.Duration
+
+	// DedupWindow, if set, deduplicates the jobs identical to the job (ie: of the same task
+	// and payload) enqueued within the window, eg: for webhooks retried by their provider.
+	// Enqueuing a duplicate returns the UUID of the job instead. Debounced jobs and streamed
+	// payloads aren't deduplicated. Deduplication requires ServerOpts.Locker (or
+	// ClientOpts.Locker) to be set, otherwise the enqueue fails with ErrDedupLocker.
+	DedupWindow time.Duration
+
+	// PartitionKey, if set, processes the jobs with the same key serially and in the order they
+	// were consumed, even if the task's concurrency is more than one.
+	PartitionKey string
+
+	// Worker, if set, pins the job to the worker (ServerOpts.WorkerID): the job is enqueued
+	// onto the worker's queue of its queue (WorkerQueue()), which the worker consumes for its
+	// Pinnable tasks. If the worker isn't registered (it heartbeats) at enqueue, or it dies
+	// before processing the job, the job falls back to its queue. See JobCtx.ThenPinned().
+	Worker string
+
+	// Requires are the labels of the servers that can process the job (eg: gpu=true). The
+	// job is enqueued onto the queue's label queue (LabelQueue()), which is consumed by the
+	// servers that have all the labels (ServerOpts.Labels).
+	Requires map[string]string
+
+	// Priority orders the job within its queue: jobs of a higher priority are consumed ahead
+	// of the queue's other jobs, if the broker implements PriorityBroker. Prefetched jobs
+	// are processed in the order of their priorities regardless of the broker.
+	Priority uint8
 }
 
 // Meta contains fields related to a job. These are updated when a task is consumed.
@@ -46,7 +171,63 @@ type Meta struct {
 	MaxRetry      uint32
 	Retried       uint32
 	PrevErr       string
+	EnqueuedAt    time.Time
 	ProcessedAt   time.Time
+	Timeout       time.Duration
+	ExpiresAt     time.Time
+	Deadline      time.Time
+	ETA           time.Time
+	Tenant        string
+	Tags          []string
+	Labels        map[string]string
+	Version       string
+	// NotBefore and NotAfter are the times of day in the WindowZone between which the job runs.
+	NotBefore  time.Duration
+	NotAfter   time.Duration
+	WindowZone string
+	// Requeues counts the times the job was pushed back onto its queue by workers
+	// that don't have its task registered.
+	Requeues uint32
+	// PickedUpAt is the time the job was first picked up by a worker, and QueueWait is the
+	// total time the job waited on the queue before its attempts.
+	PickedUpAt time.Time
+	QueueWait  time.Duration
+	// Attempts are the timings of the job's attempts, in order.
+	Attempts []Attempt
+	// Gate is the gate the job is held on, and HeldUntil the time after which it's
+	// released if the gate isn't opened.
+	Gate      string
+	HeldUntil time.Time
+	// PartitionKey routes the job to a processor by the key.
+	PartitionKey string
+	// Worker is the worker the job is pinned to, if it's pinned.
+	Worker string
+	// Priority orders the job within its queue.
+	Priority uint8
+	// Baggage holds the context values injected by the server's propagator at enqueue.
+	Baggage map[string]string
+	// TraceLink holds the span context the job (or the first job of its chain) was enqueued
+	// with, which the spans of the job's attempts are linked to.
+	TraceLink map[string]string
+
+	// PayloadEncoding is set if the payload was compressed or offloaded at enqueue.
+	PayloadEncoding string
+	// ResultVersion is the version of the task's result schema the job's results are saved with.
+	ResultVersion int
+
+	// RouteUUID is the UUID of the job enqueued with the job's outcome by its task's routes,
+	// and RoutedFrom the UUID of the job whose outcome the job was routed from.
+	RouteUUID  string
+	RoutedFrom string
+
+	// ContinuationUUIDs are the UUIDs of the jobs appended by the job's handler with
+	// JobCtx.Then(), which were enqueued once it succeeded.
+	ContinuationUUIDs []string
+
+	// ChainUUID is the UUID of the chain the job is a step of, and ChainDeadline the time
+	// by which the chain has to complete.
+	ChainUUID     string
+	ChainDeadline time.Time
 
 	// PrevJobResults contains any job results set by a previous job in a chain.
 	// This will be nil if the previous job doesn't set the results on JobCtx.
@@ -55,22 +236,39 @@ type Meta struct {
 
 // DefaultMeta returns Meta with a UUID and other defaults filled in.
 func DefaultMeta(opts JobOpts) Meta {
+	if opts.MaxRetries == NoRetries {
+		opts.MaxRetries = 0
+	}
+
 	return Meta{
-		UUID:     uuid.NewString(),
-		Status:   StatusStarted,
-		MaxRetry: opts.MaxRetries,
-		Schedule: opts.Schedule,
-		Queue:    opts.Queue,
+		UUID:         uuid.NewString(),
+		Status:       StatusStarted,
+		EnqueuedAt:   time.Now(),
+		MaxRetry:     opts.MaxRetries,
+		Schedule:     opts.Schedule,
+		Queue:        opts.Queue,
+		Timeout:      opts.Timeout,
+		ExpiresAt:    opts.ExpiresAt,
+		Deadline:     opts.Deadline,
+		ETA:          opts.ETA,
+		NotBefore:    opts.NotBefore,
+		NotAfter:     opts.NotAfter,
+		WindowZone:   opts.WindowZone,
+		Tenant:       opts.Tenant,
+		Tags:         opts.Tags,
+		Labels:       opts.Labels,
+		Version:      opts.Version,
+		Gate:         opts.Gate,
+		PartitionKey: opts.PartitionKey,
+		Worker:       opts.Worker,
+		Priority:     opts.Priority,
 	}
 }
 
 // NewJob returns a job with arbitrary payload.
 // It accepts the name of the task, the payload and a list of options.
+// Options that are not set are filled in with the task's defaults when the job is enqueued.
 func NewJob(handler string, payload []byte, opts JobOpts) (Job, error) {
-	if opts.Queue == "" {
-		opts.Queue = DefaultQueue
-	}
-
 	return Job{
 		Opts:    opts,
 		Task:    handler,
@@ -79,22 +277,129 @@ func NewJob(handler string, payload []byte, opts JobOpts) (Job, error) {
 }
 
 // JobCtx is passed onto handler functions. It allows access to a job's meta information to the handler.
+// The embedded context is cancelled when the job's timeout (if any) is exceeded.
 type JobCtx struct {
+	context.Context
+
 	store Results
+	cache *lruCache
+	codec Codec
+	// handlerCache is the server's cache shared by the handlers.
+	handlerCache *HandlerCache
+	// deps are the dependencies provided on the server.
+	deps *providers
 	// results just holds the results set by calling Save().
 	results [][]byte
+	// named holds the encoded results set by calling SaveNamed().
+	named map[string][]byte
+	job   Job
+	// dir is the job's working directory, if its task is sandboxed.
+	dir string
+	// state is the task's state, set up by its OnWorkerStart hook.
+	state any
+	// next holds the jobs appended with Then(), which are enqueued if the job succeeds.
+	next *continuations
+	// worker is the ID of the worker processing the job, which ThenPinned() pins jobs to.
+	worker string
+	// payload is the job's decoded payload, and blobs and stream the blob store and key
+	// the payload is streamed from instead, if it isn't decoded.
+	payload []byte
+	blobs   BlobStore
+	stream  string
 	Meta    Meta
 }
 
 // Save() sets arbitrary results for a job in the results store.
 func (c *JobCtx) Save(b []byte) error {
+	if c.store == nil {
+		return ErrNoResults
+	}
+
 	c.results = append(c.results, b)
 	d, err := msgpack.Marshal(c.results)
 	if err != nil {
 		return err
 	}
 
-	return c.store.Set(context.Background(), resultsPrefix+c.Meta.UUID, d)
+	if err := c.store.Set(context.Background(), resultsPrefix+c.Meta.UUID, d); err != nil {
+		return err
+	}
+	c.cache.remove(resultsPrefix + c.Meta.UUID)
+
+	return nil
+}
+
+// UUID() returns the job's UUID.
+func (c *JobCtx) UUID() string {
+	return c.Meta.UUID
+}
+
+// Task() returns the name of the job's task.
+func (c *JobCtx) Task() string {
+	return c.job.Task
+}
+
+// Queue() returns the queue the job was consumed from.
+func (c *JobCtx) Queue() string {
+	return c.Meta.Queue
+}
+
+// Attempt() returns the current attempt at processing the job, starting at 1.
+func (c *JobCtx) Attempt() uint32 {
+	return c.Meta.Retried + 1
+}
+
+// MaxRetries() returns the number of times the job is retried on failure.
+func (c *JobCtx) MaxRetries() uint32 {
+	return c.Meta.MaxRetry
+}
+
+// IsLastAttempt() returns true if the job won't be retried if the current attempt fails.
+func (c *JobCtx) IsLastAttempt() bool {
+	return c.Meta.Retried >= c.Meta.MaxRetry
+}
+
+// PrevErr() returns the error of the previous failed attempt, if any.
+func (c *JobCtx) PrevErr() string {
+	return c.Meta.PrevErr
+}
+
+// EnqueuedAt() returns the time at which the job was enqueued.
+func (c *JobCtx) EnqueuedAt() time.Time {
+	return c.Meta.EnqueuedAt
+}
+
+// Schedule() returns the cron schedule of the job, if it is a scheduled job.
+func (c *JobCtx) Schedule() string {
+	return c.Meta.Schedule
+}
+
+// ExpiresAt() returns the time after which the job is no longer processed, if set.
+func (c *JobCtx) ExpiresAt() time.Time {
+	return c.Meta.ExpiresAt
+}
+
+// Tenant() returns the tenant the job belongs to, if any.
+func (c *JobCtx) Tenant() string {
+	return c.Meta.Tenant
+}
+
+// Label() returns the value of the job's label.
+func (c *JobCtx) Label(key string) string {
+	return c.Meta.Labels[key]
+}
+
+// Preempted() returns true if the job was preempted by a job of a higher priority. The job's
+// context is cancelled then, and the job is requeued once the handler returns an error.
+func (c *JobCtx) Preempted() bool {
+	return isPreempted(c)
+}
+
+// Dir() returns the job's temporary working directory if its task is sandboxed, or an empty
+// string. The directory is removed after the job, hence handlers that shell out should set it
+// as the command's working directory (and TMPDIR) rather than changing the process's.
+func (c *JobCtx) Dir() string {
+	return c.dir
 }
 
 // JobMessage is a wrapper over Task, used to transport the task over a broker.
@@ -112,32 +417,415 @@ func (t *Job) message(meta Meta) JobMessage {
 	}
 }
 
+// isExpired returns true if the job message has an expiry set and it has passed.
+func (m JobMessage) isExpired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && now.After(m.ExpiresAt)
+}
+
 // Enqueue() accepts a job and returns the assigned UUID.
 // The following steps take place:
 // 1. Converts it into a job message, which assigns a UUID (among other meta info) to the job.
 // 2. Sets the job status as "started" on the results store.
 // 3. Enqueues the job (if the job is scheduled, pushes it onto the scheduler)
 func (s *Server) Enqueue(ctx context.Context, t Job) (string, error) {
+	t, err := s.prepareJob(t)
+	if err != nil {
+		return "", err
+	}
+
 	return s.enqueueWithMeta(ctx, t, DefaultMeta(t.Opts))
 }
 
-func (s *Server) enqueueWithMeta(ctx context.Context, t Job, meta Meta) (string, error) {
+// EnqueueBatch() validates all the jobs and enqueues them one after the other, returning
+// the assigned UUIDs in the same order. If enqueuing a job fails, the UUIDs of the jobs
+// enqueued so far are returned along with the error.
+func (s *Server) EnqueueBatch(ctx context.Context, jobs []Job) ([]string, error) {
+	for _, j := range jobs {
+		if err := s.validateJob(j); err != nil {
+			return nil, fmt.Errorf("could not enqueue batch : %w", err)
+		}
+	}
+
+	uuids := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		uuid, err := s.Enqueue(ctx, j)
+		if err != nil {
+			return uuids, fmt.Errorf("could not enqueue batch : %w", err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	return uuids, nil
+}
+
+// Cancel() marks a queued (retrying, or held) job as cancelled. Workers skip cancelled jobs
+// when they are consumed. Jobs that are being processed or are complete can not be cancelled.
+// The status is checked and set under the job's lock, which the worker starting the job takes
+// too, hence cancelling a job consumed by another server requires a Locker shared with it.
+func (s *Server) Cancel(ctx context.Context, uuid string) error {
+	msg, err := s.getJob(ctx, uuid, false)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, OpCancel, msg); err != nil {
+		return err
+	}
+	if err := s.cancelJob(ctx, msg); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, OpCancel, uuid, "")
+
+	return nil
+}
+
+// cancelJob marks the job as cancelled, if it's queued. The job is read again under its lock,
+// as it may have been started meanwhile.
+func (s *Server) cancelJob(ctx context.Context, msg JobMessage) error {
+	unlock, err := s.lockJob(ctx, msg.UUID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if msg, err = s.getJob(ctx, msg.UUID, false); err != nil {
+		return err
+	}
+	switch msg.Status {
+	case StatusStarted, StatusRetrying, StatusHeld:
+	default:
+		return fmt.Errorf("could not cancel job with status %s : %w", msg.Status, ErrJobNotCancellable)
+	}
+
+	return s.statusCancelled(ctx, msg)
+}
+
+// Retry() re-enqueues a failed job with the same UUID. The job's retry count is reset.
+func (s *Server) Retry(ctx context.Context, uuid string) error {
+	msg, err := s.getJob(ctx, uuid, false)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, OpRetry, msg); err != nil {
+		return err
+	}
+
+	if msg.Status != StatusFailed {
+		return fmt.Errorf("could not retry job with status %s : %w", msg.Status, ErrJobNotRetryable)
+	}
+
+	msg.Retried = 0
+	if err := s.statusStarted(ctx, msg); err != nil {
+		return err
+	}
+	// The job is removed from the failed list before it's enqueued, as it may fail again.
+	if d, ok := s.results.(FailedDeleter); ok {
+		if err := d.DeleteFailed(ctx, uuid); err != nil {
+			return err
+		}
+	}
+	if err := s.enqueueMessage(ctx, msg); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, OpRetry, uuid, "")
+
+	return nil
+}
+
+// lockJob acquires the lock of the job's status, waiting for it if it's held, and returns the
+// function that releases it.
+func (s *Server) lockJob(ctx context.Context, id string) (func(), error) {
+	return s.waitLock(ctx, jobLockPrefix+id, jobLockTTL)
+}
+
+// waitLock acquires the key's lock, waiting for it if it's held, and returns the function that
+// releases it.
+func (s *Server) waitLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	owner := s.workerID + ":" + uuid.NewString()
+	for {
+		ok, err := s.locker.Lock(ctx, key, owner, ttl)
+		if err != nil {
+			return nil, fmt.Errorf("could not acquire lock %s : %w", key, err)
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.clock.After(lockRetryDelay):
+		}
+	}
+
+	return func() {
+		// The context may be cancelled, hence a new one is used to unlock.
+		if err := s.locker.Unlock(context.Background(), key, owner); err != nil {
+			s.log.Error("error releasing lock", "key", key, "error", err)
+		}
+	}, nil
+}
+
+// startJob sets the status of the consumed job with set, unless the job was cancelled while it
+// waited in the queue, in which case it returns false. The job is checked and its status set
+// under its lock, so that it's either cancelled or started.
+func (s *Server) startJob(ctx context.Context, msg JobMessage, set func(context.Context, JobMessage) error) (bool, error) {
+	// Jobs can't be cancelled without a results store.
+	if s.results == nil {
+		return true, set(ctx, msg)
+	}
+
+	unlock, err := s.lockJob(ctx, msg.UUID)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if stored, err := s.getJob(ctx, msg.UUID, false); err == nil && stored.Status == StatusCancelled {
+		// The cancellation is emitted by the worker which skips the job, see statusCancelled.
+		s.emit(stored)
+		return false, nil
+	}
+
+	return true, set(ctx, msg)
+}
+
+// validateJob checks that the job's task is registered (in strict mode) and that
+// the payload is within the max payload size (if set).
+func (s *Server) validateJob(t Job) error {
+	if t.Opts.Gate != "" && t.Opts.Schedule != "" {
+		return fmt.Errorf("could not enqueue job %s : %w", t.Task, ErrGatedSchedule)
+	}
+	if t.Opts.Schedule != "" && !s.mode.schedules() {
+		return fmt.Errorf("could not enqueue job %s : %w", t.Task, ErrNoScheduler)
+	}
+	if t.Opts.Schedule != "" {
+		if err := ValidateSchedule(t.Opts.Schedule); err != nil {
+			return fmt.Errorf("could not enqueue job %s : %w", t.Task, err)
+		}
+	}
+	if t.Opts.Calendar != "" {
+		if t.Opts.Schedule == "" {
+			return fmt.Errorf("could not enqueue job %s : calendar set without a schedule", t.Task)
+		}
+		if _, err := s.calendar(t.Opts.Calendar); err != nil {
+			return fmt.Errorf("could not enqueue job %s : %w", t.Task, err)
+		}
+	}
+	if t.Opts.DebounceKey != "" {
+		if t.Opts.Gate != "" || t.Opts.Schedule != "" {
+			return fmt.Errorf("could not enqueue job %s : debounced jobs can not be gated or scheduled", t.Task)
+		}
+		if t.Opts.DebounceWindow <= 0 {
+			return fmt.Errorf("could not enqueue job %s : debounce window missing", t.Task)
+		}
+	}
+	if t.Opts.Worker != "" {
+		if s.results == nil {
+			return fmt.Errorf("could not enqueue job %s : %w", t.Task, ErrNoResults)
+		}
+		if t.Opts.Schedule != "" {
+			return fmt.Errorf("could not enqueue job %s : scheduled jobs can not be pinned", t.Task)
+		}
+	}
+	if err := validateWindow(t.Opts); err != nil {
+		return fmt.Errorf("could not enqueue job %s : %w", t.Task, err)
+	}
+	if err := s.validateETA(t.Opts); err != nil {
+		return fmt.Errorf("could not enqueue job %s : %w", t.Task, err)
+	}
+	if err := validateLabels(t.Opts.Requires); err != nil {
+		return fmt.Errorf("could not enqueue job %s : %w", t.Task, err)
+	}
+	if s.strict {
+		if _, err := s.getHandler(t.Task, ""); err != nil {
+			return fmt.Errorf("could not enqueue job %s : %w", t.Task, ErrTaskNotRegistered)
+		}
+	}
+	// Other payload policies are applied when the job is enqueued.
+	if s.maxPayloadSize > 0 && s.payloadPolicy == PayloadReject && len(t.Payload) > s.maxPayloadSize {
+		return fmt.Errorf("could not enqueue job %s of %d bytes : %w", t.Task, len(t.Payload), ErrPayloadTooLarge)
+	}
+
+	return nil
+}
+
+// prepareJob validates the job and fills in the options which aren't set on
+// the job with the task's defaults.
+func (s *Server) prepareJob(t Job) (Job, error) {
+	if err := s.validateJob(t); err != nil {
+		return Job{}, err
+	}
+
+	// If the task isn't registered on this server, there are no defaults to apply.
+	task, _ := s.getHandler(t.Task, t.Opts.Version)
+	if t.stream == nil {
+		if err := task.validate(t.Payload, ValidateEnqueue); err != nil {
+			return Job{}, err
+		}
+	}
+
+	// The job's options take precedence over the task's defaults.
+	if t.Opts.Queue == "" {
+		t.Opts.Queue = task.opts.Queue
+	}
+	if t.Opts.MaxRetries == 0 {
+		t.Opts.MaxRetries = task.opts.MaxRetries
+	}
+	if t.Opts.Timeout == 0 {
+		t.Opts.Timeout = task.opts.Timeout
+	}
+	if t.Opts.DedupWindow == 0 {
+		t.Opts.DedupWindow = task.opts.DedupWindow
+	}
+
+	// Fallback to the default queue if the task isn't registered on this server.
+	if t.Opts.Queue == "" {
+		t.Opts.Queue = DefaultQueue
+	}
+	if t.Opts.Tenant != "" && s.tenantQueues {
+		t.Opts.Queue = TenantQueue(t.Opts.Queue, t.Opts.Tenant)
+	}
+	// Jobs that require labels are enqueued onto the queue's label queue.
+	t.Opts.Queue = LabelQueue(t.Opts.Queue, t.Opts.Requires)
+
+	return t, nil
+}
+
+func (s *Server) enqueueWithMeta(ctx context.Context, t Job, meta Meta) (_ string, err error) {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "enqueue_with_meta")
 		defer span.End()
+		if meta.TraceLink == nil {
+			meta.TraceLink = traceCarrier(ctx)
+		}
+	}
+	meta.EnqueuedAt = s.clock.Now()
+	meta.UUID = s.ids.NewID(meta.EnqueuedAt)
+	if meta.Gate != "" && t.Opts.GateTTL > 0 {
+		meta.HeldUntil = meta.EnqueuedAt.Add(t.Opts.GateTTL)
+	}
+
+	if s.propagator != nil {
+		meta.Baggage = make(map[string]string)
+		s.propagator.Inject(ctx, meta.Baggage)
+	}
+
+	// Identical jobs are deduplicated by their payload before it's encoded.
+	var key string
+	if t.Opts.DedupWindow > 0 && t.Opts.DebounceKey == "" && t.stream == nil {
+		key = dedupKey(t)
+	}
+
+	// Compress or offload the payload if it's too large.
+	if err := s.encodePayload(ctx, &t, &meta); err != nil {
+		s.spanError(span, err)
+		return "", err
 	}
 
 	var (
 		msg = t.message(meta)
 	)
+	if s.isDraining(msg.Queue) {
+		return "", fmt.Errorf("could not enqueue job %s : %w", t.Task, ErrQueueDraining)
+	}
+
+	// Deduplicate the job with the identical job enqueued within its window, if any.
+	if key != "" {
+		var (
+			uuid    string
+			release func()
+			ok      bool
+		)
+		// err is the function's result, which the deferred release checks.
+		uuid, release, ok, err = s.dedup(ctx, msg, key)
+		if err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+		if ok {
+			s.deletePayload(ctx, msg)
+			return uuid, nil
+		}
+		defer func() {
+			if err != nil {
+				release()
+			}
+		}()
+	}
+
+	// Coalesce the job with the held job of its debounce key, if any. The key is locked until
+	// the job is held, so that concurrent jobs of the key are coalesced with it.
+	if t.Opts.DebounceKey != "" {
+		uuid, ok, unlock, err := s.debounce(ctx, &msg, t.Opts.DebounceKey)
+		if err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+		defer unlock()
+		if ok {
+			return uuid, nil
+		}
+	}
+
+	// Pin the job to its worker, by enqueuing it onto the worker's queue.
+	if msg.Worker != "" {
+		if err := s.pin(ctx, &msg); err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+	}
+
+	// Enforce the max length of the queue the job is enqueued onto.
+	delayed := msg.ETA.After(meta.EnqueuedAt)
+	if msg.Gate == "" && t.Opts.Schedule == "" && !delayed {
+		if err := s.enforceLimit(ctx, &msg); err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+	}
 
 	// Set job status in the results backend.
-	if err := s.statusStarted(ctx, msg); err != nil {
+	setStatus := s.statusStarted
+	if msg.Gate != "" {
+		setStatus = s.statusHeld
+	}
+	if err := setStatus(ctx, msg); err != nil {
+		s.spanError(span, err)
+		return "", err
+	}
+
+	if err := s.setTags(ctx, msg); err != nil {
 		s.spanError(span, err)
 		return "", err
 	}
 
+	if err := s.indexJob(ctx, msg); err != nil {
+		s.spanError(span, err)
+		return "", err
+	}
+
+	// If a gate is set, hold the job until it's opened.
+	if msg.Gate != "" {
+		if err := s.holdJob(ctx, msg); err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+		// The debounced job is released by the server's run loop once the window passes.
+		if t.Opts.DebounceKey != "" {
+			s.scheduleRelease(msg.Gate, msg.HeldUntil)
+		}
+		return msg.UUID, nil
+	}
+
+	// If an ETA is set, hold the job until it's due.
+	if delayed {
+		if err := s.delayJob(ctx, msg); err != nil {
+			s.spanError(span, err)
+			return "", err
+		}
+		return msg.UUID, nil
+	}
+
 	// If a schedule is set, add a cron job.
 	if t.Opts.Schedule != "" {
 		if err := s.enqueueScheduled(ctx, msg); err != nil {
@@ -151,20 +839,26 @@ func (s *Server) enqueueWithMeta(ctx context.Context, t Job, meta Meta) (string,
 		s.spanError(span, err)
 		return "", err
 	}
+	s.countStat(ctx, msg, statEnqueued, 0)
 
 	return msg.UUID, nil
 }
 
 func (s *Server) enqueueScheduled(ctx context.Context, msg JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "enqueue_scheduled")
 		defer span.End()
 	}
 
 	schJob := newScheduled(ctx, s.log, s.broker, msg)
 	// TODO: maintain a map of scheduled cron tasks
-	if _, err := s.cron.AddJob(msg.Schedule, schJob); err != nil {
+	cal, err := s.calendar(msg.Job.Opts.Calendar)
+	if err != nil {
+		s.spanError(span, err)
+		return err
+	}
+	if err := s.sched.add(msg.Schedule, cal, schJob); err != nil {
 		s.spanError(span, err)
 		return err
 	}
@@ -174,7 +868,7 @@ func (s *Server) enqueueScheduled(ctx context.Context, msg JobMessage) error {
 
 func (s *Server) enqueueMessage(ctx context.Context, msg JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "enqueue_message")
 		defer span.End()
 	}
@@ -185,7 +879,7 @@ func (s *Server) enqueueMessage(ctx context.Context, msg JobMessage) error {
 		return err
 	}
 
-	if err := s.broker.Enqueue(ctx, b, msg.Queue); err != nil {
+	if err := enqueuePriority(ctx, s.broker, b, msg.Queue, msg.Priority); err != nil {
 		s.spanError(span, err)
 		return err
 	}
@@ -193,9 +887,25 @@ func (s *Server) enqueueMessage(ctx context.Context, msg JobMessage) error {
 	return nil
 }
 
+// setJobMessage stores the job message and emits the status change to the event listeners.
 func (s *Server) setJobMessage(ctx context.Context, t JobMessage) error {
+	if err := s.storeJobMessage(ctx, t); err != nil {
+		return err
+	}
+	s.emit(t)
+
+	return nil
+}
+
+// storeJobMessage stores the job message, without emitting the status change.
+func (s *Server) storeJobMessage(ctx context.Context, t JobMessage) error {
+	// Job state isn't tracked without a results store.
+	if s.results == nil {
+		return nil
+	}
+
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "set_job_message")
 		defer span.End()
 	}
@@ -209,6 +919,7 @@ func (s *Server) setJobMessage(ctx context.Context, t JobMessage) error {
 		s.spanError(span, err)
 		return fmt.Errorf("could not set job message in store : %w", err)
 	}
+	s.cache.set(t.UUID, b)
 
 	return nil
 }
@@ -216,16 +927,30 @@ func (s *Server) setJobMessage(ctx context.Context, t JobMessage) error {
 // GetJob accepts a UUID and returns the job message in the results store.
 // This is useful to check the status of a job message.
 func (s *Server) GetJob(ctx context.Context, uuid string) (JobMessage, error) {
+	return s.getJob(ctx, uuid, true)
+}
+
+// getJob returns the job message, from the cache if cached is set. State transitions
+// read the job fresh from the results store, as the job may have been updated elsewhere.
+func (s *Server) getJob(ctx context.Context, uuid string, cached bool) (JobMessage, error) {
+	if s.results == nil {
+		return JobMessage{}, ErrNoResults
+	}
+
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "get_job")
 		defer span.End()
 	}
 
-	b, err := s.results.Get(ctx, uuid)
-	if err != nil {
-		s.spanError(span, err)
-		return JobMessage{}, err
+	b, ok := s.cache.get(uuid)
+	if !ok || !cached {
+		var err error
+		if b, err = s.results.Get(ctx, uuid); err != nil {
+			s.spanError(span, err)
+			return JobMessage{}, err
+		}
+		s.cache.set(uuid, b)
 	}
 
 	var t JobMessage
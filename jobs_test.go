@@ -3,6 +3,7 @@ package tasqueue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 )
@@ -52,6 +53,196 @@ func TestGetJob(t *testing.T) {
 	}
 }
 
+func TestCancelWhileStarting(t *testing.T) {
+	var (
+		srv = newServer(t)
+		ctx = context.Background()
+	)
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A job cancelled while it's being started waits for its status to be set, and fails.
+	cancelled := make(chan error, 1)
+	started, err := srv.startJob(ctx, msg, func(ctx context.Context, m JobMessage) error {
+		go func() { cancelled <- srv.Cancel(ctx, uuid) }()
+		select {
+		case err := <-cancelled:
+			t.Errorf("expected cancel to wait for the job to start, got %v", err)
+		case <-time.After(time.Millisecond * 100):
+		}
+		return srv.statusProcessing(ctx, m)
+	})
+	if err != nil || !started {
+		t.Fatalf("expected the job to start, got %v, %v", started, err)
+	}
+	if err := <-cancelled; !errors.Is(err, ErrJobNotCancellable) {
+		t.Fatalf("expected %v, got %v", ErrJobNotCancellable, err)
+	}
+
+	// A job cancelled before it's started isn't started.
+	uuid, err = srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Cancel(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	msg.UUID = uuid
+	if started, err := srv.startJob(ctx, msg, srv.statusProcessing); err != nil || started {
+		t.Fatalf("expected the cancelled job not to start, got %v, %v", started, err)
+	}
+	if msg, err = srv.GetJob(ctx, uuid); err != nil || msg.Status != StatusCancelled {
+		t.Fatalf("expected the job to stay cancelled, got %s, %v", msg.Status, err)
+	}
+}
+
+func TestExpiredJob(t *testing.T) {
+	var (
+		srv = newServer(t)
+		ctx = context.Background()
+		job = makeJob(t, false)
+	)
+	job.Opts.ExpiresAt = time.Now().Add(-time.Minute)
+	go srv.Start(ctx)
+
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for task to be consumed & processed.
+	time.Sleep(time.Second)
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Status != StatusExpired {
+		t.Fatalf("incorrect job status, expected %s, got %s", StatusExpired, msg.Status)
+	}
+}
+
+func TestTaskDefaults(t *testing.T) {
+	var (
+		srv = newServer(t)
+		ctx = context.Background()
+	)
+	srv.RegisterTask("defaults", MockHandler, TaskOpts{
+		Queue:      "defaults-queue",
+		MaxRetries: 3,
+		Timeout:    time.Minute,
+	})
+
+	job, err := NewJob("defaults", nil, JobOpts{MaxRetries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Queue != "defaults-queue" || msg.MaxRetry != 5 || msg.Timeout != time.Minute {
+		t.Fatalf("incorrect job options, got queue %s, max retry %d, timeout %v", msg.Queue, msg.MaxRetry, msg.Timeout)
+	}
+
+	// The task's retries can be disabled for a job.
+	job, err = NewJob("defaults", nil, JobOpts{MaxRetries: NoRetries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuid, err = srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = srv.GetJob(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if msg.MaxRetry != 0 {
+		t.Fatalf("expected the job's retries to be disabled, got max retry %d", msg.MaxRetry)
+	}
+}
+
+func TestStrictEnqueue(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:        NewMockBroker(),
+		Results:       NewMockResults(),
+		StrictEnqueue: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srv.Enqueue(context.Background(), makeJob(t, false)); !errors.Is(err, ErrTaskNotRegistered) {
+		t.Fatalf("expected %v enqueuing job of unregistered task, got %v", ErrTaskNotRegistered, err)
+	}
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:         NewMockBroker(),
+		Results:        NewMockResults(),
+		MaxPayloadSize: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srv.Enqueue(context.Background(), makeJob(t, false)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected %v enqueuing large payload, got %v", ErrPayloadTooLarge, err)
+	}
+}
+
+func TestJobCtxDetails(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		srv      = newServer(t)
+		attempts = make(chan JobCtx, 2)
+	)
+	srv.RegisterTask("details", func(b []byte, c JobCtx) error {
+		attempts <- c
+		if !c.IsLastAttempt() {
+			return errors.New("first attempt")
+		}
+		return nil
+	}, TaskOpts{Queue: "details"})
+	go srv.Start(ctx)
+
+	job, err := NewJob("details", nil, JobOpts{MaxRetries: 1, Labels: map[string]string{"user": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint32(1); i <= 2; i++ {
+		select {
+		case c := <-attempts:
+			if c.UUID() != uuid || c.Task() != "details" || c.Queue() != "details" || c.Label("user") != "1" {
+				t.Fatalf("unexpected job details %s %s %s", c.UUID(), c.Task(), c.Queue())
+			}
+			if c.Attempt() != i || c.EnqueuedAt().IsZero() {
+				t.Fatalf("expected attempt %d, got %d", i, c.Attempt())
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatalf("attempt %d was not processed", i)
+		}
+	}
+}
+
 func makeJob(t *testing.T, f bool) Job {
 	j, err := json.Marshal(MockPayload{ShouldErr: f})
 	if err != nil {
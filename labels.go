@@ -0,0 +1,63 @@
+package tasqueue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidLabel is returned for labels whose key is empty, or whose key or value has
+// a "=" or ",".
+var ErrInvalidLabel = errors.New("invalid label")
+
+// LabelQueue returns the name of the queue onto which the jobs that require the labels
+// (JobOpts.Requires) are enqueued, which is consumed by the servers that have the labels.
+func LabelQueue(queue string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return queue
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return queue + "@" + strings.Join(pairs, ",")
+}
+
+// labelQueues returns the label queues of the queue consumed by a server with the labels,
+// one for each combination of the labels.
+func labelQueues(queue string, labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Each non-empty subset of the keys is a combination.
+	out := make([]string, 0, 1<<len(keys)-1)
+	for set := 1; set < 1<<len(keys); set++ {
+		combo := make(map[string]string)
+		for i, k := range keys {
+			if set&(1<<i) != 0 {
+				combo[k] = labels[k]
+			}
+		}
+		out = append(out, LabelQueue(queue, combo))
+	}
+
+	return out
+}
+
+// validateLabels checks that the labels can be encoded in a label queue's name.
+func validateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if k == "" || strings.ContainsAny(k, "=,") || strings.ContainsAny(v, "=,") {
+			return fmt.Errorf("invalid label %q=%q : %w", k, v, ErrInvalidLabel)
+		}
+	}
+
+	return nil
+}
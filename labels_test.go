@@ -0,0 +1,79 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+)
+
+func TestLabelQueues(t *testing.T) {
+	if q := LabelQueue("q", map[string]string{"region": "eu", "gpu": "true"}); q != "q@gpu=true,region=eu" {
+		t.Fatalf("expected the labels to be sorted, got %s", q)
+	}
+	if q := LabelQueue("q", nil); q != "q" {
+		t.Fatalf("expected the queue without labels, got %s", q)
+	}
+
+	got := labelQueues("q", map[string]string{"region": "eu", "gpu": "true"})
+	expected := []string{"q@gpu=true", "q@region=eu", "q@gpu=true,region=eu"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if _, err := NewServer(ServerOpts{Broker: NewMockBroker(), Labels: map[string]string{"a=b": "c"}}); !errors.Is(err, ErrInvalidLabel) {
+		t.Fatalf("expected invalid labels to be rejected, got %v", err)
+	}
+}
+
+func TestLabelRouting(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		broker      = mb.New()
+		ran         = make(chan string, 4)
+	)
+	defer cancel()
+
+	var plain *Server
+	for _, labels := range []map[string]string{nil, {"gpu": "true", "region": "eu"}} {
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Labels: labels})
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "plain"
+		if labels != nil {
+			name = "gpu"
+		} else {
+			plain = srv
+		}
+		srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+			ran <- string(b) + ":" + name
+			return nil
+		}, TaskOpts{})
+		go srv.Start(ctx)
+	}
+
+	job, err := NewJob(taskName, []byte("render"), JobOpts{Requires: map[string]string{"gpu": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-ran:
+		if r != "render:gpu" {
+			t.Fatalf("expected the job to be processed by the server with the label, got %s", r)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the job to be processed")
+	}
+
+	job.Opts.Requires = map[string]string{"a=b": "c"}
+	if _, err := plain.Enqueue(ctx, job); !errors.Is(err, ErrInvalidLabel) {
+		t.Fatalf("expected invalid labels to be rejected, got %v", err)
+	}
+}
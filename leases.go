@@ -0,0 +1,243 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	workerPrefix = "tasqueue:worker:"
+	// workersKey is the set of leases of the ID's of the workers which heartbeat.
+	workersKey = "tasqueue:workers"
+	// leasePrefix prefixes the set of leases of a worker's in-flight jobs.
+	leasePrefix = "tasqueue:lease:"
+
+	defaultRecoveryInterval = time.Minute
+	// recoveryBeats is the number of the server's heartbeat periods after which a worker is
+	// considered dead, if RecoveryOpts.Timeout isn't set.
+	recoveryBeats = 3
+)
+
+var (
+	// ErrLeaseUnsupported is returned by the operations that require heartbeats, if the results
+	// store doesn't implement Leaser.
+	ErrLeaseUnsupported = errors.New("results store doesn't support leases")
+	// ErrRecoveryTimeout is returned on recovering jobs without a positive timeout, which would
+	// consider every worker dead.
+	ErrRecoveryTimeout = errors.New("recovery timeout must be positive")
+)
+
+// RecoveryPolicy is the action taken on the jobs orphaned by a dead worker.
+type RecoveryPolicy string
+
+const (
+	// RecoverRetry re-enqueues orphaned jobs which have retries left and fails the rest.
+	RecoverRetry RecoveryPolicy = "retry"
+	// RecoverFail fails orphaned jobs.
+	RecoverFail RecoveryPolicy = "fail"
+)
+
+// RecoveryOpts configures the recovery of jobs orphaned by dead workers.
+type RecoveryOpts struct {
+	// Timeout is the duration since a worker's last heartbeat, after which it is considered
+	// dead. It should be a few multiples of the workers' heartbeat period. Defaults to three
+	// of the server's heartbeat periods, if it heartbeats.
+	Timeout time.Duration
+	// Interval is the duration between recovery runs. Defaults to a minute.
+	Interval time.Duration
+	// Policy defaults to RecoverRetry.
+	Policy RecoveryPolicy
+}
+
+// worker is the heartbeat record of a worker in the results store.
+type worker struct {
+	ID        string
+	Heartbeat time.Time
+}
+
+// leasesEnabled returns true if the server heartbeats and tracks its in-flight jobs.
+func (s *Server) leasesEnabled() bool {
+	return s.heartbeat > 0 && s.leaser != nil
+}
+
+// supportsLeases returns true if the results store, or the store wrapped by a namespace,
+// implements Leaser.
+func supportsLeases(r Results) bool {
+	if ns, ok := r.(nsResults); ok {
+		r = ns.Results
+	}
+	_, ok := r.(Leaser)
+	return ok
+}
+
+// runHeartbeat periodically records the worker's heartbeat until the context is cancelled,
+// after which the worker is deregistered. It is a blocking function.
+func (s *Server) runHeartbeat(ctx context.Context) {
+	for {
+		if err := s.beat(ctx); err != nil {
+			s.log.Error("error recording heartbeat", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			// The context is cancelled, hence a new one is used to deregister.
+			if err := s.leaser.Unlease(context.Background(), workersKey, s.workerID); err != nil {
+				s.log.Error("error deregistering worker", "error", err)
+			}
+			return
+		case <-s.clock.After(s.heartbeat):
+		}
+	}
+}
+
+func (s *Server) beat(ctx context.Context) error {
+	b, err := json.Marshal(worker{ID: s.workerID, Heartbeat: s.clock.Now()})
+	if err != nil {
+		return err
+	}
+	// The record is set before registering, so that registered workers always have one.
+	if err := s.results.Set(ctx, workerPrefix+s.workerID, b); err != nil {
+		return err
+	}
+
+	return s.leaser.Lease(ctx, workersKey, s.workerID)
+}
+
+// lease records the job as in-flight on the worker.
+func (s *Server) lease(ctx context.Context, uuid string) error {
+	return s.leaser.Lease(ctx, leasePrefix+s.workerID, uuid)
+}
+
+// unlease removes the job from the in-flight jobs of the worker.
+func (s *Server) unlease(ctx context.Context, uuid string) error {
+	return s.leaser.Unlease(ctx, leasePrefix+s.workerID, uuid)
+}
+
+// RecoverJobs() looks up the workers which haven't heartbeat within the timeout, and retries or
+// fails the jobs they were processing according to the policy. The jobs pinned to them, which
+// are waiting on their worker queues, are moved back onto their queues. It returns the number
+// of jobs recovered.
+func (s *Server) RecoverJobs(ctx context.Context, o RecoveryOpts) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+	if s.leaser == nil {
+		return 0, ErrLeaseUnsupported
+	}
+	if o.Policy == "" {
+		o.Policy = RecoverRetry
+	}
+	if o.Timeout == 0 {
+		o.Timeout = s.heartbeat * recoveryBeats
+	}
+	if o.Timeout <= 0 {
+		return 0, ErrRecoveryTimeout
+	}
+
+	ids, err := s.leaser.GetLeases(ctx, workersKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		cutoff = s.clock.Now().Add(-o.Timeout)
+		n      int
+	)
+	for _, id := range ids {
+		b, err := s.results.Get(ctx, workerPrefix+id)
+		if err != nil {
+			return n, fmt.Errorf("could not get worker %s : %w", id, err)
+		}
+		var w worker
+		if err := json.Unmarshal(b, &w); err != nil {
+			return n, err
+		}
+		if w.Heartbeat.After(cutoff) {
+			continue
+		}
+
+		s.log.Info("recovering jobs of dead worker", "worker", id, "heartbeat", w.Heartbeat)
+		uuids, err := s.leaser.GetLeases(ctx, leasePrefix+id)
+		if err != nil {
+			return n, err
+		}
+		for _, uuid := range uuids {
+			ok, err := s.recoverJob(ctx, uuid, o.Policy)
+			if err != nil {
+				return n, err
+			}
+			if ok {
+				n++
+			}
+			if err := s.leaser.Unlease(ctx, leasePrefix+id, uuid); err != nil {
+				return n, err
+			}
+		}
+
+		// The jobs pinned to the worker fall back to their queues.
+		moved, err := s.unpinJobs(ctx, id)
+		n += moved
+		if err != nil {
+			return n, err
+		}
+
+		// The worker is deregistered before its record is deleted, so that registered
+		// workers always have one.
+		if err := s.leaser.Unlease(ctx, workersKey, id); err != nil {
+			return n, err
+		}
+		if err := s.results.Delete(ctx, workerPrefix+id); err != nil {
+			return n, err
+		}
+	}
+	s.metrics.GetOrCreateCounter(metricJobsRecovered).Add(n)
+
+	return n, nil
+}
+
+// recoverJob retries or fails the job if it's still being processed, and returns true if it was.
+func (s *Server) recoverJob(ctx context.Context, uuid string, policy RecoveryPolicy) (bool, error) {
+	msg, err := s.getJob(ctx, uuid, false)
+	if err != nil {
+		return false, err
+	}
+	// The worker may have died after the job was complete, but before it was unleased.
+	if msg.Status != StatusProcessing {
+		return false, nil
+	}
+
+	msg.PrevErr = "worker stopped while processing the job"
+	// A pinned job is retried on its queue, as its worker is dead.
+	if msg.Worker != "" {
+		msg.unpin()
+	}
+	if policy == RecoverRetry && msg.Retried < msg.MaxRetry {
+		return true, s.retryJob(ctx, msg, 0)
+	}
+
+	return true, s.statusFailed(ctx, msg)
+}
+
+// RunRecovery() periodically recovers the jobs of dead workers. It is a blocking function.
+func (s *Server) RunRecovery(ctx context.Context, o RecoveryOpts) {
+	if o.Interval == 0 {
+		o.Interval = defaultRecoveryInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(o.Interval):
+			n, err := s.RecoverJobs(ctx, o)
+			if err != nil {
+				s.log.Error("error recovering jobs", "error", err)
+				continue
+			}
+			s.log.Debug("recovered jobs", "count", n)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecoverJobs(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+
+	// Lease a job being processed to a worker with a stale heartbeat.
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.statusProcessing(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(worker{ID: "dead", Heartbeat: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.results.Set(ctx, workerPrefix+"dead", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.leaser.Lease(ctx, workersKey, "dead"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.leaser.Lease(ctx, leasePrefix+"dead", uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// A live worker isn't recovered.
+	if err := srv.beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := srv.RecoverJobs(ctx, RecoveryOpts{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 job recovered, got %d", n)
+	}
+
+	msg, err = srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusRetrying || msg.Retried != 1 {
+		t.Fatalf("expected job to be retrying, got status %s", msg.Status)
+	}
+
+	ids, err := srv.leaser.GetLeases(ctx, workersKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != srv.workerID {
+		t.Fatalf("expected only the live worker to be registered, got %v", ids)
+	}
+
+	// The leases aren't tags.
+	if ids, err := getTag(ctx, srv.results, workersKey); err != nil || len(ids) != 0 {
+		t.Fatalf("expected no tag of the workers, got %v (%v)", ids, err)
+	}
+}
+
+// plainResults hides the Leaser implementation of the results store.
+type plainResults struct {
+	Results
+}
+
+func TestLeasesUnsupported(t *testing.T) {
+	ctx := context.Background()
+	for _, ns := range []string{"", "test"} {
+		srv, err := NewServer(ServerOpts{
+			Broker:          NewMockBroker(),
+			Results:         plainResults{NewMockResults()},
+			HeartbeatPeriod: time.Second,
+			Namespace:       ns,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if srv.leasesEnabled() {
+			t.Fatalf("expected leases to be disabled in namespace %q", ns)
+		}
+		if _, err := srv.RecoverJobs(ctx, RecoveryOpts{Timeout: time.Minute}); !errors.Is(err, ErrLeaseUnsupported) {
+			t.Fatalf("expected %v, got %v", ErrLeaseUnsupported, err)
+		}
+	}
+}
+
+func TestRecoveryTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	// Without a timeout or a heartbeat to default it to, every worker would be considered dead.
+	srv := newServer(t)
+	for _, timeout := range []time.Duration{0, -time.Minute} {
+		if _, err := srv.RecoverJobs(ctx, RecoveryOpts{Timeout: timeout}); !errors.Is(err, ErrRecoveryTimeout) {
+			t.Fatalf("expected %v with timeout %v, got %v", ErrRecoveryTimeout, timeout, err)
+		}
+	}
+
+	// The timeout defaults to the server's heartbeat periods, and a live worker isn't recovered.
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults(), HeartbeatPeriod: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.lease(ctx, "job"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := srv.RecoverJobs(ctx, RecoveryOpts{}); err != nil || n != 0 {
+		t.Fatalf("expected no jobs recovered from the live worker, got %d, %v", n, err)
+	}
+	if ids, err := srv.leaser.GetLeases(ctx, workersKey); err != nil || len(ids) != 1 {
+		t.Fatalf("expected the live worker to stay registered, got %v, %v", ids, err)
+	}
+}
@@ -0,0 +1,36 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// startWorker calls the task's OnWorkerStart hook, if any, and returns the state it sets up.
+func (s *Server) startWorker(ctx context.Context, task Task) (any, error) {
+	if task.opts.OnWorkerStart == nil {
+		return nil, nil
+	}
+	return task.opts.OnWorkerStart(ctx)
+}
+
+// stopWorker calls the task's OnWorkerStop hook with its state, once its processors have
+// exited, ie: after the task is unregistered (or re-registered) or the server is stopped.
+func (s *Server) stopWorker(task Task, processors *sync.WaitGroup) {
+	if task.opts.OnWorkerStop == nil {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		processors.Wait()
+		task.opts.OnWorkerStop(task.state)
+		s.wg.Done()
+	}()
+}
+
+// State() returns the state set up by the task's OnWorkerStart hook, which is shared by the
+// task's jobs on the server, hence it should be safe for concurrent use. It is nil if the
+// task has no hook, or if the job isn't processed by a started server (eg: Server.Process).
+func (c *JobCtx) State() any {
+	return c.state
+}
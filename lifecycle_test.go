@@ -0,0 +1,96 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		broker  = NewMockBroker()
+		states  = make(chan any, 1)
+		stopped = make(chan any, 1)
+		starts  int
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("model", func(b []byte, c JobCtx) error {
+		states <- c.State()
+		return nil
+	}, TaskOpts{
+		Concurrency: 3,
+		OnWorkerStart: func(ctx context.Context) (any, error) {
+			starts++
+			return "model", nil
+		},
+		OnWorkerStop: func(state any) {
+			stopped <- state
+		},
+	})
+	// A task whose hook fails isn't started.
+	srv.RegisterTask("broken", func(b []byte, c JobCtx) error {
+		t.Error("expected the task whose start hook failed not to be started")
+		return nil
+	}, TaskOpts{
+		Queue: "broken",
+		OnWorkerStart: func(ctx context.Context) (any, error) {
+			return nil, errors.New("failed")
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		srv.Start(ctx)
+		close(done)
+	}()
+
+	job, err := NewJob("model", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-states:
+		if s != "model" {
+			t.Fatalf("expected the handler to get the task's state, got %v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job was not processed")
+	}
+
+	// The task whose hook failed is removed.
+	srv.p.RLock()
+	_, ok := srv.tasks[taskKey("broken", "")]
+	srv.p.RUnlock()
+	if ok {
+		t.Fatal("expected the task whose start hook failed to be removed")
+	}
+
+	// The state is torn down once the server is stopped.
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("server was not stopped")
+	}
+	select {
+	case s := <-stopped:
+		if s != "model" {
+			t.Fatalf("expected the task's state to be torn down, got %v", s)
+		}
+	default:
+		t.Fatal("expected the stop hook to be called before the server returns")
+	}
+	if starts != 1 {
+		t.Fatalf("expected the start hook to be called once, got %d", starts)
+	}
+}
@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/redisconn"
+)
+
+const keyPrefix = "tasqueue:ratelimit:"
+
+// script implements GCRA (generic cell rate algorithm), which is equivalent to a token bucket
+// holding upto a second's worth of tokens. The theoretical arrival time (TAT) of the next job
+// is stored in the key, and the server's time is used so that clients' clocks don't matter.
+// Writes after TIME require script effects replication on redis < 5. It returns the number
+// of milliseconds to wait, or 0 if a token was taken.
+var script = redis.NewScript(`
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+if now < tat - tolerance then
+	return math.max(1, math.ceil(tat - tolerance - now))
+end
+
+local new_tat = tat + interval
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil(new_tat - now + 1000))
+return 0
+`)
+
+// refundScript moves the key's TAT back by an interval, no earlier than the current time, to
+// return a token taken by the script.
+var refundScript = redis.NewScript(`
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat then
+	return 0
+end
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local new_tat = math.max(now, tat - interval)
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil(new_tat - now + 1000))
+return 0
+`)
+
+// Limiter is a redis based rate limiter, which enforces rates across all the servers sharing it.
+type Limiter struct {
+	conn redis.UniversalClient
+}
+
+type Options struct {
+	Addrs        []string
+	Auth         auth.Options
+	DB           int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// New() returns a new instance of redis rate limiter.
+func New(o Options) *Limiter {
+	return &Limiter{
+		conn: redisconn.Client(&redis.UniversalOptions{
+			Addrs:        o.Addrs,
+			Username:     o.Auth.Username,
+			Password:     o.Auth.RedisPassword(),
+			TLSConfig:    o.Auth.TLS,
+			DB:           o.DB,
+			DialTimeout:  o.DialTimeout,
+			ReadTimeout:  o.ReadTimeout,
+			WriteTimeout: o.WriteTimeout,
+		}),
+	}
+}
+
+// Take takes a token from the key's bucket, refilled at the rate (per second).
+func (l *Limiter) Take(ctx context.Context, key string, rate float64) (time.Duration, error) {
+	var (
+		interval  = 1000 / rate
+		tolerance = interval * (math.Max(rate, 1) - 1)
+	)
+	wait, err := script.Run(ctx, l.conn, []string{keyPrefix + key}, interval, tolerance).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Refund returns a token taken from the key's bucket.
+func (l *Limiter) Refund(ctx context.Context, key string, rate float64) error {
+	return refundScript.Run(ctx, l.conn, []string{keyPrefix + key}, 1000/rate).Err()
+}
@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/redisconn"
+)
+
+const keyPrefix = "tasqueue:lock:"
+
+// lockScript sets the key to the owner with the ttl (in milliseconds), if the key isn't set
+// or is already held by the owner. It returns 1 if the lock is held by the owner.
+var lockScript = redis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// unlockScript deletes the key, if it's held by the owner.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Locker is a redis based locker, which provides locks across all the servers sharing it.
+type Locker struct {
+	conn redis.UniversalClient
+}
+
+type Options struct {
+	Addrs        []string
+	Auth         auth.Options
+	DB           int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// New() returns a new instance of redis locker.
+func New(o Options) *Locker {
+	return &Locker{
+		conn: redisconn.Client(&redis.UniversalOptions{
+			Addrs:        o.Addrs,
+			Username:     o.Auth.Username,
+			Password:     o.Auth.RedisPassword(),
+			TLSConfig:    o.Auth.TLS,
+			DB:           o.DB,
+			DialTimeout:  o.DialTimeout,
+			ReadTimeout:  o.ReadTimeout,
+			WriteTimeout: o.WriteTimeout,
+		}),
+	}
+}
+
+// Lock acquires the key's lock for the owner, or extends it if the owner holds it, for the ttl.
+func (l *Locker) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	ok, err := lockScript.Run(ctx, l.conn, []string{keyPrefix + key}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+// Unlock releases the key's lock, if the owner holds it.
+func (l *Locker) Unlock(ctx context.Context, key, owner string) error {
+	return unlockScript.Run(ctx, l.conn, []string{keyPrefix + key}, owner).Err()
+}
@@ -0,0 +1,70 @@
+package tasqueue
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// metricJobsExpired counts jobs that were skipped because they expired before being picked up.
+	metricJobsExpired = "tasqueue_jobs_expired_total"
+	// metricJobsMissed counts jobs that were skipped because they were picked up after their deadline.
+	metricJobsMissed = "tasqueue_jobs_missed_total"
+	// metricJobsArchived counts jobs moved from the results store into an archive.
+	metricJobsArchived = "tasqueue_jobs_archived_total"
+	// metricJobsRecovered counts jobs retried or failed after their worker stopped heartbeating.
+	metricJobsRecovered = "tasqueue_jobs_recovered_total"
+	// metricJobsThrottled counts jobs pushed back onto the queue by the server or queue rate limits.
+	metricJobsThrottled = "tasqueue_jobs_throttled_total"
+	// metricJobsParked counts jobs moved to the park queue as their task isn't registered on the workers.
+	metricJobsParked = "tasqueue_jobs_parked_total"
+	// metricJobsReleased counts jobs released from their gates.
+	metricJobsReleased = "tasqueue_jobs_released_total"
+	// metricJobsPruned counts jobs deleted from the results store by the retention rules.
+	metricJobsPruned = "tasqueue_jobs_pruned_total"
+	// metricJobsPreempted counts jobs preempted by higher priority jobs and requeued.
+	metricJobsPreempted = "tasqueue_jobs_preempted_total"
+	// metricJobsDropped counts jobs dropped off their queue at its max length.
+	metricJobsDropped = "tasqueue_jobs_dropped_total"
+	// metricJobsDemoted counts retried jobs demoted onto their task's retry queue.
+	metricJobsDemoted = "tasqueue_jobs_demoted_total"
+	// metricCanaryJobs counts jobs processed by canary versions of tasks, by their outcome.
+	metricCanaryJobs = "tasqueue_canary_jobs_total"
+	// metricStateRaces counts the watched state mutated while handlers ran concurrently.
+	metricStateRaces = "tasqueue_state_races_total"
+	// metricMessagesRejected counts consumed messages rejected as their signature didn't verify.
+	metricMessagesRejected = "tasqueue_messages_rejected_total"
+)
+
+// WriteMetrics writes the server's metrics to the writer in the Prometheus text exposition format.
+// If the server is named, the metrics are labelled with its name, so that the metrics of the
+// servers in a process can be written together.
+func (s *Server) WriteMetrics(w io.Writer) {
+	if s.name == "" {
+		s.metrics.WritePrometheus(w)
+		return
+	}
+
+	var b bytes.Buffer
+	s.metrics.WritePrometheus(&b)
+
+	label := "server=" + strconv.Quote(s.name)
+	sc := bufio.NewScanner(&b)
+	for sc.Scan() {
+		line := sc.Text()
+		// Add the label to the samples' labels, eg: name{a="b"} 1 or name 1.
+		switch i := strings.IndexAny(line, "{ "); {
+		case line == "" || line[0] == '#' || i < 0:
+		case line[i] == ' ':
+			line = line[:i] + "{" + label + "}" + line[i:]
+		case line[i+1] == '}':
+			line = line[:i+1] + label + line[i+1:]
+		default:
+			line = line[:i+1] + label + "," + line[i+1:]
+		}
+		io.WriteString(w, line+"\n")
+	}
+}
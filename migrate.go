@@ -0,0 +1,284 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// migrateIdle is the duration for which a queue's consumer is waited on, if the queue has
+// pending messages that haven't been received.
+const migrateIdle = time.Second
+
+// MigrateOpts configures Migrate().
+type MigrateOpts struct {
+	// Queues are the queues whose pending jobs are moved. Defaults to the queues of the tasks
+	// registered on the source server, so that other keys on the broker aren't consumed.
+	Queues []string
+}
+
+// MigrateStats counts the jobs moved by Migrate().
+type MigrateStats struct {
+	// Jobs is the number of job states copied between the results stores.
+	Jobs int
+	// Messages is the number of pending job messages moved between the brokers.
+	Messages int
+}
+
+// Migrate() moves the job states from the source server's results store, and then the pending
+// job messages from its broker, onto the destination server's, eg: to move from redis to
+// nats-jetstream. Jobs retain their UUIDs and statuses. Payloads are decoded and re-encoded
+// with the destination's payload policy, and namespaces are applied by the servers. The
+// source's workers and producers should be stopped when migrating.
+func Migrate(ctx context.Context, from, to *Server, o MigrateOpts) (MigrateStats, error) {
+	var st MigrateStats
+
+	if from.results != nil && to.results != nil {
+		n, err := migrateJobs(ctx, from, to)
+		st.Jobs = n
+		if err != nil {
+			return st, err
+		}
+	}
+
+	queues := o.Queues
+	if len(queues) == 0 {
+		q, err := from.taskQueues(ctx)
+		if err != nil {
+			return st, fmt.Errorf("could not look up queues : %w", err)
+		}
+		queues = q
+	}
+	for _, q := range queues {
+		n, err := migrateQueue(ctx, from, to, q)
+		st.Messages += n
+		if err != nil {
+			return st, fmt.Errorf("could not migrate queue %s : %w", q, err)
+		}
+	}
+
+	return st, nil
+}
+
+// migrateJobs copies the job messages, results and indexes of all the jobs (those indexed
+// for search, and in the success and failed lists).
+func migrateJobs(ctx context.Context, from, to *Server) (int, error) {
+	var (
+		uuids []string
+		seen  = make(map[string]struct{})
+		add   = func(list []string) {
+			for _, u := range list {
+				if _, ok := seen[u]; !ok {
+					seen[u] = struct{}{}
+					uuids = append(uuids, u)
+				}
+			}
+		}
+	)
+	// Stores that don't index jobs only have the completed jobs.
+	for offset := 0; ; offset += queryBatchSize {
+		list, err := queryJobs(ctx, from.results, "", time.Time{}, time.Time{}, offset, queryBatchSize, false)
+		if errors.Is(err, ErrIndexUnsupported) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not query jobs : %w", err)
+		}
+		if len(list) == 0 {
+			break
+		}
+		add(list)
+	}
+	for _, fn := range []func(context.Context) ([]string, error){from.results.GetSuccess, from.results.GetFailed} {
+		list, err := fn(ctx)
+		if err != nil {
+			return 0, err
+		}
+		add(list)
+	}
+
+	for i, uuid := range uuids {
+		msg, err := from.GetJob(ctx, uuid)
+		if err != nil {
+			return i, fmt.Errorf("could not get job %s : %w", uuid, err)
+		}
+		if err := migrateJob(ctx, from, to, msg); err != nil {
+			return i, fmt.Errorf("could not migrate job %s : %w", uuid, err)
+		}
+	}
+
+	return len(uuids), nil
+}
+
+func migrateJob(ctx context.Context, from, to *Server, msg JobMessage) error {
+	if err := recodePayload(ctx, from, to, &msg); err != nil {
+		return err
+	}
+	if err := to.setJobMessage(ctx, msg); err != nil {
+		return err
+	}
+
+	// Results aren't in the store if the job didn't save any.
+	for _, key := range []string{resultsPrefix + msg.UUID, namedResultsPrefix + msg.UUID} {
+		b, err := from.results.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		if err := to.results.Set(ctx, key, b); err != nil {
+			return fmt.Errorf("could not set job results : %w", err)
+		}
+	}
+	if chunks, err := getChunks(ctx, from.results, streamPrefix+msg.UUID, 0); err == nil {
+		for _, c := range chunks {
+			if err := appendChunk(ctx, to.results, streamPrefix+msg.UUID, c); err != nil {
+				return fmt.Errorf("could not append job chunk : %w", err)
+			}
+		}
+	}
+
+	switch msg.Status {
+	case StatusDone:
+		if err := to.results.SetSuccess(ctx, msg.UUID); err != nil {
+			return err
+		}
+	case StatusFailed:
+		if err := to.results.SetFailed(ctx, msg.UUID); err != nil {
+			return err
+		}
+	}
+	if err := to.setTags(ctx, msg); err != nil {
+		return err
+	}
+
+	return to.indexJob(ctx, msg)
+}
+
+// taskQueues returns the queues of the tasks registered on the server, including the queues
+// matching their patterns.
+func (s *Server) taskQueues(ctx context.Context) ([]string, error) {
+	s.p.RLock()
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	s.p.RUnlock()
+
+	seen := make(map[string]struct{})
+	for _, t := range tasks {
+		tq := t.queues()
+		if t.opts.QueuePattern != "" {
+			var err error
+			if tq, err = listQueues(ctx, s.broker, t.opts.QueuePattern); err != nil {
+				return nil, err
+			}
+			if t.opts.RetryQueue != "" {
+				tq = append(tq, t.opts.RetryQueue)
+			}
+		}
+		for _, q := range tq {
+			seen[q] = struct{}{}
+		}
+	}
+
+	queues := make([]string, 0, len(seen))
+	for q := range seen {
+		queues = append(queues, q)
+	}
+	sort.Strings(queues)
+
+	return queues, nil
+}
+
+// migrateQueue moves the queue's pending messages until it's empty, by consuming them from
+// the source broker and enqueuing them onto the destination broker. Messages that can't be
+// moved are pushed back onto the source queue.
+func migrateQueue(ctx context.Context, from, to *Server, queue string) (int, error) {
+	var (
+		n    int
+		work = make(chan []byte)
+		done = make(chan struct{})
+		move = func(b []byte) error {
+			var msg JobMessage
+			if err := msgpack.Unmarshal(b, &msg); err != nil {
+				return fmt.Errorf("could not decode job message : %w", err)
+			}
+			if err := recodePayload(ctx, from, to, &msg); err != nil {
+				return err
+			}
+			if err := to.enqueueMessage(ctx, msg); err != nil {
+				return err
+			}
+			n++
+			return nil
+		}
+	)
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		from.broker.Consume(cctx, work, queue)
+		close(done)
+	}()
+
+	var err error
+	for err == nil {
+		var msgs [][]byte
+		if msgs, err = peek(ctx, from.broker, queue, 1); err != nil || len(msgs) == 0 {
+			break
+		}
+
+		select {
+		case b := <-work:
+			if err = move(b); err != nil {
+				from.pushBack(b, queue)
+			}
+		case <-time.After(migrateIdle):
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// Messages received by the consumer after it's stopped are moved too (or pushed back, once
+	// moving failed), so that they aren't lost.
+	cancel()
+	for {
+		select {
+		case b := <-work:
+			if err != nil {
+				from.pushBack(b, queue)
+				continue
+			}
+			if err = move(b); err != nil {
+				from.pushBack(b, queue)
+			}
+		case <-done:
+			return n, err
+		}
+	}
+}
+
+// recodePayload decodes the job's payload with the source's payload options, and re-encodes
+// it with the destination's.
+func recodePayload(ctx context.Context, from, to *Server, msg *JobMessage) error {
+	if msg.Job == nil {
+		return nil
+	}
+
+	b, err := from.decodePayload(ctx, *msg)
+	if err != nil {
+		return err
+	}
+
+	j := *msg.Job
+	j.Payload = b
+	msg.PayloadEncoding = ""
+	if err := to.encodePayload(ctx, &j, &msg.Meta); err != nil {
+		return err
+	}
+	msg.Job = &j
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestMigrate(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		fromBrk  = NewMockBroker()
+		toBrk    = NewMockBroker()
+		from, to *Server
+	)
+	for _, s := range []struct {
+		srv **Server
+		b   *MockBroker
+	}{{&from, fromBrk}, {&to, toBrk}} {
+		srv, err := NewServer(ServerOpts{Broker: s.b, Results: NewMockResults()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+		*s.srv = srv
+	}
+
+	// Process one of the jobs, leaving the other pending.
+	var uuids []string
+	for i := 0; i < 2; i++ {
+		uuid, err := from.Enqueue(ctx, makeJob(t, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+	b := <-fromBrk.data
+	fromBrk.remove(b)
+	from.Process(ctx, b)
+
+	st, err := Migrate(ctx, from, to, MigrateOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Jobs != 2 || st.Messages != 1 {
+		t.Fatalf("expected 2 jobs and 1 message to be migrated, got %+v", st)
+	}
+
+	for i, status := range []string{StatusDone, StatusStarted} {
+		msg, err := to.GetJob(ctx, uuids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != status {
+			t.Fatalf("expected migrated job status %s, got %s", status, msg.Status)
+		}
+	}
+	if succ, err := to.GetSuccess(ctx); err != nil || len(succ) != 1 || succ[0] != uuids[0] {
+		t.Fatalf("expected %s in the success list, got %v (%v)", uuids[0], succ, err)
+	}
+
+	// The pending job is moved onto the destination broker.
+	pending, err := fromBrk.GetPending(ctx, DefaultQueue, 10)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending messages on the source, got %d (%v)", len(pending), err)
+	}
+	var msg JobMessage
+	if err := msgpack.Unmarshal(<-toBrk.data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.UUID != uuids[1] || msg.Queue != DefaultQueue {
+		t.Fatalf("expected job %s on %s, got %s on %s", uuids[1], DefaultQueue, msg.UUID, msg.Queue)
+	}
+}
+
+func TestMigrateEnqueueError(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		errDown = errors.New("down")
+		fromBrk = NewMockBroker()
+	)
+	from, err := NewServer(ServerOpts{Broker: fromBrk, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := NewServer(ServerOpts{Broker: failBroker{Broker: NewMockBroker(), err: errDown}, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from.RegisterTask(taskName, MockHandler, TaskOpts{})
+	if _, err := from.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := fromBrk.GetPending(ctx, DefaultQueue, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := msgs[0]
+
+	// The message that can't be moved is pushed back onto the source queue.
+	if _, err := Migrate(ctx, from, to, MigrateOpts{}); !errors.Is(err, errDown) {
+		t.Fatalf("expected %v migrating, got %v", errDown, err)
+	}
+	if msgs, err := fromBrk.GetPending(ctx, DefaultQueue, 10); err != nil || len(msgs) != 1 || !bytes.Equal(msgs[0], b) {
+		t.Fatalf("expected the message to be pushed back onto the source, got %d (%v)", len(msgs), err)
+	}
+}
+
+func TestMigrateQueues(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	srv.RegisterTask("emails", MockHandler, TaskOpts{Queue: "emails", RetryQueue: "emails:retry"})
+	srv.RegisterTask("sms", MockHandler, TaskOpts{QueuePattern: "sms:*"})
+	for _, q := range []string{"sms:a", "other"} {
+		if err := srv.broker.Enqueue(ctx, []byte("msg"), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the queues of the registered tasks are migrated by default.
+	queues, err := srv.taskQueues(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"emails", "emails:retry", "sms:a", DefaultQueue}; !reflect.DeepEqual(queues, exp) {
+		t.Fatalf("expected queues %v, got %v", exp, queues)
+	}
+}
+
+// queryErrResults is a results store whose job index queries fail with err.
+type queryErrResults struct {
+	*rr.Results
+	err error
+}
+
+func (r queryErrResults) QueryJobs(context.Context, string, time.Time, time.Time, int, int, bool) ([]string, error) {
+	return nil, r.err
+}
+
+func TestMigrateQueryError(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		errDown = errors.New("down")
+	)
+	from, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: queryErrResults{Results: NewMockResults(), err: errDown}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := newServer(t)
+
+	if _, err := Migrate(ctx, from, to, MigrateOpts{}); !errors.Is(err, errDown) {
+		t.Fatalf("expected %v migrating, got %v", errDown, err)
+	}
+}
@@ -0,0 +1,44 @@
+package tasqueue
+
+import "errors"
+
+// Mode is the set of roles run by a started server, so that a deployment can run dedicated
+// scheduler, producer and worker nodes.
+type Mode uint8
+
+const (
+	// ModeAll runs the cron scheduler and processes jobs.
+	ModeAll Mode = iota
+	// ModeWorker processes jobs, without running the cron scheduler.
+	ModeWorker
+	// ModeScheduler runs the cron scheduler, without processing jobs.
+	ModeScheduler
+	// ModeProducer only enqueues jobs, without running the cron scheduler or processing jobs.
+	ModeProducer
+)
+
+// ErrNoScheduler is returned on enqueuing a scheduled job on a server whose mode doesn't run
+// the cron scheduler.
+var ErrNoScheduler = errors.New("scheduler not run by the server")
+
+func (m Mode) String() string {
+	switch m {
+	case ModeWorker:
+		return "worker"
+	case ModeScheduler:
+		return "scheduler"
+	case ModeProducer:
+		return "producer"
+	}
+	return "all"
+}
+
+// schedules returns true if the mode runs the cron scheduler.
+func (m Mode) schedules() bool {
+	return m == ModeAll || m == ModeScheduler
+}
+
+// processes returns true if the mode processes jobs.
+func (m Mode) processes() bool {
+	return m == ModeAll || m == ModeWorker
+}
@@ -0,0 +1,55 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestModes(t *testing.T) {
+	for _, mode := range []Mode{ModeAll, ModeWorker, ModeScheduler, ModeProducer} {
+		t.Run(mode.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			broker := NewMockBroker()
+			srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Mode: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			processed := make(chan struct{}, 1)
+			srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+				processed <- struct{}{}
+				return nil
+			}, TaskOpts{})
+
+			// Scheduled jobs are only accepted by the modes which run the scheduler.
+			job, err := NewJob(taskName, nil, JobOpts{Schedule: "@every 1h"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = srv.Enqueue(ctx, job)
+			if mode.schedules() != (err == nil) || (err != nil && !errors.Is(err, ErrNoScheduler)) {
+				t.Fatalf("unexpected error enqueuing scheduled job: %v", err)
+			}
+
+			// Jobs are only processed by the modes which process jobs.
+			if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+				t.Fatal(err)
+			}
+			go srv.Start(ctx)
+
+			select {
+			case <-processed:
+				if !mode.processes() {
+					t.Fatal("expected the job not to be processed")
+				}
+			case <-time.After(time.Millisecond * 200):
+				if mode.processes() {
+					t.Fatal("expected the job to be processed")
+				}
+			}
+		})
+	}
+}
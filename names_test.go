@@ -0,0 +1,40 @@
+package tasqueue
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNamedServers(t *testing.T) {
+	var (
+		broker = NewMockBroker()
+		out    bytes.Buffer
+	)
+	for _, name := range []string{"billing", "reports"} {
+		// The servers share the broker.
+		srv, err := NewServer(ServerOpts{Broker: broker, Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(srv.workerID, name+"-") {
+			t.Fatalf("expected the worker id to be prefixed with the name, got %s", srv.workerID)
+		}
+
+		srv.metrics.GetOrCreateCounter(metricJobsThrottled).Inc()
+		srv.metrics.GetOrCreateCounter(metricJobsExpired + `{task="a"}`).Inc()
+		srv.WriteMetrics(&out)
+	}
+
+	// The metrics of the servers are labelled with their names.
+	for _, m := range []string{
+		`tasqueue_jobs_throttled_total{server="billing"} 1`,
+		`tasqueue_jobs_expired_total{server="billing",task="a"} 1`,
+		`tasqueue_jobs_throttled_total{server="reports"} 1`,
+		`tasqueue_jobs_expired_total{server="reports",task="a"} 1`,
+	} {
+		if !strings.Contains(out.String(), m) {
+			t.Fatalf("expected %s in the metrics, got %s", m, out.String())
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package tasqueue
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// namespaced returns the key prefixed with the namespace.
+func namespaced(ns, key string) string {
+	return ns + ":" + key
+}
+
+// nsBroker prefixes the names of the queues on the broker with the namespace.
+type nsBroker struct {
+	Broker
+	ns string
+}
+
+func (b nsBroker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	return b.Broker.Enqueue(ctx, msg, namespaced(b.ns, queue))
+}
+
+func (b nsBroker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	return enqueuePriority(ctx, b.Broker, msg, namespaced(b.ns, queue), priority)
+}
+
+func (b nsBroker) Consume(ctx context.Context, work chan []byte, queue string) {
+	b.Broker.Consume(ctx, work, namespaced(b.ns, queue))
+}
+
+func (b nsBroker) Queues(ctx context.Context, pattern string) ([]string, error) {
+	queues, err := listQueues(ctx, b.Broker, namespaced(b.ns, pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, q := range queues {
+		queues[i] = strings.TrimPrefix(q, namespaced(b.ns, ""))
+	}
+
+	return queues, nil
+}
+
+func (b nsBroker) GetPending(ctx context.Context, queue string, n int) ([][]byte, error) {
+	return peek(ctx, b.Broker, namespaced(b.ns, queue), n)
+}
+
+func (b nsBroker) Depth(ctx context.Context, queue string) (int64, error) {
+	return depth(ctx, b.Broker, namespaced(b.ns, queue))
+}
+
+func (b nsBroker) Trim(ctx context.Context, queue string, n int64) ([][]byte, error) {
+	return trim(ctx, b.Broker, namespaced(b.ns, queue), n)
+}
+
+// nsResults prefixes the keys on the results store with the namespace. The uuid's in the
+// success/failed lists are prefixed as well, as the lists are shared by all namespaces.
+type nsResults struct {
+	Results
+	ns string
+}
+
+func (r nsResults) Get(ctx context.Context, uuid string) ([]byte, error) {
+	return r.Results.Get(ctx, namespaced(r.ns, uuid))
+}
+
+func (r nsResults) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	return watch(ctx, r.Results, namespaced(r.ns, key))
+}
+
+func (r nsResults) Set(ctx context.Context, uuid string, b []byte) error {
+	return r.Results.Set(ctx, namespaced(r.ns, uuid), b)
+}
+
+func (r nsResults) GetFailed(ctx context.Context) ([]string, error) {
+	uuids, err := r.Results.GetFailed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.strip(uuids), nil
+}
+
+func (r nsResults) GetSuccess(ctx context.Context) ([]string, error) {
+	uuids, err := r.Results.GetSuccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.strip(uuids), nil
+}
+
+func (r nsResults) SetFailed(ctx context.Context, uuid string) error {
+	return r.Results.SetFailed(ctx, namespaced(r.ns, uuid))
+}
+
+// DeleteFailed removes the job from the failed list if the store implements FailedDeleter.
+func (r nsResults) DeleteFailed(ctx context.Context, uuid string) error {
+	d, ok := r.Results.(FailedDeleter)
+	if !ok {
+		return nil
+	}
+	return d.DeleteFailed(ctx, namespaced(r.ns, uuid))
+}
+
+func (r nsResults) SetSuccess(ctx context.Context, uuid string) error {
+	return r.Results.SetSuccess(ctx, namespaced(r.ns, uuid))
+}
+
+func (r nsResults) SetTag(ctx context.Context, tag, uuid string) error {
+	return setTag(ctx, r.Results, namespaced(r.ns, tag), uuid)
+}
+
+func (r nsResults) GetTag(ctx context.Context, tag string) ([]string, error) {
+	return getTag(ctx, r.Results, namespaced(r.ns, tag))
+}
+
+func (r nsResults) DeleteTag(ctx context.Context, tag, uuid string) error {
+	return deleteTag(ctx, r.Results, namespaced(r.ns, tag), uuid)
+}
+
+func (r nsResults) Delete(ctx context.Context, uuid string) error {
+	return r.Results.Delete(ctx, namespaced(r.ns, uuid))
+}
+
+// IndexJob adds the job to the namespace's index of all jobs as well, as the store's
+// index of all jobs is shared by all namespaces.
+func (r nsResults) IndexJob(ctx context.Context, uuid string, t time.Time, keys []string) error {
+	i, ok := r.Results.(Indexer)
+	if !ok {
+		return ErrIndexUnsupported
+	}
+	return i.IndexJob(ctx, uuid, t, r.indexKeys(keys))
+}
+
+func (r nsResults) UnindexJob(ctx context.Context, uuid string, keys []string) error {
+	return unindexJob(ctx, r.Results, uuid, r.indexKeys(keys))
+}
+
+func (r nsResults) QueryJobs(ctx context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	return queryJobs(ctx, r.Results, namespaced(r.ns, key), from, to, offset, limit, desc)
+}
+
+func (r nsResults) AppendChunk(ctx context.Context, key string, b []byte) error {
+	return appendChunk(ctx, r.Results, namespaced(r.ns, key), b)
+}
+
+func (r nsResults) GetChunks(ctx context.Context, key string, offset int) ([][]byte, error) {
+	return getChunks(ctx, r.Results, namespaced(r.ns, key), offset)
+}
+
+// IncrCounters increments the counters if the store implements Counter.
+func (r nsResults) IncrCounters(ctx context.Context, key string, fields map[string]int64, ttl time.Duration) error {
+	c, ok := r.Results.(Counter)
+	if !ok {
+		return ErrStatsUnsupported
+	}
+	return c.IncrCounters(ctx, namespaced(r.ns, key), fields, ttl)
+}
+
+// GetCounters gets the counters if the store implements Counter.
+func (r nsResults) GetCounters(ctx context.Context, key string) (map[string]int64, error) {
+	c, ok := r.Results.(Counter)
+	if !ok {
+		return nil, ErrStatsUnsupported
+	}
+	return c.GetCounters(ctx, namespaced(r.ns, key))
+}
+
+// AddDelayed adds the delayed job if the store implements Delayer.
+func (r nsResults) AddDelayed(ctx context.Context, key, uuid string, at time.Time) error {
+	d, ok := r.Results.(Delayer)
+	if !ok {
+		return ErrDelayUnsupported
+	}
+	return d.AddDelayed(ctx, namespaced(r.ns, key), uuid, at)
+}
+
+// PopDue pops the due jobs if the store implements Delayer.
+func (r nsResults) PopDue(ctx context.Context, key string, t time.Time, n int) ([]string, error) {
+	d, ok := r.Results.(Delayer)
+	if !ok {
+		return nil, ErrDelayUnsupported
+	}
+	return d.PopDue(ctx, namespaced(r.ns, key), t, n)
+}
+
+// Lease adds the lease if the store implements Leaser.
+func (r nsResults) Lease(ctx context.Context, key, member string) error {
+	l, ok := r.Results.(Leaser)
+	if !ok {
+		return ErrLeaseUnsupported
+	}
+	return l.Lease(ctx, namespaced(r.ns, key), member)
+}
+
+// Unlease removes the lease if the store implements Leaser.
+func (r nsResults) Unlease(ctx context.Context, key, member string) error {
+	l, ok := r.Results.(Leaser)
+	if !ok {
+		return ErrLeaseUnsupported
+	}
+	return l.Unlease(ctx, namespaced(r.ns, key), member)
+}
+
+// GetLeases gets the leases if the store implements Leaser.
+func (r nsResults) GetLeases(ctx context.Context, key string) ([]string, error) {
+	l, ok := r.Results.(Leaser)
+	if !ok {
+		return nil, ErrLeaseUnsupported
+	}
+	return l.GetLeases(ctx, namespaced(r.ns, key))
+}
+
+func (r nsResults) indexKeys(keys []string) []string {
+	out := []string{namespaced(r.ns, "")}
+	for _, k := range keys {
+		out = append(out, namespaced(r.ns, k))
+	}
+
+	return out
+}
+
+// strip returns the uuid's of the namespace, without the prefix.
+func (r nsResults) strip(uuids []string) []string {
+	var (
+		prefix = namespaced(r.ns, "")
+		out    = make([]string, 0, len(uuids))
+	)
+	for _, u := range uuids {
+		if strings.HasPrefix(u, prefix) {
+			out = append(out, strings.TrimPrefix(u, prefix))
+		}
+	}
+
+	return out
+}
+
+// nsLocker prefixes the lock keys with the namespace.
+type nsLocker struct {
+	Locker
+	ns string
+}
+
+func (l nsLocker) Lock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return l.Locker.Lock(ctx, namespaced(l.ns, key), owner, ttl)
+}
+
+func (l nsLocker) Unlock(ctx context.Context, key, owner string) error {
+	return l.Locker.Unlock(ctx, namespaced(l.ns, key), owner)
+}
+
+// nsLimiter prefixes the rate limiter keys with the namespace.
+type nsLimiter struct {
+	RateLimiter
+	ns string
+}
+
+func (l nsLimiter) Take(ctx context.Context, key string, rate float64) (time.Duration, error) {
+	return l.RateLimiter.Take(ctx, namespaced(l.ns, key), rate)
+}
+
+func (l nsLimiter) Refund(ctx context.Context, key string, rate float64) error {
+	return l.RateLimiter.Refund(ctx, namespaced(l.ns, key), rate)
+}
+
+func (b nsBroker) Ping(ctx context.Context) error {
+	return ping(ctx, b.Broker)
+}
+
+func (r nsResults) Ping(ctx context.Context) error {
+	return ping(ctx, r.Results)
+}
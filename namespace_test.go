@@ -0,0 +1,60 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+		srvs    = make(map[string]*Server)
+	)
+	for _, ns := range []string{"staging", "prod"} {
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: results, Namespace: ns})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srvs[ns] = srv
+	}
+
+	uuid, err := srvs["staging"].Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srvs["staging"].GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srvs["staging"].statusFailed(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job is only visible in its namespace.
+	if _, err := srvs["prod"].GetJob(ctx, uuid); err == nil {
+		t.Fatal("expected job to not be found in another namespace")
+	}
+	for ns, n := range map[string]int{"staging": 1, "prod": 0} {
+		failed, err := srvs[ns].GetFailed(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failed) != n || (n == 1 && failed[0] != uuid) {
+			t.Fatalf("expected %d failed jobs in %s, got %v", n, ns, failed)
+		}
+	}
+
+	// The queue is prefixed on the broker, and not on the server.
+	queues, err := broker.Queues(ctx, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 1 || queues[0] != "staging:"+DefaultQueue {
+		t.Fatalf("expected queue staging:%s on the broker, got %v", DefaultQueue, queues)
+	}
+	if queues, err = listQueues(ctx, srvs["staging"].broker, "*"); err != nil || len(queues) != 1 || queues[0] != DefaultQueue {
+		t.Fatalf("expected queue %s on the server, got %v (%v)", DefaultQueue, queues, err)
+	}
+}
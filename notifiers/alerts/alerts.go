@@ -0,0 +1,278 @@
+// Package alerts sends alerts (eg: to Slack or over email) when jobs match
+// configured rules, such as N failures of a task within a window, or the growth
+// of the dead letter queue (the failed jobs list).
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/zerodha/logf"
+)
+
+const (
+	defaultBufferSize  = 100
+	defaultDLQInterval = time.Minute
+)
+
+// Kind is the kind of a rule.
+type Kind int
+
+const (
+	// KindJobs rules fire when Count jobs reach the status within the window.
+	KindJobs Kind = iota
+	// KindDLQGrowth rules fire when the failed jobs list grows by Count jobs within
+	// the window. The list is polled every Options.DLQInterval, and the Task and
+	// Status of the rule are ignored.
+	KindDLQGrowth
+)
+
+// Rule fires an alert when Count jobs (of the task, if set) reach the status within the window.
+type Rule struct {
+	Name string
+	Kind Kind
+	// Task is the task whose jobs are matched. If empty, jobs of all tasks are matched.
+	Task string
+	// Status is the job status that is matched. Defaults to tasqueue.StatusFailed.
+	Status string
+	Count  int
+	Window time.Duration
+	// Cooldown is the minimum duration between two alerts of the rule. Defaults to the window.
+	Cooldown time.Duration
+}
+
+// Alert is sent when a rule fires.
+type Alert struct {
+	Rule   Rule
+	Count  int
+	Jobs   []string
+	Time   time.Time
+	Reason string
+}
+
+// FailedLister lists the failed jobs, eg: a *tasqueue.Server or *tasqueue.Client.
+type FailedLister interface {
+	GetFailed(ctx context.Context) ([]string, error)
+}
+
+// Sender delivers alerts.
+type Sender interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+type Options struct {
+	Rules   []Rule
+	Senders []Sender
+
+	// DLQ lists the failed jobs for the KindDLQGrowth rules.
+	DLQ FailedLister
+	// DLQInterval is the interval at which the failed jobs are polled. Defaults to 1m.
+	DLQInterval time.Duration
+}
+
+// Alerter evaluates the rules against job events and sends alerts when they fire.
+type Alerter struct {
+	log     logf.Logger
+	senders []Sender
+	rules   []*rule
+	dlq     []*rule
+	ch      chan Alert
+
+	failed   FailedLister
+	interval time.Duration
+}
+
+// rule holds the jobs matched within a rule's window.
+type rule struct {
+	Rule
+
+	mu    sync.Mutex
+	times []time.Time
+	jobs  []string
+	// sizes are the sizes of the failed jobs list polled at times, for KindDLQGrowth rules.
+	sizes []int
+	last  time.Time
+}
+
+// New() returns a new instance of the alerter. It should be registered on the
+// server with srv.OnEvent(a.Handle) and started with a.Run(ctx).
+func New(o Options, lo logf.Logger) (*Alerter, error) {
+	var rules, dlq []*rule
+	for _, r := range o.Rules {
+		if r.Count <= 0 || r.Window <= 0 {
+			return nil, fmt.Errorf("rule %s requires a count and window", r.Name)
+		}
+		if r.Status == "" {
+			r.Status = tasqueue.StatusFailed
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = r.Window
+		}
+
+		switch r.Kind {
+		case KindJobs:
+			rules = append(rules, &rule{Rule: r})
+		case KindDLQGrowth:
+			if o.DLQ == nil {
+				return nil, fmt.Errorf("rule %s requires the DLQ option", r.Name)
+			}
+			dlq = append(dlq, &rule{Rule: r})
+		default:
+			return nil, fmt.Errorf("rule %s has an unknown kind %d", r.Name, r.Kind)
+		}
+	}
+	if o.DLQInterval == 0 {
+		o.DLQInterval = defaultDLQInterval
+	}
+
+	return &Alerter{
+		log:      lo,
+		senders:  o.Senders,
+		rules:    rules,
+		dlq:      dlq,
+		ch:       make(chan Alert, defaultBufferSize),
+		failed:   o.DLQ,
+		interval: o.DLQInterval,
+	}, nil
+}
+
+// Handle evaluates the rules against a job event. It doesn't block, alerts are
+// dropped if the buffer is full.
+func (a *Alerter) Handle(e tasqueue.Event) {
+	for _, r := range a.rules {
+		if al, ok := r.match(e); ok {
+			a.fire(al)
+		}
+	}
+}
+
+// fire queues the alert to be sent. It doesn't block, the alert is dropped if the
+// buffer is full.
+func (a *Alerter) fire(al Alert) {
+	select {
+	case a.ch <- al:
+	default:
+		a.log.Error("alerts buffer full, dropping alert", "rule", al.Rule.Name)
+	}
+}
+
+// checkDLQ polls the size of the failed jobs list and evaluates the KindDLQGrowth rules on it.
+func (a *Alerter) checkDLQ(ctx context.Context, now time.Time) {
+	uuids, err := a.failed.GetFailed(ctx)
+	if err != nil {
+		a.log.Error("error getting failed jobs", "error", err)
+		return
+	}
+
+	for _, r := range a.dlq {
+		if al, ok := r.grow(len(uuids), now); ok {
+			a.fire(al)
+		}
+	}
+}
+
+// Run() sends the fired alerts to all the senders, and polls the failed jobs for the
+// KindDLQGrowth rules. It is a blocking function.
+func (a *Alerter) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if len(a.dlq) > 0 {
+		tk := time.NewTicker(a.interval)
+		defer tk.Stop()
+		tick = tk.C
+		a.checkDLQ(ctx, time.Now())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick:
+			a.checkDLQ(ctx, now)
+		case al := <-a.ch:
+			for _, s := range a.senders {
+				if err := s.Send(ctx, al); err != nil {
+					a.log.Error("error sending alert", "rule", al.Rule.Name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// match records the job if it matches the rule and returns an alert if the rule fires.
+func (r *rule) match(e tasqueue.Event) (Alert, bool) {
+	if e.Job.Status != r.Status || (r.Task != "" && e.Job.Job.Task != r.Task) {
+		return Alert{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Drop the jobs which are outside the window.
+	cutoff := e.Time.Add(-r.Window)
+	i := 0
+	for i < len(r.times) && r.times[i].Before(cutoff) {
+		i++
+	}
+	r.times = append(r.times[i:], e.Time)
+	r.jobs = append(r.jobs[i:], e.Job.UUID)
+
+	if len(r.times) < r.Count || e.Time.Sub(r.last) < r.Cooldown {
+		return Alert{}, false
+	}
+	r.last = e.Time
+
+	return Alert{
+		Rule:   r.Rule,
+		Count:  len(r.times),
+		Jobs:   append([]string(nil), r.jobs...),
+		Time:   e.Time,
+		Reason: fmt.Sprintf("%d jobs of %s were %s within %s", len(r.times), r.taskName(), r.Status, r.Window),
+	}, true
+}
+
+// grow records the size of the failed jobs list and returns an alert if it grew by the
+// rule's count within the window.
+func (r *rule) grow(size int, now time.Time) (Alert, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Drop the sizes which are outside the window. The smallest size within it is the
+	// baseline, as jobs may be retried or deleted off the list.
+	cutoff := now.Add(-r.Window)
+	i := 0
+	for i < len(r.times) && r.times[i].Before(cutoff) {
+		i++
+	}
+	r.times = append(r.times[i:], now)
+	r.sizes = append(r.sizes[i:], size)
+
+	min := size
+	for _, s := range r.sizes {
+		if s < min {
+			min = s
+		}
+	}
+	growth := size - min
+
+	if growth < r.Count || now.Sub(r.last) < r.Cooldown {
+		return Alert{}, false
+	}
+	r.last = now
+
+	return Alert{
+		Rule:   r.Rule,
+		Count:  growth,
+		Time:   now,
+		Reason: fmt.Sprintf("the failed jobs grew by %d to %d within %s", growth, size, r.Window),
+	}, true
+}
+
+func (r *rule) taskName() string {
+	if r.Task == "" {
+		return "all tasks"
+	}
+	return "task " + r.Task
+}
@@ -0,0 +1,205 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/zerodha/logf"
+)
+
+var t0 = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func event(task, status string, at time.Duration) tasqueue.Event {
+	return tasqueue.Event{
+		Job:  tasqueue.JobMessage{Meta: tasqueue.Meta{UUID: fmt.Sprint(at), Status: status}, Job: &tasqueue.Job{Task: task}},
+		Time: t0.Add(at),
+	}
+}
+
+func TestJobsRule(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		rule   Rule
+		events []tasqueue.Event
+		// fired are the indexes of the events which fire the rule.
+		fired []int
+	}{
+		{
+			name:   "count within window",
+			rule:   Rule{Task: "add", Count: 2, Window: time.Minute},
+			events: []tasqueue.Event{event("add", tasqueue.StatusFailed, 0), event("add", tasqueue.StatusFailed, time.Second*30)},
+			fired:  []int{1},
+		},
+		{
+			name:   "outside window",
+			rule:   Rule{Task: "add", Count: 2, Window: time.Minute},
+			events: []tasqueue.Event{event("add", tasqueue.StatusFailed, 0), event("add", tasqueue.StatusFailed, time.Minute*2)},
+		},
+		{
+			name:   "other task",
+			rule:   Rule{Task: "add", Count: 1, Window: time.Minute},
+			events: []tasqueue.Event{event("sub", tasqueue.StatusFailed, 0)},
+		},
+		{
+			name:   "all tasks",
+			rule:   Rule{Count: 2, Window: time.Minute},
+			events: []tasqueue.Event{event("add", tasqueue.StatusFailed, 0), event("sub", tasqueue.StatusFailed, time.Second)},
+			fired:  []int{1},
+		},
+		{
+			name:   "status",
+			rule:   Rule{Status: tasqueue.StatusExpired, Count: 1, Window: time.Minute},
+			events: []tasqueue.Event{event("add", tasqueue.StatusFailed, 0), event("add", tasqueue.StatusExpired, time.Second)},
+			fired:  []int{1},
+		},
+		{
+			name: "cooldown",
+			rule: Rule{Count: 1, Window: time.Minute},
+			events: []tasqueue.Event{
+				event("add", tasqueue.StatusFailed, 0),
+				event("add", tasqueue.StatusFailed, time.Second*30),
+				event("add", tasqueue.StatusFailed, time.Minute),
+			},
+			fired: []int{0, 2},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			a, err := New(Options{Rules: []Rule{c.rule}}, logf.New(logf.Opts{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fired []int
+			for i, e := range c.events {
+				if _, ok := a.rules[0].match(e); ok {
+					fired = append(fired, i)
+				}
+			}
+			if fmt.Sprint(fired) != fmt.Sprint(c.fired) {
+				t.Fatalf("expected events %v to fire, got %v", c.fired, fired)
+			}
+		})
+	}
+}
+
+func TestDLQGrowthRule(t *testing.T) {
+	type sample struct {
+		at   time.Duration
+		size int
+	}
+	for _, c := range []struct {
+		name    string
+		rule    Rule
+		samples []sample
+		fired   []int
+	}{
+		{
+			name:    "growth within window",
+			rule:    Rule{Count: 5, Window: time.Minute * 10},
+			samples: []sample{{0, 10}, {time.Minute, 12}, {time.Minute * 2, 15}},
+			fired:   []int{2},
+		},
+		{
+			name:    "slow growth",
+			rule:    Rule{Count: 5, Window: time.Minute * 10},
+			samples: []sample{{0, 10}, {time.Minute * 6, 13}, {time.Minute * 12, 16}},
+		},
+		{
+			name:    "growth after a retry",
+			rule:    Rule{Count: 5, Window: time.Minute * 10},
+			samples: []sample{{0, 10}, {time.Minute, 2}, {time.Minute * 2, 7}},
+			fired:   []int{2},
+		},
+		{
+			name:    "cooldown",
+			rule:    Rule{Count: 1, Window: time.Minute, Cooldown: time.Minute * 5},
+			samples: []sample{{0, 0}, {time.Second, 1}, {time.Second * 2, 2}, {time.Minute * 6, 3}, {time.Minute*6 + time.Second, 4}},
+			fired:   []int{1, 4},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.rule.Kind = KindDLQGrowth
+			a, err := New(Options{Rules: []Rule{c.rule}, DLQ: &failedList{}}, logf.New(logf.Opts{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fired []int
+			for i, s := range c.samples {
+				if _, ok := a.dlq[0].grow(s.size, t0.Add(s.at)); ok {
+					fired = append(fired, i)
+				}
+			}
+			if fmt.Sprint(fired) != fmt.Sprint(c.fired) {
+				t.Fatalf("expected samples %v to fire, got %v", c.fired, fired)
+			}
+		})
+	}
+}
+
+// failedList is a failed jobs list of n jobs.
+type failedList struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (f *failedList) set(n int) {
+	f.mu.Lock()
+	f.n = n
+	f.mu.Unlock()
+}
+
+func (f *failedList) GetFailed(context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return make([]string, f.n), nil
+}
+
+// sender sends the alerts on a channel.
+type sender chan Alert
+
+func (s sender) Send(_ context.Context, a Alert) error {
+	s <- a
+	return nil
+}
+
+func TestDLQGrowth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := New(Options{Rules: []Rule{{Kind: KindDLQGrowth, Count: 1, Window: time.Minute}}}, logf.New(logf.Opts{})); err == nil {
+		t.Fatal("expected an error without the DLQ option")
+	}
+
+	var (
+		dlq = &failedList{n: 3}
+		out = make(sender, 1)
+	)
+	a, err := New(Options{
+		Rules:       []Rule{{Name: "dlq", Kind: KindDLQGrowth, Count: 2, Window: time.Minute}},
+		Senders:     []Sender{out},
+		DLQ:         dlq,
+		DLQInterval: time.Millisecond * 10,
+	}, logf.New(logf.Opts{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go a.Run(ctx)
+
+	// Run() polls the list on start, and the rule fires on a following poll.
+	time.Sleep(time.Millisecond * 50)
+	dlq.set(5)
+
+	select {
+	case al := <-out:
+		if al.Rule.Name != "dlq" || al.Count != 2 {
+			t.Fatalf("unexpected alert %+v", al)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an alert")
+	}
+}
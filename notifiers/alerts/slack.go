@@ -0,0 +1,51 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Slack sends alerts to a Slack incoming webhook.
+type Slack struct {
+	url  string
+	http *http.Client
+}
+
+// NewSlack() returns a sender that posts alerts to the Slack incoming webhook URL.
+func NewSlack(url string) *Slack {
+	return &Slack{
+		url:  url,
+		http: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (s *Slack) Send(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("*tasqueue alert: %s*\n%s", a.Rule.Name, a.Reason),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPOptions struct {
+	// Addr is the host:port of the SMTP server.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTP sends alerts over email.
+type SMTP struct {
+	opt SMTPOptions
+}
+
+// NewSMTP() returns a sender that emails alerts using the SMTP server.
+func NewSMTP(o SMTPOptions) *SMTP {
+	return &SMTP{opt: o}
+}
+
+func (s *SMTP) Send(_ context.Context, a Alert) error {
+	var auth smtp.Auth
+	if s.opt.Username != "" {
+		host, _, err := net.SplitHostPort(s.opt.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.opt.Username, s.opt.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: tasqueue alert: %s\r\n\r\n%s\r\n\r\nJobs:\r\n%s\r\n",
+		s.opt.From, strings.Join(s.opt.To, ", "), a.Rule.Name, a.Reason, strings.Join(a.Jobs, "\r\n"))
+
+	return smtp.SendMail(s.opt.Addr, auth, s.opt.From, s.opt.To, []byte(msg))
+}
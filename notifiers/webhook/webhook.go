@@ -0,0 +1,222 @@
+// Package webhook notifies external systems of jobs reaching a final state, by
+// sending a signed JSON POST to a webhook URL.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/zerodha/logf"
+)
+
+const (
+	// EndpointLabel is the job label which sets a per-job webhook, by the name of one of the
+	// configured Endpoints. Jobs can't set a URL, as they'd have the workers post to any host.
+	EndpointLabel = "webhook"
+
+	// SignatureHeader holds the hex encoded HMAC-SHA256 signature of the request body.
+	SignatureHeader = "X-Tasqueue-Signature"
+
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+	defaultTimeout    = time.Second * 10
+	defaultBufferSize = 1000
+)
+
+type Options struct {
+	// URL is the webhook URL for all the jobs. It is overridden by TaskURLs
+	// and the job's EndpointLabel.
+	URL string
+	// TaskURLs is a map of task name -> webhook URL.
+	TaskURLs map[string]string
+	// Endpoints is a map of name -> webhook URL, which jobs select with the EndpointLabel.
+	Endpoints map[string]string
+
+	// Secret is the key used to sign the request body. If empty, requests aren't signed.
+	Secret string
+
+	// MaxRetries is the number of times a failed request is retried, with Backoff
+	// doubled after each attempt.
+	MaxRetries int
+	Backoff    time.Duration
+	Timeout    time.Duration
+
+	// BufferSize is the number of notifications buffered before they are dropped.
+	BufferSize int
+}
+
+// Notification is the JSON body posted to the webhook.
+type Notification struct {
+	UUID   string            `json:"uuid"`
+	Task   string            `json:"task"`
+	Queue  string            `json:"queue"`
+	Status string            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Tenant string            `json:"tenant,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Time   time.Time         `json:"time"`
+}
+
+// Notifier posts notifications for jobs that reach a final state.
+type Notifier struct {
+	opt  Options
+	log  logf.Logger
+	http *http.Client
+	ch   chan request
+}
+
+type request struct {
+	url  string
+	body []byte
+}
+
+// New() returns a new instance of the webhook notifier. It should be registered
+// on the server with srv.OnEvent(n.Notify) and started with n.Run(ctx).
+func New(o Options, lo logf.Logger) *Notifier {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+	if o.Backoff == 0 {
+		o.Backoff = defaultBackoff
+	}
+	if o.Timeout == 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.BufferSize == 0 {
+		o.BufferSize = defaultBufferSize
+	}
+
+	return &Notifier{
+		opt:  o,
+		log:  lo,
+		http: &http.Client{Timeout: o.Timeout},
+		ch:   make(chan request, o.BufferSize),
+	}
+}
+
+// Notify queues a notification if the event is for a job that reached a final state
+// and a webhook URL is configured for it. It doesn't block, notifications are dropped
+// if the buffer is full.
+func (n *Notifier) Notify(e tasqueue.Event) {
+	if !tasqueue.IsFinal(e.Job.Status) {
+		return
+	}
+
+	url := n.url(e.Job)
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(Notification{
+		UUID:   e.Job.UUID,
+		Task:   e.Job.Job.Task,
+		Queue:  e.Job.Queue,
+		Status: e.Job.Status,
+		Error:  e.Job.PrevErr,
+		Tenant: e.Job.Tenant,
+		Labels: e.Job.Labels,
+		Time:   e.Time,
+	})
+	if err != nil {
+		n.log.Error("error marshalling webhook notification", "error", err)
+		return
+	}
+
+	select {
+	case n.ch <- request{url: url, body: body}:
+	default:
+		n.log.Error("webhook buffer full, dropping notification", "uuid", e.Job.UUID)
+	}
+}
+
+// Run() sends the queued notifications. It is a blocking function.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-n.ch:
+			if err := n.send(ctx, r); err != nil {
+				n.log.Error("error sending webhook notification", "url", r.url, "error", err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) url(msg tasqueue.JobMessage) string {
+	if name := msg.Labels[EndpointLabel]; name != "" {
+		u, ok := n.opt.Endpoints[name]
+		if !ok {
+			n.log.Error("unknown webhook endpoint, dropping notification", "uuid", msg.UUID, "endpoint", name)
+		}
+		return u
+	}
+	if u := n.opt.TaskURLs[msg.Job.Task]; u != "" {
+		return u
+	}
+
+	return n.opt.URL
+}
+
+// send posts the notification, retrying with an exponential backoff on errors and non 2xx responses.
+func (n *Notifier) send(ctx context.Context, r request) error {
+	var (
+		backoff = n.opt.Backoff
+		err     error
+	)
+	for i := 0; i <= n.opt.MaxRetries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err = n.post(ctx, r); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (n *Notifier) post(ctx context.Context, r request) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(r.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.opt.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.opt.Secret, r.body))
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the body. Receivers can use it
+// to verify the signature header.
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
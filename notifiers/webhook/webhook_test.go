@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/zerodha/logf"
+)
+
+func event(task, status string, labels map[string]string) tasqueue.Event {
+	return tasqueue.Event{
+		Job: tasqueue.JobMessage{
+			Meta: tasqueue.Meta{UUID: "uuid", Status: status, Labels: labels},
+			Job:  &tasqueue.Job{Task: task},
+		},
+		Time: time.Now(),
+	}
+}
+
+func TestURL(t *testing.T) {
+	n := New(Options{
+		URL:       "http://default",
+		TaskURLs:  map[string]string{"add": "http://add"},
+		Endpoints: map[string]string{"billing": "http://billing"},
+	}, logf.New(logf.Opts{}))
+
+	for _, c := range []struct {
+		name   string
+		task   string
+		labels map[string]string
+		url    string
+	}{
+		{"default", "sub", nil, "http://default"},
+		{"task", "add", nil, "http://add"},
+		{"endpoint", "add", map[string]string{EndpointLabel: "billing"}, "http://billing"},
+		{"unknown endpoint", "add", map[string]string{EndpointLabel: "http://169.254.169.254/"}, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if u := n.url(event(c.task, tasqueue.StatusDone, c.labels).Job); u != c.url {
+				t.Fatalf("expected url %q, got %q", c.url, u)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var (
+		calls  int32
+		bodies = make(chan Notification, 10)
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, and is retried.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get(SignatureHeader); sig != Sign("secret", b) {
+			t.Errorf("incorrect signature %s", sig)
+		}
+		var n Notification
+		if err := json.Unmarshal(b, &n); err != nil {
+			t.Error(err)
+		}
+		bodies <- n
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := New(Options{URL: ts.URL, Secret: "secret", Backoff: time.Millisecond}, logf.New(logf.Opts{}))
+	go n.Run(ctx)
+
+	// Only final states are notified.
+	n.Notify(event("add", tasqueue.StatusProcessing, nil))
+	n.Notify(event("add", tasqueue.StatusCancelled, nil))
+
+	select {
+	case b := <-bodies:
+		if b.UUID != "uuid" || b.Task != "add" || b.Status != tasqueue.StatusCancelled {
+			t.Fatalf("unexpected notification %+v", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a notification")
+	}
+	select {
+	case b := <-bodies:
+		t.Fatalf("unexpected notification %+v", b)
+	case <-time.After(time.Millisecond * 50):
+	}
+	if c := atomic.LoadInt32(&calls); c != 2 {
+		t.Fatalf("expected 2 requests, got %d", c)
+	}
+}
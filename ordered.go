@@ -0,0 +1,199 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// orderedLockPrefix prefixes the key of the lock held by the consumer of an ordered queue.
+	orderedLockPrefix = "tasqueue:ordered:"
+	// orderedLockTTL is the duration after which the lock of a consumer that stopped refreshing
+	// it (eg: as its server died) expires. The lock is refreshed, or retried, at a third of it.
+	orderedLockTTL = time.Second * 30
+)
+
+// lock is a lock held by an owner until it expires.
+type lock struct {
+	owner   string
+	expires time.Time
+}
+
+// localLocker is the default Locker, which provides locks within the server.
+type localLocker struct {
+	clock Clock
+
+	mu    sync.Mutex
+	locks map[string]lock
+}
+
+func newLocalLocker(c Clock) *localLocker {
+	return &localLocker{clock: c, locks: make(map[string]lock)}
+}
+
+func (l *localLocker) Lock(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if lk, ok := l.locks[key]; ok && lk.owner != owner && now.Before(lk.expires) {
+		return false, nil
+	}
+	l.locks[key] = lock{owner: owner, expires: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (l *localLocker) Unlock(_ context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lk, ok := l.locks[key]; ok && lk.owner == owner {
+		delete(l.locks, key)
+	}
+
+	return nil
+}
+
+// isOrdered returns true if the queue is processed in strict FIFO order.
+func (s *Server) isOrdered(queue string) bool {
+	_, ok := s.ordered[queue]
+	return ok
+}
+
+// consumeOrdered consumes the ordered queue while holding its lock, so that only one
+// consumer across the servers sharing the locker consumes it. Other consumers wait for the
+// lock to be released or expire. It returns after the context is cancelled.
+func (s *Server) consumeOrdered(ctx context.Context, queue string) {
+	// The jobs are processed with the server's context, like the processors', so that the
+	// job being processed isn't cancelled as the queue is paused or the task restarted.
+	s.p.RLock()
+	jctx := s.runCtx
+	s.p.RUnlock()
+	if jctx == nil {
+		return
+	}
+
+	var (
+		key   = orderedLockPrefix + queue
+		owner = s.workerID + ":" + uuid.NewString()
+	)
+	for {
+		ok, err := s.locker.Lock(ctx, key, owner, orderedLockTTL)
+		if err != nil {
+			s.log.Error("error acquiring lock of ordered queue", "queue", queue, "error", err)
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(orderedLockTTL / 3):
+			}
+			continue
+		}
+
+		s.log.Info("acquired lock of ordered queue", "queue", queue)
+		// The lock is refreshed until the last job received is processed, even if the
+		// consumer is stopped meanwhile.
+		var (
+			cctx, cancel = context.WithCancel(ctx)
+			processed    = make(chan struct{})
+		)
+		go func() {
+			defer cancel()
+			for {
+				select {
+				case <-processed:
+					return
+				case <-s.clock.After(orderedLockTTL / 3):
+				}
+				// The context may be cancelled, hence a new one is used to refresh.
+				if ok, err := s.locker.Lock(context.Background(), key, owner, orderedLockTTL); err != nil || !ok {
+					s.log.Error("lost lock of ordered queue, stopping consumer", "queue", queue, "error", err)
+					return
+				}
+			}
+		}()
+		s.processOrdered(cctx, jctx, queue)
+		close(processed)
+		cancel()
+
+		// The context may be cancelled, hence a new one is used to unlock.
+		if err := s.locker.Unlock(context.Background(), key, owner); err != nil {
+			s.log.Error("error releasing lock of ordered queue", "queue", queue, "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// processOrdered consumes the queue, and processes each job it receives before receiving the
+// next one, instead of passing it to the task's processor. It returns once the consumer has
+// exited and the last job it received is processed, so that the queue's lock is held until
+// then, and another server doesn't start the next job meanwhile.
+func (s *Server) processOrdered(ctx, jctx context.Context, queue string) {
+	var (
+		work = make(chan []byte)
+		done = make(chan struct{})
+	)
+	go func() {
+		s.consumeQueue(ctx, work, queue)
+		close(done)
+	}()
+
+	for {
+		select {
+		case b := <-work:
+			// A job received as the server stops is pushed back onto the queue.
+			if jctx.Err() != nil {
+				s.pushBack(b, queue)
+				continue
+			}
+			s.handle(jctx, b)
+		case <-done:
+			return
+		}
+	}
+}
+
+// holdBack holds back a job over its rate or quota for the duration. Jobs of ordered queues
+// are held in place, and it returns false once the duration has passed, for the job to be
+// checked again. Other jobs are pushed back onto the queue, and it returns true.
+func (s *Server) holdBack(ctx context.Context, work []byte, queue string, wait time.Duration) bool {
+	if !s.isOrdered(queue) {
+		s.requeueLater(ctx, work, queue, wait)
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		s.pushBack(work, queue)
+		return true
+	case <-s.clock.After(wait):
+		return false
+	}
+}
+
+// inPlaceRetry is returned on retrying a job of an ordered queue, which is retried by the
+// processor instead of being pushed back onto the queue behind the jobs enqueued after it.
+type inPlaceRetry struct {
+	msg JobMessage
+}
+
+func (r *inPlaceRetry) Error() string {
+	return "job retried in place"
+}
+
+// retryInPlace increments the retried count of the job, to be retried by the processor.
+func (s *Server) retryInPlace(ctx context.Context, msg JobMessage) error {
+	msg.Retried += 1
+	if err := s.statusRetrying(ctx, msg); err != nil {
+		return err
+	}
+
+	return &inPlaceRetry{msg: msg}
+}
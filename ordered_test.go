@@ -0,0 +1,244 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+	rb "github.com/kalbhor/tasqueue/brokers/redis"
+	"github.com/zerodha/logf"
+)
+
+func TestOrderedQueues(t *testing.T) {
+	var (
+		broker  = NewMockBroker()
+		results = NewMockResults()
+		locker  = newLocalLocker(systemClock{})
+
+		mu      sync.Mutex
+		running int
+		order   []int
+		failed  bool
+		wg      sync.WaitGroup
+	)
+	handler := func(b []byte, _ JobCtx) error {
+		i, _ := strconv.Atoi(string(b))
+
+		mu.Lock()
+		running++
+		if running > 1 {
+			t.Error("expected jobs of the ordered queue to be processed one at a time")
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		// The first job fails once, and is retried before the jobs behind it.
+		if i == 0 && !failed {
+			failed = true
+			return fmt.Errorf("job failed")
+		}
+		order = append(order, i)
+		wg.Done()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The servers share the locker, so that only one of them consumes the queue.
+	var srvs []*Server
+	for i := 0; i < 2; i++ {
+		srv, err := NewServer(ServerOpts{
+			Broker:        broker,
+			Results:       results,
+			OrderedQueues: []string{DefaultQueue},
+			Locker:        locker,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask("ordered", handler, TaskOpts{Concurrency: 4})
+		srvs = append(srvs, srv)
+	}
+
+	for i := 0; i < 10; i++ {
+		job, err := NewJob("ordered", []byte(strconv.Itoa(i)), JobOpts{MaxRetries: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srvs[0].Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, srv := range srvs {
+		go srv.Start(ctx)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, j := range order {
+		if i != j {
+			t.Fatalf("expected jobs to be processed in order, got %v", order)
+		}
+	}
+}
+
+func TestOrderedQueueConcurrency(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:        mb.New(),
+		Results:       NewMockResults(),
+		OrderedQueues: []string{"ordered"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The task's retry queue isn't ordered, hence its jobs are processed concurrently.
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+		wg      sync.WaitGroup
+	)
+	srv.RegisterTask("ordered", func(b []byte, _ JobCtx) error {
+		defer wg.Done()
+
+		mu.Lock()
+		if running++; running > max {
+			max = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 50)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, TaskOpts{Queue: "ordered", RetryQueue: "retries", RetryConcurrency: 3})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		job, err := NewJob("ordered", nil, JobOpts{Queue: "retries"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if max != 3 {
+		t.Fatalf("expected the retry queue's jobs to be processed concurrently, got %d at a time", max)
+	}
+}
+
+// TestOrderedQueuesRedis checks the order in which the jobs of an ordered queue are processed
+// on redis, whose lists are consumed from the head. It's skipped if redis isn't running.
+func TestOrderedQueuesRedis(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := rb.New(rb.Options{
+		Addrs:      []string{redisAddr},
+		Password:   redisPass,
+		DB:         redisDB,
+		PollPeriod: time.Millisecond * 100,
+	}, logf.New(logf.Opts{Level: logf.FatalLevel}))
+	if err := broker.Ping(ctx); err != nil {
+		t.Skipf("redis isn't available: %v", err)
+	}
+
+	var (
+		queue = fmt.Sprintf("tasqueue:test:ordered:%d", time.Now().UnixNano())
+		order = make(chan int, 10)
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:        broker,
+		Results:       NewMockResults(),
+		OrderedQueues: []string{queue},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("ordered", func(b []byte, _ JobCtx) error {
+		i, _ := strconv.Atoi(string(b))
+		order <- i
+		return nil
+	}, TaskOpts{Queue: queue})
+
+	for i := 0; i < cap(order); i++ {
+		job, err := NewJob("ordered", []byte(strconv.Itoa(i)), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+
+	for i := 0; i < cap(order); i++ {
+		select {
+		case j := <-order:
+			if j != i {
+				t.Fatalf("expected job %d to be processed next, got %d", i, j)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for jobs")
+		}
+	}
+}
+
+func TestLocalLocker(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		clock = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		l     = newLocalLocker(clock)
+	)
+	lock := func(owner string, exp bool) {
+		ok, err := l.Lock(ctx, "key", owner, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != exp {
+			t.Fatalf("expected lock by %s to be %v, got %v", owner, exp, ok)
+		}
+	}
+
+	lock("a", true)
+	lock("b", false)
+	// The owner extends its lock.
+	lock("a", true)
+
+	// An expired lock can be acquired by another owner.
+	clock.advance(time.Minute * 2)
+	lock("b", true)
+
+	// Only the owner releases the lock.
+	if err := l.Unlock(ctx, "key", "a"); err != nil {
+		t.Fatal(err)
+	}
+	lock("a", false)
+	if err := l.Unlock(ctx, "key", "b"); err != nil {
+		t.Fatal(err)
+	}
+	lock("a", true)
+}
@@ -0,0 +1,136 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel"
+	spans "go.opentelemetry.io/otel/trace"
+)
+
+// ErrTrimUnsupported is returned on trimming a queue, if the broker doesn't implement Trimmer.
+var ErrTrimUnsupported = errors.New("broker doesn't support trimming queues")
+
+// OverflowPolicy is the handling of jobs enqueued onto a queue at its max length.
+type OverflowPolicy uint8
+
+const (
+	// OverflowReject rejects the job with ErrQueueFull.
+	OverflowReject OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest jobs of the queue to make room for the job, which
+	// are marked as dropped. It requires a broker which implements Trimmer, otherwise the
+	// job is rejected.
+	OverflowDropOldest
+	// OverflowDivert enqueues the job onto the limit's OverflowQueue instead.
+	OverflowDivert
+)
+
+// QueueLimit caps the number of messages waiting in a queue. The length is checked at enqueue,
+// hence concurrent producers can overshoot it.
+type QueueLimit struct {
+	MaxLength int64
+	Overflow  OverflowPolicy
+	// OverflowQueue is the queue onto which jobs are diverted with OverflowDivert. It needs a
+	// task consuming it.
+	OverflowQueue string
+}
+
+// trim removes the oldest messages of the queue, so that n remain, if the broker implements
+// Trimmer.
+func trim(ctx context.Context, b Broker, queue string, n int64) ([][]byte, error) {
+	if t, ok := b.(Trimmer); ok {
+		return t.Trim(ctx, queue, n)
+	}
+	return nil, ErrTrimUnsupported
+}
+
+// parseLimits validates the queue limits.
+func parseLimits(limits map[string]QueueLimit) (map[string]QueueLimit, error) {
+	out := make(map[string]QueueLimit, len(limits))
+	for q, l := range limits {
+		if l.MaxLength <= 0 {
+			return nil, fmt.Errorf("max length of queue %s should be positive", q)
+		}
+		if l.Overflow == OverflowDivert && (l.OverflowQueue == "" || l.OverflowQueue == q) {
+			return nil, fmt.Errorf("overflow queue of queue %s should be another queue", q)
+		}
+		out[q] = l
+	}
+
+	return out, nil
+}
+
+// enforceLimit applies the overflow policy of the message's queue, if the queue is at its max
+// length. Diverted messages are moved onto the overflow queue.
+func (s *Server) enforceLimit(ctx context.Context, msg *JobMessage) error {
+	l, ok := s.limits[msg.Queue]
+	if !ok {
+		return nil
+	}
+
+	if l.Overflow == OverflowDropOldest {
+		dropped, err := trim(ctx, s.broker, msg.Queue, l.MaxLength-1)
+		if err == nil {
+			s.dropJobs(ctx, dropped)
+			return nil
+		}
+		if !errors.Is(err, ErrTrimUnsupported) {
+			return fmt.Errorf("could not trim queue %s : %w", msg.Queue, err)
+		}
+		// The job is rejected if the oldest jobs can't be dropped.
+	}
+
+	d, err := depth(ctx, s.broker, msg.Queue)
+	if err != nil {
+		return fmt.Errorf("could not check length of queue %s : %w", msg.Queue, err)
+	}
+	if d < l.MaxLength {
+		return nil
+	}
+
+	if l.Overflow == OverflowDivert {
+		s.log.Debug("diverting job onto overflow queue", "uuid", msg.UUID, "queue", msg.Queue, "overflow_queue", l.OverflowQueue)
+		msg.Queue = l.OverflowQueue
+		return nil
+	}
+
+	return fmt.Errorf("could not enqueue job %s onto queue %s with %d jobs : %w", msg.Job.Task, msg.Queue, d, ErrQueueFull)
+}
+
+// dropJobs marks the jobs trimmed off a queue as dropped.
+func (s *Server) dropJobs(ctx context.Context, msgs [][]byte) {
+	for _, b := range msgs {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(b, &msg); err != nil {
+			s.log.Error("error decoding dropped job", "error", err)
+			continue
+		}
+		if err := s.statusDropped(ctx, msg); err != nil {
+			s.log.Error("error setting the status to dropped", "uuid", msg.UUID, "error", err)
+		}
+	}
+}
+
+func (s *Server) statusDropped(ctx context.Context, t JobMessage) error {
+	var span spans.Span
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "status_dropped")
+		defer span.End()
+	}
+
+	t.ProcessedAt = s.clock.Now()
+	t.Status = StatusDropped
+
+	if err := s.setJobMessage(ctx, t); err != nil {
+		s.spanError(span, err)
+		return err
+	}
+
+	s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",queue="%s"}`, metricJobsDropped, t.Job.Task, t.Queue)).Inc()
+
+	s.deletePayload(ctx, t)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+)
+
+func TestQueueLimits(t *testing.T) {
+	if _, err := NewServer(ServerOpts{Broker: NewMockBroker(), QueueLimits: map[string]QueueLimit{"a": {MaxLength: 1, Overflow: OverflowDivert}}}); err == nil {
+		t.Fatal("expected an error for a diverting limit without an overflow queue")
+	}
+
+	ctx := context.Background()
+	srv, err := NewServer(ServerOpts{
+		Broker:    mb.New(),
+		Results:   NewMockResults(),
+		Namespace: "test",
+		QueueLimits: map[string]QueueLimit{
+			"reject": {MaxLength: 2},
+			"drop":   {MaxLength: 2, Overflow: OverflowDropOldest},
+			"divert": {MaxLength: 1, Overflow: OverflowDivert, OverflowQueue: "overflow"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue := func(queue string) (string, error) {
+		j, err := NewJob(taskName, nil, JobOpts{Queue: queue})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return srv.Enqueue(ctx, j)
+	}
+	depthOf := func(queue string) int64 {
+		d, err := srv.QueueDepth(ctx, queue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	var uuids []string
+	for _, q := range []string{"reject", "reject", "drop", "drop", "drop", "divert", "divert"} {
+		uuid, err := enqueue(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	if _, err := enqueue("reject"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// The oldest job is dropped off the queue.
+	if d := depthOf("drop"); d != 2 {
+		t.Fatalf("expected the queue to be trimmed to 2 jobs, got %d", d)
+	}
+	msg, err := srv.GetJob(ctx, uuids[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDropped {
+		t.Fatalf("expected status %s, got %s", StatusDropped, msg.Status)
+	}
+
+	// The job over the limit is diverted onto the overflow queue.
+	msg, err = srv.GetJob(ctx, uuids[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Queue != "overflow" || depthOf("divert") != 1 || depthOf("overflow") != 1 {
+		t.Fatalf("expected the job to be diverted, got queue %s", msg.Queue)
+	}
+}
@@ -0,0 +1,74 @@
+package tasqueue
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// laneBuffer is the number of jobs buffered on each processor's lane, so that a busy lane
+// doesn't hold up routing the jobs of the other lanes until it's full.
+const laneBuffer = 16
+
+// partitionKey is the part of a job message decoded to route it to a lane.
+type partitionKey struct {
+	PartitionKey string
+	Queue        string
+}
+
+// partition routes the messages received on work by their partition key. Messages with a
+// key are sent to the lane the key hashes to, whose processor processes them serially and
+// in order, while the rest are sent to shared, to be processed by any of the processors.
+// When it stops, the message being routed and the messages left on the lanes are pushed
+// back onto their queues.
+func (s *Server) partition(ctx context.Context, work <-chan []byte, shared chan<- []byte, lanes []chan []byte, done <-chan struct{}) {
+	defer func() {
+		for _, lane := range lanes {
+			s.drainLane(lane)
+		}
+	}()
+
+	for {
+		var b []byte
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case b = <-work:
+		}
+
+		out := shared
+		var k partitionKey
+		if err := msgpack.Unmarshal(b, &k); err == nil && k.PartitionKey != "" {
+			h := fnv.New32a()
+			h.Write([]byte(k.PartitionKey))
+			out = lanes[h.Sum32()%uint32(len(lanes))]
+		}
+
+		select {
+		case <-ctx.Done():
+			s.pushBack(b, k.Queue)
+			return
+		case <-done:
+			s.pushBack(b, k.Queue)
+			return
+		case out <- b:
+		}
+	}
+}
+
+// drainLane pushes the messages buffered on the lane back onto their queues.
+func (s *Server) drainLane(lane chan []byte) {
+	for {
+		select {
+		case b := <-lane:
+			var k partitionKey
+			msgpack.Unmarshal(b, &k)
+			s.pushBack(b, k.Queue)
+		default:
+			return
+		}
+	}
+}
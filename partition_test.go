@@ -0,0 +1,141 @@
+package tasqueue
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPartitionKey(t *testing.T) {
+	// The mock broker's consumers share the enqueued messages, hence a server with
+	// only the partitioned task is used.
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		running = make(map[string]int)
+		order   = make(map[string][]int)
+		wg      sync.WaitGroup
+	)
+	srv.RegisterTask("partitioned", func(b []byte, c JobCtx) error {
+		defer wg.Done()
+		key := c.Label("key")
+
+		mu.Lock()
+		running[key]++
+		if running[key] > 1 {
+			t.Errorf("expected jobs of key %s to be processed serially", key)
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		i, _ := strconv.Atoi(string(b))
+		mu.Lock()
+		running[key]--
+		order[key] = append(order[key], i)
+		mu.Unlock()
+		return nil
+	}, TaskOpts{Concurrency: 4})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys := []string{"a", "b", "c"}
+	for i := 0; i < 30; i++ {
+		key := keys[i%len(keys)]
+		job, err := NewJob("partitioned", []byte(strconv.Itoa(i)), JobOpts{
+			PartitionKey: key,
+			Labels:       map[string]string{"key": key},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for key, o := range order {
+		for i := 1; i < len(o); i++ {
+			if o[i] < o[i-1] {
+				t.Fatalf("expected jobs of key %s to be processed in order, got %v", key, o)
+			}
+		}
+	}
+}
+
+func TestPartitionLanes(t *testing.T) {
+	b := mb.New()
+	srv, err := NewServer(ServerOpts{Broker: b, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// laneOf returns the lane of the partition key, as routed by the server.
+	laneOf := func(key string) int {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return int(h.Sum32() % 2)
+	}
+	message := func(key string) []byte {
+		job, err := NewJob(taskName, []byte(key), JobOpts{PartitionKey: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := job.message(Meta{UUID: key, Queue: "partitioned", PartitionKey: key})
+		bm, err := msgpack.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bm
+	}
+	busy, free := "a", "b"
+	for i := 0; laneOf(busy) == laneOf(free); i++ {
+		free = "b" + strconv.Itoa(i)
+	}
+
+	var (
+		work  = make(chan []byte)
+		lanes = []chan []byte{make(chan []byte, laneBuffer), make(chan []byte, laneBuffer)}
+		done  = make(chan struct{})
+		exit  = make(chan struct{})
+	)
+	go func() {
+		srv.partition(context.Background(), work, make(chan []byte), lanes, done)
+		close(exit)
+	}()
+
+	// The jobs of a busy lane are buffered, without holding up the other lanes.
+	for i := 0; i < laneBuffer; i++ {
+		work <- message(busy)
+	}
+	work <- message(free)
+	select {
+	case <-lanes[laneOf(free)]:
+	case <-time.After(time.Second):
+		t.Fatal("expected the job to be routed past the busy lane")
+	}
+
+	// The job being routed and the jobs buffered on the lanes are pushed back when it stops.
+	work <- message(busy)
+	close(done)
+	<-exit
+	if n, err := b.Depth(context.Background(), "partitioned"); err != nil || n != laneBuffer+1 {
+		t.Fatalf("expected %d jobs to be pushed back, got %d, %v", laneBuffer+1, n, err)
+	}
+}
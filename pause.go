@@ -0,0 +1,120 @@
+package tasqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// pauses holds the queues whose consumption is paused. The changed channel is closed (and
+// replaced) on every change, to wake up the consumers waiting on it.
+type pauses struct {
+	mu      sync.Mutex
+	paused  map[string]struct{}
+	changed chan struct{}
+}
+
+func newPauses() *pauses {
+	return &pauses{paused: make(map[string]struct{}), changed: make(chan struct{})}
+}
+
+// get returns true if the queue is paused, and the channel which is closed on the next change.
+func (p *pauses) get(queue string) (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.paused[queue]
+	return ok, p.changed
+}
+
+// set replaces the paused queues.
+func (p *pauses) set(queues []string) {
+	p.update(func(paused map[string]struct{}) {
+		for q := range paused {
+			delete(paused, q)
+		}
+		for _, q := range queues {
+			paused[q] = struct{}{}
+		}
+	})
+}
+
+// update changes the paused queues, and wakes up the consumers.
+func (p *pauses) update(fn func(paused map[string]struct{})) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fn(p.paused)
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// list returns the paused queues, sorted.
+func (p *pauses) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]string, 0, len(p.paused))
+	for q := range p.paused {
+		out = append(out, q)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// PauseQueue() stops the server's consumers of the queue from consuming its jobs, until
+// UnpauseQueue() is called, eg: while a downstream service is down. The jobs already consumed
+// are processed, and jobs can still be enqueued onto the queue.
+func (s *Server) PauseQueue(queue string) {
+	s.pauses.update(func(paused map[string]struct{}) { paused[queue] = struct{}{} })
+	s.log.Info("paused queue", "queue", queue)
+	s.recordAudit(context.Background(), OpPauseQueue, queue, "")
+}
+
+// UnpauseQueue() resumes the consumption of a paused queue.
+func (s *Server) UnpauseQueue(queue string) {
+	s.pauses.update(func(paused map[string]struct{}) { delete(paused, queue) })
+	s.log.Info("unpaused queue", "queue", queue)
+	s.recordAudit(context.Background(), OpUnpauseQueue, queue, "")
+}
+
+// consumePausable() consumes the queue until the context is cancelled, stopping its consumer
+// while the queue is paused.
+func (s *Server) consumePausable(ctx context.Context, queue string, consume func(ctx context.Context)) {
+	for {
+		paused, changed := s.pauses.get(queue)
+		if paused {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				continue
+			}
+		}
+
+		cctx, stop := context.WithCancel(ctx)
+		go func() {
+			for {
+				select {
+				case <-cctx.Done():
+					return
+				case <-changed:
+					var paused bool
+					if paused, changed = s.pauses.get(queue); paused {
+						s.log.Debug("stopping consumer of paused queue", "queue", queue)
+						stop()
+						return
+					}
+				}
+			}
+		}()
+		consume(cctx)
+		paused = cctx.Err() != nil && ctx.Err() == nil
+		stop()
+		// The consumer exited on its own, or the server stopped.
+		if !paused {
+			return
+		}
+	}
+}
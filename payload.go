@@ -0,0 +1,126 @@
+package tasqueue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+)
+
+// PayloadPolicy is the action taken on enqueuing a job whose payload is larger than
+// the max payload size.
+type PayloadPolicy uint8
+
+const (
+	// PayloadReject rejects the job with ErrPayloadTooLarge.
+	PayloadReject PayloadPolicy = iota
+	// PayloadCompress gzips the payload. If the compressed payload is still
+	// too large, the job is rejected.
+	PayloadCompress
+	// PayloadOffload stores the payload in the blob store and enqueues a reference to it instead.
+	PayloadOffload
+)
+
+const (
+	blobPrefix = "tasqueue:payload:"
+
+	// Encodings of job payloads, set on the job meta.
+	encodingGzip = "gzip"
+	encodingBlob = "blob"
+)
+
+// encodePayload applies the payload policy to a job whose payload exceeds the max payload size.
+// The payload is replaced on the job and the encoding used is set on the meta.
+func (s *Server) encodePayload(ctx context.Context, t *Job, meta *Meta) error {
+	// Streamed payloads are always offloaded.
+	if t.stream != nil {
+		key := blobPrefix + meta.UUID
+		if err := putStream(ctx, s.blobs, key, t.stream); err != nil {
+			return fmt.Errorf("could not offload payload : %w", err)
+		}
+		t.Payload, t.stream = []byte(key), nil
+		meta.PayloadEncoding = encodingBlob
+		return nil
+	}
+	if s.maxPayloadSize == 0 || len(t.Payload) <= s.maxPayloadSize {
+		return nil
+	}
+
+	switch s.payloadPolicy {
+	case PayloadCompress:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(t.Payload); err != nil {
+			return fmt.Errorf("could not compress payload : %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("could not compress payload : %w", err)
+		}
+		if buf.Len() > s.maxPayloadSize {
+			return fmt.Errorf("could not enqueue job %s of %d bytes compressed : %w", t.Task, buf.Len(), ErrPayloadTooLarge)
+		}
+		t.Payload = buf.Bytes()
+		meta.PayloadEncoding = encodingGzip
+
+	case PayloadOffload:
+		key := blobPrefix + meta.UUID
+		if err := s.blobs.Put(ctx, key, t.Payload); err != nil {
+			return fmt.Errorf("could not offload payload : %w", err)
+		}
+		t.Payload = []byte(key)
+		meta.PayloadEncoding = encodingBlob
+
+	default:
+		return fmt.Errorf("could not enqueue job %s of %d bytes : %w", t.Task, len(t.Payload), ErrPayloadTooLarge)
+	}
+
+	return nil
+}
+
+// decodePayload returns the original payload of a job message, decompressing it or fetching
+// it from the blob store depending on how it was encoded at enqueue.
+func (s *Server) decodePayload(ctx context.Context, msg JobMessage) ([]byte, error) {
+	switch msg.PayloadEncoding {
+	case "":
+		return msg.Job.Payload, nil
+
+	case encodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(msg.Job.Payload))
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload : %w", err)
+		}
+		defer r.Close()
+
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress payload : %w", err)
+		}
+		return b, nil
+
+	case encodingBlob:
+		if s.blobs == nil {
+			return nil, fmt.Errorf("could not fetch offloaded payload : blob store missing in options")
+		}
+		b, err := s.blobs.Get(ctx, string(msg.Job.Payload))
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch offloaded payload : %w", err)
+		}
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("unknown payload encoding %s", msg.PayloadEncoding)
+}
+
+// deletePayload deletes the offloaded payload of a job that has reached a final state.
+// Scheduled jobs are enqueued repeatedly, hence their payloads are retained. It isn't called
+// for failed jobs, so that they can be retried.
+func (s *Server) deletePayload(ctx context.Context, msg JobMessage) {
+	if msg.PayloadEncoding != encodingBlob || msg.Schedule != "" || s.blobs == nil {
+		return
+	}
+
+	if err := s.blobs.Delete(ctx, string(msg.Job.Payload)); err != nil {
+		s.log.Error("could not delete offloaded payload", "uuid", msg.UUID, "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	bi "github.com/kalbhor/tasqueue/blobs/in-memory"
+)
+
+func NewMockBlobStore() *bi.Blobs {
+	return bi.New()
+}
+
+func TestPayloadPolicies(t *testing.T) {
+	var payload = bytes.Repeat([]byte("tasqueue"), 64)
+
+	for _, policy := range []PayloadPolicy{PayloadCompress, PayloadOffload} {
+		blobs := NewMockBlobStore()
+		srv, err := NewServer(ServerOpts{
+			Broker:         NewMockBroker(),
+			Results:        NewMockResults(),
+			MaxPayloadSize: 128,
+			PayloadPolicy:  policy,
+			BlobStore:      blobs,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recv := make(chan []byte, 1)
+		srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+			recv <- b
+			return nil
+		}, TaskOpts{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go srv.Start(ctx)
+
+		job, err := NewJob(taskName, payload, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msg.Job.Payload) > 128 {
+			t.Fatalf("payload of %d bytes was not encoded with policy %d", len(msg.Job.Payload), policy)
+		}
+
+		select {
+		case b := <-recv:
+			if !bytes.Equal(b, payload) {
+				t.Fatalf("incorrect payload received by handler with policy %d", policy)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("job was not processed")
+		}
+
+		// Offloaded payloads are deleted once the job is complete.
+		if policy == PayloadOffload {
+			time.Sleep(100 * time.Millisecond)
+			if _, err := blobs.Get(ctx, string(msg.Job.Payload)); err == nil {
+				t.Fatal("offloaded payload was not deleted")
+			}
+		}
+		cancel()
+	}
+}
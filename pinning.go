@@ -0,0 +1,102 @@
+package tasqueue
+
+import (
+	"context"
+	"strings"
+)
+
+// pinnedPrefix prefixes the tag indexing the jobs pinned to a worker, which fall back to their
+// queues if the worker dies before processing them.
+const pinnedPrefix = "tasqueue:pinned:"
+
+// WorkerQueue returns the worker's queue of the queue, onto which the jobs pinned to the worker
+// (JobOpts.Worker) are enqueued. It's consumed by the worker for its Pinnable tasks. The
+// separator isn't matched by queue patterns, hence worker queues aren't consumed by patterns.
+func WorkerQueue(queue, worker string) string {
+	return queue + "/" + worker
+}
+
+// ThenPinned() appends jobs like Then(), which are pinned to the worker processing the job
+// (see JobOpts.Worker), eg: for steps that use state cached locally by the worker, or
+// hardware attached to it.
+func (c *JobCtx) ThenPinned(jobs ...Job) {
+	for i := range jobs {
+		jobs[i].Opts.Worker = c.worker
+	}
+	c.Then(jobs...)
+}
+
+// pin enqueues the job onto its worker's queue, if the worker is registered (it heartbeats).
+// Otherwise, the job isn't pinned. Pinned jobs are indexed on the worker, so that they're
+// moved back onto their queue if the worker dies (see RecoverJobs()).
+func (s *Server) pin(ctx context.Context, msg *JobMessage) error {
+	var workers []string
+	if s.leaser != nil {
+		var err error
+		if workers, err = s.leaser.GetLeases(ctx, workersKey); err != nil {
+			return err
+		}
+	}
+	if !contains(workers, msg.Worker) {
+		s.log.Debug("pinned worker isn't registered, enqueuing job onto its queue", "uuid", msg.UUID, "worker", msg.Worker)
+		msg.Worker = ""
+		return nil
+	}
+
+	msg.Queue = WorkerQueue(msg.Queue, msg.Worker)
+	return setTag(ctx, s.results, pinnedPrefix+msg.Worker, msg.UUID)
+}
+
+// unpin removes the job from its worker's queue.
+func (m *JobMessage) unpin() {
+	m.Queue = strings.TrimSuffix(m.Queue, "/"+m.Worker)
+	m.Worker = ""
+}
+
+// fellBack returns true if the pinned job consumed from its worker's queue was moved back onto
+// its queue in the meantime (as the worker was presumed dead), and hence has to be skipped.
+// Otherwise, the job is removed from the worker's pinned jobs.
+func (s *Server) fellBack(ctx context.Context, msg JobMessage) bool {
+	stored, err := s.getJob(ctx, msg.UUID, false)
+	if err == nil && stored.Worker == "" {
+		return true
+	}
+	if err := deleteTag(ctx, s.results, pinnedPrefix+msg.Worker, msg.UUID); err != nil {
+		s.log.Error("error removing pinned job", "uuid", msg.UUID, "worker", msg.Worker, "error", err)
+	}
+
+	return false
+}
+
+// unpinJobs moves the jobs pinned to the dead worker, that are waiting on its queues, back onto
+// their queues, and returns the number of jobs moved.
+func (s *Server) unpinJobs(ctx context.Context, worker string) (int, error) {
+	uuids, err := getTag(ctx, s.results, pinnedPrefix+worker)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, uuid := range uuids {
+		msg, err := s.getJob(ctx, uuid, false)
+		if err != nil {
+			return n, err
+		}
+		if msg.Worker == worker && (msg.Status == StatusStarted || msg.Status == StatusRetrying) {
+			s.log.Info("moving job pinned to dead worker onto its queue", "uuid", uuid, "worker", worker)
+			msg.unpin()
+			if err := s.setJobMessage(ctx, msg); err != nil {
+				return n, err
+			}
+			if err := s.enqueueMessage(ctx, msg); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err := deleteTag(ctx, s.results, pinnedPrefix+worker, uuid); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,96 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPinning(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:          broker,
+		Results:         NewMockResults(),
+		Clock:           clock,
+		WorkerID:        "gpu-1",
+		HeartbeatPeriod: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("load", func(b []byte, c JobCtx) error {
+		c.ThenPinned(makeJob(t, false))
+		return nil
+	}, TaskOpts{})
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{Pinnable: true})
+
+	task, err := srv.getHandler(taskName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qs := task.queues(); !contains(qs, WorkerQueue(DefaultQueue, "gpu-1")) {
+		t.Fatalf("expected the pinnable task to consume its worker queue, got %v", qs)
+	}
+
+	next := func() JobMessage {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(<-broker.data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	load := func() JobMessage {
+		if _, err := srv.Enqueue(ctx, Job{Task: "load"}); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+		return next()
+	}
+
+	// Jobs pinned to a worker that isn't registered are enqueued onto their queue.
+	if msg := load(); msg.Queue != DefaultQueue || msg.Worker != "" {
+		t.Fatalf("expected the job to fall back to its queue, got %s", msg.Queue)
+	}
+
+	if err := srv.beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pinned := load()
+	if pinned.Queue != WorkerQueue(DefaultQueue, "gpu-1") || pinned.Worker != "gpu-1" {
+		t.Fatalf("expected the job to be pinned to the worker, got %s", pinned.Queue)
+	}
+
+	// The jobs pinned to a dead worker are moved back onto their queue, and the pinned message
+	// is skipped if it's consumed afterwards.
+	clock.advance(time.Minute * 2)
+	if n, err := srv.RecoverJobs(ctx, RecoveryOpts{Timeout: time.Minute}); err != nil || n != 1 {
+		t.Fatalf("expected the pinned job to be recovered, got %d : %v", n, err)
+	}
+	moved := next()
+	if moved.UUID != pinned.UUID || moved.Queue != DefaultQueue || moved.Worker != "" {
+		t.Fatalf("expected the pinned job to be moved onto its queue, got %s", moved.Queue)
+	}
+
+	b, err := msgpack.Marshal(pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, b)
+	if msg, err := srv.GetJob(ctx, pinned.UUID); err != nil || msg.Status != StatusStarted {
+		t.Fatalf("expected the pinned message to be skipped, got %s : %v", msg.Status, err)
+	}
+	b, err = msgpack.Marshal(moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, b)
+	if msg, err := srv.GetJob(ctx, pinned.UUID); err != nil || msg.Status != StatusDone {
+		t.Fatalf("expected the moved job to be done, got %s : %v", msg.Status, err)
+	}
+}
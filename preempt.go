@@ -0,0 +1,113 @@
+package tasqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// preemptible is a running job of a preemptible task.
+type preemptible struct {
+	priority int
+	cost     int
+	started  time.Time
+	cancel   context.CancelFunc
+	// preempted is set (to 1) once the job is preempted.
+	preempted int32
+}
+
+type preemptKey struct{}
+
+// preemptibles holds the running jobs of the preemptible tasks, by UUID.
+type preemptibles struct {
+	mu   sync.Mutex
+	jobs map[string]*preemptible
+}
+
+// runPreemptible returns the job's context, which is cancelled if the job is preempted, and the
+// function to call once the job is done. Jobs are only preemptible if the server's cost is capped,
+// and not on ordered queues, as requeuing them would break the order.
+func (s *Server) runPreemptible(ctx context.Context, task Task, msg JobMessage) (context.Context, func()) {
+	if !task.opts.Preemptible || s.costs == nil || s.isOrdered(msg.Queue) {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &preemptible{priority: task.opts.Priority, cost: task.cost(), started: s.clock.Now(), cancel: cancel}
+
+	s.preempts.mu.Lock()
+	s.preempts.jobs[msg.UUID] = p
+	s.preempts.mu.Unlock()
+
+	return context.WithValue(ctx, preemptKey{}, p), func() {
+		s.preempts.mu.Lock()
+		delete(s.preempts.jobs, msg.UUID)
+		s.preempts.mu.Unlock()
+		cancel()
+	}
+}
+
+// preempt preempts the running preemptible jobs of a lower priority than the job's, until the
+// job's cost fits within the server's max cost. The lowest priority jobs are preempted first,
+// and among them, the most recently started ones, which lose the least work.
+func (s *Server) preempt(priority, cost int) {
+	need := s.costs.shortfall(cost)
+	if need <= 0 {
+		return
+	}
+
+	s.preempts.mu.Lock()
+	defer s.preempts.mu.Unlock()
+
+	var victims []*preemptible
+	for _, p := range s.preempts.jobs {
+		if p.priority < priority && atomic.LoadInt32(&p.preempted) == 0 {
+			victims = append(victims, p)
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool {
+		if victims[i].priority != victims[j].priority {
+			return victims[i].priority < victims[j].priority
+		}
+		return victims[i].started.After(victims[j].started)
+	})
+
+	for _, p := range victims {
+		if need <= 0 {
+			return
+		}
+		atomic.StoreInt32(&p.preempted, 1)
+		p.cancel()
+		need -= p.cost
+	}
+}
+
+// isPreempted returns true if the job of the context was preempted.
+func isPreempted(ctx context.Context) bool {
+	p, ok := ctx.Value(preemptKey{}).(*preemptible)
+	return ok && atomic.LoadInt32(&p.preempted) == 1
+}
+
+// requeuePreempted enqueues the preempted job back onto its queue, without counting the
+// attempt towards its retries.
+func (s *Server) requeuePreempted(msg JobMessage) error {
+	s.log.Debug("job preempted, requeuing", "uuid", msg.UUID, "task", msg.Job.Task)
+	s.metrics.GetOrCreateCounter(metricJobsPreempted).Inc()
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// The job's context is cancelled, hence a new one is used.
+	ctx := context.Background()
+	if err := s.statusStarted(ctx, msg); err != nil {
+		return err
+	}
+
+	return enqueuePriority(ctx, s.broker, b, msg.Queue, msg.Priority)
+}
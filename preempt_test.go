@@ -0,0 +1,98 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPreemption(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		broker    = NewMockBroker()
+		started   = make(chan struct{})
+		preempted = make(chan bool, 1)
+		ran       = make(chan struct{})
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), MaxCost: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("batch", func(b []byte, c JobCtx) error {
+		started <- struct{}{}
+		<-c.Done()
+		preempted <- c.Preempted()
+		return c.Err()
+	}, TaskOpts{Preemptible: true})
+	srv.RegisterTask("urgent", func(b []byte, c JobCtx) error {
+		close(ran)
+		return nil
+	}, TaskOpts{Priority: 1})
+
+	enqueue := func(task string) string {
+		job, err := NewJob(task, nil, JobOpts{MaxRetries: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.Process(ctx, <-broker.data)
+		return uuid
+	}
+
+	// The urgent job preempts the batch job, which holds the server's max cost.
+	uuid := enqueue("batch")
+	<-started
+	enqueue("urgent")
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected the urgent job to preempt the batch job")
+	}
+	if !<-preempted {
+		t.Fatal("expected the batch job to be preempted")
+	}
+
+	// The preempted job is requeued, without counting the attempt.
+	var msg JobMessage
+	if err := msgpack.Unmarshal(<-broker.data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.UUID != uuid || msg.Retried != 0 {
+		t.Fatalf("expected the preempted job to be requeued as is, got %s with %d retries", msg.UUID, msg.Retried)
+	}
+	if msg, err = srv.GetJob(ctx, uuid); err != nil || msg.Status != StatusStarted {
+		t.Fatalf("expected the preempted job to be queued, got %s (%v)", msg.Status, err)
+	}
+}
+
+func TestCostPriority(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		l     = newCostLimiter(1)
+		order = make(chan int, 2)
+	)
+	if !l.acquire(ctx, 1) {
+		t.Fatal("expected to acquire the cost")
+	}
+
+	// Waiters of a higher priority are served first.
+	for _, p := range []int{0, 1} {
+		p := p
+		go func() {
+			l.acquirePriority(ctx, 1, p)
+			order <- p
+			l.release(1)
+		}()
+		time.Sleep(time.Millisecond * 20)
+	}
+	l.release(1)
+	if p := <-order; p != 1 {
+		t.Fatalf("expected the higher priority waiter to be served first, got %d", p)
+	}
+	<-order
+}
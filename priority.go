@@ -0,0 +1,12 @@
+package tasqueue
+
+import "context"
+
+// enqueuePriority enqueues the message onto the queue with the priority, if it's set and the
+// broker implements PriorityBroker. Otherwise, the message is enqueued in order.
+func enqueuePriority(ctx context.Context, b Broker, msg []byte, queue string, priority uint8) error {
+	if p, ok := b.(PriorityBroker); ok && priority > 0 {
+		return p.EnqueuePriority(ctx, msg, queue, priority)
+	}
+	return b.Enqueue(ctx, msg, queue)
+}
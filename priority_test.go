@@ -0,0 +1,107 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// priorityBroker is a mock broker which records the priorities of the messages enqueued.
+type priorityBroker struct {
+	*MockBroker
+	priorities []uint8
+}
+
+func (b *priorityBroker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	b.priorities = append(b.priorities, priority)
+	return b.MockBroker.Enqueue(ctx, msg, queue)
+}
+
+func TestEnqueuePriority(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = &priorityBroker{MockBroker: NewMockBroker()}
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error { return nil }, TaskOpts{})
+
+	// Only the jobs with a priority are enqueued with it.
+	for _, p := range []uint8{0, 5} {
+		j, err := NewJob(taskName, nil, JobOpts{Priority: p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(broker.priorities) != 1 || broker.priorities[0] != 5 {
+		t.Fatalf("expected one job enqueued with priority 5, got %v", broker.priorities)
+	}
+
+	var msg JobMessage
+	<-broker.data
+	if err := msgpack.Unmarshal(<-broker.data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Priority != 5 {
+		t.Fatalf("expected the job message to carry priority 5, got %d", msg.Priority)
+	}
+}
+
+func TestPrefetchPriorities(t *testing.T) {
+	broker := NewMockBroker()
+	srv, err := NewServer(ServerOpts{Broker: broker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := func(uuid string, priority uint8) []byte {
+		b, err := msgpack.Marshal(JobMessage{Meta: Meta{UUID: uuid, Priority: priority}, Job: &Job{Task: taskName}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	uuid := func(b []byte) string {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.UUID
+	}
+
+	var (
+		ctx  = context.Background()
+		work = make(chan []byte)
+		out  = make(chan []byte)
+		done = make(chan struct{})
+		exit = make(chan struct{})
+	)
+	go func() {
+		srv.prefetch(ctx, work, out, 4, DefaultQueue, done)
+		close(exit)
+	}()
+
+	// The prefetched jobs are processed by the highest priority, in the order they were
+	// consumed within a priority.
+	work <- message("low", 0)
+	work <- message("high", 9)
+	work <- message("mid", 3)
+	work <- message("high-2", 9)
+	for _, u := range []string{"high", "high-2", "mid"} {
+		if got := uuid(<-out); got != u {
+			t.Fatalf("expected %s, got %s", u, got)
+		}
+	}
+
+	close(done)
+	<-exit
+	if got := uuid(<-broker.data); got != "low" {
+		t.Fatalf("expected the buffered job to be pushed back, got %s", got)
+	}
+}
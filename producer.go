@@ -0,0 +1,44 @@
+package tasqueue
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// TaskRef is the contract of a task that is processed by another service's workers: its name,
+// and optionally the version of its handler and its queue. As the task isn't registered on
+// the producer, its defaults can't be looked up, hence the producer has to agree with the
+// workers on them.
+type TaskRef struct {
+	Name    string
+	Version string
+	Queue   string
+}
+
+// NewJob() returns a job of the referenced task, with the task's version and queue unless
+// they are set on the options.
+func (r TaskRef) NewJob(payload []byte, opts JobOpts) (Job, error) {
+	if opts.Version == "" {
+		opts.Version = r.Version
+	}
+	if opts.Queue == "" {
+		opts.Queue = r.Queue
+	}
+
+	return NewJob(r.Name, payload, opts)
+}
+
+// NewJobMessage() returns the message of the job as it's enqueued onto the broker by a server
+// or client, with a new UUID, and the default queue if the job's queue isn't set. It is meant
+// for producers which enqueue onto the broker directly, with EncodeJobMessage(), whose jobs'
+// state isn't tracked until they're processed. Other producers should use a Client, which
+// only requires a broker.
+func NewJobMessage(j Job) JobMessage {
+	if j.Opts.Queue == "" {
+		j.Opts.Queue = DefaultQueue
+	}
+
+	return j.message(DefaultMeta(j.Opts))
+}
+
+// EncodeJobMessage() encodes the job message as it's enqueued onto the broker.
+func EncodeJobMessage(msg JobMessage) ([]byte, error) {
+	return msgpack.Marshal(msg)
+}
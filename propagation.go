@@ -0,0 +1,47 @@
+package tasqueue
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ValuePropagator propagates the string context values of the keys, mapped by name. The
+// name identifies the value on the carrier, as context keys are typically unexported types.
+type ValuePropagator map[string]any
+
+func (p ValuePropagator) Inject(ctx context.Context, carrier map[string]string) {
+	for name, key := range p {
+		if v, ok := ctx.Value(key).(string); ok {
+			carrier[name] = v
+		}
+	}
+}
+
+func (p ValuePropagator) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	for name, key := range p {
+		if v, ok := carrier[name]; ok {
+			ctx = context.WithValue(ctx, key, v)
+		}
+	}
+
+	return ctx
+}
+
+// OTelPropagator adapts an open telemetry propagator (eg: propagation.Baggage{}) to propagate
+// its values with jobs.
+func OTelPropagator(p propagation.TextMapPropagator) Propagator {
+	return otelPropagator{p}
+}
+
+type otelPropagator struct {
+	p propagation.TextMapPropagator
+}
+
+func (o otelPropagator) Inject(ctx context.Context, carrier map[string]string) {
+	o.p.Inject(ctx, propagation.MapCarrier(carrier))
+}
+
+func (o otelPropagator) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return o.p.Extract(ctx, propagation.MapCarrier(carrier))
+}
@@ -0,0 +1,43 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type requestIDKey struct{}
+
+func TestValuePropagator(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:     NewMockBroker(),
+		Results:    NewMockResults(),
+		Propagator: ValuePropagator{"request_id": requestIDKey{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(chan any, 1)
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		ids <- c.Value(requestIDKey{})
+		return nil
+	}, TaskOpts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	if _, err := srv.Enqueue(context.WithValue(ctx, requestIDKey{}, "req-1"), makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-ids:
+		if id != "req-1" {
+			t.Fatalf("expected request id req-1 in the handler's context, got %v", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job was not processed")
+	}
+}
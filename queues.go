@@ -0,0 +1,31 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrQueuesUnsupported is returned on looking up queues by a pattern, if the broker
+	// doesn't implement QueueLister.
+	ErrQueuesUnsupported = errors.New("broker doesn't support looking up queues")
+	// ErrPendingUnsupported is returned on peeking at the pending messages of a queue, if
+	// the broker doesn't implement Peeker.
+	ErrPendingUnsupported = errors.New("broker doesn't support peeking at queues")
+)
+
+// listQueues returns the queues matching the pattern, if the broker implements QueueLister.
+func listQueues(ctx context.Context, b Broker, pattern string) ([]string, error) {
+	if l, ok := b.(QueueLister); ok {
+		return l.Queues(ctx, pattern)
+	}
+	return nil, ErrQueuesUnsupported
+}
+
+// peek returns upto n messages waiting in the queue, if the broker implements Peeker.
+func peek(ctx context.Context, b Broker, queue string, n int) ([][]byte, error) {
+	if p, ok := b.(Peeker); ok {
+		return p.GetPending(ctx, queue, n)
+	}
+	return nil, ErrPendingUnsupported
+}
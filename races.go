@@ -0,0 +1,126 @@
+package tasqueue
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// raceDetector watches shared state for mutations across the concurrent invocations of
+// handlers, when the server's race detection is enabled.
+type raceDetector struct {
+	mu      sync.Mutex
+	watches []stateWatch
+	active  map[*invocation]struct{}
+}
+
+// stateWatch is a piece of shared (eg: package-level) state, read by fn.
+type stateWatch struct {
+	name string
+	fn   func() any
+}
+
+// invocation is a handler's invocation, with the snapshot of the watched state it started
+// with, and the tasks whose handlers ran concurrently with it.
+type invocation struct {
+	task  string
+	snap  []string
+	peers map[string]struct{}
+}
+
+func newRaceDetector(enabled bool) *raceDetector {
+	if !enabled {
+		return nil
+	}
+	return &raceDetector{active: make(map[*invocation]struct{})}
+}
+
+// WatchState() registers a piece of shared state (eg: a package-level map or struct) read by
+// fn, which the server's race detection (ServerOpts.DetectRaces) checks for mutations made
+// while handlers run concurrently. It's a no-op if race detection isn't enabled.
+func (s *Server) WatchState(name string, fn func() any) {
+	if s.races == nil {
+		return
+	}
+
+	s.races.mu.Lock()
+	s.races.watches = append(s.races.watches, stateWatch{name: name, fn: fn})
+	s.races.mu.Unlock()
+}
+
+// begin snapshots the watched state as the task's handler is invoked.
+func (r *raceDetector) begin(task string) *invocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv := &invocation{task: task, snap: r.snapshot(), peers: make(map[string]struct{})}
+	for a := range r.active {
+		a.peers[task] = struct{}{}
+		inv.peers[a.task] = struct{}{}
+	}
+	r.active[inv] = struct{}{}
+
+	return inv
+}
+
+// end returns the names of the watched state which was mutated during the invocation, if
+// other handlers ran concurrently with it, and the tasks of those handlers.
+func (r *raceDetector) end(inv *invocation) ([]string, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.active, inv)
+	if len(inv.peers) == 0 {
+		return nil, nil
+	}
+
+	var (
+		snap    = r.snapshot()
+		mutated []string
+	)
+	for i, w := range r.watches {
+		// State watched after the invocation began isn't compared.
+		if i < len(inv.snap) && snap[i] != inv.snap[i] {
+			mutated = append(mutated, w.name)
+		}
+	}
+	if len(mutated) == 0 {
+		return nil, nil
+	}
+
+	peers := make([]string, 0, len(inv.peers))
+	for t := range inv.peers {
+		peers = append(peers, t)
+	}
+	sort.Strings(peers)
+
+	return mutated, peers
+}
+
+// snapshot returns the printed values of the watched state.
+func (r *raceDetector) snapshot() []string {
+	out := make([]string, len(r.watches))
+	for i, w := range r.watches {
+		out[i] = fmt.Sprintf("%#v", w.fn())
+	}
+
+	return out
+}
+
+// runDetected runs the task's handler, and reports the watched state that was mutated while
+// it ran concurrently with other handlers, if race detection is enabled.
+func (s *Server) runDetected(task Task, payload []byte, c JobCtx) error {
+	if s.races == nil {
+		return runHandler(task, payload, c)
+	}
+
+	inv := s.races.begin(task.name)
+	err := runHandler(task, payload, c)
+	mutated, peers := s.races.end(inv)
+	for _, m := range mutated {
+		s.log.Warn("shared state mutated during concurrent handlers", "task", task.name, "uuid", c.Meta.UUID, "state", m, "concurrent_tasks", peers)
+		s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",state="%s"}`, metricStateRaces, task.name, m)).Inc()
+	}
+
+	return err
+}
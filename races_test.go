@@ -0,0 +1,63 @@
+package tasqueue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDetectRaces(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+		shared  = map[string]int{}
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results, DetectRaces: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WatchState("shared", func() any { return shared })
+
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	srv.RegisterTask("blocking", func(b []byte, c JobCtx) error {
+		close(started)
+		<-release
+		return nil
+	}, TaskOpts{})
+
+	// A handler that mutates the state on its own isn't reported.
+	inv := srv.races.begin(taskName)
+	shared["a"]++
+	if mutated, _ := srv.races.end(inv); mutated != nil {
+		t.Fatalf("expected no races without concurrent handlers, got %v", mutated)
+	}
+
+	// Mutations made while another handler runs are reported.
+	job, err := NewJob("blocking", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Process(ctx, <-broker.data)
+		close(done)
+	}()
+	<-started
+
+	inv = srv.races.begin(taskName)
+	shared["b"]++
+	mutated, peers := srv.races.end(inv)
+	close(release)
+	<-done
+
+	if !reflect.DeepEqual(mutated, []string{"shared"}) || !reflect.DeepEqual(peers, []string{"blocking"}) {
+		t.Fatalf("expected the mutation to be reported with the concurrent task, got %v, %v", mutated, peers)
+	}
+}
@@ -0,0 +1,127 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// globalRateKey is the rate limiter key of the server-wide rate.
+const globalRateKey = "global"
+
+// bucket is a token bucket which holds up to a second's worth of tokens.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, now time.Time) *bucket {
+	return &bucket{tokens: burst(rate), last: now}
+}
+
+func burst(rate float64) float64 {
+	if rate < 1 {
+		return 1
+	}
+	return rate
+}
+
+// take takes a token from the bucket. If the bucket is empty, it returns false and
+// the duration after which a token is available.
+func (b *bucket) take(rate float64, now time.Time) (time.Duration, bool) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if limit := burst(rate); b.tokens > limit {
+		b.tokens = limit
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+
+	return 0, true
+}
+
+// refund returns a token to the bucket, upto its limit.
+func (b *bucket) refund(rate float64) {
+	if b.tokens++; b.tokens > burst(rate) {
+		b.tokens = burst(rate)
+	}
+}
+
+// localLimiter is the default RateLimiter, which limits the rate within the server.
+type localLimiter struct {
+	clock Clock
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLocalLimiter(c Clock) *localLimiter {
+	return &localLimiter{clock: c, buckets: make(map[string]*bucket)}
+}
+
+func (l *localLimiter) Take(_ context.Context, key string, rate float64) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(rate, now)
+		l.buckets[key] = b
+	}
+
+	wait, _ := b.take(rate, now)
+	return wait, nil
+}
+
+func (l *localLimiter) Refund(_ context.Context, key string, rate float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.refund(rate)
+	}
+	return nil
+}
+
+// throttle returns the duration after which the job should be retried, if the global
+// or the queue's rate limit is exceeded. The global token is refunded if the queue's limit
+// holds back the job, so that it doesn't count towards the global rate. Limiter errors are
+// logged and don't hold back jobs.
+func (s *Server) throttle(ctx context.Context, queue string) time.Duration {
+	s.rmu.RLock()
+	rate, queueRate := s.rate, s.queueRates[queue]
+	s.rmu.RUnlock()
+
+	taken := false
+	if rate > 0 {
+		wait, err := s.limiter.Take(ctx, globalRateKey, rate)
+		switch {
+		case err != nil:
+			s.log.Error("error taking from the global rate limit", "error", err)
+		case wait > 0:
+			return wait
+		default:
+			taken = true
+		}
+	}
+
+	if queueRate > 0 {
+		wait, err := s.limiter.Take(ctx, "queue:"+queue, queueRate)
+		if err != nil {
+			s.log.Error("error taking from the queue rate limit", "queue", queue, "error", err)
+		} else if wait > 0 {
+			if taken {
+				if err := s.limiter.Refund(ctx, globalRateKey, rate); err != nil {
+					s.log.Error("error refunding the global rate limit", "error", err)
+				}
+			}
+			return wait
+		}
+	}
+
+	return 0
+}
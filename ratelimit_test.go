@@ -0,0 +1,74 @@
+package tasqueue
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:  NewMockBroker(),
+		Results: NewMockResults(),
+		Rate:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int32
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		atomic.AddInt32(&n, 1)
+		return nil
+	}, TaskOpts{Concurrency: 5})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	for i := 0; i < 8; i++ {
+		if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A burst of 2 jobs is processed immediately, and 2 jobs a second after.
+	time.Sleep(time.Second)
+	if c := atomic.LoadInt32(&n); c < 2 || c > 4 {
+		t.Fatalf("expected 2-4 jobs processed in a second, got %d", c)
+	}
+}
+
+func TestThrottleRefund(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		clock = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:     NewMockBroker(),
+		Results:    NewMockResults(),
+		Clock:      clock,
+		Rate:       2,
+		QueueRates: map[string]float64{"slow": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The global token taken for a job held back by its queue's limit is refunded.
+	if wait := srv.throttle(ctx, "slow"); wait != 0 {
+		t.Fatalf("expected the first job not to be throttled, got %v", wait)
+	}
+	for i := 0; i < 3; i++ {
+		if wait := srv.throttle(ctx, "slow"); wait == 0 {
+			t.Fatal("expected the job to be throttled by the queue's limit")
+		}
+	}
+	if wait := srv.throttle(ctx, DefaultQueue); wait != 0 {
+		t.Fatalf("expected a global token to be left, got %v", wait)
+	}
+	if wait := srv.throttle(ctx, DefaultQueue); wait == 0 {
+		t.Fatal("expected the job to be throttled by the global limit")
+	}
+}
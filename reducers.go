@@ -0,0 +1,135 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// ReduceConcat concatenates the results of the jobs.
+	ReduceConcat = "concat"
+	// ReduceJSONArray combines the results of the jobs, which should be JSON, into a JSON array.
+	// Jobs without results are null.
+	ReduceJSONArray = "json"
+)
+
+// Reducer combines the results of the jobs of a chain (in the order of its steps) or group
+// (in the order of its jobs) into one result. Each job's results, as saved with JobCtx.Save(),
+// are concatenated, and are nil if the job didn't save any.
+type Reducer func(results [][]byte) ([]byte, error)
+
+// builtinReducers are the reducers available on every server, which ServerOpts.Reducers
+// can't override.
+var builtinReducers = map[string]Reducer{
+	ReduceConcat:    concatReducer,
+	ReduceJSONArray: jsonArrayReducer,
+}
+
+func concatReducer(results [][]byte) ([]byte, error) {
+	return bytes.Join(results, nil), nil
+}
+
+func jsonArrayReducer(results [][]byte) ([]byte, error) {
+	arr := make([]json.RawMessage, len(results))
+	for i, r := range results {
+		if len(r) == 0 {
+			arr[i] = json.RawMessage("null")
+			continue
+		}
+		if !json.Valid(r) {
+			return nil, fmt.Errorf("result %d isn't valid json", i)
+		}
+		arr[i] = r
+	}
+
+	return json.Marshal(arr)
+}
+
+// validateReducer checks that the named reducer is registered on the server.
+func (s *Server) validateReducer(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := s.reducer(name); !ok {
+		return fmt.Errorf("reducer %s not registered", name)
+	}
+	return nil
+}
+
+func (s *Server) reducer(name string) (Reducer, bool) {
+	if r, ok := builtinReducers[name]; ok {
+		return r, true
+	}
+	r, ok := s.reducers[name]
+	return r, ok
+}
+
+// reducedResult looks up the chain or group of the UUID, and if it's complete and has a
+// reducer, returns its jobs' results combined by the reducer, which are stored as its result.
+// It returns false if the UUID isn't of a chain or group with a reducer, or it's incomplete.
+func (s *Server) reducedResult(ctx context.Context, uuid string) ([]byte, bool, error) {
+	b, err := s.results.Get(ctx, uuid)
+	if err != nil {
+		return nil, false, nil
+	}
+	var root struct {
+		Chain *Chain
+		Group *Group
+	}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, false, nil
+	}
+
+	var (
+		reduce string
+		jobs   []string
+	)
+	switch {
+	case root.Chain != nil && root.Chain.Reduce != "":
+		c, err := s.GetChain(ctx, uuid)
+		if err != nil || c.Status != StatusDone {
+			return nil, false, err
+		}
+		reduce, jobs = c.Chain.Reduce, c.PrevJobs
+	case root.Group != nil && root.Group.Reduce != "":
+		g, err := s.GetGroup(ctx, uuid)
+		if err != nil || g.Status != StatusDone {
+			return nil, false, err
+		}
+		reduce, jobs = g.Group.Reduce, g.JobUUIDs
+	default:
+		return nil, false, nil
+	}
+
+	fn, ok := s.reducer(reduce)
+	if !ok {
+		return nil, false, fmt.Errorf("reducer %s not registered", reduce)
+	}
+	results := make([][]byte, len(jobs))
+	for i, j := range jobs {
+		r, err := s.GetResult(ctx, j)
+		if err != nil {
+			// Jobs which didn't save results have none in the store.
+			continue
+		}
+		results[i] = bytes.Join(r, nil)
+	}
+	out, err := fn(results)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not reduce results of %s : %w", uuid, err)
+	}
+
+	// The reduced result is stored as the root's result, like a job's.
+	if b, err = msgpack.Marshal([][]byte{out}); err != nil {
+		return nil, false, err
+	}
+	if err := s.results.Set(ctx, resultsPrefix+uuid, b); err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
@@ -0,0 +1,99 @@
+package tasqueue
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReducers(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:  broker,
+		Results: NewMockResults(),
+		Reducers: map[string]Reducer{
+			"upper": func(results [][]byte) ([]byte, error) {
+				var s []string
+				for _, r := range results {
+					s = append(s, strings.ToUpper(string(r)))
+				}
+				return []byte(strings.Join(s, "|")), nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("echo", func(b []byte, c JobCtx) error {
+		if len(b) == 0 {
+			return nil
+		}
+		return c.Save(b)
+	}, TaskOpts{})
+
+	jobs := func(payloads ...string) []Job {
+		var jobs []Job
+		for _, p := range payloads {
+			j, err := NewJob("echo", []byte(p), JobOpts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobs = append(jobs, j)
+		}
+		return jobs
+	}
+	process := func(n int) {
+		for i := 0; i < n; i++ {
+			srv.Process(ctx, <-broker.data)
+		}
+	}
+
+	// A chain's steps' results are combined into a JSON array, with null for steps without results.
+	chn, err := NewChain(jobs(`{"a":1}`, "", `[2]`)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chn.Reduce = ReduceJSONArray
+	uuid, err := srv.EnqueueChain(ctx, chn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	process(1)
+	if _, err := srv.GetResult(ctx, uuid); err == nil {
+		t.Fatal("expected no result for an incomplete chain")
+	}
+	process(2)
+	res, err := srv.GetResult(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || string(res[0]) != `[{"a":1},null,[2]]` {
+		t.Fatalf("expected the chain's results as a json array, got %q", res)
+	}
+
+	// A group's results are combined in the order of its jobs by the custom reducer.
+	grp, err := NewGroup(jobs("x", "y", "z")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grp.Reduce = "upper"
+	if uuid, err = srv.EnqueueGroup(ctx, grp); err != nil {
+		t.Fatal(err)
+	}
+	process(3)
+	if res, err = srv.GetResult(ctx, uuid); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || string(res[0]) != "X|Y|Z" {
+		t.Fatalf("expected the group's reduced result, got %q", res)
+	}
+
+	// Unknown reducers are rejected.
+	grp.Reduce = "unknown"
+	if _, err := srv.EnqueueGroup(ctx, grp); err == nil {
+		t.Fatal("expected an unknown reducer to be rejected")
+	}
+}
@@ -0,0 +1,213 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/zerodha/logf"
+)
+
+const defaultReloadInterval = time.Second * 30
+
+// RuntimeConfig is the configuration of a server that can be reloaded while it's running (see
+// Reload()), eg: from a config file or an admin API, without restarting the server or losing
+// its in-flight jobs. Fields that aren't set are left unchanged.
+type RuntimeConfig struct {
+	// LogLevel is the level of the server's logs: debug, info, warn or error.
+	LogLevel string `json:"log_level,omitempty" yaml:"log_level" koanf:"log_level"`
+
+	// Concurrency is a map of task -> the number of processors of the task (of all its
+	// versions). The consumers of a task whose concurrency changes are restarted, while its
+	// processors finish the jobs they already received.
+	Concurrency map[string]uint32 `json:"concurrency,omitempty" yaml:"concurrency" koanf:"concurrency"`
+
+	// Rate is the maximum number of jobs started per second by the server (ServerOpts.Rate),
+	// zero being unlimited, and QueueRates replaces the rates of the queues (ServerOpts.QueueRates).
+	Rate       *float64           `json:"rate,omitempty" yaml:"rate" koanf:"rate"`
+	QueueRates map[string]float64 `json:"queue_rates,omitempty" yaml:"queue_rates" koanf:"queue_rates"`
+
+	// PausedQueues replaces the queues whose consumption is paused (see PauseQueue()). An empty
+	// list unpauses all the queues.
+	PausedQueues []string `json:"paused_queues" yaml:"paused_queues" koanf:"paused_queues"`
+}
+
+// ConfigSource returns the runtime config to reload the server with, eg: read from a file.
+type ConfigSource func(ctx context.Context) (RuntimeConfig, error)
+
+// levelLogger is the server's logger, whose level can be changed while the server is running.
+type levelLogger struct {
+	logf.Logger
+	level *int32
+}
+
+func newLevelLogger(lo logf.Logger) levelLogger {
+	level := int32(lo.Opts.Level)
+	// The level is filtered by the wrapper, whose frame is skipped to get the caller.
+	lo.Opts.Level = logf.DebugLevel
+	lo.Opts.CallerSkipFrameCount++
+
+	return levelLogger{Logger: lo, level: &level}
+}
+
+func (l levelLogger) enabled(lvl logf.Level) bool {
+	return lvl >= logf.Level(atomic.LoadInt32(l.level))
+}
+
+func (l levelLogger) setLevel(lvl logf.Level) {
+	atomic.StoreInt32(l.level, int32(lvl))
+}
+
+func (l levelLogger) Debug(msg string, fields ...interface{}) {
+	if l.enabled(logf.DebugLevel) {
+		l.Logger.Debug(msg, fields...)
+	}
+}
+
+func (l levelLogger) Info(msg string, fields ...interface{}) {
+	if l.enabled(logf.InfoLevel) {
+		l.Logger.Info(msg, fields...)
+	}
+}
+
+func (l levelLogger) Warn(msg string, fields ...interface{}) {
+	if l.enabled(logf.WarnLevel) {
+		l.Logger.Warn(msg, fields...)
+	}
+}
+
+func (l levelLogger) Error(msg string, fields ...interface{}) {
+	if l.enabled(logf.ErrorLevel) {
+		l.Logger.Error(msg, fields...)
+	}
+}
+
+// RuntimeConfig() returns the server's current runtime config.
+func (s *Server) RuntimeConfig() RuntimeConfig {
+	s.rmu.RLock()
+	var (
+		rate   = s.rate
+		queues = make(map[string]float64, len(s.queueRates))
+	)
+	for q, r := range s.queueRates {
+		queues[q] = r
+	}
+	s.rmu.RUnlock()
+
+	c := RuntimeConfig{
+		LogLevel:     logf.Level(atomic.LoadInt32(s.log.level)).String(),
+		Concurrency:  make(map[string]uint32),
+		Rate:         &rate,
+		QueueRates:   queues,
+		PausedQueues: s.pauses.list(),
+	}
+	s.p.RLock()
+	for _, t := range s.tasks {
+		c.Concurrency[t.name] = t.opts.Concurrency
+	}
+	s.p.RUnlock()
+
+	return c
+}
+
+// Reload() applies the runtime config onto the running server, eg: to raise a task's
+// concurrency or pause a queue without a restart. The config is validated before any of it is
+// applied.
+func (s *Server) Reload(ctx context.Context, c RuntimeConfig) error {
+	var (
+		level logf.Level
+		err   error
+	)
+	if c.LogLevel != "" {
+		if level, err = logf.LevelFromString(c.LogLevel); err != nil {
+			return fmt.Errorf("could not reload : %w", err)
+		}
+	}
+	if c.Rate != nil && *c.Rate < 0 {
+		return fmt.Errorf("could not reload : invalid rate %v", *c.Rate)
+	}
+
+	// The tasks whose concurrency changes are re-registered, which restarts them.
+	var restart []Task
+	s.p.RLock()
+	for name, n := range c.Concurrency {
+		if n == 0 {
+			s.p.RUnlock()
+			return fmt.Errorf("could not reload : invalid concurrency of task %s", name)
+		}
+		found := false
+		for _, t := range s.tasks {
+			if t.name != name {
+				continue
+			}
+			found = true
+			if t.opts.Concurrency != n {
+				t.opts.Concurrency = n
+				restart = append(restart, t)
+			}
+		}
+		if !found {
+			s.p.RUnlock()
+			return fmt.Errorf("could not reload task %s : %w", name, ErrTaskNotRegistered)
+		}
+	}
+	s.p.RUnlock()
+
+	if c.LogLevel != "" {
+		s.log.setLevel(level)
+	}
+	if c.Rate != nil || c.QueueRates != nil {
+		s.rmu.Lock()
+		if c.Rate != nil {
+			s.rate = *c.Rate
+		}
+		if c.QueueRates != nil {
+			s.queueRates = c.QueueRates
+		}
+		s.rmu.Unlock()
+	}
+	if c.PausedQueues != nil {
+		s.pauses.set(c.PausedQueues)
+	}
+	for _, t := range restart {
+		s.log.Info("restarting task with new concurrency", "name", t.name, "version", t.opts.Version, "concurrency", t.opts.Concurrency)
+		s.registerHandler(t)
+	}
+
+	b, _ := json.Marshal(c)
+	s.log.Info("reloaded runtime config", "config", string(b))
+	s.recordAudit(ctx, OpReload, "", string(b))
+
+	return nil
+}
+
+// RunReload() periodically reads the runtime config from the source, and reloads the server
+// when it changes. If interval is zero, it defaults to 30 seconds. It is a blocking function.
+func (s *Server) RunReload(ctx context.Context, src ConfigSource, interval time.Duration) {
+	if interval == 0 {
+		interval = defaultReloadInterval
+	}
+
+	var last *RuntimeConfig
+	for {
+		c, err := src(ctx)
+		if err != nil {
+			s.log.Error("error reading runtime config", "error", err)
+		} else if last == nil || !reflect.DeepEqual(c, *last) {
+			if err := s.Reload(ctx, c); err != nil {
+				s.log.Error("error reloading runtime config", "error", err)
+			} else {
+				last = &c
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(interval):
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zerodha/logf"
+)
+
+func TestReload(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		logs = &syncBuffer{}
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:  NewMockBroker(),
+		Results: NewMockResults(),
+		Logger:  logf.New(logf.Opts{Writer: logs, Level: logf.InfoLevel}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{Concurrency: 1})
+
+	// An invalid config isn't applied at all.
+	rate := 10.0
+	err = srv.Reload(ctx, RuntimeConfig{Rate: &rate, Concurrency: map[string]uint32{"unknown": 2}})
+	if !errors.Is(err, ErrTaskNotRegistered) {
+		t.Fatalf("expected %v, got %v", ErrTaskNotRegistered, err)
+	}
+	if c := srv.RuntimeConfig(); *c.Rate != 0 {
+		t.Fatalf("expected the rate to be unchanged, got %v", *c.Rate)
+	}
+
+	srv.log.Debug("before reload")
+	err = srv.Reload(ctx, RuntimeConfig{
+		LogLevel:    "debug",
+		Concurrency: map[string]uint32{taskName: 4},
+		Rate:        &rate,
+		QueueRates:  map[string]float64{"emails": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.log.Debug("after reload")
+	if strings.Contains(logs.String(), "before reload") || !strings.Contains(logs.String(), "after reload") {
+		t.Fatalf("expected debug logs after the reload only, got %s", logs.String())
+	}
+
+	c := srv.RuntimeConfig()
+	if c.LogLevel != "debug" || c.Concurrency[taskName] != 4 || *c.Rate != rate || c.QueueRates["emails"] != 2 {
+		t.Fatalf("expected the reloaded config, got %+v", c)
+	}
+}
+
+func TestReloadPausedQueues(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		broker      = NewMockBroker()
+	)
+	defer cancel()
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	if err := srv.Reload(ctx, RuntimeConfig{PausedQueues: []string{DefaultQueue}}); err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start(ctx)
+
+	// The paused queue isn't consumed.
+	msg, err := srv.GetJobBlocking(ctx, uuid, time.Millisecond*100)
+	if !errors.Is(err, ErrWaitTimeout) || msg.Status != StatusStarted {
+		t.Fatalf("expected the job to wait on the paused queue, got %s : %v", msg.Status, err)
+	}
+
+	// The running server's jobs are processed once the queue is unpaused, and after its
+	// task's concurrency is reloaded.
+	if err := srv.Reload(ctx, RuntimeConfig{PausedQueues: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = srv.GetJobBlocking(ctx, uuid, time.Second*5); err != nil || msg.Status != StatusDone {
+		t.Fatalf("expected the job to be done once the queue is unpaused, got %s : %v", msg.Status, err)
+	}
+	if err := srv.Reload(ctx, RuntimeConfig{Concurrency: map[string]uint32{taskName: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if uuid, err = srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = srv.GetJobBlocking(ctx, uuid, time.Second*5); err != nil || msg.Status != StatusDone {
+		t.Fatalf("expected the job to be done after the reload, got %s : %v", msg.Status, err)
+	}
+
+	// A queue paused while it's consumed stops being consumed.
+	srv.PauseQueue(DefaultQueue)
+	time.Sleep(time.Millisecond * 50)
+	if uuid, err = srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = srv.GetJobBlocking(ctx, uuid, time.Millisecond*100); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected the job to wait on the paused queue, got %s : %v", msg.Status, err)
+	}
+	srv.UnpauseQueue(DefaultQueue)
+	if msg, err = srv.GetJobBlocking(ctx, uuid, time.Second*5); err != nil || msg.Status != StatusDone {
+		t.Fatalf("expected the job to be done once the queue is unpaused, got %s : %v", msg.Status, err)
+	}
+}
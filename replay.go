@@ -0,0 +1,83 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReplayLabel is the label set on replayed jobs, with the UUID of the job they replay.
+const ReplayLabel = "replay_of"
+
+// ReplayOpts configures Replay().
+type ReplayOpts struct {
+	// Queues and Tasks rewrite the queue and task names of the replayed jobs (old -> new name).
+	Queues map[string]string
+	Tasks  map[string]string
+	// Filter, if set, replays only the records for which it returns true.
+	Filter func(JobRecord) bool
+}
+
+// Replay() re-enqueues the jobs of the job records read from r as NDJSON, as written by the
+// ndjson archive or ExportJobs(), eg: to reprocess historical jobs after a change in the
+// handlers. Replayed jobs are new jobs, with new UUIDs, and the original UUID as the
+// ReplayLabel. Their schedule and expiry are not carried over, while chained jobs are. It
+// returns the number of jobs enqueued.
+func (s *Server) Replay(ctx context.Context, r io.Reader, o ReplayOpts) (int, error) {
+	var (
+		dec = json.NewDecoder(r)
+		n   int
+	)
+	for {
+		var rec JobRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return n, fmt.Errorf("could not decode job record : %w", err)
+		}
+		if rec.Job == nil || (o.Filter != nil && !o.Filter(rec)) {
+			continue
+		}
+
+		j, err := s.replayJob(ctx, rec.JobMessage, o)
+		if err != nil {
+			return n, fmt.Errorf("could not replay job %s : %w", rec.UUID, err)
+		}
+		if _, err := s.Enqueue(ctx, j); err != nil {
+			return n, fmt.Errorf("could not replay job %s : %w", rec.UUID, err)
+		}
+		n++
+	}
+}
+
+// replayJob returns the job of the message, with its payload decoded and names rewritten.
+func (s *Server) replayJob(ctx context.Context, msg JobMessage, o ReplayOpts) (Job, error) {
+	b, err := s.decodePayload(ctx, msg)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j := *msg.Job
+	j.Payload = b
+	if t, ok := o.Tasks[j.Task]; ok {
+		j.Task = t
+	}
+	if q, ok := o.Queues[j.Opts.Queue]; ok {
+		j.Opts.Queue = q
+	}
+	j.Opts.Schedule = ""
+	j.Opts.ExpiresAt = time.Time{}
+
+	labels := make(map[string]string, len(j.Opts.Labels)+1)
+	for k, v := range j.Opts.Labels {
+		labels[k] = v
+	}
+	labels[ReplayLabel] = msg.UUID
+	j.Opts.Labels = labels
+
+	return j, nil
+}
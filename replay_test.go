@@ -0,0 +1,68 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestReplay(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		srv    = newServer(t)
+		broker = NewMockBroker()
+	)
+	var uuids []string
+	for _, f := range []bool{false, true} {
+		uuid, err := srv.Enqueue(ctx, makeJob(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.statusFailed(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	var buf bytes.Buffer
+	if _, err := srv.ExportJobs(ctx, JobFilter{Status: StatusFailed}, &buf, ExportNDJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay one of the jobs onto another server, renaming its task and queue.
+	dst, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := dst.Replay(ctx, &buf, ReplayOpts{
+		Tasks:  map[string]string{taskName: "renamed"},
+		Queues: map[string]string{DefaultQueue: "replays"},
+		Filter: func(r JobRecord) bool { return r.UUID == uuids[1] },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 job replayed, got %d", n)
+	}
+
+	var msg JobMessage
+	if err := msgpack.Unmarshal(<-broker.data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.UUID == uuids[1] || msg.Labels[ReplayLabel] != uuids[1] {
+		t.Fatalf("expected a new job replaying %s, got %s replaying %s", uuids[1], msg.UUID, msg.Labels[ReplayLabel])
+	}
+	if msg.Job.Task != "renamed" || msg.Queue != "replays" || msg.Status != StatusStarted {
+		t.Fatalf("expected a started job of task renamed on queue replays, got %s job of %s on %s", msg.Status, msg.Job.Task, msg.Queue)
+	}
+	if !bytes.Equal(msg.Job.Payload, makeJob(t, true).Payload) {
+		t.Fatalf("expected the payload to be replayed, got %s", msg.Job.Payload)
+	}
+}
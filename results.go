@@ -0,0 +1,175 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	namedResultsPrefix = "tasqueue:result:named:"
+	streamPrefix       = "tasqueue:result:stream:"
+	// streamPollPeriod is the interval at which new chunks are looked up by StreamResult().
+	streamPollPeriod = time.Millisecond * 500
+)
+
+// ErrChunksUnsupported is returned by the operations which append chunks (eg: streamed results,
+// usage records and the audit log), if the results store doesn't implement Chunker.
+var ErrChunksUnsupported = errors.New("results store doesn't support chunks")
+
+// JSONCodec is the default codec for named results.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(b []byte, v any) error {
+	return json.Unmarshal(b, v)
+}
+
+// SaveNamed() encodes v with the server's result codec and saves it in the results store
+// under the key, replacing any result previously saved under the same key.
+func (c *JobCtx) SaveNamed(key string, v any) error {
+	if c.store == nil {
+		return ErrNoResults
+	}
+
+	b, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if c.named == nil {
+		c.named = make(map[string][]byte)
+	}
+	c.named[key] = b
+
+	d, err := msgpack.Marshal(c.named)
+	if err != nil {
+		return err
+	}
+
+	return c.store.Set(context.Background(), namedResultsPrefix+c.Meta.UUID, d)
+}
+
+// GetNamedResult() decodes the result saved by the job under the key into v.
+// ErrResultNotFound is returned if the job hasn't saved a result under the key.
+func (s *Server) GetNamedResult(ctx context.Context, uuid, key string, v any) error {
+	if s.results == nil {
+		return ErrNoResults
+	}
+
+	res, err := s.getNamedResults(ctx, uuid)
+	if err != nil {
+		return err
+	}
+
+	b, ok := res[key]
+	if !ok {
+		return ErrResultNotFound
+	}
+
+	return s.codec.Unmarshal(b, v)
+}
+
+// getNamedResults returns the encoded named results of the job.
+func (s *Server) getNamedResults(ctx context.Context, uuid string) (map[string][]byte, error) {
+	b, err := s.results.Get(ctx, namedResultsPrefix+uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string][]byte
+	if err := msgpack.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Append() persists a chunk of incremental output (eg: log lines) of the job, which can be
+// tailed with StreamResult() while the job runs.
+func (c *JobCtx) Append(chunk []byte) error {
+	if c.store == nil {
+		return ErrNoResults
+	}
+
+	return appendChunk(context.Background(), c.store, streamPrefix+c.Meta.UUID, chunk)
+}
+
+// StreamResult() returns a channel on which the chunks appended by the job are sent, starting
+// from the first chunk. The channel is closed once the job is complete and all its chunks
+// are sent, or the context is cancelled.
+func (s *Server) StreamResult(ctx context.Context, uuid string) (<-chan []byte, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
+	if _, err := s.GetJob(ctx, uuid); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+
+		t := time.NewTicker(streamPollPeriod)
+		defer t.Stop()
+
+		var offset int
+		for {
+			// The job is fetched before its chunks, so that chunks appended just before
+			// the job completed aren't missed.
+			msg, err := s.GetJob(ctx, uuid)
+			if err != nil {
+				s.log.Error("error getting job", "uuid", uuid, "error", err)
+				return
+			}
+
+			chunks, err := getChunks(ctx, s.results, streamPrefix+uuid, offset)
+			if err != nil {
+				s.log.Error("error getting job chunks", "uuid", uuid, "error", err)
+				return
+			}
+			for _, c := range chunks {
+				select {
+				case ch <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+			offset += len(chunks)
+
+			if IsFinal(msg.Status) {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// appendChunk appends the chunk to the list at the key, if the store implements Chunker.
+func appendChunk(ctx context.Context, r Results, key string, b []byte) error {
+	if c, ok := r.(Chunker); ok {
+		return c.AppendChunk(ctx, key, b)
+	}
+	return ErrChunksUnsupported
+}
+
+// getChunks returns the chunks at the key from the offset, if the store implements Chunker.
+func getChunks(ctx context.Context, r Results, key string, offset int) ([][]byte, error) {
+	if c, ok := r.(Chunker); ok {
+		return c.GetChunks(ctx, key, offset)
+	}
+	return nil, ErrChunksUnsupported
+}
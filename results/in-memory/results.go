@@ -2,8 +2,11 @@ package inmemory
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue/internal/errs"
 )
 
 type Results struct {
@@ -11,11 +14,34 @@ type Results struct {
 	store   map[string][]byte
 	failed  []string
 	success []string
+	tags    map[string][]string
+	leases  map[string][]string
+	index   map[string][]entry
+	chunks  map[string][][]byte
+	// counters are the hashes of counters, with their expiry.
+	counters map[string]*counters
+	// delayed are the indexes of delayed jobs, ordered by the times they're due.
+	delayed map[string][]entry
+	// watchers are the channels notified of changes to each key.
+	watchers map[string][]chan struct{}
+}
+
+// entry is a job uuid in an index, ordered by time.
+type entry struct {
+	uuid string
+	t    time.Time
 }
 
 func New() *Results {
 	return &Results{
-		store: make(map[string][]byte),
+		store:    make(map[string][]byte),
+		tags:     make(map[string][]string),
+		leases:   make(map[string][]string),
+		index:    make(map[string][]entry),
+		chunks:   make(map[string][][]byte),
+		counters: make(map[string]*counters),
+		delayed:  make(map[string][]entry),
+		watchers: make(map[string][]chan struct{}),
 	}
 }
 
@@ -24,7 +50,7 @@ func (r *Results) Get(ctx context.Context, uuid string) ([]byte, error) {
 	v, ok := r.store[uuid]
 	r.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("value not found")
+		return nil, errs.ErrNotFound
 	}
 
 	return v, nil
@@ -33,11 +59,43 @@ func (r *Results) Get(ctx context.Context, uuid string) ([]byte, error) {
 func (r *Results) Set(ctx context.Context, uuid string, b []byte) error {
 	r.mu.Lock()
 	r.store[uuid] = b
+	for _, ch := range r.watchers[uuid] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
 	r.mu.Unlock()
 
 	return nil
 }
 
+// Watch notifies of the values set at the key, until the context is done.
+func (r *Results) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.watchers[key] = append(r.watchers[key], ch)
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		ws := r.watchers[key]
+		for i, w := range ws {
+			if w == ch {
+				r.watchers[key] = append(ws[:i:i], ws[i+1:]...)
+				break
+			}
+		}
+		if len(r.watchers[key]) == 0 {
+			delete(r.watchers, key)
+		}
+	}()
+
+	return ch, nil
+}
+
 func (r *Results) SetSuccess(_ context.Context, uuid string) error {
 	r.mu.Lock()
 	r.success = append(r.success, uuid)
@@ -48,7 +106,15 @@ func (r *Results) SetSuccess(_ context.Context, uuid string) error {
 
 func (r *Results) SetFailed(_ context.Context, uuid string) error {
 	r.mu.Lock()
-	r.failed = append(r.success, uuid)
+	r.failed = append(r.failed, uuid)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) DeleteFailed(_ context.Context, uuid string) error {
+	r.mu.Lock()
+	r.failed = remove(r.failed, uuid)
 	r.mu.Unlock()
 
 	return nil
@@ -69,3 +135,254 @@ func (r *Results) GetFailed(_ context.Context) ([]string, error) {
 
 	return fail, nil
 }
+
+// SetTag adds the uuid to the tag, once, like the set of the redis store.
+func (r *Results) SetTag(_ context.Context, tag, uuid string) error {
+	r.mu.Lock()
+	if !contains(r.tags[tag], uuid) {
+		r.tags[tag] = append(r.tags[tag], uuid)
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) GetTag(_ context.Context, tag string) ([]string, error) {
+	r.mu.Lock()
+	uuids := r.tags[tag]
+	r.mu.Unlock()
+
+	return uuids, nil
+}
+
+func (r *Results) DeleteTag(_ context.Context, tag, uuid string) error {
+	r.mu.Lock()
+	r.tags[tag] = remove(r.tags[tag], uuid)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) Lease(_ context.Context, key, member string) error {
+	r.mu.Lock()
+	if !contains(r.leases[key], member) {
+		r.leases[key] = append(r.leases[key], member)
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) Unlease(_ context.Context, key, member string) error {
+	r.mu.Lock()
+	r.leases[key] = remove(r.leases[key], member)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) GetLeases(_ context.Context, key string) ([]string, error) {
+	r.mu.Lock()
+	members := append([]string(nil), r.leases[key]...)
+	r.mu.Unlock()
+
+	return members, nil
+}
+
+func (r *Results) Delete(_ context.Context, uuid string) error {
+	r.mu.Lock()
+	delete(r.store, uuid)
+	delete(r.chunks, uuid)
+	r.success = remove(r.success, uuid)
+	r.failed = remove(r.failed, uuid)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func contains(list []string, uuid string) bool {
+	for _, v := range list {
+		if v == uuid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove returns a copy of the list without the uuid.
+func remove(list []string, uuid string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != uuid {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+func (r *Results) IndexJob(_ context.Context, uuid string, t time.Time, keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range append([]string{""}, keys...) {
+		idx := r.index[k]
+		// Insert after the entries with the same time, to retain the order of indexing.
+		i := sort.Search(len(idx), func(i int) bool { return idx[i].t.After(t) })
+		idx = append(idx, entry{})
+		copy(idx[i+1:], idx[i:])
+		idx[i] = entry{uuid: uuid, t: t}
+		r.index[k] = idx
+	}
+
+	return nil
+}
+
+func (r *Results) UnindexJob(_ context.Context, uuid string, keys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range append([]string{""}, keys...) {
+		idx := r.index[k]
+		out := make([]entry, 0, len(idx))
+		for _, e := range idx {
+			if e.uuid != uuid {
+				out = append(out, e)
+			}
+		}
+		r.index[k] = out
+	}
+
+	return nil
+}
+
+func (r *Results) QueryJobs(_ context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	r.mu.Lock()
+	idx := r.index[key]
+	r.mu.Unlock()
+
+	var uuids []string
+	for _, e := range idx {
+		if (!from.IsZero() && e.t.Before(from)) || (!to.IsZero() && e.t.After(to)) {
+			continue
+		}
+		uuids = append(uuids, e.uuid)
+	}
+
+	if desc {
+		for i, j := 0, len(uuids)-1; i < j; i, j = i+1, j-1 {
+			uuids[i], uuids[j] = uuids[j], uuids[i]
+		}
+	}
+
+	if offset >= len(uuids) {
+		return nil, nil
+	}
+	uuids = uuids[offset:]
+	if limit > 0 && limit < len(uuids) {
+		uuids = uuids[:limit]
+	}
+
+	return uuids, nil
+}
+
+func (r *Results) AppendChunk(_ context.Context, key string, b []byte) error {
+	r.mu.Lock()
+	r.chunks[key] = append(r.chunks[key], b)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Results) GetChunks(_ context.Context, key string, offset int) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.chunks[key]
+	if offset >= len(c) {
+		return nil, nil
+	}
+
+	return append([][]byte(nil), c[offset:]...), nil
+}
+
+// counters is a hash of counters, which expires at the time, if it's set.
+type counters struct {
+	fields  map[string]int64
+	expires time.Time
+}
+
+func (r *Results) IncrCounters(_ context.Context, key string, fields map[string]int64, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.counters[key]
+	if !ok || c.expired() {
+		c = &counters{fields: make(map[string]int64)}
+		r.counters[key] = c
+	}
+	for f, n := range fields {
+		c.fields[f] += n
+	}
+	if ttl > 0 {
+		c.expires = time.Now().Add(ttl)
+	}
+
+	return nil
+}
+
+func (r *Results) GetCounters(_ context.Context, key string) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string]int64)
+	if c, ok := r.counters[key]; ok && !c.expired() {
+		for f, n := range c.fields {
+			out[f] = n
+		}
+	}
+
+	return out, nil
+}
+
+func (c *counters) expired() bool {
+	return !c.expires.IsZero() && time.Now().After(c.expires)
+}
+
+func (r *Results) AddDelayed(_ context.Context, key, uuid string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := r.delayed[key]
+	for i, e := range idx {
+		if e.uuid == uuid {
+			idx = append(idx[:i], idx[i+1:]...)
+			break
+		}
+	}
+	i := sort.Search(len(idx), func(i int) bool { return idx[i].t.After(at) })
+	idx = append(idx, entry{})
+	copy(idx[i+1:], idx[i:])
+	idx[i] = entry{uuid: uuid, t: at}
+	r.delayed[key] = idx
+
+	return nil
+}
+
+func (r *Results) PopDue(_ context.Context, key string, t time.Time, n int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		idx   = r.delayed[key]
+		uuids []string
+	)
+	for len(idx) > 0 && len(uuids) < n && !idx[0].t.After(t) {
+		uuids = append(uuids, idx[0].uuid)
+		idx = idx[1:]
+	}
+	r.delayed[key] = idx
+
+	return uuids, nil
+}
@@ -2,8 +2,12 @@ package nats
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/errs"
+	"github.com/kalbhor/tasqueue/internal/natsconn"
 	"github.com/nats-io/nats.go"
 	"github.com/zerodha/logf"
 )
@@ -20,7 +24,10 @@ type Results struct {
 }
 
 type Options struct {
-	URL         string
+	URL  string
+	Auth auth.Options
+
+	// Deprecated: use Auth. Username and Password are used if EnabledAuth is set.
 	EnabledAuth bool
 	Username    string
 	Password    string
@@ -28,13 +35,7 @@ type Options struct {
 
 // New() returns a new instance of nats-jetstream broker.
 func New(cfg Options, lo logf.Logger) (*Results, error) {
-	opt := []nats.Option{}
-
-	if cfg.EnabledAuth {
-		opt = append(opt, nats.UserInfo(cfg.Username, cfg.Password))
-	}
-
-	conn, err := nats.Connect(cfg.URL, opt...)
+	conn, err := natsconn.Conn(connOptions(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to nats : %w", err)
 	}
@@ -59,6 +60,9 @@ func New(cfg Options, lo logf.Logger) (*Results, error) {
 
 func (r *Results) Get(_ context.Context, uuid string) ([]byte, error) {
 	rs, err := r.conn.Get(resultPrefix + uuid)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, errs.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +76,37 @@ func (r *Results) Set(_ context.Context, uuid string, b []byte) error {
 	}
 	return nil
 }
+
+// Watch watches the key on the KV bucket, and notifies of its updates until the context
+// is done.
+func (r *Results) Watch(ctx context.Context, key string) (<-chan struct{}, error) {
+	w, err := r.conn.Watch(resultPrefix+key, nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer w.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-w.Updates():
+				if !ok {
+					return
+				}
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
 func (r *Results) SetSuccess(_ context.Context, uuid string) error {
 	return fmt.Errorf("method not implemented")
 }
@@ -87,3 +122,29 @@ func (r *Results) GetSuccess(_ context.Context) ([]string, error) {
 func (r *Results) GetFailed(_ context.Context) ([]string, error) {
 	return nil, fmt.Errorf("method not implemented")
 }
+
+func (r *Results) Delete(_ context.Context, uuid string) error {
+	return r.conn.Delete(resultPrefix + uuid)
+}
+
+// connOptions returns the connection options for the auth options. Backends with the same
+// options share a connection.
+func connOptions(cfg Options) natsconn.Options {
+	o := natsconn.Options{URL: cfg.URL, TLS: cfg.Auth.TLS}
+	switch {
+	case cfg.Auth.Token != "":
+		o.Token = cfg.Auth.Token
+	case cfg.Auth.Username != "":
+		o.Username, o.Password = cfg.Auth.Username, cfg.Auth.Password
+	case cfg.EnabledAuth:
+		o.Username, o.Password = cfg.Username, cfg.Password
+	}
+
+	return o
+}
+
+// Ping checks the connection to the nats server.
+func (r *Results) Ping(_ context.Context) error {
+	_, err := r.conn.Status()
+	return err
+}
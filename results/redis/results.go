@@ -2,9 +2,14 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/auth"
+	"github.com/kalbhor/tasqueue/internal/errs"
+	"github.com/kalbhor/tasqueue/internal/redisconn"
 	"github.com/zerodha/logf"
 )
 
@@ -15,6 +20,16 @@ const (
 	// Suffix for hashmaps storing success/failed job uuid's
 	success = "success"
 	failed  = "failed"
+
+	// Prefix for sets storing the job uuid's of a tag.
+	tagPrefix = "tag:"
+
+	// Prefix for sets storing leases, eg: the workers which heartbeat and their in-flight jobs.
+	leasePrefix = "lease:"
+
+	// Prefix for sorted sets indexing job uuid's by time. The index of all jobs is suffixed with indexAll.
+	indexPrefix = "index:"
+	indexAll    = "_all"
 )
 
 type Results struct {
@@ -25,13 +40,30 @@ type Results struct {
 
 type Options struct {
 	Addrs        []string
-	Password     string
 	DB           int
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
 	MinIdleConns int
+
+	// PoolSize is the maximum number of connections (default: 10 per CPU), and PoolTimeout
+	// is the time to wait for a connection when all are busy (default: ReadTimeout + 1s).
+	PoolSize    int
+	PoolTimeout time.Duration
+	// MaxRetries is the number of times failed commands are retried (default: 3, -1 disables),
+	// with a backoff between MinRetryBackoff and MaxRetryBackoff.
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Client, if set, is used instead of connecting with the options. Backends with the
+	// same options (eg: a broker and results store on the same redis) share a client.
+	Client redis.UniversalClient
+
+	Auth auth.Options
+	// Deprecated: use Auth.Password.
+	Password string
 }
 
 func DefaultRedis() Options {
@@ -43,21 +75,34 @@ func DefaultRedis() Options {
 }
 
 func New(o Options, lo logf.Logger) *Results {
+	pass := o.Auth.RedisPassword()
+	if pass == "" {
+		pass = o.Password
+	}
+	conn := o.Client
+	if conn == nil {
+		conn = redisconn.Client(&redis.UniversalOptions{
+			Addrs:           o.Addrs,
+			Username:        o.Auth.Username,
+			Password:        pass,
+			TLSConfig:       o.Auth.TLS,
+			DB:              o.DB,
+			DialTimeout:     o.DialTimeout,
+			ReadTimeout:     o.ReadTimeout,
+			WriteTimeout:    o.WriteTimeout,
+			IdleTimeout:     o.IdleTimeout,
+			MinIdleConns:    o.MinIdleConns,
+			PoolSize:        o.PoolSize,
+			PoolTimeout:     o.PoolTimeout,
+			MaxRetries:      o.MaxRetries,
+			MinRetryBackoff: o.MinRetryBackoff,
+			MaxRetryBackoff: o.MaxRetryBackoff,
+		})
+	}
 	return &Results{
-		opt: o,
-		conn: redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs:        o.Addrs,
-				Password:     o.Password,
-				DB:           o.DB,
-				DialTimeout:  o.DialTimeout,
-				ReadTimeout:  o.ReadTimeout,
-				WriteTimeout: o.WriteTimeout,
-				IdleTimeout:  o.IdleTimeout,
-				MinIdleConns: o.MinIdleConns,
-			},
-		),
-		lo: lo,
+		opt:  o,
+		conn: conn,
+		lo:   lo,
 	}
 }
 
@@ -91,6 +136,11 @@ func (r *Results) SetSuccess(ctx context.Context, uuid string) error {
 	return nil
 }
 
+func (r *Results) DeleteFailed(ctx context.Context, uuid string) error {
+	r.lo.Debug("removing job from failed", "uuid", uuid)
+	return r.conn.LRem(ctx, resultPrefix+failed, 0, uuid).Err()
+}
+
 func (r *Results) SetFailed(ctx context.Context, uuid string) error {
 	r.lo.Debug("setting job as failed")
 	_, err := r.conn.RPush(ctx, resultPrefix+failed, uuid).Result()
@@ -101,6 +151,178 @@ func (r *Results) SetFailed(ctx context.Context, uuid string) error {
 	return nil
 }
 
+func (r *Results) SetTag(ctx context.Context, tag, uuid string) error {
+	r.lo.Debug("setting job tag", "tag", tag, "uuid", uuid)
+	return r.conn.SAdd(ctx, resultPrefix+tagPrefix+tag, uuid).Err()
+}
+
+func (r *Results) GetTag(ctx context.Context, tag string) ([]string, error) {
+	r.lo.Debug("getting jobs of tag", "tag", tag)
+	return r.conn.SMembers(ctx, resultPrefix+tagPrefix+tag).Result()
+}
+
+func (r *Results) DeleteTag(ctx context.Context, tag, uuid string) error {
+	r.lo.Debug("deleting job tag", "tag", tag, "uuid", uuid)
+	return r.conn.SRem(ctx, resultPrefix+tagPrefix+tag, uuid).Err()
+}
+
+func (r *Results) Lease(ctx context.Context, key, member string) error {
+	r.lo.Debug("setting lease", "key", key, "member", member)
+	return r.conn.SAdd(ctx, resultPrefix+leasePrefix+key, member).Err()
+}
+
+func (r *Results) Unlease(ctx context.Context, key, member string) error {
+	r.lo.Debug("deleting lease", "key", key, "member", member)
+	return r.conn.SRem(ctx, resultPrefix+leasePrefix+key, member).Err()
+}
+
+func (r *Results) GetLeases(ctx context.Context, key string) ([]string, error) {
+	r.lo.Debug("getting leases", "key", key)
+	return r.conn.SMembers(ctx, resultPrefix+leasePrefix+key).Result()
+}
+
+func (r *Results) Delete(ctx context.Context, uuid string) error {
+	r.lo.Debug("deleting result for job", "uuid", uuid)
+	pipe := r.conn.TxPipeline()
+	pipe.Del(ctx, resultPrefix+uuid)
+	pipe.LRem(ctx, resultPrefix+success, 0, uuid)
+	pipe.LRem(ctx, resultPrefix+failed, 0, uuid)
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *Results) IndexJob(ctx context.Context, uuid string, t time.Time, keys []string) error {
+	r.lo.Debug("indexing job", "uuid", uuid)
+	var (
+		pipe = r.conn.TxPipeline()
+		z    = &redis.Z{Score: float64(t.UnixMilli()), Member: uuid}
+	)
+	for _, k := range append([]string{indexAll}, keys...) {
+		pipe.ZAdd(ctx, resultPrefix+indexPrefix+k, z)
+	}
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *Results) UnindexJob(ctx context.Context, uuid string, keys []string) error {
+	r.lo.Debug("unindexing job", "uuid", uuid)
+	pipe := r.conn.TxPipeline()
+	for _, k := range append([]string{indexAll}, keys...) {
+		pipe.ZRem(ctx, resultPrefix+indexPrefix+k, uuid)
+	}
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *Results) QueryJobs(ctx context.Context, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	r.lo.Debug("querying jobs", "key", key)
+	if key == "" {
+		key = indexAll
+	}
+
+	rng := &redis.ZRangeBy{Min: "-inf", Max: "+inf", Offset: int64(offset), Count: int64(limit)}
+	if !from.IsZero() {
+		rng.Min = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		rng.Max = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	// A count of 0 returns no results, while a negative count returns all.
+	if limit <= 0 {
+		rng.Count = -1
+	}
+
+	if desc {
+		return r.conn.ZRevRangeByScore(ctx, resultPrefix+indexPrefix+key, rng).Result()
+	}
+	return r.conn.ZRangeByScore(ctx, resultPrefix+indexPrefix+key, rng).Result()
+}
+
+func (r *Results) AppendChunk(ctx context.Context, key string, b []byte) error {
+	r.lo.Debug("appending chunk", "key", key)
+	return r.conn.RPush(ctx, resultPrefix+key, b).Err()
+}
+
+func (r *Results) GetChunks(ctx context.Context, key string, offset int) ([][]byte, error) {
+	rs, err := r.conn.LRange(ctx, resultPrefix+key, int64(offset), -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]byte, len(rs))
+	for i, c := range rs {
+		out[i] = []byte(c)
+	}
+
+	return out, nil
+}
+
+// IncrCounters increments the fields of the hash at the key, and sets its expiry, atomically.
+func (r *Results) IncrCounters(ctx context.Context, key string, fields map[string]int64, ttl time.Duration) error {
+	_, err := r.conn.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for f, n := range fields {
+			p.HIncrBy(ctx, resultPrefix+key, f, n)
+		}
+		if ttl > 0 {
+			p.Expire(ctx, resultPrefix+key, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (r *Results) GetCounters(ctx context.Context, key string) (map[string]int64, error) {
+	rs, err := r.conn.HGetAll(ctx, resultPrefix+key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]int64, len(rs))
+	for f, v := range rs {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out[f] = n
+	}
+
+	return out, nil
+}
+
+func (r *Results) AddDelayed(ctx context.Context, key, uuid string, at time.Time) error {
+	return r.conn.ZAdd(ctx, resultPrefix+key, &redis.Z{Score: float64(at.UnixMilli()), Member: uuid}).Err()
+}
+
+// PopDue removes the due uuid's one at a time, so that a uuid removed by another caller in
+// between isn't returned.
+func (r *Results) PopDue(ctx context.Context, key string, t time.Time, n int) ([]string, error) {
+	uuids, err := r.conn.ZRangeByScore(ctx, resultPrefix+key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(t.UnixMilli(), 10),
+		Count: int64(n),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		rem, err := r.conn.ZRem(ctx, resultPrefix+key, uuid).Result()
+		if err != nil {
+			return out, err
+		}
+		if rem == 1 {
+			out = append(out, uuid)
+		}
+	}
+
+	return out, nil
+}
+
 func (r *Results) Set(ctx context.Context, uuid string, b []byte) error {
 	r.lo.Debug("setting result for job", "uuid", uuid)
 	return r.conn.Set(ctx, resultPrefix+uuid, b, defaultExpiry).Err()
@@ -109,9 +331,17 @@ func (r *Results) Set(ctx context.Context, uuid string, b []byte) error {
 func (r *Results) Get(ctx context.Context, uuid string) ([]byte, error) {
 	r.lo.Debug("getting result for job", "uuid", uuid)
 	rs, err := r.conn.Get(ctx, resultPrefix+uuid).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, errs.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return []byte(rs), nil
 }
+
+// Ping checks the connection to redis.
+func (r *Results) Ping(ctx context.Context) error {
+	return r.conn.Ping(ctx).Err()
+}
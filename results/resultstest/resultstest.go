@@ -0,0 +1,412 @@
+// Package resultstest is a conformance suite for tasqueue.Results implementations. Backend
+// authors can run it from a test against their results store to validate it against the
+// contract expected by the server:
+//
+//	func TestResults(t *testing.T) {
+//		resultstest.Run(t, func(t *testing.T) tasqueue.Results {
+//			return myresults.New(...)
+//		})
+//	}
+package resultstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+// Factory returns the results store under test. It's called once per test, and the stores
+// it returns may share their storage, as each test uses its own keys.
+type Factory func(t *testing.T) tasqueue.Results
+
+// Run runs the conformance tests against the results stores returned by the factory.
+func Run(t *testing.T, f Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, tasqueue.Results, string)
+	}{
+		{"GetSet", testGetSet},
+		{"GetMissing", testGetMissing},
+		{"SuccessFailed", testSuccessFailed},
+		{"Tags", testTags},
+		{"Leases", testLeases},
+		{"Delete", testDelete},
+		{"Index", testIndex},
+		{"Chunks", testChunks},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Prefix the keys with a unique name, as stores may share their storage.
+			prefix := fmt.Sprintf("resultstest:%d:", time.Now().UnixNano())
+			tc.fn(t, f(t), prefix)
+		})
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// only returns the values of the list which are in vals, retaining their order.
+func only(list []string, vals ...string) []string {
+	out := []string{}
+	for _, s := range list {
+		if contains(vals, s) {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// testGetSet checks that values are stored unaltered and overwritten.
+func testGetSet(t *testing.T, r tasqueue.Results, prefix string) {
+	ctx := context.Background()
+	for _, v := range [][]byte{[]byte("\x00binary\xffvalue"), []byte("overwritten")} {
+		if err := r.Set(ctx, prefix+"key", v); err != nil {
+			t.Fatalf("error setting value: %v", err)
+		}
+		got, err := r.Get(ctx, prefix+"key")
+		if err != nil {
+			t.Fatalf("error getting value: %v", err)
+		}
+		if string(got) != string(v) {
+			t.Fatalf("expected value %q, got %q", v, got)
+		}
+	}
+}
+
+// testGetMissing checks that getting a key which doesn't exist returns tasqueue.ErrNotFound.
+func testGetMissing(t *testing.T, r tasqueue.Results, prefix string) {
+	if _, err := r.Get(context.Background(), prefix+"missing"); !errors.Is(err, tasqueue.ErrNotFound) {
+		t.Fatalf("expected %v getting a missing key, got %v", tasqueue.ErrNotFound, err)
+	}
+}
+
+// testSuccessFailed checks that jobs are recorded in the success and failed lists, and removed
+// from the failed list if the store implements tasqueue.FailedDeleter.
+func testSuccessFailed(t *testing.T, r tasqueue.Results, prefix string) {
+	var (
+		ctx  = context.Background()
+		succ = prefix + "success"
+		fail = prefix + "failed"
+	)
+	if err := r.SetSuccess(ctx, succ); err != nil {
+		t.Fatalf("error setting success: %v", err)
+	}
+	if err := r.SetFailed(ctx, fail); err != nil {
+		t.Fatalf("error setting failed: %v", err)
+	}
+
+	s, err := r.GetSuccess(ctx)
+	if err != nil {
+		t.Fatalf("error getting success: %v", err)
+	}
+	f, err := r.GetFailed(ctx)
+	if err != nil {
+		t.Fatalf("error getting failed: %v", err)
+	}
+	if !contains(s, succ) || contains(s, fail) {
+		t.Fatalf("expected %s (and not %s) in the success list, got %v", succ, fail, s)
+	}
+	if !contains(f, fail) || contains(f, succ) {
+		t.Fatalf("expected %s (and not %s) in the failed list, got %v", fail, succ, f)
+	}
+
+	d, ok := r.(tasqueue.FailedDeleter)
+	if !ok {
+		return
+	}
+	if err := d.DeleteFailed(ctx, fail); err != nil {
+		t.Fatalf("error deleting failed: %v", err)
+	}
+	if f, err = r.GetFailed(ctx); err != nil || contains(f, fail) {
+		t.Fatalf("expected %s not to be in the failed list, got %v (%v)", fail, f, err)
+	}
+}
+
+// testTags checks that uuids are added to and removed from a tag's index, if the store
+// implements tasqueue.Tagger.
+func testTags(t *testing.T, r tasqueue.Results, prefix string) {
+	tg, ok := r.(tasqueue.Tagger)
+	if !ok {
+		t.Skip("results store doesn't implement tasqueue.Tagger")
+	}
+
+	var (
+		ctx = context.Background()
+		tag = prefix + "tag"
+	)
+	for _, u := range []string{"a", "b", "b"} {
+		if err := tg.SetTag(ctx, tag, u); err != nil {
+			t.Fatalf("error setting tag: %v", err)
+		}
+	}
+	if err := tg.DeleteTag(ctx, tag, "a"); err != nil {
+		t.Fatalf("error deleting tag: %v", err)
+	}
+
+	got, err := tg.GetTag(ctx, tag)
+	if err != nil {
+		t.Fatalf("error getting tag: %v", err)
+	}
+	if len(got) != 1 || got[0] != "b" {
+		t.Fatalf("expected only b, once, in the tag, got %v", got)
+	}
+	if err := tg.DeleteTag(ctx, tag, "b"); err != nil {
+		t.Fatalf("error deleting tag: %v", err)
+	}
+	if got, err = tg.GetTag(ctx, tag); err != nil || len(got) != 0 {
+		t.Fatalf("expected the tag to be empty, got %v (%v)", got, err)
+	}
+}
+
+// testLeases checks that members are added to and removed from a set of leases, apart from
+// the tags, if the store implements tasqueue.Leaser.
+func testLeases(t *testing.T, r tasqueue.Results, prefix string) {
+	l, ok := r.(tasqueue.Leaser)
+	if !ok {
+		t.Skip("results store doesn't implement tasqueue.Leaser")
+	}
+
+	var (
+		ctx = context.Background()
+		key = prefix + "lease"
+	)
+	for _, m := range []string{"a", "b", "b"} {
+		if err := l.Lease(ctx, key, m); err != nil {
+			t.Fatalf("error setting lease: %v", err)
+		}
+	}
+	if err := l.Unlease(ctx, key, "a"); err != nil {
+		t.Fatalf("error deleting lease: %v", err)
+	}
+
+	got, err := l.GetLeases(ctx, key)
+	if err != nil {
+		t.Fatalf("error getting leases: %v", err)
+	}
+	if len(got) != 1 || got[0] != "b" {
+		t.Fatalf("expected only b, once, in the leases, got %v", got)
+	}
+	if tg, ok := r.(tasqueue.Tagger); ok {
+		if tags, err := tg.GetTag(ctx, key); err != nil || len(tags) != 0 {
+			t.Fatalf("expected the leases not to be tagged, got %v (%v)", tags, err)
+		}
+	}
+}
+
+// testDelete checks that deleting a job deletes its value and removes it from the
+// success and failed lists.
+func testDelete(t *testing.T, r tasqueue.Results, prefix string) {
+	var (
+		ctx  = context.Background()
+		uuid = prefix + "job"
+	)
+	if err := r.Set(ctx, uuid, []byte("value")); err != nil {
+		t.Fatalf("error setting value: %v", err)
+	}
+	if err := r.SetSuccess(ctx, uuid); err != nil {
+		t.Fatalf("error setting success: %v", err)
+	}
+	if err := r.Delete(ctx, uuid); err != nil {
+		t.Fatalf("error deleting: %v", err)
+	}
+
+	if _, err := r.Get(ctx, uuid); err == nil {
+		t.Fatal("expected an error getting a deleted key")
+	}
+	s, err := r.GetSuccess(ctx)
+	if err != nil {
+		t.Fatalf("error getting success: %v", err)
+	}
+	if contains(s, uuid) {
+		t.Fatalf("expected %s to be removed from the success list", uuid)
+	}
+}
+
+// testIndex checks that indexed jobs are queried in time order, bounded by time and paged, if
+// the store implements tasqueue.Indexer.
+func testIndex(t *testing.T, r tasqueue.Results, prefix string) {
+	ix, ok := r.(tasqueue.Indexer)
+	if !ok {
+		t.Skip("results store doesn't implement tasqueue.Indexer")
+	}
+
+	var (
+		ctx   = context.Background()
+		key   = prefix + "key"
+		base  = time.Now().Truncate(time.Second)
+		uuids = []string{prefix + "a", prefix + "b", prefix + "c", prefix + "d"}
+	)
+	// Index out of order, to check that jobs are ordered by time.
+	for _, i := range []int{2, 0, 3, 1} {
+		if err := ix.IndexJob(ctx, uuids[i], base.Add(time.Duration(i)*time.Second), []string{key}); err != nil {
+			t.Fatalf("error indexing job: %v", err)
+		}
+	}
+
+	query := func(key string, from, to time.Time, offset, limit int, desc bool) []string {
+		t.Helper()
+
+		got, err := ix.QueryJobs(ctx, key, from, to, offset, limit, desc)
+		if err != nil {
+			t.Fatalf("error querying jobs: %v", err)
+		}
+		return only(got, uuids...)
+	}
+	for _, tc := range []struct {
+		name     string
+		from, to time.Time
+		offset   int
+		limit    int
+		desc     bool
+		exp      []string
+	}{
+		{"all", time.Time{}, time.Time{}, 0, 0, false, uuids},
+		{"desc", time.Time{}, time.Time{}, 0, 0, true, []string{uuids[3], uuids[2], uuids[1], uuids[0]}},
+		{"bounded", base.Add(time.Second), base.Add(time.Second * 2), 0, 0, false, uuids[1:3]},
+		{"paged", time.Time{}, time.Time{}, 1, 2, false, uuids[1:3]},
+		{"past the end", time.Time{}, time.Time{}, 4, 0, false, []string{}},
+	} {
+		if got := query(key, tc.from, tc.to, tc.offset, tc.limit, tc.desc); !reflect.DeepEqual(got, tc.exp) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.exp, got)
+		}
+	}
+
+	// Jobs are also in the index of all jobs, which other jobs may share.
+	if got := query("", base, base.Add(time.Second*3), 0, 0, false); !reflect.DeepEqual(got, uuids) {
+		t.Fatalf("expected %v in the index of all jobs, got %v", uuids, got)
+	}
+
+	if err := ix.UnindexJob(ctx, uuids[1], []string{key}); err != nil {
+		t.Fatalf("error unindexing job: %v", err)
+	}
+	exp := []string{uuids[0], uuids[2], uuids[3]}
+	if got := query(key, time.Time{}, time.Time{}, 0, 0, false); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v after unindexing, got %v", exp, got)
+	}
+	if got := query("", base, base.Add(time.Second*3), 0, 0, false); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v in the index of all jobs after unindexing, got %v", exp, got)
+	}
+}
+
+// testChunks checks that chunks are appended, read from an offset and deleted by Delete, if
+// the store implements tasqueue.Chunker.
+func testChunks(t *testing.T, r tasqueue.Results, prefix string) {
+	c, ok := r.(tasqueue.Chunker)
+	if !ok {
+		t.Skip("results store doesn't implement tasqueue.Chunker")
+	}
+
+	var (
+		ctx    = context.Background()
+		key    = prefix + "stream"
+		chunks = [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	)
+	for _, ch := range chunks {
+		if err := c.AppendChunk(ctx, key, ch); err != nil {
+			t.Fatalf("error appending chunk: %v", err)
+		}
+	}
+
+	for offset, exp := range map[int][][]byte{0: chunks, 1: chunks[1:], 3: nil, 5: nil} {
+		got, err := c.GetChunks(ctx, key, offset)
+		if err != nil {
+			t.Fatalf("error getting chunks: %v", err)
+		}
+		if len(got) != len(exp) || (len(exp) > 0 && !reflect.DeepEqual(got, exp)) {
+			t.Fatalf("expected chunks %q from offset %d, got %q", exp, offset, got)
+		}
+	}
+
+	if err := r.Delete(ctx, key); err != nil {
+		t.Fatalf("error deleting: %v", err)
+	}
+	if got, err := c.GetChunks(ctx, key, 0); err != nil || len(got) != 0 {
+		t.Fatalf("expected chunks to be deleted, got %q (%v)", got, err)
+	}
+}
+
+// testConcurrency checks that concurrent writes aren't lost, including the tags and chunks if
+// the store implements tasqueue.Tagger and tasqueue.Chunker.
+func testConcurrency(t *testing.T, r tasqueue.Results, prefix string) {
+	var (
+		tg, tagged = r.(tasqueue.Tagger)
+		c, chunked = r.(tasqueue.Chunker)
+		ctx        = context.Background()
+		n          = 50
+		wg         sync.WaitGroup
+		errs       = make(chan error, n*3)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("%skey:%d", prefix, i)
+			if err := r.Set(ctx, key, []byte(key)); err != nil {
+				errs <- err
+			}
+			if tagged {
+				if err := tg.SetTag(ctx, prefix+"tag", key); err != nil {
+					errs <- err
+				}
+			}
+			if chunked {
+				if err := c.AppendChunk(ctx, prefix+"stream", []byte(key)); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("error writing concurrently: %v", err)
+	}
+
+	var exp []string
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("%skey:%d", prefix, i)
+		if got, err := r.Get(ctx, key); err != nil || string(got) != key {
+			t.Fatalf("expected value %q, got %q (%v)", key, got, err)
+		}
+		exp = append(exp, key)
+	}
+	sort.Strings(exp)
+
+	if tagged {
+		tags, err := tg.GetTag(ctx, prefix+"tag")
+		if err != nil {
+			t.Fatalf("error getting tag: %v", err)
+		}
+		sort.Strings(tags)
+		if !reflect.DeepEqual(tags, exp) {
+			t.Fatalf("expected %d uuids in the tag, got %d", n, len(tags))
+		}
+	}
+
+	if chunked {
+		chunks, err := c.GetChunks(ctx, prefix+"stream", 0)
+		if err != nil {
+			t.Fatalf("error getting chunks: %v", err)
+		}
+		if len(chunks) != n {
+			t.Fatalf("expected %d chunks, got %d", n, len(chunks))
+		}
+	}
+}
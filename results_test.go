@@ -0,0 +1,134 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNamedResults(t *testing.T) {
+	type rows struct {
+		Processed int
+		Skipped   []string
+	}
+
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+	srv.RegisterTask("import", func(b []byte, c JobCtx) error {
+		if err := c.SaveNamed("rows", rows{Processed: 10, Skipped: []string{"a"}}); err != nil {
+			return err
+		}
+		return c.SaveNamed("file", "import.csv")
+	}, TaskOpts{})
+	go srv.Start(ctx)
+
+	job, err := NewJob("import", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the job to be consumed & processed.
+	time.Sleep(time.Second)
+
+	var r rows
+	if err := srv.GetNamedResult(ctx, uuid, "rows", &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Processed != 10 || len(r.Skipped) != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	var file string
+	if err := srv.GetNamedResult(ctx, uuid, "file", &file); err != nil || file != "import.csv" {
+		t.Fatalf("expected result import.csv, got %q (%v)", file, err)
+	}
+
+	if err := srv.GetNamedResult(ctx, uuid, "missing", &file); !errors.Is(err, ErrResultNotFound) {
+		t.Fatalf("expected %v, got %v", ErrResultNotFound, err)
+	}
+}
+
+func TestStreamResult(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		srv     = newServer(t)
+		release = make(chan struct{})
+	)
+	srv.RegisterTask("tail", func(b []byte, c JobCtx) error {
+		if err := c.Append([]byte("first")); err != nil {
+			return err
+		}
+		<-release
+		return c.Append([]byte("second"))
+	}, TaskOpts{})
+	go srv.Start(ctx)
+
+	job, err := NewJob("tail", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := srv.StreamResult(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first chunk is streamed while the job is running.
+	select {
+	case c := <-ch:
+		if string(c) != "first" {
+			t.Fatalf("expected chunk first, got %s", c)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("chunk was not streamed")
+	}
+	close(release)
+
+	var chunks []string
+	for c := range ch {
+		chunks = append(chunks, string(c))
+	}
+	if len(chunks) != 1 || chunks[0] != "second" {
+		t.Fatalf("expected chunks [second], got %v", chunks)
+	}
+}
+
+func TestResultsUnsupported(t *testing.T) {
+	ctx := context.Background()
+	for _, ns := range []string{"", "test"} {
+		srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: plainResults{NewMockResults()}, Namespace: ns})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Jobs aren't indexed on a store without indexes.
+		if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+			t.Fatalf("expected the job to be enqueued in namespace %q, got %v", ns, err)
+		}
+		if _, err := srv.GetJobs(ctx, JobFilter{}); !errors.Is(err, ErrIndexUnsupported) {
+			t.Fatalf("expected %v in namespace %q, got %v", ErrIndexUnsupported, ns, err)
+		}
+		if _, err := srv.GetJobsByTag(ctx, "tag", ""); !errors.Is(err, ErrTagsUnsupported) {
+			t.Fatalf("expected %v in namespace %q, got %v", ErrTagsUnsupported, ns, err)
+		}
+
+		job, err := NewJob(taskName, nil, JobOpts{Gate: "approval"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); !errors.Is(err, ErrTagsUnsupported) {
+			t.Fatalf("expected %v holding a job in namespace %q, got %v", ErrTagsUnsupported, ns, err)
+		}
+	}
+}
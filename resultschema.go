@@ -0,0 +1,73 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoMigration is returned on reading a result whose version can't be upgraded to the
+// current version of its task's result schema, as a migration is missing.
+var ErrNoMigration = errors.New("result migration missing")
+
+// ResultMigration upgrades a result saved by a job from a version of its task's result
+// schema to the next version.
+type ResultMigration func(result []byte) ([]byte, error)
+
+// ResultSchema is the version of the shape of a task's results, and the migrations which
+// upgrade the results saved with the older versions.
+type ResultSchema struct {
+	// Version is the current version, which the results saved by the task's jobs are tagged
+	// with (Meta.ResultVersion).
+	Version int
+	// Migrations is a map of version -> migration which upgrades the results of the version
+	// to the next version.
+	Migrations map[int]ResultMigration
+}
+
+// migrate upgrades the results saved with the version to the schema's version.
+func (rs ResultSchema) migrate(results [][]byte, version int) ([][]byte, error) {
+	out := make([][]byte, len(results))
+	copy(out, results)
+
+	for v := version; v < rs.Version; v++ {
+		m, ok := rs.Migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("could not upgrade result from version %d : %w", v, ErrNoMigration)
+		}
+		for i, r := range out {
+			b, err := m(r)
+			if err != nil {
+				return nil, fmt.Errorf("could not upgrade result from version %d : %w", v, err)
+			}
+			out[i] = b
+		}
+	}
+
+	return out, nil
+}
+
+// resultVersion returns the current version of the task's result schema.
+func (s *Server) resultVersion(task string) int {
+	return s.schemas[task].Version
+}
+
+// migrateResults upgrades the results of the job to the current version of its task's
+// result schema, if they were saved with an older version.
+func (s *Server) migrateResults(ctx context.Context, uuid string, results [][]byte) ([][]byte, error) {
+	if len(s.schemas) == 0 {
+		return results, nil
+	}
+
+	// The reduced results of chains and groups don't have a job message, and aren't versioned.
+	msg, err := s.GetJob(ctx, uuid)
+	if err != nil || msg.Job == nil {
+		return results, nil
+	}
+	rs, ok := s.schemas[msg.Job.Task]
+	if !ok || msg.ResultVersion >= rs.Version {
+		return results, nil
+	}
+
+	return rs.migrate(results, msg.ResultVersion)
+}
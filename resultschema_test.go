@@ -0,0 +1,80 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResultSchemas(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+	)
+
+	// Results are saved with v1 of the schema, and read with v3.
+	process := func(schemas map[string]ResultSchema) string {
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: results, ResultSchemas: schemas})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+			return c.Save([]byte("name"))
+		}, TaskOpts{})
+
+		job, err := NewJob(taskName, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+		return uuid
+	}
+	uuid := process(map[string]ResultSchema{taskName: {Version: 1}})
+
+	appendField := func(f string) ResultMigration {
+		return func(b []byte) ([]byte, error) {
+			return append(append(b, ','), f...), nil
+		}
+	}
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results, ResultSchemas: map[string]ResultSchema{
+		taskName: {Version: 3, Migrations: map[int]ResultMigration{1: appendField("email"), 2: appendField("phone")}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ResultVersion != 1 {
+		t.Fatalf("expected the job to be tagged with the result version 1, got %d", msg.ResultVersion)
+	}
+	res, err := srv.GetResult(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !bytes.Equal(res[0], []byte("name,email,phone")) {
+		t.Fatalf("expected the result to be upgraded, got %q", res)
+	}
+
+	// Results of the current version aren't migrated.
+	current := process(map[string]ResultSchema{taskName: {Version: 3}})
+	if res, err = srv.GetResult(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !bytes.Equal(res[0], []byte("name")) {
+		t.Fatalf("expected the result to not be upgraded, got %q", res)
+	}
+
+	// Results which can't be upgraded fail.
+	srv.schemas[taskName] = ResultSchema{Version: 3, Migrations: map[int]ResultMigration{1: appendField("email")}}
+	if _, err := srv.GetResult(ctx, uuid); !errors.Is(err, ErrNoMigration) {
+		t.Fatalf("expected a missing migration to fail, got %v", err)
+	}
+}
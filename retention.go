@@ -0,0 +1,137 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultJanitorInterval = time.Hour
+
+// RetentionRule is the duration for which the completed jobs matching the rule are kept in
+// the results store. Queue, Task and Status (a final status) limit the rule to the jobs that
+// match them, if they're set.
+type RetentionRule struct {
+	Queue  string
+	Task   string
+	Status string
+	// Keep is the duration after completion for which the jobs are kept.
+	Keep time.Duration
+}
+
+// match returns true if the rule applies to the job.
+func (r RetentionRule) match(msg JobMessage) bool {
+	return (r.Queue == "" || r.Queue == msg.Queue) &&
+		(r.Task == "" || (msg.Job != nil && r.Task == msg.Job.Task)) &&
+		(r.Status == "" || r.Status == msg.Status)
+}
+
+// RetentionOpts configures the janitor.
+type RetentionOpts struct {
+	// Rules are matched in order, and the first rule that matches a job applies. Hence
+	// per queue or task overrides should be listed before the general rules. Jobs that
+	// don't match any rule are kept.
+	Rules []RetentionRule
+	// Interval is the duration between pruning runs. Defaults to an hour.
+	Interval time.Duration
+}
+
+// PruneJobs() deletes the completed jobs which are past the retention of the first rule that
+// matches them from the results store, and returns the number of jobs deleted. Jobs that the
+// caller isn't authorized to prune are skipped.
+func (s *Server) PruneJobs(ctx context.Context, rules []RetentionRule) (int, error) {
+	if s.results == nil {
+		return 0, ErrNoResults
+	}
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	// Jobs complete after they're enqueued, hence the jobs enqueued within the shortest
+	// retention are kept by all the rules.
+	var (
+		now   = s.clock.Now()
+		least = rules[0].Keep
+	)
+	for _, r := range rules {
+		if r.Keep < least {
+			least = r.Keep
+		}
+	}
+
+	var (
+		until  = now.Add(-least)
+		pruned = make(map[string]int)
+		n      int
+	)
+	for offset := 0; ; {
+		uuids, err := queryJobs(ctx, s.results, "", time.Time{}, until, offset, queryBatchSize, false)
+		if err != nil {
+			return n, err
+		}
+
+		deleted := 0
+		for _, uuid := range uuids {
+			msg, err := s.getJob(ctx, uuid, false)
+			if err != nil {
+				return n, err
+			}
+			if !IsFinal(msg.Status) {
+				continue
+			}
+
+			for _, r := range rules {
+				if !r.match(msg) {
+					continue
+				}
+				// Jobs that the caller isn't authorized to prune are kept.
+				if msg.ProcessedAt.Before(now.Add(-r.Keep)) && s.authorize(ctx, OpPrune, msg) == nil {
+					// Pruned failed jobs can't be retried, hence their payloads are deleted too.
+					s.deletePayload(ctx, msg)
+					if err := s.deleteJob(ctx, msg); err != nil {
+						return n, err
+					}
+					s.recordAudit(ctx, OpPrune, msg.UUID, "")
+					pruned[msg.Status]++
+					deleted++
+					n++
+				}
+				break
+			}
+		}
+
+		if len(uuids) < queryBatchSize {
+			break
+		}
+		// The deleted jobs are no longer in the index.
+		offset += len(uuids) - deleted
+	}
+
+	for status, c := range pruned {
+		s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{status="%s"}`, metricJobsPruned, status)).Add(c)
+	}
+
+	return n, nil
+}
+
+// RunJanitor() periodically prunes the results store according to the retention rules.
+// It is a blocking function.
+func (s *Server) RunJanitor(ctx context.Context, o RetentionOpts) {
+	if o.Interval == 0 {
+		o.Interval = defaultJanitorInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(o.Interval):
+			n, err := s.PruneJobs(ctx, o.Rules)
+			if err != nil {
+				s.log.Error("error pruning jobs", "error", err)
+				continue
+			}
+			s.log.Debug("pruned jobs", "count", n)
+		}
+	}
+}
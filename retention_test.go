@@ -0,0 +1,70 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPruneJobs(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+	srv.RegisterTask("important", MockHandler, TaskOpts{})
+
+	var (
+		rules = []RetentionRule{
+			{Task: "important", Keep: time.Hour * 24 * 90},
+			{Status: StatusFailed, Keep: time.Hour * 24 * 30},
+			{Status: StatusDone, Keep: time.Hour * 24},
+		}
+		success   = makeJob(t, false)
+		failed    = makeJob(t, true)
+		important = makeJob(t, false)
+	)
+	important.Task = "important"
+	failed.Opts.MaxRetries = 0
+
+	var uuids []string
+	for _, j := range []Job{success, failed, important} {
+		uuid, err := srv.Enqueue(ctx, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+		uuids = append(uuids, uuid)
+	}
+
+	prune := func(exp int, kept ...string) {
+		n, err := srv.PruneJobs(ctx, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != exp {
+			t.Fatalf("expected %d jobs pruned, got %d", exp, n)
+		}
+		for _, uuid := range kept {
+			if _, err := srv.GetJob(ctx, uuid); err != nil {
+				t.Fatalf("expected job %s to be kept, got %v", uuid, err)
+			}
+		}
+	}
+
+	prune(0, uuids...)
+
+	// Successful jobs are pruned after a day, failed jobs after 30 days, and the jobs of the
+	// important task after 90 days.
+	clock.advance(time.Hour * 25)
+	prune(1, uuids[1:]...)
+	clock.advance(time.Hour * 24 * 30)
+	prune(1, uuids[2])
+	clock.advance(time.Hour * 24 * 60)
+	prune(1)
+}
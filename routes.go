@@ -0,0 +1,49 @@
+package tasqueue
+
+import (
+	"context"
+)
+
+// Route is a follow-up task, enqueued with the outcome of a job of the task that routes to it.
+// The follow-up job's payload is the last result saved by the job (if any), and its
+// Meta.PrevJobResults are all of them. Its options are applied as on any other job.
+type Route struct {
+	Task string
+	Opts JobOpts
+}
+
+// Routes routes the outcomes of a task's jobs to follow-up tasks, as a static alternative
+// to enqueuing chains.
+type Routes struct {
+	// Success is enqueued once a job succeeds, and Failure once a job fails without
+	// further retries. Routes without a task aren't enqueued.
+	Success Route
+	Failure Route
+}
+
+// route enqueues the route's task with the job's results, and records the follow-up job's
+// UUID on the job.
+func (s *Server) route(ctx context.Context, msg *JobMessage, r Route) error {
+	if r.Task == "" {
+		return nil
+	}
+
+	// The results are read back from the store, as they're saved on the handler's copy of the
+	// JobCtx. A job without results (or without a results store) routes an empty payload.
+	results, _ := s.GetResult(ctx, msg.UUID)
+	var payload []byte
+	if len(results) > 0 {
+		payload = results[len(results)-1]
+	}
+	nj, err := s.prepareJob(Job{Task: r.Task, Payload: payload, Opts: r.Opts})
+	if err != nil {
+		return err
+	}
+	meta := DefaultMeta(nj.Opts)
+	meta.PrevJobResults = results
+	meta.RoutedFrom = msg.UUID
+	meta.TraceLink = msg.TraceLink
+
+	msg.RouteUUID, err = s.enqueueWithMeta(ctx, nj, meta)
+	return err
+}
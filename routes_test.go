@@ -0,0 +1,71 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRoutes(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = NewMockResults()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		if err := c.Save(append([]byte("result:"), b...)); err != nil {
+			return err
+		}
+		if string(b) == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{Routes: Routes{
+		Success: Route{Task: "notify", Opts: JobOpts{Queue: "notifications"}},
+		Failure: Route{Task: "alert"},
+	}})
+
+	for _, tc := range []struct {
+		payload string
+		route   string
+		queue   string
+	}{
+		{"ok", "notify", "notifications"},
+		{"fail", "alert", DefaultQueue},
+	} {
+		job, err := NewJob(taskName, []byte(tc.payload), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+
+		// The job's result is the follow-up job's payload.
+		var next JobMessage
+		if err := msgpack.Unmarshal(<-broker.data, &next); err != nil {
+			t.Fatal(err)
+		}
+		if next.Job.Task != tc.route || next.Queue != tc.queue || string(next.Job.Payload) != "result:"+tc.payload {
+			t.Fatalf("expected the outcome to be routed to %s on %s, got %s on %s with %q", tc.route, tc.queue, next.Job.Task, next.Queue, next.Job.Payload)
+		}
+		if next.RoutedFrom != uuid {
+			t.Fatalf("expected the follow-up job to be routed from %s, got %s", uuid, next.RoutedFrom)
+		}
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.RouteUUID != next.UUID {
+			t.Fatalf("expected the follow-up job's UUID %s on the job, got %s", next.UUID, msg.RouteUUID)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCallFailed is returned by Call() if the called job didn't succeed, ie: it failed, or
+// was cancelled or skipped.
+var ErrCallFailed = errors.New("call failed")
+
+// Call() enqueues a job of the task with the payload, waits for it to complete and returns
+// the result saved by its handler (the last one, if it called JobCtx.Save() multiple times),
+// so that tasks can be used as a durable RPC layer. The wait is bounded by the context, which
+// should have a deadline. Unless the options set ExpiresAt, the job expires at the context's
+// deadline, hence a job picked up after the caller has given up isn't executed.
+func (c *Client) Call(ctx context.Context, task string, payload []byte, opts JobOpts) ([]byte, error) {
+	if d, ok := ctx.Deadline(); ok && opts.ExpiresAt.IsZero() {
+		opts.ExpiresAt = d
+	}
+	j, err := NewJob(task, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid, err := c.Enqueue(ctx, j)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := c.srv.GetJobBlocking(ctx, uuid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not wait for job %s : %w", uuid, err)
+	}
+	if msg.Status != StatusDone {
+		return nil, fmt.Errorf("job %s is %s : %s : %w", uuid, msg.Status, msg.PrevErr, ErrCallFailed)
+	}
+
+	res, err := c.srv.GetResult(ctx, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("could not get result of job %s : %w", uuid, err)
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res[len(res)-1], nil
+}
@@ -0,0 +1,66 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCall(t *testing.T) {
+	var (
+		broker  = NewMockBroker()
+		results = NewMockResults()
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	cl, err := NewClient(ClientOpts{Broker: broker, Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("greet", func(b []byte, c JobCtx) error {
+		if len(b) == 0 {
+			return fmt.Errorf("no name")
+		}
+		return c.Save([]byte("hello " + string(b)))
+	}, TaskOpts{})
+
+	go srv.Start(ctx)
+
+	res, err := cl.Call(ctx, "greet", []byte("world"), JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != "hello world" {
+		t.Fatalf("unexpected reply %q", res)
+	}
+
+	if _, err := cl.Call(ctx, "greet", nil, JobOpts{}); !errors.Is(err, ErrCallFailed) {
+		t.Fatalf("expected ErrCallFailed, got %v", err)
+	}
+
+	// A job that isn't processed in time expires at the deadline of the call.
+	idle, err := NewClient(ClientOpts{Broker: NewMockBroker(), Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cctx, ccancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer ccancel()
+	if _, err := idle.Call(cctx, "greet", []byte("world"), JobOpts{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the call to time out, got %v", err)
+	}
+	msgs, err := idle.GetJobs(ctx, JobFilter{Status: StatusStarted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := cctx.Deadline()
+	if len(msgs) != 1 || !msgs[0].ExpiresAt.Equal(d) {
+		t.Fatalf("expected the queued job to expire at the deadline, got %+v", msgs)
+	}
+}
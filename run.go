@@ -0,0 +1,173 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultDrainTimeout = time.Second * 30
+	// idlePollPeriod is the period at which a draining server is checked for in-flight jobs.
+	idlePollPeriod = time.Millisecond * 100
+)
+
+// RunOpts configures Run().
+type RunOpts struct {
+	// DrainTimeout is the maximum duration for which the in-flight jobs are waited for on
+	// SIGINT or SIGTERM, after which their contexts are cancelled. Defaults to 30s.
+	DrainTimeout time.Duration
+	// Dump, if set, is written the server's in-flight jobs on SIGUSR1.
+	Dump io.Writer
+}
+
+// Run() starts the server and handles the process' signals. It is a blocking function, which
+// returns after the server has stopped. On SIGINT or SIGTERM the server stops consuming jobs,
+// and stops once the in-flight jobs are done (or the drain timeout passes). A second SIGINT or
+// SIGTERM, or a SIGQUIT, stops the server immediately, cancelling the in-flight jobs.
+func Run(s *Server, o RunOpts) {
+	if o.DrainTimeout == 0 {
+		o.DrainTimeout = defaultDrainTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	watch := append([]os.Signal{os.Interrupt, syscall.SIGTERM}, quitSignals...)
+	if o.Dump != nil {
+		watch = append(watch, dumpSignals...)
+	}
+	signal.Notify(sigs, watch...)
+	defer signal.Stop(sigs)
+
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+
+	var (
+		draining bool
+		timeout  <-chan time.Time
+	)
+	for {
+		select {
+		case <-done:
+			return
+		case <-timeout:
+			s.log.Info("drain timed out, cancelling in-flight jobs")
+			cancel()
+		case sig := <-sigs:
+			switch {
+			case isSignal(sig, dumpSignals):
+				s.dumpInFlight(o.Dump)
+			case draining || isSignal(sig, quitSignals):
+				s.log.Info("stopping server", "signal", sig)
+				cancel()
+			default:
+				s.log.Info("draining server", "signal", sig)
+				draining = true
+				timeout = time.After(o.DrainTimeout)
+				s.stopConsumers()
+				go func() {
+					s.waitIdle(ctx)
+					cancel()
+				}()
+			}
+		}
+	}
+}
+
+func isSignal(sig os.Signal, sigs []os.Signal) bool {
+	for _, s := range sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// stopConsumers stops the consumers of the running tasks, while the processors finish the
+// jobs already received. Tasks registered afterwards aren't started.
+func (s *Server) stopConsumers() {
+	s.p.Lock()
+	for _, stop := range s.stops {
+		stop()
+	}
+	s.runCtx = nil
+	s.stops = make(map[string]context.CancelFunc)
+	s.p.Unlock()
+}
+
+// waitIdle waits until the server isn't processing (or holding back) any jobs. The server is
+// considered idle once it has no in-flight jobs on two consecutive checks, as a job may be in
+// transit between a consumer and a processor on one check.
+func (s *Server) waitIdle(ctx context.Context) {
+	tk := time.NewTicker(idlePollPeriod)
+	defer tk.Stop()
+
+	idle := 0
+	for {
+		s.qmu.Lock()
+		n := len(s.inflight)
+		s.qmu.Unlock()
+		if n > 0 {
+			idle = 0
+		} else if idle++; idle == 2 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+		}
+	}
+}
+
+// dumpInFlight writes the jobs being processed by the server, and the number of in-flight
+// (processed or held back) jobs of each queue.
+func (s *Server) dumpInFlight(w io.Writer) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+
+	var (
+		now   = s.clock.Now()
+		jobs  = make([]JobMessage, 0, len(s.running))
+		queue = make([]string, 0, len(s.inflight))
+	)
+	for _, msg := range s.running {
+		jobs = append(jobs, msg)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ProcessedAt.Before(jobs[j].ProcessedAt) })
+	for q := range s.inflight {
+		queue = append(queue, q)
+	}
+	sort.Strings(queue)
+
+	for _, msg := range jobs {
+		fmt.Fprintf(w, "job uuid=%s task=%s queue=%s attempt=%d running=%s\n",
+			msg.UUID, msg.Job.Task, msg.Queue, msg.Retried+1, now.Sub(msg.ProcessedAt).Round(time.Millisecond))
+	}
+	for _, q := range queue {
+		fmt.Fprintf(w, "queue name=%s inflight=%d\n", q, s.inflight[q])
+	}
+}
+
+// setRunning records the job as being processed by the server, or removes it if running is false.
+func (s *Server) setRunning(msg JobMessage, running bool) {
+	s.qmu.Lock()
+	if running {
+		msg.ProcessedAt = s.clock.Now()
+		s.running[msg.UUID] = msg
+	} else {
+		delete(s.running, msg.UUID)
+	}
+	s.qmu.Unlock()
+}
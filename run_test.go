@@ -0,0 +1,94 @@
+//go:build !windows
+
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		srv     = newServer(t)
+		started = make(chan struct{})
+		dump    = &syncBuffer{}
+	)
+	srv.RegisterTask("slow", func(b []byte, c JobCtx) error {
+		close(started)
+		select {
+		case <-time.After(time.Millisecond * 300):
+			return nil
+		case <-c.Done():
+			return c.Err()
+		}
+	}, TaskOpts{})
+
+	job, err := NewJob("slow", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		Run(srv, RunOpts{Dump: dump})
+		close(done)
+	}()
+	<-started
+
+	// SIGUSR1 dumps the in-flight jobs.
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && !strings.Contains(dump.String(), uuid); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !strings.Contains(dump.String(), "uuid="+uuid) {
+		t.Fatalf("expected the in-flight job in the dump, got %q", dump.String())
+	}
+
+	// SIGTERM stops the server after the in-flight job is done.
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the server to stop after draining")
+	}
+
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone {
+		t.Fatalf("expected the in-flight job to complete before stopping, got %s", msg.Status)
+	}
+}
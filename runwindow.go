@@ -0,0 +1,91 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// windowGate is the gate on which the jobs picked up outside of their run windows are held
+// until their windows open.
+const windowGate = "tasqueue:window"
+
+// validateWindow checks the run window of the job's options.
+func validateWindow(o JobOpts) error {
+	for _, d := range []time.Duration{o.NotBefore, o.NotAfter} {
+		if d < 0 || d >= time.Hour*24 {
+			return fmt.Errorf("invalid run window time of day %v", d)
+		}
+	}
+	if o.WindowZone != "" {
+		if _, err := time.LoadLocation(o.WindowZone); err != nil {
+			return fmt.Errorf("invalid run window zone : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// windowWait returns the duration after which the job's run window opens, or zero if the job
+// has no window or the window is open at the time.
+func (m JobMessage) windowWait(now time.Time) time.Duration {
+	if m.NotBefore == 0 && m.NotAfter == 0 {
+		return 0
+	}
+
+	loc, err := time.LoadLocation(m.WindowZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	var (
+		t        = now.In(loc)
+		y, mo, d = t.Date()
+		midnight = time.Date(y, mo, d, 0, 0, 0, 0, loc)
+		tod      = t.Sub(midnight)
+		end      = m.NotAfter
+	)
+	if end == 0 {
+		end = time.Hour * 24
+	}
+
+	// A window that ends before it begins is open overnight.
+	if m.NotBefore < end {
+		if tod >= m.NotBefore && tod < end {
+			return 0
+		}
+	} else if tod >= m.NotBefore || tod < end {
+		return 0
+	}
+
+	open := midnight.Add(m.NotBefore)
+	if tod >= m.NotBefore {
+		open = time.Date(y, mo, d+1, 0, 0, 0, 0, loc).Add(m.NotBefore)
+	}
+
+	return open.Sub(t)
+}
+
+// delayToWindow holds the job picked up outside of its run window until the window opens, on
+// the window gate. Without a results store, or for ordered queues, the job is held back like
+// the jobs over their rate (see holdBack()). It returns false if the job can be processed.
+func (s *Server) delayToWindow(ctx context.Context, work []byte, msg JobMessage, open time.Time) bool {
+	if s.results == nil || s.isOrdered(msg.Queue) {
+		return s.holdBack(ctx, work, msg.Queue, open.Sub(s.clock.Now()))
+	}
+
+	s.log.Debug("holding job until its run window", "uuid", msg.UUID, "open", open)
+	msg.Gate, msg.HeldUntil = windowGate, open
+	if err := s.statusHeld(ctx, msg); err != nil {
+		s.log.Error("could not hold job until its run window", "uuid", msg.UUID, "error", err)
+		return s.holdBack(ctx, work, msg.Queue, open.Sub(s.clock.Now()))
+	}
+	if err := s.holdJob(ctx, msg); err != nil {
+		s.log.Error("could not hold job until its run window", "uuid", msg.UUID, "error", err)
+		return s.holdBack(ctx, work, msg.Queue, open.Sub(s.clock.Now()))
+	}
+
+	// The jobs held until the same window are released once, as it opens.
+	s.scheduleRelease(windowGate, open)
+
+	return true
+}
@@ -0,0 +1,96 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWindowWait(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 1, h, m, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		before, after time.Duration
+		now           time.Time
+		wait          time.Duration
+	}{
+		{0, 0, at(3, 0), 0},
+		{time.Hour * 9, time.Hour * 18, at(12, 0), 0},
+		{time.Hour * 9, time.Hour * 18, at(8, 30), time.Minute * 30},
+		{time.Hour * 9, time.Hour * 18, at(18, 0), time.Hour * 15},
+		// A window without an end lasts until midnight.
+		{time.Hour * 9, 0, at(23, 0), 0},
+		// A window that ends before it begins is open overnight.
+		{time.Hour * 22, time.Hour * 6, at(2, 0), 0},
+		{time.Hour * 22, time.Hour * 6, at(12, 0), time.Hour * 10},
+	} {
+		m := JobMessage{Meta: Meta{NotBefore: tc.before, NotAfter: tc.after}}
+		if w := m.windowWait(tc.now); w != tc.wait {
+			t.Fatalf("expected the window %v-%v to open after %v at %v, got %v", tc.before, tc.after, tc.wait, tc.now, w)
+		}
+	}
+
+	// The window is in its zone.
+	m := JobMessage{Meta: Meta{NotBefore: time.Hour * 9, NotAfter: time.Hour * 18, WindowZone: "Asia/Kolkata"}}
+	if w := m.windowWait(at(3, 0)); w != time.Minute*30 {
+		t.Fatalf("expected the window to open after 30m at 08:30 IST, got %v", w)
+	}
+}
+
+func TestRunWindow(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC))
+		ran    = make(chan struct{}, 1)
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		ran <- struct{}{}
+		return nil
+	}, TaskOpts{})
+
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go srv.runReleases(rctx)
+
+	job, err := NewJob(taskName, nil, JobOpts{NotBefore: time.Hour * 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err == nil {
+		t.Fatal("expected an invalid window to be rejected")
+	}
+
+	// The job picked up before its window is held until the window opens.
+	job, err = NewJob(taskName, nil, JobOpts{NotBefore: time.Hour * 9, NotAfter: time.Hour * 17})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusHeld || len(ran) != 0 {
+		t.Fatalf("expected the job to be held until its window, got %s", msg.Status)
+	}
+
+	clock.wait(t)
+	clock.advance(time.Hour * 3)
+	select {
+	case b := <-broker.data:
+		srv.Process(ctx, b)
+	case <-time.After(time.Second):
+		t.Fatal("expected the job to be released once its window opened")
+	}
+	if len(ran) != 1 {
+		t.Fatal("expected the job to run in its window")
+	}
+}
@@ -0,0 +1,25 @@
+package tasqueue
+
+import (
+	"fmt"
+	"os"
+)
+
+// sandbox creates the temporary working directory of a job of a sandboxed task, and returns
+// it with the function that removes it.
+func (s *Server) sandbox(task Task, uuid string) (string, func(), error) {
+	if !task.opts.Sandbox {
+		return "", func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp(s.sandboxDir, "tasqueue-"+uuid+"-")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("could not create job directory : %w", err)
+	}
+
+	return dir, func() {
+		if err := os.RemoveAll(dir); err != nil {
+			s.log.Error("could not remove job directory", "uuid", uuid, "dir", dir, "error", err)
+		}
+	}, nil
+}
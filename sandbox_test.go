@@ -0,0 +1,77 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandbox(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		base   = t.TempDir()
+		dirs   []string
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), SandboxDir: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("sandboxed", func(b []byte, c JobCtx) error {
+		dirs = append(dirs, c.Dir())
+		if !strings.HasPrefix(c.Dir(), base) {
+			t.Errorf("expected the job directory to be created in %s, got %s", base, c.Dir())
+		}
+		if err := os.WriteFile(filepath.Join(c.Dir(), "out"), b, 0o600); err != nil {
+			t.Error(err)
+		}
+		if string(b) == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{Sandbox: true})
+
+	// The directories of jobs that complete or fail are removed.
+	for _, p := range []string{"ok", "fail"} {
+		job, err := NewJob("sandboxed", []byte(p), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+	}
+	if len(dirs) != 2 || dirs[0] == dirs[1] {
+		t.Fatalf("expected each job to get its own directory, got %v", dirs)
+	}
+	for _, d := range dirs {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			t.Fatalf("expected the job directory %s to be removed, got %v", d, err)
+		}
+	}
+
+	// Jobs of tasks that aren't sandboxed don't get a directory.
+	ran := false
+	srv.RegisterTask("plain", func(b []byte, c JobCtx) error {
+		ran = true
+		if c.Dir() != "" {
+			t.Errorf("expected no job directory, got %s", c.Dir())
+		}
+		return nil
+	}, TaskOpts{})
+	job, err := NewJob("plain", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	if !ran {
+		t.Fatal("expected the job of the plain task to be processed")
+	}
+}
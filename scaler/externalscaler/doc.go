@@ -0,0 +1,4 @@
+// Package externalscaler is the generated code of KEDA's external scaler gRPC service.
+package externalscaler
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative externalscaler.proto
@@ -0,0 +1,137 @@
+package scaler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/kalbhor/tasqueue/scaler/externalscaler"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPollInterval = time.Second * 10
+
+	// QueuesMetadata is the key of the ScaledObject's scaler metadata which sets the queues,
+	// comma separated. Defaults to the default queue.
+	QueuesMetadata = "queues"
+	// MetricName is the name of the metric reported to KEDA.
+	MetricName = "tasqueue-depth"
+)
+
+// grpcScaler implements KEDA's external scaler service on the Scaler.
+type grpcScaler struct {
+	externalscaler.UnimplementedExternalScalerServer
+	s *Scaler
+}
+
+// GRPC returns KEDA's external scaler service, to be registered on a gRPC server with
+// externalscaler.RegisterExternalScalerServer().
+func (s *Scaler) GRPC() externalscaler.ExternalScalerServer {
+	return &grpcScaler{s: s}
+}
+
+func (g *grpcScaler) IsActive(ctx context.Context, ref *externalscaler.ScaledObjectRef) (*externalscaler.IsActiveResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.s.opt.Timeout)
+	defer cancel()
+
+	ok, err := g.s.IsActive(ctx, refQueues(ref))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &externalscaler.IsActiveResponse{Result: ok}, nil
+}
+
+// StreamIsActive polls the depth of the queues every PollInterval, and sends whether the
+// workers should be active when it changes.
+func (g *grpcScaler) StreamIsActive(ref *externalscaler.ScaledObjectRef, stream externalscaler.ExternalScaler_StreamIsActiveServer) error {
+	var (
+		ctx    = stream.Context()
+		queues = refQueues(ref)
+		tk     = time.NewTicker(g.s.opt.PollInterval)
+		sent   bool
+		last   bool
+	)
+	defer tk.Stop()
+
+	for {
+		tctx, cancel := context.WithTimeout(ctx, g.s.opt.Timeout)
+		ok, err := g.s.IsActive(tctx, queues)
+		cancel()
+		if err != nil {
+			return grpcError(err)
+		}
+		if !sent || ok != last {
+			if err := stream.Send(&externalscaler.IsActiveResponse{Result: ok}); err != nil {
+				return err
+			}
+			sent, last = true, ok
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tk.C:
+		}
+	}
+}
+
+func (g *grpcScaler) GetMetricSpec(ctx context.Context, ref *externalscaler.ScaledObjectRef) (*externalscaler.GetMetricSpecResponse, error) {
+	return &externalscaler.GetMetricSpecResponse{
+		MetricSpecs: []*externalscaler.MetricSpec{{
+			MetricName: MetricName,
+			TargetSize: g.s.opt.TargetDepth,
+		}},
+	}, nil
+}
+
+func (g *grpcScaler) GetMetrics(ctx context.Context, req *externalscaler.GetMetricsRequest) (*externalscaler.GetMetricsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.s.opt.Timeout)
+	defer cancel()
+
+	d, err := g.s.Depth(ctx, refQueues(req.GetScaledObjectRef()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	name := req.GetMetricName()
+	if name == "" {
+		name = MetricName
+	}
+
+	return &externalscaler.GetMetricsResponse{
+		MetricValues: []*externalscaler.MetricValue{{
+			MetricName:  name,
+			MetricValue: d,
+		}},
+	}, nil
+}
+
+// refQueues returns the queues set in the scaler metadata of the ScaledObject.
+func refQueues(ref *externalscaler.ScaledObjectRef) []string {
+	var queues []string
+	for _, q := range strings.Split(ref.GetScalerMetadata()[QueuesMetadata], ",") {
+		if q = strings.TrimSpace(q); q != "" {
+			queues = append(queues, q)
+		}
+	}
+	if len(queues) == 0 {
+		queues = []string{tasqueue.DefaultQueue}
+	}
+
+	return queues
+}
+
+// grpcError maps the error of looking up the depths onto a gRPC status, as the handler does
+// onto an HTTP status.
+func grpcError(err error) error {
+	if errors.Is(err, tasqueue.ErrDepthUnsupported) {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+
+	return status.Error(codes.Unavailable, err.Error())
+}
@@ -0,0 +1,121 @@
+// Package scaler feeds the backlog of a server's queues to KEDA, to autoscale the worker
+// deployments on it without a custom exporter.
+//
+// The handler serves the queue depths as JSON, for KEDA's metrics-api scaler, and GRPC()
+// serves them over KEDA's external scaler gRPC interface.
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+)
+
+const (
+	defaultTargetDepth = 10
+	defaultTimeout     = time.Second * 5
+
+	// QueueParam is the query parameter which sets the queues of a request. It can be
+	// repeated, and defaults to the default queue.
+	QueueParam = "queue"
+)
+
+type Options struct {
+	// TargetDepth is the number of waiting messages per worker replica, reported as the
+	// target for KEDA. Defaults to 10.
+	TargetDepth int64
+	// ActivationDepth is the depth above which the workers are scaled up from zero.
+	ActivationDepth int64
+	// Timeout is the timeout of looking up the depths. Defaults to 5s.
+	Timeout time.Duration
+	// PollInterval is the interval at which the depths are looked up for KEDA's
+	// StreamIsActive calls. Defaults to 10s.
+	PollInterval time.Duration
+}
+
+// Metric is the JSON body served by the handler.
+type Metric struct {
+	// Depth is the total number of messages waiting in the queues.
+	Depth  int64 `json:"depth"`
+	Active bool  `json:"active"`
+	Target int64 `json:"target"`
+}
+
+// Scaler looks up the backlog of the server's queues. The server's broker has to implement
+// tasqueue.Depther.
+type Scaler struct {
+	srv *tasqueue.Server
+	opt Options
+}
+
+// New() returns a new instance of the scaler.
+func New(srv *tasqueue.Server, o Options) *Scaler {
+	if o.TargetDepth == 0 {
+		o.TargetDepth = defaultTargetDepth
+	}
+	if o.Timeout == 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.PollInterval == 0 {
+		o.PollInterval = defaultPollInterval
+	}
+
+	return &Scaler{srv: srv, opt: o}
+}
+
+// Depth returns the total number of messages waiting in the queues.
+func (s *Scaler) Depth(ctx context.Context, queues []string) (int64, error) {
+	var n int64
+	for _, q := range queues {
+		d, err := s.srv.QueueDepth(ctx, q)
+		if err != nil {
+			return 0, fmt.Errorf("could not get depth of queue %s : %w", q, err)
+		}
+		n += d
+	}
+
+	return n, nil
+}
+
+// IsActive returns true if the backlog of the queues is above the activation depth, ie: there
+// should be at least one worker.
+func (s *Scaler) IsActive(ctx context.Context, queues []string) (bool, error) {
+	d, err := s.Depth(ctx, queues)
+	if err != nil {
+		return false, err
+	}
+
+	return d > s.opt.ActivationDepth, nil
+}
+
+// Handler returns a handler which serves the Metric of the requested queues, eg: for KEDA's
+// metrics-api scaler with valueLocation set to depth.
+func (s *Scaler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.opt.Timeout)
+		defer cancel()
+
+		queues := r.URL.Query()[QueueParam]
+		if len(queues) == 0 {
+			queues = []string{tasqueue.DefaultQueue}
+		}
+
+		d, err := s.Depth(ctx, queues)
+		if err != nil {
+			code := http.StatusServiceUnavailable
+			if errors.Is(err, tasqueue.ErrDepthUnsupported) {
+				code = http.StatusNotImplemented
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Metric{Depth: d, Active: d > s.opt.ActivationDepth, Target: s.opt.TargetDepth})
+	})
+}
@@ -0,0 +1,187 @@
+package scaler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+	"github.com/kalbhor/tasqueue/scaler/externalscaler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// plainBroker hides the Depther implementation of the broker.
+type plainBroker struct {
+	tasqueue.Broker
+}
+
+// downBroker is a broker whose depth lookups fail.
+type downBroker struct {
+	*mb.Broker
+}
+
+func (downBroker) Depth(context.Context, string) (int64, error) {
+	return 0, errors.New("broker down")
+}
+
+func newScaler(t *testing.T, b tasqueue.Broker, o Options) *Scaler {
+	srv, err := tasqueue.NewServer(tasqueue.ServerOpts{Broker: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask("emails", func([]byte, tasqueue.JobCtx) error { return nil }, tasqueue.TaskOpts{Queue: "emails"})
+
+	for i := 0; i < 3; i++ {
+		j, err := tasqueue.NewJob("emails", nil, tasqueue.JobOpts{Queue: "emails"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(context.Background(), j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return New(srv, o)
+}
+
+func TestHandler(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		broker tasqueue.Broker
+		query  string
+		code   int
+		metric Metric
+	}{
+		{"active", mb.New(), "?queue=emails", http.StatusOK, Metric{Depth: 3, Active: true, Target: 50}},
+		{"default queue", mb.New(), "", http.StatusOK, Metric{Depth: 0, Active: false, Target: 50}},
+		{"unsupported", plainBroker{mb.New()}, "?queue=emails", http.StatusNotImplemented, Metric{}},
+		{"down", downBroker{mb.New()}, "?queue=emails", http.StatusServiceUnavailable, Metric{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			sc := newScaler(t, c.broker, Options{TargetDepth: 50})
+
+			w := httptest.NewRecorder()
+			sc.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/scaler"+c.query, nil))
+			if w.Code != c.code {
+				t.Fatalf("expected status %d, got %d: %s", c.code, w.Code, w.Body)
+			}
+			if c.code != http.StatusOK {
+				return
+			}
+
+			var m Metric
+			if err := json.NewDecoder(w.Body).Decode(&m); err != nil {
+				t.Fatal(err)
+			}
+			if m != c.metric {
+				t.Fatalf("expected metric %+v, got %+v", c.metric, m)
+			}
+		})
+	}
+}
+
+// dial serves the scaler's gRPC service on an in-memory listener, and returns a client of it.
+func dial(t *testing.T, sc *Scaler) externalscaler.ExternalScalerClient {
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	externalscaler.RegisterExternalScalerServer(g, sc.GRPC())
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return externalscaler.NewExternalScalerClient(conn)
+}
+
+func TestGRPC(t *testing.T) {
+	ctx := context.Background()
+	b := mb.New()
+	cl := dial(t, newScaler(t, b, Options{TargetDepth: 50, ActivationDepth: 2, PollInterval: time.Millisecond * 10}))
+	ref := &externalscaler.ScaledObjectRef{Name: "workers", ScalerMetadata: map[string]string{QueuesMetadata: "emails, reports"}}
+
+	spec, err := cl.GetMetricSpec(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.MetricSpecs) != 1 || spec.MetricSpecs[0].MetricName != MetricName || spec.MetricSpecs[0].TargetSize != 50 {
+		t.Fatalf("unexpected metric spec %+v", spec.MetricSpecs)
+	}
+
+	m, err := cl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{ScaledObjectRef: ref, MetricName: "s0-" + MetricName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.MetricValues) != 1 || m.MetricValues[0].MetricName != "s0-"+MetricName || m.MetricValues[0].MetricValue != 3 {
+		t.Fatalf("unexpected metric values %+v", m.MetricValues)
+	}
+
+	a, err := cl.IsActive(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Result {
+		t.Fatal("expected the scaler to be active")
+	}
+
+	// The stream sends the initial state, then its changes.
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := cl.StreamIsActive(sctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := stream.Recv(); err != nil || !r.Result {
+		t.Fatalf("expected the stream to be active, got %v, %v", r, err)
+	}
+	work := make(chan []byte, 3)
+	wctx, wcancel := context.WithCancel(ctx)
+	go b.Consume(wctx, work, "emails")
+	for i := 0; i < 2; i++ {
+		<-work
+	}
+	wcancel()
+	if r, err := stream.Recv(); err != nil || r.Result {
+		t.Fatalf("expected the stream to be inactive, got %v, %v", r, err)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	ctx := context.Background()
+	ref := &externalscaler.ScaledObjectRef{ScalerMetadata: map[string]string{QueuesMetadata: "emails"}}
+
+	for _, c := range []struct {
+		name   string
+		broker tasqueue.Broker
+		code   codes.Code
+	}{
+		{"unsupported", plainBroker{mb.New()}, codes.Unimplemented},
+		{"down", downBroker{mb.New()}, codes.Unavailable},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cl := dial(t, newScaler(t, c.broker, Options{}))
+
+			if _, err := cl.IsActive(ctx, ref); status.Code(err) != c.code {
+				t.Fatalf("expected code %v, got %v", c.code, err)
+			}
+			if _, err := cl.GetMetrics(ctx, &externalscaler.GetMetricsRequest{ScaledObjectRef: ref}); status.Code(err) != c.code {
+				t.Fatalf("expected code %v, got %v", c.code, err)
+			}
+		})
+	}
+}
@@ -2,21 +2,22 @@ package tasqueue
 
 import (
 	"context"
+	"sync"
 
-	"github.com/zerodha/logf"
+	"github.com/robfig/cron/v3"
 )
 
 // scheduledJob holds the broker & results interfaces required to enqeueue a task.
 // It has a Run() method that enqeues the task. This method is called by the cron scheduler.
 type scheduledJob struct {
-	log    logf.Logger
+	log    levelLogger
 	ctx    context.Context
 	broker Broker
 	msg    JobMessage
 }
 
 // newScheduled accepts a broker, a byte message and job options
-func newScheduled(ctx context.Context, log logf.Logger, b Broker, msg JobMessage) *scheduledJob {
+func newScheduled(ctx context.Context, log levelLogger, b Broker, msg JobMessage) *scheduledJob {
 	return &scheduledJob{
 		log:    log,
 		ctx:    ctx,
@@ -34,3 +35,55 @@ func (s *scheduledJob) Run() {
 	}
 
 }
+
+// scheduler runs the scheduled jobs on their cron schedules, timed by the server's clock.
+// Jobs added before the scheduler is started are run once it starts.
+type scheduler struct {
+	clock   Clock
+	once    sync.Once
+	started chan struct{}
+}
+
+func newScheduler(c Clock) *scheduler {
+	return &scheduler{clock: c, started: make(chan struct{})}
+}
+
+func (s *scheduler) start() {
+	s.once.Do(func() {
+		close(s.started)
+	})
+}
+
+// running returns true if the scheduler has been started.
+func (s *scheduler) running() bool {
+	select {
+	case <-s.started:
+		return true
+	default:
+		return false
+	}
+}
+
+// add parses the cron spec (the standard 5 field syntax, or a descriptor like @every 1h)
+// and runs the job on its schedule, on the days the calendar (if any) allows.
+func (s *scheduler) add(spec string, cal Calendar, j cron.Job) error {
+	sch, err := parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-s.started
+		for {
+			now := s.clock.Now()
+			next := nextRun(sch, cal, now)
+			if next.IsZero() {
+				return
+			}
+			<-s.clock.After(next.Sub(now))
+			j.Run()
+		}
+	}()
+
+	return nil
+}
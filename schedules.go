@@ -0,0 +1,156 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	schedulePrefix = "tasqueue:schedule:"
+	// maxScheduleRuns is the number of the latest runs kept in a schedule's history.
+	maxScheduleRuns = 100
+
+	kindChain = "chain"
+	kindGroup = "group"
+)
+
+// ScheduleMessage is the run history of a chain or group enqueued on a cron schedule.
+// A ScheduleMessage is stored in the results store.
+type ScheduleMessage struct {
+	UUID string
+	Spec string
+	// Calendar is the name of the calendar on whose days the schedule runs, if any.
+	Calendar string
+	// Kind is the kind of the scheduled unit, "chain" or "group".
+	Kind string
+	// Runs are the UUIDs of the chains (or groups) enqueued on the schedule, oldest first.
+	// Only the latest runs are kept.
+	Runs []string
+	// LastRunAt is the time of the latest run, and LastErr its error if it couldn't be enqueued.
+	LastRunAt time.Time
+	LastErr   string
+}
+
+// scheduledUnit enqueues a chain or group on each run of its schedule, and records the run.
+type scheduledUnit struct {
+	srv     *Server
+	ctx     context.Context
+	uuid    string
+	enqueue func(context.Context) (string, error)
+}
+
+// Run() lets scheduledUnit implement the cron job interface.
+func (u *scheduledUnit) Run() {
+	uuid, err := u.enqueue(u.ctx)
+	if err != nil {
+		u.srv.log.Error("could not enqueue scheduled run", "schedule", u.uuid, "error", err)
+	}
+	if err := u.srv.recordRun(u.ctx, u.uuid, uuid, err); err != nil {
+		u.srv.log.Error("could not record scheduled run", "schedule", u.uuid, "error", err)
+	}
+}
+
+// ScheduleChain() enqueues the chain on the cron schedule, eg: a nightly pipeline, and returns
+// the schedule's UUID. The runs of the chain are linked on the schedule (see GetSchedule()).
+func (s *Server) ScheduleChain(ctx context.Context, spec string, c Chain) (string, error) {
+	return s.scheduleUnit(ctx, spec, c.Calendar, kindChain, c.Jobs, func(ctx context.Context) (string, error) {
+		return s.EnqueueChain(ctx, c)
+	})
+}
+
+// ScheduleGroup() enqueues the group on the cron schedule, and returns the schedule's UUID.
+// The runs of the group are linked on the schedule (see GetSchedule()).
+func (s *Server) ScheduleGroup(ctx context.Context, spec string, g Group) (string, error) {
+	return s.scheduleUnit(ctx, spec, g.Calendar, kindGroup, g.Jobs, func(ctx context.Context) (string, error) {
+		return s.EnqueueGroup(ctx, g)
+	})
+}
+
+// GetSchedule() returns the run history of a scheduled chain or group.
+func (s *Server) GetSchedule(ctx context.Context, uuid string) (ScheduleMessage, error) {
+	if s.results == nil {
+		return ScheduleMessage{}, ErrNoResults
+	}
+
+	b, err := s.results.Get(ctx, schedulePrefix+uuid)
+	if err != nil {
+		return ScheduleMessage{}, err
+	}
+
+	var m ScheduleMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return ScheduleMessage{}, err
+	}
+
+	return m, nil
+}
+
+// scheduleUnit validates the jobs of the chain or group and adds it to the scheduler, to run
+// on the days of the named calendar, if any.
+func (s *Server) scheduleUnit(ctx context.Context, spec, calendar, kind string, jobs []Job, enqueue func(context.Context) (string, error)) (string, error) {
+	// The runs are tracked on the results store.
+	if s.results == nil {
+		return "", ErrNoResults
+	}
+	if !s.mode.schedules() {
+		return "", fmt.Errorf("could not schedule %s : %w", kind, ErrNoScheduler)
+	}
+	for _, j := range jobs {
+		if j.Opts.Schedule != "" {
+			return "", fmt.Errorf("could not schedule %s : jobs of a scheduled %s can not be scheduled", kind, kind)
+		}
+		if err := s.validateJob(j); err != nil {
+			return "", fmt.Errorf("could not schedule %s : %w", kind, err)
+		}
+	}
+	if err := ValidateSchedule(spec); err != nil {
+		return "", fmt.Errorf("could not schedule %s : %w", kind, err)
+	}
+	cal, err := s.calendar(calendar)
+	if err != nil {
+		return "", fmt.Errorf("could not schedule %s : %w", kind, err)
+	}
+
+	m := ScheduleMessage{UUID: s.ids.NewID(s.clock.Now()), Spec: spec, Calendar: calendar, Kind: kind}
+	if err := s.setScheduleMessage(ctx, m); err != nil {
+		return "", err
+	}
+	if err := s.sched.add(spec, cal, &scheduledUnit{srv: s, ctx: ctx, uuid: m.UUID, enqueue: enqueue}); err != nil {
+		return "", err
+	}
+	s.recordAudit(ctx, OpSchedule, m.UUID, kind+" "+spec)
+
+	return m.UUID, nil
+}
+
+// recordRun records the run of the schedule, which enqueued the chain or group of the UUID,
+// or failed with the error.
+func (s *Server) recordRun(ctx context.Context, schedule, uuid string, err error) error {
+	m, gerr := s.GetSchedule(ctx, schedule)
+	if gerr != nil {
+		return gerr
+	}
+
+	m.LastRunAt = s.clock.Now()
+	m.LastErr = ""
+	if err != nil {
+		m.LastErr = err.Error()
+	} else {
+		m.Runs = append(m.Runs, uuid)
+		if len(m.Runs) > maxScheduleRuns {
+			m.Runs = m.Runs[len(m.Runs)-maxScheduleRuns:]
+		}
+	}
+
+	return s.setScheduleMessage(ctx, m)
+}
+
+func (s *Server) setScheduleMessage(ctx context.Context, m ScheduleMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.results.Set(ctx, schedulePrefix+m.UUID, b)
+}
@@ -0,0 +1,82 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestScheduleChain(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, Mode: ModeScheduler})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jobs []Job
+	for _, task := range []string{"extract", "transform", "load"} {
+		j, err := NewJob(task, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, j)
+	}
+	chn, err := NewChain(jobs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.ScheduleChain(ctx, "not a spec", chn); err == nil {
+		t.Fatal("expected an invalid spec to be rejected")
+	}
+	uuid, err := srv.ScheduleChain(ctx, "@daily", chn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.sched.start()
+
+	// Each run enqueues the chain's first job, and is linked on the schedule.
+	for i := 1; i <= 2; i++ {
+		clock.wait(t)
+		clock.advance(time.Hour * 24)
+
+		var msg JobMessage
+		select {
+		case b := <-broker.data:
+			if err := msgpack.Unmarshal(b, &msg); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the chain to be enqueued")
+		}
+		if msg.Job.Task != "extract" {
+			t.Fatalf("expected the chain's first job to be enqueued, got %s", msg.Job.Task)
+		}
+
+		var sch ScheduleMessage
+		for j := 0; j < 100; j++ {
+			if sch, err = srv.GetSchedule(ctx, uuid); err != nil {
+				t.Fatal(err)
+			}
+			if len(sch.Runs) == i {
+				break
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		if sch.Kind != "chain" || len(sch.Runs) != i {
+			t.Fatalf("expected %d runs of the scheduled chain, got %+v", i, sch)
+		}
+		c, err := srv.GetChain(ctx, sch.Runs[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.JobUUID != msg.UUID {
+			t.Fatalf("expected the run to be the enqueued chain, got %+v", c)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// queryBatchSize is the number of uuid's fetched from the index at once while searching.
+const queryBatchSize = 100
+
+// ErrIndexUnsupported is returned by the operations which query the job indexes (eg: GetJobs(),
+// retention and archiving), if the results store doesn't implement Indexer.
+var ErrIndexUnsupported = errors.New("results store doesn't support job indexes")
+
+// JobFilter matches job messages. Empty fields match all jobs.
+type JobFilter struct {
+	Status string
+	// Statuses match jobs in any of the statuses (or in Status), eg: for dashboards of
+	// the failed and retrying jobs.
+	Statuses []string
+	Task     string
+	Queue    string
+	// Labels match jobs that have all the labels.
+	Labels map[string]string
+	// Error matches jobs whose error contains the substring.
+	Error string
+	// Since and Until match the time at which the job was last processed.
+	Since time.Time
+	Until time.Time
+
+	// Offset is the number of matching jobs to skip, and Limit is the maximum number of
+	// matching jobs returned (0 returns all). Jobs are sorted by enqueue time, newest
+	// first if Desc is set. These are only used by GetJobs().
+	Offset int
+	Limit  int
+	Desc   bool
+	// Cursor, if set, is the UUID of the last job of the previous page, after which the
+	// matching jobs are returned. Unlike Offset, jobs enqueued or removed in between don't
+	// shift the pages.
+	Cursor string
+}
+
+func (f JobFilter) match(m JobMessage) bool {
+	switch {
+	case (f.Status != "" || len(f.Statuses) > 0) && m.Status != f.Status && !contains(f.Statuses, m.Status),
+		f.Task != "" && (m.Job == nil || m.Job.Task != f.Task),
+		f.Queue != "" && m.Queue != f.Queue,
+		f.Error != "" && !strings.Contains(m.PrevErr, f.Error),
+		!f.Since.IsZero() && m.ProcessedAt.Before(f.Since),
+		!f.Until.IsZero() && m.ProcessedAt.After(f.Until):
+		return false
+	}
+	for k, v := range f.Labels {
+		if m.Labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexKeys returns the keys of the secondary indexes a job is added to.
+func indexKeys(msg JobMessage) []string {
+	keys := []string{"queue:" + msg.Queue}
+	if msg.Job != nil {
+		keys = append(keys, "task:"+msg.Job.Task)
+	}
+
+	return keys
+}
+
+// indexJob adds the job to the job indexes on the results store. Jobs aren't indexed on
+// stores which don't implement Indexer.
+func (s *Server) indexJob(ctx context.Context, msg JobMessage) error {
+	if s.results == nil {
+		return nil
+	}
+
+	i, ok := s.results.(Indexer)
+	if !ok {
+		return nil
+	}
+	if err := i.IndexJob(ctx, msg.UUID, msg.EnqueuedAt, indexKeys(msg)); err != nil && !errors.Is(err, ErrIndexUnsupported) {
+		return err
+	}
+
+	return nil
+}
+
+// unindexJob removes the job from the job indexes, if the store implements Indexer.
+func unindexJob(ctx context.Context, r Results, uuid string, keys []string) error {
+	if i, ok := r.(Indexer); ok {
+		return i.UnindexJob(ctx, uuid, keys)
+	}
+	return ErrIndexUnsupported
+}
+
+// queryJobs queries the job index of the key, if the store implements Indexer.
+func queryJobs(ctx context.Context, r Results, key string, from, to time.Time, offset, limit int, desc bool) ([]string, error) {
+	if i, ok := r.(Indexer); ok {
+		return i.QueryJobs(ctx, key, from, to, offset, limit, desc)
+	}
+	return nil, ErrIndexUnsupported
+}
+
+// GetJobs() returns the job messages matching the filter, eg: to list the jobs of several
+// statuses for a dashboard. The most selective index (task, then queue) is scanned, and the
+// jobs in it are matched against the rest of the filter.
+func (s *Server) GetJobs(ctx context.Context, f JobFilter) ([]JobMessage, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
+
+	var key string
+	switch {
+	case f.Task != "":
+		key = "task:" + f.Task
+	case f.Queue != "":
+		key = "queue:" + f.Queue
+	}
+
+	var (
+		out     []JobMessage
+		skipped int
+		// Jobs processed before Until were enqueued before it, hence it bounds the index.
+		from, to = time.Time{}, f.Until
+		// seek is true until the cursor is passed.
+		seek = f.Cursor != ""
+	)
+	// The index is scanned from the cursor's enqueue time, past the jobs enqueued at the same
+	// time before it.
+	if seek {
+		c, err := s.GetJob(ctx, f.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("could not get cursor job %s : %w", f.Cursor, err)
+		}
+		if !f.Desc {
+			from = c.EnqueuedAt
+		} else if to.IsZero() || c.EnqueuedAt.Before(to) {
+			to = c.EnqueuedAt
+		}
+	}
+	for offset := 0; ; offset += queryBatchSize {
+		uuids, err := queryJobs(ctx, s.results, key, from, to, offset, queryBatchSize, f.Desc)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uuid := range uuids {
+			if seek {
+				seek = uuid != f.Cursor
+				continue
+			}
+			msg, err := s.GetJob(ctx, uuid)
+			if err != nil {
+				return nil, err
+			}
+			if !f.match(msg) {
+				continue
+			}
+			if skipped < f.Offset {
+				skipped++
+				continue
+			}
+
+			out = append(out, msg)
+			if f.Limit > 0 && len(out) == f.Limit {
+				return out, nil
+			}
+		}
+
+		if len(uuids) < queryBatchSize {
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetJobs(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+	go srv.Start(ctx)
+
+	var uuids []string
+	for _, f := range []bool{false, true, true, true} {
+		job := makeJob(t, f)
+		job.Opts.Labels = map[string]string{"failing": "no"}
+		if f {
+			job.Opts.Labels["failing"] = "yes"
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	// Wait for jobs to be consumed & processed.
+	time.Sleep(time.Second)
+
+	msgs, err := srv.GetJobs(ctx, JobFilter{Task: taskName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 jobs, got %d", len(msgs))
+	}
+
+	msgs, err = srv.GetJobs(ctx, JobFilter{
+		Status: StatusFailed,
+		Labels: map[string]string{"failing": "yes"},
+		Error:  "error",
+		Offset: 1,
+		Limit:  1,
+		Desc:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].UUID != uuids[2] {
+		t.Fatalf("expected job %s, got %v", uuids[2], msgs)
+	}
+
+	// Jobs of any of the statuses are matched.
+	msgs, err = srv.GetJobs(ctx, JobFilter{Statuses: []string{StatusFailed, StatusDone}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 jobs, got %d", len(msgs))
+	}
+
+	// Pages follow the cursor.
+	for _, desc := range []bool{false, true} {
+		var (
+			f    = JobFilter{Task: taskName, Limit: 3, Desc: desc}
+			seen []string
+		)
+		for {
+			msgs, err := srv.GetJobs(ctx, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) == 0 {
+				break
+			}
+			for _, m := range msgs {
+				seen = append(seen, m.UUID)
+			}
+			f.Cursor = msgs[len(msgs)-1].UUID
+		}
+		if len(seen) != len(uuids) {
+			t.Fatalf("expected the pages to list %d jobs, got %d", len(uuids), len(seen))
+		}
+		for i := range seen {
+			exp := uuids[i]
+			if desc {
+				exp = uuids[len(uuids)-1-i]
+			}
+			if seen[i] != exp {
+				t.Fatalf("expected the pages to list %v in order (desc: %v), got %v", uuids, desc, seen)
+			}
+		}
+	}
+}
@@ -2,11 +2,13 @@ package tasqueue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
-	"github.com/robfig/cron/v3"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/google/uuid"
 	"github.com/vmihailenco/msgpack/v5"
 	"github.com/zerodha/logf"
 	"go.opentelemetry.io/otel"
@@ -16,7 +18,8 @@ import (
 )
 
 const (
-	defaultConcurrency = 1
+	defaultConcurrency   = 1
+	defaultRefreshPeriod = time.Second * 5
 
 	// This is the initial state when a job is pushed onto the broker.
 	StatusStarted = "queued"
@@ -34,6 +37,24 @@ const (
 	// This state is analogous to statusStarted.
 	StatusRetrying = "retrying"
 
+	// The state when a job is picked up by a worker after its expiry time.
+	// Expired jobs are not executed.
+	StatusExpired = "expired"
+
+	// The state when a job is picked up by a worker after its deadline.
+	// Missed jobs are not executed.
+	StatusMissed = "missed"
+
+	// The state when a job is cancelled before it is processed.
+	StatusCancelled = "cancelled"
+
+	// The state when a job is held on its gate, until the gate is opened or its TTL passes.
+	StatusHeld = "held"
+
+	// The state when a job is dropped off its queue to make room for newer jobs.
+	// Dropped jobs are not executed.
+	StatusDropped = "dropped"
+
 	// name used to identify this instrumentation library.
 	tracer = "tasqueue"
 )
@@ -50,11 +71,120 @@ type Task struct {
 	handler handler
 
 	opts TaskOpts
+	// state is the state set up by OnWorkerStart, while the task is started.
+	state any
+	// labels are the server's labels, whose label queues of the task's queues are consumed.
+	labels map[string]string
+	// worker is the server's worker ID, whose worker queues of the task's queues are consumed
+	// if the task is pinnable.
+	worker string
 }
 
 type TaskOpts struct {
-	Concurrency  uint32
-	Queue        string
+	Concurrency uint32
+	Queue       string
+
+	// QueuePattern, if set, consumes all the queues matching the pattern (path.Match syntax) instead
+	// of Queue. Matching queues are looked up periodically and are served in a round-robin manner.
+	QueuePattern string
+
+	// HashQueues consistently hashes the queues matching QueuePattern across the live workers
+	// running the task, each of which consumes only the queues hashed to it, so that the same
+	// queues land on the same workers, eg: for handlers caching per-queue state. Membership is
+	// by heartbeats, hence it requires ServerOpts.HeartbeatPeriod and a results store. When a
+	// worker joins or leaves, only its queues move, once the workers refresh their queues.
+	HashQueues bool
+
+	// Pinnable consumes the server's worker queues (WorkerQueue()) of the task's queues, onto
+	// which the jobs pinned to the server are enqueued (JobOpts.Worker, JobCtx.ThenPinned()).
+	// It isn't applied to queue patterns.
+	Pinnable bool
+
+	// Tenants, if set, are the tenants whose namespaced queues are consumed instead of Queue.
+	// Each tenant's queue is consumed by its own set of Concurrency processors.
+	Tenants []string
+
+	// MaxRetries and Timeout are the defaults applied to jobs of this task at enqueue,
+	// unless they are set on the job's options. A job's retries are disabled with NoRetries.
+	MaxRetries uint32
+	Timeout    time.Duration
+	// DedupWindow is the default dedup window of the task's jobs (JobOpts.DedupWindow).
+	DedupWindow time.Duration
+
+	// Version is the version of the task's handler. Multiple versions of a task can be
+	// registered, and jobs are processed by the handler of the job's version.
+	Version string
+
+	// Singleton runs at most one job of the task at a time across the servers sharing the
+	// Locker. Jobs consumed while another job of the task is running are held back.
+	Singleton bool
+
+	// ConcurrencyGroup is the concurrency group (of ServerOpts.ConcurrencyGroups) whose combined
+	// limit of concurrent jobs on the server is shared by the tasks in the group.
+	ConcurrencyGroup string
+
+	// Cost is the cost (eg: the memory or CPU weight) of the task's jobs, counted towards
+	// ServerOpts.MaxCost. Defaults to 1.
+	Cost uint32
+
+	// Priority is the priority of the task's jobs waiting for the server's max cost. Jobs of a
+	// higher priority are served first, and preempt the running jobs of Preemptible tasks of
+	// a lower priority if their cost doesn't fit. Preempted jobs are requeued without counting
+	// the attempt, and their handler's context is cancelled (see JobCtx.Preempted).
+	Priority    int
+	Preemptible bool
+
+	// Prefetch is the number of the task's jobs consumed ahead of its processors, per queue,
+	// which are processed in the order of their deadlines (earliest first). It isn't applied
+	// on ordered queues.
+	Prefetch int
+
+	// RetryQueue, if set, is the lower priority queue onto which the task's jobs are demoted
+	// when they're retried, after DemoteAfter retries on their queue, so that failing retries
+	// don't starve fresh jobs. The task consumes the retry queue with RetryConcurrency
+	// processors (default: 1), in addition to its queues.
+	RetryQueue       string
+	DemoteAfter      uint32
+	RetryConcurrency uint32
+
+	// RetryBackoff, if set, delays the retries of the task's jobs, doubling from the backoff
+	// with each retry upto MaxRetryBackoff (default: an hour). The retries are held on the
+	// results store if it implements Delayer, and in memory otherwise.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Canary, if its sample is set, registers the task's version as a canary, which processes
+	// a sample of the task's unversioned jobs in place of (or in the shadow of) the task's other
+	// versions. See CanaryOpts.
+	Canary CanaryOpts
+
+	// Routes, if set, enqueues follow-up tasks with the results of the task's jobs once they
+	// succeed or fail. See Routes.
+	Routes Routes
+
+	// Validate, if set, validates the payloads of the task's jobs (eg: against a schema, see
+	// ValidateJSON()), at enqueue and before the handler is called, as set by ValidateMode.
+	// Invalid payloads are rejected at enqueue, and fail the job without retries before the
+	// handler is called, with a *ValidationError (ErrInvalidPayload).
+	Validate     func(payload []byte) error
+	ValidateMode ValidateMode
+
+	// StreamPayload streams the payloads offloaded onto the blob store (eg: by EnqueueStream())
+	// to the task's handlers, which read them with JobCtx.PayloadReader(), instead of passing
+	// them to the handlers in memory.
+	StreamPayload bool
+
+	// Sandbox gives each job of the task a temporary working directory (JobCtx.Dir), which is
+	// removed once the job completes or fails, including its callbacks.
+	Sandbox bool
+
+	// OnWorkerStart, if set, is called when the task is started on the server, to set up
+	// expensive resources (eg: DB pools, ML models) once rather than per job. The returned
+	// state is passed to the task's handlers (see JobCtx.State). If it fails, the task isn't
+	// started and is unregistered. OnWorkerStop is called with the state once the task's processors have exited.
+	OnWorkerStart func(ctx context.Context) (any, error)
+	OnWorkerStop  func(state any)
+
 	SuccessCB    func(JobCtx)
 	ProcessingCB func(JobCtx)
 	RetryingCB   func(JobCtx)
@@ -64,7 +194,7 @@ type TaskOpts struct {
 // RegisterTask maps a new task against the tasks map on the server.
 // It accepts different options for the task (to set callbacks).
 func (s *Server) RegisterTask(name string, fn handler, opts TaskOpts) {
-	s.log.Info("added handler", "name", name)
+	s.log.Info("added handler", "name", name, "version", opts.Version)
 
 	if opts.Concurrency <= 0 {
 		opts.Concurrency = defaultConcurrency
@@ -72,21 +202,122 @@ func (s *Server) RegisterTask(name string, fn handler, opts TaskOpts) {
 	if opts.Queue == "" {
 		opts.Queue = DefaultQueue
 	}
+	if opts.RetryQueue == opts.Queue {
+		opts.RetryQueue = ""
+	}
+	if opts.RetryQueue != "" && opts.RetryConcurrency == 0 {
+		opts.RetryConcurrency = 1
+	}
+	if opts.RetryBackoff > 0 && opts.MaxRetryBackoff == 0 {
+		opts.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+	if opts.HashQueues && (opts.QueuePattern == "" || !s.leasesEnabled()) {
+		s.log.Warn("hashing queues requires a queue pattern and heartbeats, all queues are consumed", "name", name)
+		opts.HashQueues = false
+	}
+	if _, ok := s.cgroups[opts.ConcurrencyGroup]; opts.ConcurrencyGroup != "" && !ok {
+		s.log.Warn("concurrency group not configured, task is not limited", "name", name, "group", opts.ConcurrencyGroup)
+	}
 
-	s.registerHandler(name, Task{name: name, handler: fn, opts: opts})
+	s.registerHandler(Task{name: name, handler: fn, opts: opts, labels: s.labels, worker: s.workerID})
 }
 
 // Server is the main store that holds the broker and the results communication interfaces.
 // It also stores the registered tasks.
 type Server struct {
-	log       logf.Logger
+	log       levelLogger
 	broker    Broker
 	results   Results
-	cron      *cron.Cron
+	leaser    Leaser
+	sched     *scheduler
 	traceProv *trace.TracerProvider
+	sampling  TraceSampling
+	metrics   *metrics.Set
+
+	// strict rejects enqueuing jobs of tasks that aren't registered.
+	strict         bool
+	maxPayloadSize int
+	payloadPolicy  PayloadPolicy
+	blobs          BlobStore
+	tenantQueues   bool
+	tenants        map[string]*tenantLimiter
+	refreshPeriod  time.Duration
+	codec          Codec
+	propagator     Propagator
+	workerID       string
+	heartbeat      time.Duration
+	limiter        RateLimiter
+	rate           float64
+	queueRates     map[string]float64
+	cache          *lruCache
+	handlerCache   *HandlerCache
+	deps           *providers
+	clock          Clock
+	ids            IDGenerator
+	sandboxDir     string
+	mode           Mode
+	name           string
+	unknown        UnknownTaskOpts
+	windows        map[string][]window
+	ordered        map[string]struct{}
+	locker         Locker
+	sharedLocker   bool
+	cgroups        map[string]chan struct{}
+	costs          *costLimiter
+	fair           *fairShare
+	labels         map[string]string
+	schemas        map[string]ResultSchema
+	races          *raceDetector
+	calendars      map[string]Calendar
+	stats          bool
 
 	p     sync.RWMutex
 	tasks map[string]Task
+	// runCtx is set while the server is running, to start the tasks registered meanwhile.
+	runCtx context.Context
+	// stops holds the functions which stop the consumers of the running tasks.
+	stops map[string]context.CancelFunc
+	wg    sync.WaitGroup
+
+	// releases holds the gate releases scheduled on the run loop, which is notified on released.
+	gmu      sync.Mutex
+	releases map[gateRelease]struct{}
+	released chan struct{}
+
+	lmu       sync.RWMutex
+	listeners []func(Event)
+	// expiredCBs are called with the chains which expired past their deadline.
+	expiredCBs []func(ChainMessage)
+
+	// waiters holds the channels of the GetJobBlocking() calls waiting on each job.
+	wmu     sync.Mutex
+	waiters map[string][]chan struct{}
+
+	// draining holds the queues being drained, inflight the number of each queue's jobs
+	// that are being processed or held back, and running the jobs being processed.
+	qmu      sync.Mutex
+	draining map[string]struct{}
+	inflight map[string]int
+	running  map[string]JobMessage
+	// consumers is the number of running consumers of each started task.
+	consumers map[string]int
+	preempts  preemptibles
+
+	usage       *usageTracker
+	usagePeriod time.Duration
+
+	// rmu guards the rates, which can be reloaded while the server is running, and pauses
+	// holds the queues whose consumption is paused.
+	rmu    sync.RWMutex
+	pauses *pauses
+
+	delayedPeriod time.Duration
+
+	limits map[string]QueueLimit
+
+	reducers   map[string]Reducer
+	authorizer Authorizer
+	audit      AuditSink
 }
 
 type ServerOpts struct {
@@ -94,6 +325,173 @@ type ServerOpts struct {
 	Results       Results
 	Logger        logf.Logger
 	TraceProvider *trace.TracerProvider
+	// TraceSampling, if set, traces a fraction of the processed jobs, instead of all of them.
+	TraceSampling TraceSampling
+
+	// StrictEnqueue rejects jobs whose task isn't registered on the server.
+	StrictEnqueue bool
+	// MaxPayloadSize is the maximum size (in bytes) of a job's payload. Larger payloads are
+	// handled at enqueue according to the PayloadPolicy. If it is zero, payloads are not size checked.
+	MaxPayloadSize int
+	PayloadPolicy  PayloadPolicy
+	// BlobStore stores the payloads offloaded with the PayloadOffload policy.
+	BlobStore BlobStore
+
+	// TenantQueues enqueues jobs with a tenant onto the tenant's namespaced queue.
+	TenantQueues bool
+	// TenantQuotas is a map of tenant -> quota enforced on processing the tenant's jobs.
+	TenantQuotas map[string]TenantQuota
+
+	// QueueRefreshPeriod is the interval at which queues matching a task's queue pattern are looked up.
+	QueueRefreshPeriod time.Duration
+
+	// ResultCodec encodes the named results saved by jobs. Defaults to JSON.
+	ResultCodec Codec
+
+	// Propagator carries values from the context of Enqueue into the handler's context.
+	Propagator Propagator
+
+	// Name identifies the server, among multiple servers in a process (eg: hosting isolated
+	// groups of tasks). It's logged by the default logger, labels the server's metrics (as
+	// server) and prefixes the default WorkerID.
+	Name string
+
+	// WorkerID identifies the server in heartbeats and the leases of its in-flight jobs.
+	// Defaults to a random UUID.
+	WorkerID string
+	// HeartbeatPeriod is the interval at which the server records its heartbeat. If it is
+	// set, in-flight jobs are leased to the server, so that RecoverJobs() can recover them
+	// if the server dies. It requires a results store that implements Leaser.
+	HeartbeatPeriod time.Duration
+
+	// Rate is the maximum number of jobs started per second by the server, and QueueRates
+	// is a map of queue -> maximum jobs started per second from the queue. Jobs over the rate
+	// are pushed back onto the queue. The rates are enforced by the RateLimiter, which defaults
+	// to a limiter local to the server. A distributed limiter enforces them across servers.
+	Rate        float64
+	QueueRates  map[string]float64
+	RateLimiter RateLimiter
+
+	// Cache caches the job messages and results read from the results store.
+	Cache CacheOpts
+	// HandlerCache is the size and TTL of the cache shared by the handlers on the server
+	// (JobCtx.Cache()). If its size is zero, there's no cache.
+	HandlerCache CacheOpts
+
+	// Clock tells the time. Defaults to the system clock.
+	Clock Clock
+
+	// UnknownTask configures the handling of jobs whose task isn't registered on the server.
+	UnknownTask UnknownTaskOpts
+
+	// Windows is a map of queue -> windows during which the queue isn't consumed by the server.
+	Windows map[string][]Window
+
+	// OrderedQueues are processed in strict FIFO order, by one job at a time across the servers
+	// sharing the Locker, which defaults to a locker local to the server. The queues' tasks are
+	// processed with a concurrency of one, and failed jobs are retried in place. The Locker
+	// also provides the locks of singleton tasks, gates and dedup windows. Deduplication
+	// requires the Locker to be set, as a local locker doesn't dedup across servers.
+	OrderedQueues []string
+	Locker        Locker
+
+	// Labels are the labels of the server (eg: region=eu, gpu=true). Jobs which require labels
+	// (JobOpts.Requires) are only processed by the servers that have them. Besides its tasks'
+	// queues, the server consumes their label queues for each combination of its labels.
+	Labels map[string]string
+
+	// FairQueues are shared by multiple tasks, whose jobs are interleaved so that each task
+	// gets a fair share of the worker time, instead of a chatty task starving the others.
+	// Each consumer of the queues buffers up to FairBuffer jobs (default: 16), and dispatches
+	// the job of the task which has had the least worker time on the queue first.
+	FairQueues []string
+	FairBuffer int
+
+	// ConcurrencyGroups is a map of group -> maximum number of jobs of the tasks in the group
+	// (TaskOpts.ConcurrencyGroup) processed concurrently by the server.
+	ConcurrencyGroups map[string]int
+
+	// MaxCost caps the total cost (TaskOpts.Cost) of the jobs processed concurrently by the
+	// server, eg: so that a memory-heavy job and many light jobs can run side by side. Jobs
+	// wait for their cost to fit. If it is zero, the cost isn't capped.
+	MaxCost int
+
+	// Signer signs the messages enqueued onto the broker. If Verifier is set, consumed
+	// messages whose signature doesn't verify (or which aren't signed) are rejected.
+	Signer   Signer
+	Verifier Verifier
+
+	// Cipher encrypts the messages enqueued onto the broker, and decrypts the messages
+	// consumed from it. Messages that can't be decrypted are rejected.
+	Cipher Cipher
+
+	// Mode is the set of roles run by the started server. Defaults to ModeAll, which runs the
+	// cron scheduler and processes jobs.
+	Mode Mode
+
+	// SandboxDir is the directory in which the working directories of the jobs of sandboxed
+	// tasks are created. Defaults to the OS's temporary directory.
+	SandboxDir string
+
+	// IDGenerator generates the IDs of the enqueued jobs, groups and chains. Defaults to
+	// random UUIDs. ULID generates IDs that sort by enqueue time.
+	IDGenerator IDGenerator
+
+	// UsagePeriod, if set, tracks the execution time, attempts and payload bytes of the jobs
+	// per task, queue and tenant, which are flushed onto the results store at the period and
+	// reported by UsageReport().
+	UsagePeriod time.Duration
+
+	// DelayedPeriod is the period at which the delayed jobs (JobOpts.ETA) and retries
+	// (TaskOpts.RetryBackoff) that are due are moved from the results store onto their
+	// queues. Defaults to a second.
+	DelayedPeriod time.Duration
+
+	// QueueLimits is a map of queue -> max length of the queue, and the handling of the jobs
+	// enqueued onto it once it's full.
+	QueueLimits map[string]QueueLimit
+
+	// Namespace, if set, prefixes the queue names on the broker, and the keys on the results
+	// store and rate limiter (as "namespace:"), so that multiple environments or applications
+	// can share a broker and results store.
+	Namespace string
+
+	// ResultSchemas is a map of task -> the version of the shape of the task's results, which
+	// the results saved by its jobs are tagged with, and the migrations which GetResult()
+	// upgrades the results saved with older versions with.
+	ResultSchemas map[string]ResultSchema
+
+	// Stats records the transitions of the jobs (enqueued, done, failed, retried) and the
+	// durations of their attempts onto the results store, which Stats() reports the counts
+	// and rates of. The store has to implement Counter.
+	Stats bool
+
+	// DetectRaces is a debug mode, which checks the shared state registered with WatchState()
+	// for mutations made while handlers run concurrently, eg: after raising a task's
+	// Concurrency. The mutated state and the tasks that ran concurrently are logged, and
+	// counted (tasqueue_state_races_total). It reads the state around each job, hence it's
+	// meant for debugging, not production.
+	DetectRaces bool
+
+	// Reducers is a map of name -> custom reducer, which chains and groups can combine their
+	// jobs' results with (Chain.Reduce, Group.Reduce), in addition to the builtin reducers.
+	Reducers map[string]Reducer
+
+	// Calendars is a map of name -> calendar, on whose days scheduled jobs, chains and groups
+	// can run (JobOpts.Calendar, Chain.Calendar, Group.Calendar), eg: BusinessDays with a
+	// holiday list, in addition to the builtin calendars.
+	Calendars map[string]Calendar
+
+	// Authorizer, if set, authorizes the admin operations on jobs (cancel, retry, resuming
+	// chains and pruning) with the caller set on the operation's context (WithCaller()).
+	Authorizer Authorizer
+
+	// AuditSink, if set, records the admin actions performed on the server (cancelling,
+	// retrying, resuming chains, pruning, draining queues and schedules), with the caller
+	// set on their context. AuditLog records them onto the results store instead, from where
+	// they're returned by GetAuditLog().
+	AuditSink AuditSink
+	AuditLog  bool
 }
 
 // NewServer() returns a new instance of server, with sane defaults.
@@ -101,166 +499,815 @@ func NewServer(o ServerOpts) (*Server, error) {
 	if o.Broker == nil {
 		return nil, fmt.Errorf("broker missing in options")
 	}
-	if o.Results == nil {
-		return nil, fmt.Errorf("results missing in options")
+	if o.PayloadPolicy == PayloadOffload && o.BlobStore == nil {
+		return nil, fmt.Errorf("blob store missing in options")
+	}
+	if o.UsagePeriod > 0 && o.Results == nil {
+		return nil, fmt.Errorf("results store missing in options")
 	}
 	if o.Logger.Level == 0 {
-		o.Logger = logf.New(logf.Opts{})
+		var fields []interface{}
+		if o.Name != "" {
+			fields = []interface{}{"server", o.Name}
+		}
+		o.Logger = logf.New(logf.Opts{DefaultFields: fields})
+	}
+	if o.QueueRefreshPeriod == 0 {
+		o.QueueRefreshPeriod = defaultRefreshPeriod
+	}
+	if o.DelayedPeriod == 0 {
+		o.DelayedPeriod = defaultDelayedPeriod
+	}
+	if o.ResultCodec == nil {
+		o.ResultCodec = JSONCodec{}
+	}
+	if o.WorkerID == "" {
+		o.WorkerID = uuid.NewString()
+		if o.Name != "" {
+			o.WorkerID = o.Name + "-" + o.WorkerID
+		}
+	}
+	if o.Clock == nil {
+		o.Clock = systemClock{}
+	}
+	windows, err := parseWindows(o.Windows)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseLimits(o.QueueLimits)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateLabels(o.Labels); err != nil {
+		return nil, err
+	}
+	if o.UnknownTask.Delay == 0 {
+		o.UnknownTask.Delay = defaultUnknownTaskDelay
+	}
+	if o.UnknownTask.MaxRequeues == 0 {
+		o.UnknownTask.MaxRequeues = defaultUnknownTaskRequeues
+	}
+	if o.UnknownTask.ParkQueue == "" {
+		o.UnknownTask.ParkQueue = DefaultParkQueue
+	}
+	if o.RateLimiter == nil {
+		o.RateLimiter = newLocalLimiter(o.Clock)
+	}
+	sharedLocker := o.Locker != nil
+	if o.Locker == nil {
+		o.Locker = newLocalLocker(o.Clock)
+	}
+	if o.IDGenerator == nil {
+		o.IDGenerator = uuidGenerator{}
+	}
+	if o.Namespace != "" {
+		o.Broker = nsBroker{Broker: o.Broker, ns: o.Namespace}
+		if o.Results != nil {
+			o.Results = nsResults{Results: o.Results, ns: o.Namespace}
+		}
+		o.RateLimiter = nsLimiter{RateLimiter: o.RateLimiter, ns: o.Namespace}
+		o.Locker = nsLocker{Locker: o.Locker, ns: o.Namespace}
+	}
+
+	if o.AuditSink == nil && o.AuditLog && o.Results != nil {
+		o.AuditSink = resultsAuditSink{results: o.Results}
+	}
+
+	set := metrics.NewSet()
+	if o.Signer != nil || o.Verifier != nil || o.Cipher != nil {
+		o.Broker = signedBroker{Broker: o.Broker, signer: o.Signer, verifier: o.Verifier, cipher: o.Cipher, log: o.Logger, metrics: set}
+	}
+
+	var usage *usageTracker
+	if o.UsagePeriod > 0 {
+		usage = newUsageTracker()
+	}
+
+	ordered := make(map[string]struct{}, len(o.OrderedQueues))
+	for _, q := range o.OrderedQueues {
+		ordered[q] = struct{}{}
+	}
+
+	tenants := make(map[string]*tenantLimiter, len(o.TenantQuotas))
+	for t, q := range o.TenantQuotas {
+		tenants[t] = newTenantLimiter(q, o.Clock)
+	}
+
+	// Heartbeats and leases are kept apart from the jobs' tags, if the store supports it.
+	var leaser Leaser
+	if o.Results != nil && supportsLeases(o.Results) {
+		leaser = o.Results.(Leaser)
+	} else if o.HeartbeatPeriod > 0 {
+		o.Logger.Warn("results store doesn't support leases, heartbeats are disabled")
 	}
 
 	return &Server{
-		traceProv: o.TraceProvider,
-		log:       o.Logger,
-		cron:      cron.New(),
-		broker:    o.Broker,
-		results:   o.Results,
-		tasks:     make(map[string]Task),
+		traceProv:      o.TraceProvider,
+		sampling:       o.TraceSampling,
+		log:            newLevelLogger(o.Logger),
+		sched:          newScheduler(o.Clock),
+		broker:         o.Broker,
+		results:        o.Results,
+		leaser:         leaser,
+		metrics:        set,
+		strict:         o.StrictEnqueue,
+		maxPayloadSize: o.MaxPayloadSize,
+		payloadPolicy:  o.PayloadPolicy,
+		blobs:          o.BlobStore,
+		tenantQueues:   o.TenantQueues,
+		tenants:        tenants,
+		refreshPeriod:  o.QueueRefreshPeriod,
+		codec:          o.ResultCodec,
+		propagator:     o.Propagator,
+		workerID:       o.WorkerID,
+		heartbeat:      o.HeartbeatPeriod,
+		limiter:        o.RateLimiter,
+		rate:           o.Rate,
+		queueRates:     o.QueueRates,
+		cache:          newLRUCache(o.Cache, o.Clock),
+		handlerCache:   newHandlerCache(o.HandlerCache, o.Clock),
+		deps:           newProviders(),
+		clock:          o.Clock,
+		ids:            o.IDGenerator,
+		sandboxDir:     o.SandboxDir,
+		mode:           o.Mode,
+		name:           o.Name,
+		unknown:        o.UnknownTask,
+		windows:        windows,
+		ordered:        ordered,
+		locker:         o.Locker,
+		sharedLocker:   sharedLocker,
+		cgroups:        newConcurrencyGroups(o.ConcurrencyGroups),
+		costs:          newCostLimiter(o.MaxCost),
+		fair:           newFairShare(o.FairQueues, o.FairBuffer),
+		labels:         o.Labels,
+		schemas:        o.ResultSchemas,
+		races:          newRaceDetector(o.DetectRaces),
+		calendars:      o.Calendars,
+		stats:          o.Stats,
+		tasks:          make(map[string]Task),
+		stops:          make(map[string]context.CancelFunc),
+		draining:       make(map[string]struct{}),
+		inflight:       make(map[string]int),
+		running:        make(map[string]JobMessage),
+		consumers:      make(map[string]int),
+		waiters:        make(map[string][]chan struct{}),
+		releases:       make(map[gateRelease]struct{}),
+		released:       make(chan struct{}, 1),
+		preempts:       preemptibles{jobs: make(map[string]*preemptible)},
+		usage:          usage,
+		usagePeriod:    o.UsagePeriod,
+		pauses:         newPauses(),
+		delayedPeriod:  o.DelayedPeriod,
+		limits:         limits,
+		reducers:       o.Reducers,
+		authorizer:     o.Authorizer,
+		audit:          o.AuditSink,
 	}, nil
 }
 
-// GetResult() accepts a UUID and returns the result of the job in the results store.
+// GetResult() accepts a UUID and returns the result of the job in the results store. For the UUID
+// of a complete chain or group with a reducer, it returns the result combining its jobs' results.
 func (s *Server) GetResult(ctx context.Context, uuid string) ([][]byte, error) {
-	b, err := s.results.Get(ctx, resultsPrefix+uuid)
-	if err != nil {
-		return nil, err
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
+
+	b, ok := s.cache.get(resultsPrefix + uuid)
+	if !ok {
+		var err error
+		if b, err = s.results.Get(ctx, resultsPrefix+uuid); err != nil {
+			// The results of a chain or group with a reducer are combined once it's complete.
+			rb, ok, rerr := s.reducedResult(ctx, uuid)
+			if rerr != nil {
+				return nil, rerr
+			}
+			if !ok {
+				return nil, err
+			}
+			b = rb
+		}
+		s.cache.set(resultsPrefix+uuid, b)
 	}
 
 	var d [][]byte
-	if err := msgpack.Unmarshal(b, d); err != nil {
+	if err := msgpack.Unmarshal(b, &d); err != nil {
 		return nil, err
 	}
 
-	return d, nil
+	return s.migrateResults(ctx, uuid, d)
 }
 
 // GetFailed() returns the list of uuid's of jobs that failed.
+//
+// Deprecated: use GetJobs() with the failed status, which filters and pages through the jobs.
 func (s *Server) GetFailed(ctx context.Context) ([]string, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
 	return s.results.GetFailed(ctx)
 }
 
 // GetSuccess() returns the list of uuid's of jobs that were successful.
+//
+// Deprecated: use GetJobs() with the successful status, which filters and pages through the jobs.
 func (s *Server) GetSuccess(ctx context.Context) ([]string, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
 	return s.results.GetSuccess(ctx)
 }
 
-// Start() starts the job consumer and processor. It is a blocking function.
+// GetPending() returns upto n job messages waiting in the queue on the broker, without
+// consuming them, if the broker implements Peeker. Otherwise, it returns ErrPendingUnsupported.
+func (s *Server) GetPending(ctx context.Context, queue string, n int) ([]JobMessage, error) {
+	msgs, err := peek(ctx, s.broker, queue, n)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]JobMessage, 0, len(msgs))
+	for _, b := range msgs {
+		var msg JobMessage
+		if err := msgpack.Unmarshal(b, &msg); err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+
+	return out, nil
+}
+
+// Start() starts the job consumer and processor. It is a blocking function, which
+// returns after the context is cancelled and the consumers and processors have exited.
+// Tasks registered (or unregistered) while the server is running are started (or stopped).
 func (s *Server) Start(ctx context.Context) {
-	go s.cron.Start()
+	s.log.Info("starting server", "mode", s.mode)
+	if s.mode.schedules() {
+		s.sched.start()
+	}
+
+	if s.tracing(ctx) {
+		var span spans.Span
+		ctx, span = otel.Tracer(tracer).Start(ctx, "start")
+		defer span.End()
+	}
+
+	if s.leasesEnabled() && s.mode.processes() {
+		s.wg.Add(1)
+		go func() {
+			s.runHeartbeat(ctx)
+			s.wg.Done()
+		}()
+	}
+	if s.usage != nil && s.mode.processes() {
+		s.wg.Add(1)
+		go func() {
+			s.runUsage(ctx)
+			s.wg.Done()
+		}()
+	}
+	if _, ok := s.results.(Delayer); ok && s.mode != ModeProducer {
+		s.wg.Add(1)
+		go func() {
+			s.runDelayed(ctx)
+			s.wg.Done()
+		}()
+	}
+	if s.results != nil {
+		s.wg.Add(1)
+		go func() {
+			s.runReleases(ctx)
+			s.wg.Done()
+		}()
+	}
+
 	// Loop over each registered task.
-	s.p.RLock()
-	tasks := s.tasks
-	s.p.RUnlock()
+	s.p.Lock()
+	s.runCtx = ctx
+	for _, task := range s.tasks {
+		s.startTask(ctx, task)
+	}
+	s.p.Unlock()
 
-	var wg sync.WaitGroup
-	for _, task := range tasks {
-		if s.traceProv != nil {
-			var span spans.Span
-			ctx, span = otel.Tracer(tracer).Start(ctx, "start")
-			defer span.End()
-		}
+	<-ctx.Done()
+
+	s.p.Lock()
+	s.runCtx = nil
+	s.stops = make(map[string]context.CancelFunc)
+	s.p.Unlock()
+
+	s.wg.Wait()
+}
+
+// startTask starts the consumers and processors of the task. The consumers are stopped on
+// unregistering the task, while the processors run until the consumers have exited, so that
+// the messages already received are processed. It should be called with the task lock held.
+func (s *Server) startTask(ctx context.Context, task Task) {
+	if !s.mode.processes() {
+		return
+	}
 
-		task := task
-		work := make(chan []byte)
-		wg.Add(1)
+	// A task whose hook fails is removed, so that its jobs aren't processed without its state.
+	state, err := s.startWorker(ctx, task)
+	if err != nil {
+		s.log.Error("error starting task", "name", task.name, "version", task.opts.Version, "error", err)
+		delete(s.tasks, task.key())
+		return
+	}
+	task.state = state
+	s.tasks[task.key()] = task
+
+	cctx, stop := context.WithCancel(ctx)
+	s.stops[task.key()] = stop
+
+	// processors tracks the task's processors, to tear down its state once they've exited.
+	var processors sync.WaitGroup
+	defer s.stopWorker(task, &processors)
+
+	for _, queue := range task.queues() {
+		var (
+			queue = queue
+			work  = make(chan []byte)
+			done  = make(chan struct{})
+		)
+		s.wg.Add(1)
+		s.trackConsumer(task.key(), 1)
 		go func() {
-			s.consume(ctx, work, task.opts.Queue)
-			wg.Done()
+			defer s.trackConsumer(task.key(), -1)
+			if task.opts.QueuePattern != "" && queue != task.opts.RetryQueue {
+				s.consumePattern(cctx, work, queue, task.opts.HashQueues)
+			} else {
+				s.consume(cctx, work, queue)
+			}
+			close(done)
+			s.wg.Done()
 		}()
 
-		for i := 0; i < int(task.opts.Concurrency); i++ {
-			wg.Add(1)
+		// The jobs of fair queues are interleaved by their task, and prefetched jobs are
+		// processed in the order of their deadlines.
+		jobs := work
+		if s.fair.isFair(queue) && !s.isOrdered(queue) {
+			jobs = make(chan []byte)
+			s.wg.Add(1)
+			go func() {
+				s.dispatchFair(ctx, work, jobs, s.fair.buffer, queue, done)
+				s.wg.Done()
+			}()
+		} else if task.opts.Prefetch > 0 && !s.isOrdered(queue) {
+			jobs = make(chan []byte)
+			s.wg.Add(1)
+			go func() {
+				s.prefetch(ctx, work, jobs, task.opts.Prefetch, queue, done)
+				s.wg.Done()
+			}()
+		}
+
+		// With multiple processors, the jobs with a partition key are routed to
+		// a processor's lane by the key, to process them serially and in order.
+		var (
+			in = jobs
+			n  = task.concurrency(queue)
+		)
+		// The jobs of an ordered queue are processed one at a time by its consumer (see
+		// processOrdered()), hence it's given a single processor.
+		if s.isOrdered(queue) {
+			n = 1
+		}
+		lanes := make([]chan []byte, n)
+		if n > 1 {
+			shared := make(chan []byte)
+			for i := range lanes {
+				lanes[i] = make(chan []byte, laneBuffer)
+			}
+			s.wg.Add(1)
 			go func() {
-				s.process(ctx, work)
-				wg.Done()
+				s.partition(ctx, jobs, shared, lanes, done)
+				s.wg.Done()
 			}()
+			in = shared
+		}
+
+		for i := 0; i < int(n); i++ {
+			lane := lanes[i]
+			s.wg.Add(1)
+			processors.Add(1)
+			go func() {
+				s.process(ctx, in, lane, done)
+				processors.Done()
+				s.wg.Done()
+			}()
+		}
+	}
+}
+
+// queues returns the queues consumed for the task. These are the tenants' namespaced
+// queues if the task is registered for tenants, and the label queues of the queues for the
+// server's labels, followed by the retry queue if it's set.
+func (t Task) queues() []string {
+	var queues []string
+	switch {
+	case t.opts.QueuePattern != "":
+		queues = []string{t.opts.QueuePattern}
+	case len(t.opts.Tenants) == 0:
+		queues = []string{t.opts.Queue}
+	default:
+		queues = make([]string, 0, len(t.opts.Tenants)+1)
+		for _, tenant := range t.opts.Tenants {
+			queues = append(queues, TenantQueue(t.opts.Queue, tenant))
+		}
+	}
+	if len(t.labels) > 0 && t.opts.QueuePattern == "" {
+		for _, q := range queues {
+			queues = append(queues, labelQueues(q, t.labels)...)
 		}
 	}
-	wg.Wait()
+	if t.opts.Pinnable && t.opts.QueuePattern == "" {
+		for _, q := range queues {
+			queues = append(queues, WorkerQueue(q, t.worker))
+		}
+	}
+	if t.opts.RetryQueue != "" {
+		queues = append(queues, t.opts.RetryQueue)
+	}
+
+	return queues
 }
 
-// consume() listens on the queue for task messages and passes the task to processor.
+// consume() listens on the queue for task messages and passes the task to processor, while
+// the queue isn't paused.
 func (s *Server) consume(ctx context.Context, work chan []byte, queue string) {
 	s.log.Info("starting task consumer..")
+	s.consumePausable(ctx, queue, func(ctx context.Context) {
+		if s.isOrdered(queue) {
+			s.consumeOrdered(ctx, queue)
+			return
+		}
+		s.consumeQueue(ctx, work, queue)
+	})
+}
+
+// consumeQueue() consumes the queue, outside of its windows if it has any.
+func (s *Server) consumeQueue(ctx context.Context, work chan []byte, queue string) {
+	if ws, ok := s.windows[queue]; ok {
+		s.consumeWindowed(ctx, work, queue, ws)
+		return
+	}
 	s.broker.Consume(ctx, work, queue)
 }
 
-// process() listens on the work channel for tasks. On receiving a task it checks the
-// processors map and passes payload to relevant processor.
-func (s *Server) process(ctx context.Context, w chan []byte) {
-	s.log.Info("starting processor..")
+// consumePattern() periodically looks up the queues matching the pattern and starts a consumer
+// for each new queue. All the consumers share the work channel, whose blocked senders are served
+// in FIFO order. As each consumer holds at most one pending message, the matching queues are
+// served in a round-robin manner, irrespective of their backlog. The consumers of the queues
+// which no longer exist are stopped. If hashed is set, only the queues hashed to the worker
+// are consumed, and the consumers of the queues which moved to other workers are stopped.
+func (s *Server) consumePattern(ctx context.Context, work chan []byte, pattern string, hashed bool) {
+	var (
+		wg       sync.WaitGroup
+		active   = make(map[string]context.CancelFunc)
+		tk       = time.NewTicker(s.refreshPeriod)
+		register = func() {
+			queues, err := listQueues(ctx, s.broker, pattern)
+			if err != nil {
+				s.log.Error("error looking up queues", "pattern", pattern, "error", err)
+				return
+			}
+			if hashed {
+				if queues, err = s.ownedQueues(ctx, pattern, queues); err != nil {
+					s.log.Error("error hashing queues", "pattern", pattern, "error", err)
+					return
+				}
+			}
+
+			// The consumers of the queues that no longer exist (or that moved to another
+			// worker) are stopped, so that they don't pile up as queues come and go.
+			current := make(map[string]struct{}, len(queues))
+			for _, q := range queues {
+				current[q] = struct{}{}
+			}
+			for q, stop := range active {
+				if _, ok := current[q]; !ok {
+					s.log.Debug("stopping consumer of queue no longer matched", "queue", q)
+					stop()
+					delete(active, q)
+				}
+			}
+			for _, q := range queues {
+				if _, ok := active[q]; ok {
+					continue
+				}
+				qctx, stop := context.WithCancel(ctx)
+				active[q] = stop
+
+				q := q
+				wg.Add(1)
+				go func() {
+					s.consume(qctx, work, q)
+					wg.Done()
+				}()
+			}
+		}
+	)
+	defer tk.Stop()
+
+	register()
 	for {
-		var span spans.Span
-		if s.traceProv != nil {
-			ctx, span = otel.Tracer(tracer).Start(ctx, "process")
-			defer span.End()
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			if hashed {
+				s.leaveRing(pattern)
+			}
+			return
+		case <-tk.C:
+			register()
 		}
+	}
+}
 
+// process() listens on the work channel (and the processor's lane, if any) for tasks. On
+// receiving a task it checks the processors map and passes payload to relevant processor.
+func (s *Server) process(ctx context.Context, w, lane chan []byte, done <-chan struct{}) {
+	s.log.Info("starting processor..")
+	for {
 		select {
 		case <-ctx.Done():
 			s.log.Info("shutting down processor..")
 			return
+		case <-done:
+			s.log.Info("stopping processor of unregistered task..")
+			return
 		case work := <-w:
-			var (
-				msg JobMessage
-				err error
-			)
-			// Decode the bytes into a job message
-			if err = msgpack.Unmarshal(work, &msg); err != nil {
-				s.spanError(span, err)
-				s.log.Error("error unmarshalling task", "error", err)
+			s.handle(ctx, work)
+		case work := <-lane:
+			s.handle(ctx, work)
+		}
+	}
+}
+
+// Process() processes a job message, as consumed from the broker, synchronously. It is
+// useful to run jobs without starting the server, eg: in tests.
+func (s *Server) Process(ctx context.Context, b []byte) {
+	s.handle(ctx, b)
+}
+
+// handle() processes a job message consumed from the broker. Errors are logged, and
+// recorded on the job message in the results store.
+func (s *Server) handle(ctx context.Context, work []byte) {
+	var msg JobMessage
+	// Decode the bytes into a job message
+	if err := msgpack.Unmarshal(work, &msg); err != nil {
+		s.log.Error("error unmarshalling task", "error", err)
+		return
+	}
+	s.track(msg.Queue, 1)
+	defer s.track(msg.Queue, -1)
+
+	// The job's spans are sampled per message, and the span is ended once the message is handled.
+	var span spans.Span
+	if ctx = s.sampleTrace(ctx, msg); s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "process", jobSpanOpts(msg)...)
+		defer span.End()
+	}
+	// Fetch the registered task handler.
+	task, err := s.getHandler(msg.Job.Task, msg.Version)
+	if errors.Is(err, errVersionNotFound) {
+		// Leave the job to a worker that has the version, eg: during a rolling migration.
+		s.log.Debug("handler version not registered, requeuing job", "uuid", msg.UUID, "version", msg.Version)
+		s.requeueLater(ctx, work, msg.Queue, versionRequeueDelay)
+		return
+	}
+	if errors.Is(err, ErrTaskNotRegistered) {
+		// Leave the job to a worker that has the task, eg: during a rolling deploy.
+		s.requeueUnknown(ctx, msg)
+		return
+	}
+	if err != nil {
+		s.spanError(span, err)
+		s.log.Error("handler not found", "error", err)
+		return
+	}
+	// A sample of the task's jobs is processed by its canary version, if any.
+	task, shadow := s.canary(msg, task)
+
+	// Skip pinned jobs which fell back to their queue, as their worker was presumed dead.
+	if msg.Worker != "" && s.results != nil && s.fellBack(ctx, msg) {
+		s.log.Debug("skipping pinned job that fell back to its queue", "uuid", msg.UUID)
+		return
+	}
+
+	// Skip jobs which were picked up after their expiry. Jobs which were cancelled while
+	// waiting in the queue are skipped as they're started, below.
+	if msg.isExpired(s.clock.Now()) {
+		if _, err := s.startJob(ctx, msg, s.statusExpired); err != nil {
+			s.spanError(span, err)
+			s.log.Error("error setting the status to expired", "error", err)
+		}
+		return
+	}
+
+	// Skip jobs which were picked up after their deadline.
+	if msg.missedDeadline(s.clock.Now()) {
+		if _, err := s.startJob(ctx, msg, s.statusMissed); err != nil {
+			s.spanError(span, err)
+			s.log.Error("error setting the status to missed", "error", err)
+		}
+		return
+	}
+
+	// Delay the job until its run window opens.
+	now := s.clock.Now()
+	if wait := msg.windowWait(now); wait > 0 {
+		if s.delayToWindow(ctx, work, msg, now.Add(wait)) {
+			return
+		}
+	}
+
+	// Hold back the job if the server's or the queue's rate is exceeded.
+	for wait := s.throttle(ctx, msg.Queue); wait > 0; wait = s.throttle(ctx, msg.Queue) {
+		s.metrics.GetOrCreateCounter(metricJobsThrottled).Inc()
+		if s.holdBack(ctx, work, msg.Queue, wait) {
+			return
+		}
+	}
+
+	// Wait for a slot of the task's concurrency group.
+	release, ok := s.acquireGroup(ctx, task.opts.ConcurrencyGroup, work, msg.Queue)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Wait for the job's cost to fit within the server's max cost.
+	releaseCost, ok := s.acquireCost(ctx, task, work, msg.Queue)
+	if !ok {
+		return
+	}
+	defer releaseCost()
+
+	// Hold back the job if another job of the singleton task is running. The job's
+	// context is cancelled if the lock is lost.
+	jctx := ctx
+	if task.opts.Singleton {
+		for {
+			lctx, unlock, ok := s.lockSingleton(ctx, task, msg.UUID)
+			if ok {
+				jctx = lctx
+				defer unlock()
 				break
 			}
-			// Fetch the registered task handler.
-			task, err := s.getHandler(msg.Job.Task)
-			if err != nil {
-				s.spanError(span, err)
-				s.log.Error("handler not found", "error", err)
-				break
+			if s.holdBack(ctx, work, msg.Queue, singletonRetryDelay) {
+				return
 			}
+		}
+	}
 
-			// Set the job status as being "processed"
-			if err := s.statusProcessing(ctx, msg); err != nil {
-				s.spanError(span, err)
-				s.log.Error("error setting the status to processing", "error", err)
-				break
+	// Hold back the job if its tenant is over quota.
+	limiter := s.tenants[msg.Tenant]
+	if limiter != nil {
+		for wait, ok := limiter.acquire(); !ok; wait, ok = limiter.acquire() {
+			if s.holdBack(ctx, work, msg.Queue, wait) {
+				return
 			}
+		}
+	}
+
+	// Lease the job to the worker, so that it can be recovered if the worker dies.
+	leased := false
+	if s.leasesEnabled() {
+		if err := s.lease(ctx, msg.UUID); err != nil {
+			s.log.Error("error leasing job", "error", err)
+		} else {
+			leased = true
+		}
+	}
+
+	s.setRunning(msg, true)
+	defer s.setRunning(msg, false)
 
-			if err := s.execJob(ctx, msg, task); err != nil {
-				s.spanError(span, err)
-				s.log.Error("could not execute job. err", "error", err)
+	// The job's context is cancelled if it's preempted by a job of a higher priority.
+	jctx, done := s.runPreemptible(jctx, task, msg)
+	defer done()
+
+	// Set the job status as being "processed", unless it was cancelled while waiting in the queue.
+	msg.startAttempt(s.clock.Now())
+	if started, err := s.startJob(ctx, msg, s.statusProcessing); err != nil {
+		s.spanError(span, err)
+		s.log.Error("error setting the status to processing", "error", err)
+	} else if !started {
+		s.log.Debug("skipping cancelled job", "uuid", msg.UUID)
+	} else {
+		err := s.execJob(jctx, msg, task)
+		// Jobs of ordered queues are retried in place.
+		var r *inPlaceRetry
+		for errors.As(err, &r) {
+			r.msg.startAttempt(s.clock.Now())
+			if err = s.statusProcessing(ctx, r.msg); err == nil {
+				err = s.execJob(jctx, r.msg, task)
 			}
 		}
+		if err != nil {
+			s.spanError(span, err)
+			s.log.Error("could not execute job. err", "error", err)
+		}
+		if shadow != nil {
+			s.shadowJob(ctx, msg, *shadow)
+		}
+	}
+
+	if leased {
+		if err := s.unlease(ctx, msg.UUID); err != nil {
+			s.log.Error("error releasing job lease", "error", err)
+		}
+	}
+
+	if limiter != nil {
+		limiter.release()
 	}
 }
 
 func (s *Server) execJob(ctx context.Context, msg JobMessage, task Task) error {
 	var span spans.Span
-	if s.traceProv != nil {
-		ctx, span = otel.Tracer(tracer).Start(ctx, "exec_job")
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "exec_job", jobSpanOpts(msg)...)
 		defer span.End()
 	}
+	// Restore the values propagated from the context the job was enqueued with. Jobs
+	// enqueued from the handler, such as the next job of a chain, carry them on.
+	if s.propagator != nil && len(msg.Baggage) > 0 {
+		ctx = s.propagator.Extract(ctx, msg.Baggage)
+	}
+
+	// If the job has a timeout, the handler's context is cancelled after it.
+	var (
+		jctx   = ctx
+		cancel = func() {}
+	)
+	if msg.Timeout > 0 {
+		jctx, cancel = context.WithTimeout(ctx, msg.Timeout)
+	}
+	defer cancel()
+
+	// Jobs of sandboxed tasks get a working directory, which is removed after the job.
+	dir, cleanup, err := s.sandbox(task, msg.UUID)
+	defer cleanup()
+
+	// Tag the job with the version of the results it saves.
+	msg.ResultVersion = s.resultVersion(msg.Job.Task)
+
 	// Create the task context, which will be passed to the handler.
 	// TODO: maybe use sync.Pool
-	taskCtx := JobCtx{Meta: msg.Meta, store: s.results}
+	taskCtx := JobCtx{Context: jctx, Meta: msg.Meta, job: *msg.Job, store: s.results, cache: s.cache, handlerCache: s.handlerCache, deps: s.deps, codec: s.codec, dir: dir, state: task.state, next: &continuations{}, worker: s.workerID}
 
 	if task.opts.ProcessingCB != nil {
 		task.opts.ProcessingCB(taskCtx)
 	}
 
-	err := task.handler(msg.Job.Payload, taskCtx)
+	// Decode the payload if it was compressed or offloaded. A failure (or a failure to
+	// create the job's directory) is treated like a handler error, so that the job is retried.
+	var payload []byte
+	if err == nil {
+		payload, err = s.jobPayload(jctx, msg, task, &taskCtx)
+	}
+	if err == nil && !task.streamed(msg) {
+		err = task.validate(payload, ValidateProcess)
+	}
+	var elapsed time.Duration
+	if err == nil {
+		started := s.clock.Now()
+		err = s.runDetected(task, payload, taskCtx)
+		elapsed = s.clock.Now().Sub(started)
+		s.recordUsage(msg, started, err != nil)
+		s.countCanary(task, err)
+		s.fair.add(msg.Queue, msg.Job.Task, elapsed)
+	}
+	msg.endAttempt(s.clock.Now(), err)
+	if err != nil && isPreempted(jctx) {
+		return s.requeuePreempted(msg)
+	}
 	if err != nil {
 		// Set the job's error
 		msg.PrevErr = err.Error()
-		// Try queueing the job again.
-		if msg.MaxRetry != msg.Retried {
+		// Try queueing the job again, unless the handler skipped retries.
+		if msg.MaxRetry != msg.Retried && !errors.Is(err, ErrSkipRetry) {
 			if task.opts.RetryingCB != nil {
 				task.opts.RetryingCB(taskCtx)
 			}
-			return s.retryJob(ctx, msg)
+			s.countStat(ctx, msg, statRetried, elapsed)
+			if s.isOrdered(msg.Queue) {
+				return s.retryInPlace(ctx, msg)
+			}
+			return s.retryJob(ctx, s.demote(task, msg), task.retryBackoff(msg.Retried))
 		} else {
 			if task.opts.FailedCB != nil {
 				task.opts.FailedCB(taskCtx)
 			}
+			// The job is failed even if its outcome can't be routed.
+			if err := s.route(ctx, &msg, task.opts.Routes.Failure); err != nil {
+				s.log.Error("could not route failed job", "uuid", msg.UUID, "task", task.opts.Routes.Failure.Task, "error", err)
+			}
+			s.countStat(ctx, msg, statFailed, elapsed)
 			// If we hit max retries, set the task status as failed.
 			return s.statusFailed(ctx, msg)
 		}
@@ -274,32 +1321,69 @@ func (s *Server) execJob(ctx context.Context, msg JobMessage, task Task) error {
 	if msg.Job.OnSuccess != nil {
 		// Extract OnSuccessJob into a variable to get opts.
 		j := msg.Job.OnSuccess
-		nj := *j
-		meta := DefaultMeta(nj.Opts)
+		nj, err := s.prepareJob(*j)
+		if err != nil {
+			return err
+		}
+		meta := chainStepMeta(DefaultMeta(nj.Opts), msg.ChainUUID, msg.ChainDeadline)
 		meta.PrevJobResults = taskCtx.results
+		// The jobs of a chain are linked to the span the chain was enqueued with.
+		meta.TraceLink = msg.TraceLink
 		msg.OnSuccessUUID, err = s.enqueueWithMeta(ctx, nj, meta)
 		if err != nil {
 			return err
 		}
 	}
+	if err := s.route(ctx, &msg, task.opts.Routes.Success); err != nil {
+		return err
+	}
+	// Enqueue the jobs appended by the handler with Then().
+	if err := s.enqueueContinuations(ctx, &msg, taskCtx.next); err != nil {
+		return err
+	}
 
 	if err := s.statusDone(ctx, msg); err != nil {
 		s.spanError(span, err)
 		return err
 	}
+	s.countStat(ctx, msg, statDone, elapsed)
 
 	return nil
 }
 
-// retryJob() increments the retried count and re-queues the task message.
-func (s *Server) retryJob(ctx context.Context, msg JobMessage) error {
+// runHandler() executes the task's handler. If the job has a timeout and the handler doesn't
+// return before it, the timeout error is returned and the handler is left to return on its own.
+func runHandler(task Task, payload []byte, c JobCtx) error {
+	if c.Meta.Timeout == 0 {
+		return task.handler(payload, c)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- task.handler(payload, c)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-c.Done():
+		return fmt.Errorf("job timed out : %w", c.Err())
+	}
+}
+
+// retryJob() increments the retried count and re-queues the task message, after the delay
+// if it's set.
+func (s *Server) retryJob(ctx context.Context, msg JobMessage, delay time.Duration) error {
 	var span spans.Span
-	if s.traceProv != nil {
-		ctx, span = otel.Tracer(tracer).Start(ctx, "retry_job")
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "retry_job", jobSpanOpts(msg)...)
 		defer span.End()
 	}
 
 	msg.Retried += 1
+	if delay > 0 {
+		msg.ETA = s.clock.Now().Add(delay)
+	}
 	b, err := msgpack.Marshal(msg)
 	if err != nil {
 		s.spanError(span, err)
@@ -311,7 +1395,23 @@ func (s *Server) retryJob(ctx context.Context, msg JobMessage) error {
 		return err
 	}
 
-	if err := s.broker.Enqueue(ctx, b, msg.Queue); err != nil {
+	// The retry of a pinned job falls back to its queue as well, if the worker dies.
+	if msg.Worker != "" && s.results != nil {
+		if err := setTag(ctx, s.results, pinnedPrefix+msg.Worker, msg.UUID); err != nil {
+			s.spanError(span, err)
+			return err
+		}
+	}
+
+	if delay > 0 {
+		if err := s.retryLater(ctx, msg, b); err != nil {
+			s.spanError(span, err)
+			return err
+		}
+		return nil
+	}
+
+	if err := enqueuePriority(ctx, s.broker, b, msg.Queue, msg.Priority); err != nil {
 		s.spanError(span, err)
 		return err
 	}
@@ -319,31 +1419,87 @@ func (s *Server) retryJob(ctx context.Context, msg JobMessage) error {
 	return nil
 }
 
-func (s *Server) registerHandler(name string, t Task) {
+func (s *Server) registerHandler(t Task) {
 	s.p.Lock()
-	s.tasks[name] = t
-	s.p.Unlock()
+	defer s.p.Unlock()
+
+	key := t.key()
+	s.tasks[key] = t
+	// Restart the task if the server is running, as it may have been re-registered with new options.
+	if s.runCtx != nil {
+		if stop, ok := s.stops[key]; ok {
+			stop()
+		}
+		s.startTask(s.runCtx, t)
+	}
+}
+
+// UnregisterTask() removes the handlers of the versions of the task, or of all its versions if
+// none are given. If the server is running, the task's consumers are stopped, and its processors
+// exit after processing the jobs already received. Jobs of the task consumed afterwards (eg: by
+// another task's consumers on the same queue) can't be processed.
+func (s *Server) UnregisterTask(name string, versions ...string) {
+	s.log.Info("removed handler", "name", name, "versions", versions)
+
+	s.p.Lock()
+	defer s.p.Unlock()
+
+	for key, t := range s.tasks {
+		if t.name != name || (len(versions) > 0 && !contains(versions, t.opts.Version)) {
+			continue
+		}
+
+		delete(s.tasks, key)
+		if stop, ok := s.stops[key]; ok {
+			stop()
+			delete(s.stops, key)
+		}
+	}
 }
 
-func (s *Server) getHandler(name string) (Task, error) {
+// getHandler returns the handler of the version of the task. A job of a version that isn't
+// registered falls back to the task's unversioned handler, while an unversioned job falls back
+// to the task's latest version which isn't a canary.
+func (s *Server) getHandler(name, version string) (Task, error) {
 	s.p.RLock()
-	fn, ok := s.tasks[name]
-	s.p.RUnlock()
-	if !ok {
-		return Task{}, fmt.Errorf("handler %v not found", name)
+	defer s.p.RUnlock()
+
+	if t, ok := s.tasks[taskKey(name, version)]; ok {
+		return t, nil
+	}
+	if version != "" {
+		if t, ok := s.tasks[taskKey(name, "")]; ok {
+			return t, nil
+		}
+	}
+
+	var (
+		latest Task
+		found  bool
+	)
+	for _, t := range s.tasks {
+		if t.name == name && t.opts.Canary.Sample <= 0 && (!found || versionLess(latest.opts.Version, t.opts.Version)) {
+			latest, found = t, true
+		}
+	}
+	switch {
+	case !found:
+		return Task{}, fmt.Errorf("handler %v not found : %w", name, ErrTaskNotRegistered)
+	case version != "":
+		return Task{}, fmt.Errorf("handler %v version %v not found : %w", name, version, errVersionNotFound)
 	}
 
-	return fn, nil
+	return latest, nil
 }
 
 func (s *Server) statusStarted(ctx context.Context, t JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "status_started")
 		defer span.End()
 	}
 
-	t.ProcessedAt = time.Now()
+	t.ProcessedAt = s.clock.Now()
 	t.Status = StatusStarted
 
 	if err := s.setJobMessage(ctx, t); err != nil {
@@ -354,14 +1510,32 @@ func (s *Server) statusStarted(ctx context.Context, t JobMessage) error {
 	return nil
 }
 
+func (s *Server) statusHeld(ctx context.Context, t JobMessage) error {
+	var span spans.Span
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "status_held")
+		defer span.End()
+	}
+
+	t.ProcessedAt = s.clock.Now()
+	t.Status = StatusHeld
+
+	if err := s.setJobMessage(ctx, t); err != nil {
+		s.spanError(span, err)
+		return err
+	}
+
+	return nil
+}
+
 func (s *Server) statusProcessing(ctx context.Context, t JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "status_processing")
 		defer span.End()
 	}
 
-	t.ProcessedAt = time.Now()
+	t.ProcessedAt = s.clock.Now()
 	t.Status = StatusProcessing
 
 	if err := s.setJobMessage(ctx, t); err != nil {
@@ -374,16 +1548,18 @@ func (s *Server) statusProcessing(ctx context.Context, t JobMessage) error {
 
 func (s *Server) statusDone(ctx context.Context, t JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "status_done")
 		defer span.End()
 	}
 
-	t.ProcessedAt = time.Now()
+	t.ProcessedAt = s.clock.Now()
 	t.Status = StatusDone
 
-	if err := s.results.SetSuccess(ctx, t.UUID); err != nil {
-		return err
+	if s.results != nil {
+		if err := s.results.SetSuccess(ctx, t.UUID); err != nil {
+			return err
+		}
 	}
 
 	if err := s.setJobMessage(ctx, t); err != nil {
@@ -391,21 +1567,25 @@ func (s *Server) statusDone(ctx context.Context, t JobMessage) error {
 		return err
 	}
 
+	s.deletePayload(ctx, t)
+
 	return nil
 }
 
 func (s *Server) statusFailed(ctx context.Context, t JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "status_failed")
 		defer span.End()
 	}
 
-	t.ProcessedAt = time.Now()
+	t.ProcessedAt = s.clock.Now()
 	t.Status = StatusFailed
 
-	if err := s.results.SetFailed(ctx, t.UUID); err != nil {
-		return err
+	if s.results != nil {
+		if err := s.results.SetFailed(ctx, t.UUID); err != nil {
+			return err
+		}
 	}
 
 	if err := s.setJobMessage(ctx, t); err != nil {
@@ -418,12 +1598,12 @@ func (s *Server) statusFailed(ctx context.Context, t JobMessage) error {
 
 func (s *Server) statusRetrying(ctx context.Context, t JobMessage) error {
 	var span spans.Span
-	if s.traceProv != nil {
+	if s.tracing(ctx) {
 		ctx, span = otel.Tracer(tracer).Start(ctx, "status_retrying")
 		defer span.End()
 	}
 
-	t.ProcessedAt = time.Now()
+	t.ProcessedAt = s.clock.Now()
 	t.Status = StatusRetrying
 
 	if err := s.setJobMessage(ctx, t); err != nil {
@@ -434,10 +1614,68 @@ func (s *Server) statusRetrying(ctx context.Context, t JobMessage) error {
 	return nil
 }
 
-// spanError checks if tracing is enabled & adds an error to
+func (s *Server) statusCancelled(ctx context.Context, t JobMessage) error {
+	var span spans.Span
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "status_cancelled")
+		defer span.End()
+	}
+
+	// Jobs waiting in a queue are usually cancelled by a client, whose events aren't listened
+	// to, hence their cancellation is emitted by the worker which consumes and skips them.
+	// Held jobs aren't consumed, and are emitted here.
+	held := t.Status == StatusHeld
+	t.ProcessedAt = s.clock.Now()
+	t.Status = StatusCancelled
+
+	if err := s.storeJobMessage(ctx, t); err != nil {
+		s.spanError(span, err)
+		return err
+	}
+	if held {
+		s.emit(t)
+	} else {
+		s.wake(t.UUID)
+	}
+
+	s.deletePayload(ctx, t)
+
+	return nil
+}
+
+func (s *Server) statusExpired(ctx context.Context, t JobMessage) error {
+	var span spans.Span
+	if s.tracing(ctx) {
+		ctx, span = otel.Tracer(tracer).Start(ctx, "status_expired")
+		defer span.End()
+	}
+
+	t.ProcessedAt = s.clock.Now()
+	t.Status = StatusExpired
+
+	if err := s.setJobMessage(ctx, t); err != nil {
+		s.spanError(span, err)
+		return err
+	}
+
+	s.metrics.GetOrCreateCounter(fmt.Sprintf(`%s{task="%s",queue="%s"}`, metricJobsExpired, t.Job.Task, t.Queue)).Inc()
+
+	s.deletePayload(ctx, t)
+
+	// A step of a chain expires past the chain's deadline, which expires the chain.
+	if t.ChainUUID != "" && s.results != nil {
+		if _, err := s.GetChain(ctx, t.ChainUUID); err != nil {
+			s.log.Error("error updating chain", "uuid", t.ChainUUID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// spanError checks if the job is traced & adds an error to
 // supplied span.
 func (s *Server) spanError(sp spans.Span, err error) {
-	if s.traceProv != nil {
+	if sp != nil {
 		sp.RecordError(err)
 		sp.SetStatus(codes.Error, err.Error())
 	}
@@ -1,14 +1,19 @@
 package tasqueue
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/zerodha/logf"
 
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
 	rr "github.com/kalbhor/tasqueue/results/in-memory"
 )
 
@@ -35,6 +40,218 @@ func newServer(t *testing.T) *Server {
 	return srv
 }
 
+func TestServerWithoutResults(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker: NewMockBroker(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		if err := c.Save(b); !errors.Is(err, ErrNoResults) {
+			t.Errorf("expected %v saving results, got %v", ErrNoResults, err)
+		}
+		close(done)
+		return nil
+	}, TaskOpts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job was not processed")
+	}
+
+	if _, err := srv.GetJob(ctx, uuid); !errors.Is(err, ErrNoResults) {
+		t.Fatalf("expected %v getting job, got %v", ErrNoResults, err)
+	}
+}
+
+func TestQueuePattern(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:             NewMockBroker(),
+		Results:            NewMockResults(),
+		QueueRefreshPeriod: time.Millisecond * 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{QueuePattern: "emails.*"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	var uuids []string
+	for _, q := range []string{"emails.a", "emails.b"} {
+		job := makeJob(t, false)
+		job.Opts.Queue = q
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	// Wait for the queues to be looked up and the jobs to be processed.
+	time.Sleep(time.Second)
+	for _, uuid := range uuids {
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != StatusDone {
+			t.Fatalf("incorrect job status, expected %s, got %s", StatusDone, msg.Status)
+		}
+	}
+}
+
+func TestQueuePatternRoundRobin(t *testing.T) {
+	srv, err := NewServer(ServerOpts{Broker: mb.New(), Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		queues []string
+		wg     sync.WaitGroup
+	)
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		defer wg.Done()
+		mu.Lock()
+		queues = append(queues, c.Queue())
+		mu.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		return nil
+	}, TaskOpts{QueuePattern: "emails.*", Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A backlog on one queue doesn't hold up the jobs of the other.
+	for i, q := range []string{"emails.a", "emails.a", "emails.a", "emails.a", "emails.a", "emails.a", "emails.b", "emails.b"} {
+		job := makeJob(t, false)
+		job.Opts.Queue = q
+		wg.Add(1)
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatalf("error enqueuing job %d: %v", i, err)
+		}
+	}
+	go srv.Start(ctx)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	b := 0
+	for _, q := range queues[:5] {
+		if q == "emails.b" {
+			b++
+		}
+	}
+	if b != 2 {
+		t.Fatalf("expected the queues to be served in turns, got %v", queues)
+	}
+}
+
+// vanishingBroker is a broker whose queues can be hidden from lookups, as if they no longer
+// exist, and which counts the running consumers of each queue.
+type vanishingBroker struct {
+	Broker
+
+	mu        sync.Mutex
+	hidden    map[string]bool
+	consumers map[string]int
+}
+
+func (b *vanishingBroker) Queues(ctx context.Context, pattern string) ([]string, error) {
+	queues, err := listQueues(ctx, b.Broker, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := queues[:0]
+	for _, q := range queues {
+		if !b.hidden[q] {
+			out = append(out, q)
+		}
+	}
+	return out, nil
+}
+
+func (b *vanishingBroker) Consume(ctx context.Context, work chan []byte, queue string) {
+	b.count(queue, 1)
+	defer b.count(queue, -1)
+	b.Broker.Consume(ctx, work, queue)
+}
+
+func (b *vanishingBroker) count(queue string, n int) {
+	b.mu.Lock()
+	b.consumers[queue] += n
+	b.mu.Unlock()
+}
+
+func (b *vanishingBroker) running(queue string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.consumers[queue]
+}
+
+func TestQueuePatternVanished(t *testing.T) {
+	b := &vanishingBroker{Broker: mb.New(), hidden: make(map[string]bool), consumers: make(map[string]int)}
+	srv, err := NewServer(ServerOpts{Broker: b, Results: NewMockResults(), QueueRefreshPeriod: time.Millisecond * 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{QueuePattern: "emails.*"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, q := range []string{"emails.a", "emails.b"} {
+		job := makeJob(t, false)
+		job.Opts.Queue = q
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start(ctx)
+
+	// waitRunning waits for the number of the queue's running consumers.
+	waitRunning := func(queue string, n int) {
+		for i := 0; i < 100 && b.running(queue) != n; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if c := b.running(queue); c != n {
+			t.Fatalf("expected %d consumers of %s, got %d", n, queue, c)
+		}
+	}
+	waitRunning("emails.b", 1)
+
+	// The consumer of a queue that no longer exists is stopped, and restarted if it reappears.
+	b.mu.Lock()
+	b.hidden["emails.b"] = true
+	b.mu.Unlock()
+	waitRunning("emails.b", 0)
+	waitRunning("emails.a", 1)
+
+	b.mu.Lock()
+	b.hidden["emails.b"] = false
+	b.mu.Unlock()
+	waitRunning("emails.b", 1)
+}
+
 type MockPayload struct {
 	ShouldErr bool
 }
@@ -81,6 +298,7 @@ func (r *MockResults) Set(_ context.Context, uuid string, b []byte) error {
 }
 
 type MockBroker struct {
+	mu     sync.Mutex
 	queues map[string][][]byte
 	data   chan []byte
 }
@@ -99,12 +317,281 @@ func (r *MockBroker) Consume(ctx context.Context, work chan []byte, _ string) {
 			fmt.Println("stopping consumer")
 			return
 		case d := <-r.data:
+			r.remove(d)
 			work <- d
 		}
 	}
 }
 
-func (r *MockBroker) Enqueue(_ context.Context, msg []byte, _ string) error {
+// remove removes a consumed message from its queue.
+func (r *MockBroker) remove(msg []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for q, msgs := range r.queues {
+		for i, m := range msgs {
+			if bytes.Equal(m, msg) {
+				r.queues[q] = append(msgs[:i:i], msgs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (r *MockBroker) Enqueue(_ context.Context, msg []byte, queue string) error {
+	r.mu.Lock()
+	r.queues[queue] = append(r.queues[queue], msg)
+	r.mu.Unlock()
+
 	r.data <- msg
 	return nil
 }
+
+func (r *MockBroker) Queues(_ context.Context, pattern string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	for q := range r.queues {
+		if ok, _ := path.Match(pattern, q); ok {
+			out = append(out, q)
+		}
+	}
+
+	return out, nil
+}
+
+// GetPending returns the messages enqueued onto the queue. Consumed messages aren't
+// removed, hence it is only accurate before the server is started.
+func (r *MockBroker) GetPending(_ context.Context, queue string, n int) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msgs := r.queues[queue]
+	if len(msgs) > n {
+		msgs = msgs[:n]
+	}
+
+	return msgs, nil
+}
+
+func TestGetPending(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+
+	var uuids []string
+	for i := 0; i < 3; i++ {
+		uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	msgs, err := srv.GetPending(ctx, DefaultQueue, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].UUID != uuids[0] || msgs[1].UUID != uuids[1] {
+		t.Fatalf("expected pending jobs %v, got %v", uuids[:2], msgs)
+	}
+}
+
+// plainBroker hides the optional interfaces implemented by the broker.
+type plainBroker struct {
+	Broker
+}
+
+func TestBrokerUnsupported(t *testing.T) {
+	ctx := context.Background()
+	for _, ns := range []string{"", "test"} {
+		srv, err := NewServer(ServerOpts{Broker: plainBroker{NewMockBroker()}, Results: NewMockResults(), Namespace: ns})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask(taskName, MockHandler, TaskOpts{QueuePattern: "mock.*"})
+
+		if _, err := srv.GetPending(ctx, DefaultQueue, 1); !errors.Is(err, ErrPendingUnsupported) {
+			t.Fatalf("expected %v in namespace %q, got %v", ErrPendingUnsupported, ns, err)
+		}
+		if _, err := srv.Snapshot(ctx); !errors.Is(err, ErrQueuesUnsupported) {
+			t.Fatalf("expected %v in namespace %q, got %v", ErrQueuesUnsupported, ns, err)
+		}
+	}
+}
+
+func TestRegisterTaskWhileRunning(t *testing.T) {
+	srv, err := NewServer(ServerOpts{
+		Broker:  NewMockBroker(),
+		Results: NewMockResults(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	// Wait for the server to start.
+	time.Sleep(time.Millisecond * 100)
+
+	done := make(chan struct{}, 1)
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		done <- struct{}{}
+		return nil
+	}, TaskOpts{})
+
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job of the task registered while running was not processed")
+	}
+
+	srv.UnregisterTask(taskName)
+	if _, err := srv.getHandler(taskName, ""); err == nil {
+		t.Fatal("expected the task to be unregistered")
+	}
+
+	srv.p.RLock()
+	n := len(srv.stops)
+	srv.p.RUnlock()
+	if n != 0 {
+		t.Fatalf("expected no running tasks, got %d", n)
+	}
+}
+
+// mockClock is a fake clock, which fires the timers that are due when it's advanced.
+type mockClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters map[chan time.Time]time.Time
+}
+
+func newMockClock(t time.Time) *mockClock {
+	return &mockClock{now: t, waiters: make(map[chan time.Time]time.Time)}
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *mockClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters[ch] = c.now.Add(d)
+	return ch
+}
+
+func (c *mockClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for ch, at := range c.waiters {
+		if !at.After(c.now) {
+			ch <- c.now
+			delete(c.waiters, ch)
+		}
+	}
+}
+
+// wait waits for a timer to be set on the clock.
+func (c *mockClock) wait(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		n := len(c.waiters)
+		c.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("timed out waiting for a timer")
+}
+
+func TestProcessWithClock(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	var uuids []string
+	for _, exp := range []time.Duration{time.Hour, time.Minute} {
+		job := makeJob(t, false)
+		job.Opts.ExpiresAt = clock.Now().Add(exp)
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	clock.advance(time.Minute * 30)
+	for i, status := range []string{StatusDone, StatusExpired} {
+		srv.Process(ctx, <-broker.data)
+
+		msg, err := srv.GetJob(ctx, uuids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != status {
+			t.Fatalf("expected job status %s, got %s", status, msg.Status)
+		}
+		if !msg.ProcessedAt.Equal(clock.Now()) {
+			t.Fatalf("expected processed at %v, got %v", clock.Now(), msg.ProcessedAt)
+		}
+	}
+}
+
+func TestScheduleWithClock(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := makeJob(t, false)
+	job.Opts.Schedule = "*/5 * * * *"
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	srv.sched.start()
+
+	// The job is enqueued on every tick of the schedule, and not before.
+	for i := 0; i < 3; i++ {
+		clock.wait(t)
+		clock.advance(time.Minute * 4)
+		select {
+		case <-broker.data:
+			t.Fatal("expected scheduled job to not be enqueued before its schedule")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		clock.advance(time.Minute)
+		select {
+		case <-broker.data:
+		case <-time.After(time.Second):
+			t.Fatalf("expected scheduled job to be enqueued at %v", clock.Now())
+		}
+	}
+}
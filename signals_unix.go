@@ -0,0 +1,15 @@
+//go:build !windows
+
+package tasqueue
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// quitSignals stop the server run by Run() immediately.
+	quitSignals = []os.Signal{syscall.SIGQUIT}
+	// dumpSignals dump the in-flight jobs of the server run by Run().
+	dumpSignals = []os.Signal{syscall.SIGUSR1}
+)
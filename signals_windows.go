@@ -0,0 +1,9 @@
+package tasqueue
+
+import "os"
+
+// There are no quit or dump signals on windows.
+var (
+	quitSignals []os.Signal
+	dumpSignals []os.Signal
+)
@@ -0,0 +1,301 @@
+package tasqueue
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/zerodha/logf"
+)
+
+// envelope is a signed and/or encrypted message on the broker. If CipherKeyID is set, Msg
+// is encrypted, and the signature covers the encrypted message.
+type envelope struct {
+	KeyID       string
+	Sig         []byte
+	Msg         []byte
+	CipherKeyID string
+}
+
+// signedData returns the data signed for a message, which includes the queue, so that a
+// signed message can't be replayed onto another queue.
+func signedData(queue string, msg []byte) []byte {
+	b := make([]byte, 0, len(queue)+1+len(msg))
+	b = append(b, queue...)
+	b = append(b, 0)
+	return append(b, msg...)
+}
+
+// signedBroker signs (and encrypts, if the cipher is set) the messages enqueued onto the
+// broker, and verifies (and decrypts) the messages consumed from it. If the verifier is set,
+// messages that don't verify (or aren't signed) are rejected.
+type signedBroker struct {
+	Broker
+	signer   Signer
+	verifier Verifier
+	cipher   Cipher
+	log      logf.Logger
+	metrics  *metrics.Set
+}
+
+func (b signedBroker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	return b.EnqueuePriority(ctx, msg, queue, 0)
+}
+
+func (b signedBroker) EnqueuePriority(ctx context.Context, msg []byte, queue string, priority uint8) error {
+	if b.signer == nil && b.cipher == nil {
+		return enqueuePriority(ctx, b.Broker, msg, queue, priority)
+	}
+
+	var e envelope
+	if b.cipher != nil {
+		key, sealed, err := b.cipher.Seal(msg, []byte(queue))
+		if err != nil {
+			return fmt.Errorf("could not encrypt message : %w", err)
+		}
+		e.CipherKeyID, msg = key, sealed
+	}
+	if b.signer != nil {
+		key, sig, err := b.signer.Sign(signedData(queue, msg))
+		if err != nil {
+			return fmt.Errorf("could not sign message : %w", err)
+		}
+		e.KeyID, e.Sig = key, sig
+	}
+	e.Msg = msg
+
+	env, err := msgpack.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return enqueuePriority(ctx, b.Broker, env, queue, priority)
+}
+
+func (b signedBroker) Consume(ctx context.Context, work chan []byte, queue string) {
+	in := make(chan []byte)
+	go b.Broker.Consume(ctx, in, queue)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-in:
+			msg, err := b.open(m, queue)
+			if err != nil {
+				b.log.Error("rejecting message", "queue", queue, "error", err)
+				b.metrics.GetOrCreateCounter(metricMessagesRejected).Inc()
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case work <- msg:
+			}
+		}
+	}
+}
+
+func (b signedBroker) GetPending(ctx context.Context, queue string, n int) ([][]byte, error) {
+	msgs, err := peek(ctx, b.Broker, queue, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages that don't verify would be rejected on consumption, hence they're skipped.
+	out := make([][]byte, 0, len(msgs))
+	for _, m := range msgs {
+		if msg, err := b.open(m, queue); err == nil {
+			out = append(out, msg)
+		}
+	}
+
+	return out, nil
+}
+
+// open returns the message in the envelope, verifying its signature if the verifier is set,
+// and decrypting it if it's encrypted.
+func (b signedBroker) open(m []byte, queue string) ([]byte, error) {
+	var env envelope
+	if err := msgpack.Unmarshal(m, &env); err != nil || env.Msg == nil {
+		if b.verifier != nil {
+			return nil, fmt.Errorf("message not signed : %w", ErrInvalidSignature)
+		}
+		// Unsigned messages are passed through, eg: while signing is being rolled out.
+		return m, nil
+	}
+
+	if b.verifier != nil {
+		if env.Sig == nil {
+			return nil, fmt.Errorf("message not signed : %w", ErrInvalidSignature)
+		}
+		if err := b.verifier.Verify(env.KeyID, signedData(queue, env.Msg), env.Sig); err != nil {
+			return nil, err
+		}
+	}
+
+	if env.CipherKeyID == "" {
+		return env.Msg, nil
+	}
+	if b.cipher == nil {
+		return nil, fmt.Errorf("message encrypted with key %s, but no cipher is set : %w", env.CipherKeyID, ErrDecrypt)
+	}
+
+	return b.cipher.Open(env.CipherKeyID, env.Msg, []byte(queue))
+}
+
+// HMAC signs and verifies messages with HMAC-SHA256. Messages are signed with the key of ID
+// Key, and verified with any of the Keys, so that the signing key can be rotated by adding the
+// new key to the verifiers' keys before signing with it.
+type HMAC struct {
+	Key  string
+	Keys map[string][]byte
+}
+
+func (h HMAC) Sign(msg []byte) (string, []byte, error) {
+	k, ok := h.Keys[h.Key]
+	if !ok {
+		return "", nil, fmt.Errorf("signing key %s not found", h.Key)
+	}
+
+	return h.Key, hmacSum(k, msg), nil
+}
+
+func (h HMAC) Verify(keyID string, msg, sig []byte) error {
+	k, ok := h.Keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key %s : %w", keyID, ErrInvalidSignature)
+	}
+	if !hmac.Equal(hmacSum(k, msg), sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func hmacSum(key, msg []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+// Ed25519Signer signs messages with the Ed25519 private key of ID Key. Unlike HMAC, the workers
+// only need the public key to verify messages, and can't sign messages themselves.
+type Ed25519Signer struct {
+	Key        string
+	PrivateKey ed25519.PrivateKey
+}
+
+func (e Ed25519Signer) Sign(msg []byte) (string, []byte, error) {
+	return e.Key, ed25519.Sign(e.PrivateKey, msg), nil
+}
+
+// Ed25519Verifier verifies messages with any of the public keys, by ID.
+type Ed25519Verifier struct {
+	Keys map[string]ed25519.PublicKey
+}
+
+func (e Ed25519Verifier) Verify(keyID string, msg, sig []byte) error {
+	k, ok := e.Keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key %s : %w", keyID, ErrInvalidSignature)
+	}
+	if !ed25519.Verify(k, msg, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// AESGCM encrypts messages with AES-GCM (AES-256 with 32 byte keys). Messages are encrypted
+// with the key of ID Key, and decrypted with any of the Keys, so that the key can be rotated
+// by adding the new key to the workers' keys before encrypting with it. Each message is sealed
+// with a random nonce, which is prepended to it.
+type AESGCM struct {
+	Key  string
+	Keys map[string][]byte
+}
+
+func (a AESGCM) Seal(msg, ad []byte) (string, []byte, error) {
+	k, ok := a.Keys[a.Key]
+	if !ok {
+		return "", nil, fmt.Errorf("encryption key %s not found", a.Key)
+	}
+	aead, err := newGCM(k)
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(msg)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return a.Key, aead.Seal(nonce, nonce, msg, ad), nil
+}
+
+func (a AESGCM) Open(keyID string, sealed, ad []byte) ([]byte, error) {
+	k, ok := a.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s : %w", keyID, ErrDecrypt)
+	}
+	aead, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	msg, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return msg, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
+func (b signedBroker) Ping(ctx context.Context) error {
+	return ping(ctx, b.Broker)
+}
+
+func (b signedBroker) Queues(ctx context.Context, pattern string) ([]string, error) {
+	return listQueues(ctx, b.Broker, pattern)
+}
+
+func (b signedBroker) Depth(ctx context.Context, queue string) (int64, error) {
+	return depth(ctx, b.Broker, queue)
+}
+
+func (b signedBroker) Trim(ctx context.Context, queue string, n int64) ([][]byte, error) {
+	msgs, err := trim(ctx, b.Broker, queue, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages that don't verify are dropped without being returned.
+	out := make([][]byte, 0, len(msgs))
+	for _, m := range msgs {
+		if msg, err := b.open(m, queue); err == nil {
+			out = append(out, msg)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/zerodha/logf"
+)
+
+func TestSignedBroker(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		keys   = HMAC{Key: "k1", Keys: map[string][]byte{"k1": []byte("secret")}}
+		sb     = signedBroker{Broker: broker, signer: keys, verifier: keys, log: logf.New(logf.Opts{}), metrics: metrics.NewSet()}
+	)
+
+	consume := func(queue string) []byte {
+		cctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer cancel()
+
+		work := make(chan []byte, 1)
+		go sb.Consume(cctx, work, queue)
+		select {
+		case b := <-work:
+			return b
+		case <-cctx.Done():
+			return nil
+		}
+	}
+
+	// Signed messages are verified and unwrapped.
+	if err := sb.Enqueue(ctx, []byte("msg"), "q"); err != nil {
+		t.Fatal(err)
+	}
+	if b := consume("q"); !bytes.Equal(b, []byte("msg")) {
+		t.Fatalf("expected the signed message to be consumed, got %q", b)
+	}
+
+	// Unsigned messages, and messages signed for another queue, are rejected.
+	if err := broker.Enqueue(ctx, []byte("foreign"), "q"); err != nil {
+		t.Fatal(err)
+	}
+	if b := consume("q"); b != nil {
+		t.Fatalf("expected the unsigned message to be rejected, got %q", b)
+	}
+	if err := sb.Enqueue(ctx, []byte("msg"), "q"); err != nil {
+		t.Fatal(err)
+	}
+	if b := consume("other"); b != nil {
+		t.Fatalf("expected the message signed for another queue to be rejected, got %q", b)
+	}
+}
+
+func TestHMACRotation(t *testing.T) {
+	var (
+		old      = HMAC{Key: "k1", Keys: map[string][]byte{"k1": []byte("old")}}
+		rotated  = HMAC{Key: "k2", Keys: map[string][]byte{"k1": []byte("old"), "k2": []byte("new")}}
+		verifier = rotated
+	)
+
+	// Messages signed with the old and the new keys verify.
+	for _, s := range []HMAC{old, rotated} {
+		key, sig, err := s.Sign([]byte("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.Verify(key, []byte("msg"), sig); err != nil {
+			t.Fatalf("expected message signed with %s to verify, got %v", key, err)
+		}
+		if err := verifier.Verify(key, []byte("tampered"), sig); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected %v for a tampered message, got %v", ErrInvalidSignature, err)
+		}
+	}
+}
+
+func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		s = Ed25519Signer{Key: "k1", PrivateKey: priv}
+		v = Ed25519Verifier{Keys: map[string]ed25519.PublicKey{"k1": pub}}
+	)
+
+	key, sig, err := s.Sign([]byte("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(key, []byte("msg"), sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(key, []byte("tampered"), sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected %v for a tampered message, got %v", ErrInvalidSignature, err)
+	}
+	if err := v.Verify("k2", []byte("msg"), sig); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected %v for an unknown key, got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestEncryptedBroker(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		keys   = AESGCM{Key: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte("k"), 32)}}
+		signer = HMAC{Key: "k1", Keys: map[string][]byte{"k1": []byte("secret")}}
+		sb     = signedBroker{Broker: broker, signer: signer, verifier: signer, cipher: keys, log: logf.New(logf.Opts{}), metrics: metrics.NewSet()}
+	)
+
+	// The message on the broker is encrypted, and is decrypted on consumption.
+	if err := sb.Enqueue(ctx, []byte("secret msg"), "q"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := broker.GetPending(ctx, "q", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 || bytes.Contains(raw[0], []byte("secret msg")) {
+		t.Fatalf("expected the message on the broker to be encrypted, got %q", raw)
+	}
+	if b, err := sb.open(raw[0], "q"); err != nil || !bytes.Equal(b, []byte("secret msg")) {
+		t.Fatalf("expected the message to be decrypted, got %q, %v", b, err)
+	}
+
+	// Messages encrypted for another queue, or without the key, can't be decrypted.
+	unsigned := signedBroker{Broker: broker, cipher: keys}
+	if _, err := unsigned.open(raw[0], "other"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected %v for a message of another queue, got %v", ErrDecrypt, err)
+	}
+	unsigned.cipher = AESGCM{Keys: map[string][]byte{"k2": bytes.Repeat([]byte("k"), 32)}}
+	if _, err := unsigned.open(raw[0], "q"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected %v for an unknown key, got %v", ErrDecrypt, err)
+	}
+	unsigned.cipher = nil
+	if _, err := unsigned.open(raw[0], "q"); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected %v without a cipher, got %v", ErrDecrypt, err)
+	}
+}
+
+func TestAESGCMRotation(t *testing.T) {
+	var (
+		old     = AESGCM{Key: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte("o"), 32)}}
+		rotated = AESGCM{Key: "k2", Keys: map[string][]byte{"k1": bytes.Repeat([]byte("o"), 32), "k2": bytes.Repeat([]byte("n"), 32)}}
+	)
+
+	// Messages encrypted with the old and the new keys are decrypted, and tampered ones aren't.
+	for _, c := range []AESGCM{old, rotated} {
+		key, sealed, err := c.Seal([]byte("msg"), []byte("q"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg, err := rotated.Open(key, sealed, []byte("q")); err != nil || !bytes.Equal(msg, []byte("msg")) {
+			t.Fatalf("expected message encrypted with %s to be decrypted, got %q, %v", key, msg, err)
+		}
+		sealed[len(sealed)-1] ^= 1
+		if _, err := rotated.Open(key, sealed, []byte("q")); !errors.Is(err, ErrDecrypt) {
+			t.Fatalf("expected %v for a tampered message, got %v", ErrDecrypt, err)
+		}
+	}
+}
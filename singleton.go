@@ -0,0 +1,58 @@
+package tasqueue
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// singletonLockPrefix prefixes the key of the lock held by the running job of a singleton task.
+	singletonLockPrefix = "tasqueue:singleton:"
+	// singletonLockTTL is the duration after which the lock of a job that stopped refreshing it
+	// (eg: as its server died) expires. The lock is refreshed at a third of it.
+	singletonLockTTL = time.Second * 30
+	// singletonRetryDelay is the delay after which a job held back by a running job of its
+	// singleton task is checked again.
+	singletonRetryDelay = time.Second
+)
+
+// lockSingleton acquires the lock of the singleton task for the job, and refreshes it until
+// it's released. It returns a context that's cancelled if the lock is lost, and the function
+// that releases the lock, or false if another job of the task holds the lock.
+func (s *Server) lockSingleton(ctx context.Context, task Task, uuid string) (context.Context, func(), bool) {
+	var (
+		key   = singletonLockPrefix + task.name
+		owner = s.workerID + ":" + uuid
+	)
+	ok, err := s.locker.Lock(ctx, key, owner, singletonLockTTL)
+	if err != nil {
+		s.log.Error("error acquiring lock of singleton task", "task", task.name, "error", err)
+	}
+	if !ok {
+		return nil, nil, false
+	}
+
+	lctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-lctx.Done():
+				return
+			case <-s.clock.After(singletonLockTTL / 3):
+			}
+			if ok, err := s.locker.Lock(lctx, key, owner, singletonLockTTL); err != nil || !ok {
+				s.log.Error("lost lock of singleton task, cancelling job", "task", task.name, "uuid", uuid, "error", err)
+				return
+			}
+		}
+	}()
+
+	return lctx, func() {
+		cancel()
+		// The context may be cancelled, hence a new one is used to unlock.
+		if err := s.locker.Unlock(context.Background(), key, owner); err != nil {
+			s.log.Error("error releasing lock of singleton task", "task", task.name, "error", err)
+		}
+	}, true
+}
@@ -0,0 +1,66 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSingletonTask(t *testing.T) {
+	var (
+		broker  = NewMockBroker()
+		results = NewMockResults()
+		locker  = newLocalLocker(systemClock{})
+
+		mu      sync.Mutex
+		running int
+		wg      sync.WaitGroup
+	)
+	handler := func(b []byte, _ JobCtx) error {
+		defer wg.Done()
+
+		mu.Lock()
+		running++
+		if running > 1 {
+			t.Error("expected one job of the singleton task to run at a time")
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 50)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The servers share the locker, so that the task runs one job at a time across them.
+	var srvs []*Server
+	for i := 0; i < 2; i++ {
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: results, Locker: locker})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask("singleton", handler, TaskOpts{Concurrency: 4, Singleton: true})
+		srvs = append(srvs, srv)
+	}
+
+	for i := 0; i < 3; i++ {
+		job, err := NewJob("singleton", nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		if _, err := srvs[0].Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, srv := range srvs {
+		go srv.Start(ctx)
+	}
+	wg.Wait()
+}
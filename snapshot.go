@@ -0,0 +1,213 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const defaultSnapshotInterval = time.Second * 30
+
+// ErrDepthUnsupported is returned on looking up the depth of a queue, if the broker doesn't
+// implement Depther.
+var ErrDepthUnsupported = errors.New("broker doesn't support queue depths")
+
+// Snapshot is a machine readable status of the server, eg: for an operator or autoscaler.
+type Snapshot struct {
+	Time     time.Time `json:"time"`
+	WorkerID string    `json:"worker_id"`
+	Name     string    `json:"name,omitempty"`
+	Mode     string    `json:"mode"`
+	Started  bool      `json:"started"`
+
+	Queues []QueueStatus `json:"queues"`
+	Tasks  []TaskStatus  `json:"tasks"`
+	// Workers are the workers which heartbeat onto the results store, across servers.
+	Workers []WorkerStatus `json:"workers,omitempty"`
+}
+
+// QueueStatus is the status of a queue consumed by the server.
+type QueueStatus struct {
+	Name string `json:"name"`
+	// Depth is the number of messages waiting in the queue, or -1 if the broker doesn't
+	// support queue depths.
+	Depth int64 `json:"depth"`
+	// InFlight is the number of the queue's jobs being processed (or held back) by the server.
+	InFlight int  `json:"in_flight"`
+	Draining bool `json:"draining,omitempty"`
+}
+
+// TaskStatus is the status of a task registered on the server.
+type TaskStatus struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version,omitempty"`
+	Queues      []string `json:"queues"`
+	Concurrency uint32   `json:"concurrency"`
+	// Consumers is the number of the task's consumers running.
+	Consumers int `json:"consumers"`
+}
+
+// WorkerStatus is the last heartbeat of a worker.
+type WorkerStatus struct {
+	ID        string    `json:"id"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// SnapshotOpts configures the periodic publishing of the server's snapshot.
+type SnapshotOpts struct {
+	// Interval is the duration between snapshots. Defaults to 30s.
+	Interval time.Duration
+	// Publish is called with each snapshot's JSON document, eg: to write it to a ConfigMap,
+	// or to a file (SnapshotFile) shared with a sidecar.
+	Publish func(ctx context.Context, b []byte) error
+}
+
+// QueueDepth() returns the number of messages waiting in the queue, if the broker implements
+// Depther. Otherwise, it returns ErrDepthUnsupported.
+func (s *Server) QueueDepth(ctx context.Context, queue string) (int64, error) {
+	return depth(ctx, s.broker, queue)
+}
+
+// depth returns the depth of the queue, if the broker implements Depther.
+func depth(ctx context.Context, b Broker, queue string) (int64, error) {
+	if d, ok := b.(Depther); ok {
+		return d.Depth(ctx, queue)
+	}
+	return 0, ErrDepthUnsupported
+}
+
+// Snapshot() returns the status of the server: its queues and their depths, its tasks and
+// their versions, and the workers that heartbeat onto the results store.
+func (s *Server) Snapshot(ctx context.Context) (Snapshot, error) {
+	snap := Snapshot{Time: s.clock.Now(), WorkerID: s.workerID, Name: s.name, Mode: s.mode.String()}
+
+	s.p.RLock()
+	snap.Started = s.runCtx != nil
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	s.p.RUnlock()
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].key() < tasks[j].key() })
+
+	// The queues of the tasks consuming a pattern are the queues matching it.
+	queues := make(map[string]struct{})
+	for _, t := range tasks {
+		tq := t.queues()
+		if t.opts.QueuePattern != "" {
+			var err error
+			if tq, err = listQueues(ctx, s.broker, t.opts.QueuePattern); err != nil {
+				return Snapshot{}, err
+			}
+			if t.opts.RetryQueue != "" {
+				tq = append(tq, t.opts.RetryQueue)
+			}
+		}
+		for _, q := range tq {
+			queues[q] = struct{}{}
+		}
+		snap.Tasks = append(snap.Tasks, TaskStatus{Name: t.name, Version: t.opts.Version, Queues: tq, Concurrency: t.opts.Concurrency})
+	}
+
+	for q := range queues {
+		d, err := s.QueueDepth(ctx, q)
+		if errors.Is(err, ErrDepthUnsupported) {
+			d = -1
+		} else if err != nil {
+			return Snapshot{}, fmt.Errorf("could not get depth of queue %s : %w", q, err)
+		}
+		snap.Queues = append(snap.Queues, QueueStatus{Name: q, Depth: d, Draining: s.isDraining(q)})
+	}
+	sort.Slice(snap.Queues, func(i, j int) bool { return snap.Queues[i].Name < snap.Queues[j].Name })
+
+	s.qmu.Lock()
+	for i, q := range snap.Queues {
+		snap.Queues[i].InFlight = s.inflight[q.Name]
+	}
+	for i, t := range tasks {
+		snap.Tasks[i].Consumers = s.consumers[t.key()]
+	}
+	s.qmu.Unlock()
+
+	if s.leaser == nil {
+		return snap, nil
+	}
+	ids, err := s.leaser.GetLeases(ctx, workersKey)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		b, err := s.results.Get(ctx, workerPrefix+id)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("could not get worker %s : %w", id, err)
+		}
+		var w worker
+		if err := json.Unmarshal(b, &w); err != nil {
+			return Snapshot{}, err
+		}
+		snap.Workers = append(snap.Workers, WorkerStatus{ID: w.ID, Heartbeat: w.Heartbeat})
+	}
+
+	return snap, nil
+}
+
+// RunSnapshots() periodically publishes the server's snapshot as a JSON document. It is a
+// blocking function.
+func (s *Server) RunSnapshots(ctx context.Context, o SnapshotOpts) {
+	if o.Interval == 0 {
+		o.Interval = defaultSnapshotInterval
+	}
+
+	for {
+		if err := s.publishSnapshot(ctx, o.Publish); err != nil {
+			s.log.Error("error publishing snapshot", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(o.Interval):
+		}
+	}
+}
+
+func (s *Server) publishSnapshot(ctx context.Context, publish func(context.Context, []byte) error) error {
+	snap, err := s.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	return publish(ctx, b)
+}
+
+// SnapshotFile returns a publisher which writes the snapshot to the file. The file is
+// replaced atomically, so that readers don't see partial snapshots.
+func SnapshotFile(path string) func(context.Context, []byte) error {
+	return func(_ context.Context, b []byte) error {
+		f, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.Write(b); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		return os.Rename(f.Name(), path)
+	}
+}
@@ -0,0 +1,80 @@
+package tasqueue
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	mb "github.com/kalbhor/tasqueue/brokers/in-memory"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	srv, err := NewServer(ServerOpts{
+		Broker:          mb.New(),
+		Results:         NewMockResults(),
+		Name:            "api",
+		WorkerID:        "worker-1",
+		HeartbeatPeriod: time.Minute,
+		Namespace:       "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{Concurrency: 2})
+	srv.RegisterTask("reports", MockHandler, TaskOpts{Queue: "reports", Version: "v2"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := srv.beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := srv.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Name != "api" || snap.WorkerID != "worker-1" || snap.Mode != ModeAll.String() || snap.Started {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+
+	// The depths are looked up in the namespace.
+	queues := []QueueStatus{{Name: "reports", Depth: 0}, {Name: DefaultQueue, Depth: 2}}
+	if !reflect.DeepEqual(snap.Queues, queues) {
+		t.Fatalf("expected queues %+v, got %+v", queues, snap.Queues)
+	}
+	tasks := []TaskStatus{
+		{Name: taskName, Queues: []string{DefaultQueue}, Concurrency: 2},
+		{Name: "reports", Version: "v2", Queues: []string{"reports"}, Concurrency: 1},
+	}
+	if !reflect.DeepEqual(snap.Tasks, tasks) {
+		t.Fatalf("expected tasks %+v, got %+v", tasks, snap.Tasks)
+	}
+	if len(snap.Workers) != 1 || snap.Workers[0].ID != "worker-1" {
+		t.Fatalf("expected the worker's heartbeat, got %+v", snap.Workers)
+	}
+
+	// The published snapshot is the JSON document of the snapshot.
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := srv.publishSnapshot(ctx, SnapshotFile(path)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Queues, queues) {
+		t.Fatalf("expected the published queues %+v, got %+v", queues, got.Queues)
+	}
+}
@@ -0,0 +1,264 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// statsKey is the hash of the counters of the jobs' transitions, and statsMinutePrefix
+	// prefixes the hashes of the counters of each minute, for the rates and durations.
+	statsKey          = "tasqueue:stats"
+	statsMinutePrefix = "tasqueue:stats:"
+	// statsMinuteTTL is the duration for which the counters of a minute are kept.
+	statsMinuteTTL = time.Minute * 20
+
+	statEnqueued = "enqueued"
+	statDone     = "done"
+	statFailed   = "failed"
+	statRetried  = "retried"
+	// statDuration is the cumulative duration of the attempts, and statHistogram prefixes the
+	// counts of the attempts in each of the durationBounds.
+	statDuration  = "duration"
+	statHistogram = "h"
+)
+
+// ErrStatsUnsupported is returned on getting the stats, if the results store doesn't
+// implement Counter.
+var ErrStatsUnsupported = errors.New("results store doesn't support counters")
+
+// durationBounds are the upper bounds of the histogram of the attempts' durations, which the
+// 95th percentile is estimated by. Attempts beyond the last bound are counted past it.
+var durationBounds = []time.Duration{
+	time.Millisecond * 5, time.Millisecond * 10, time.Millisecond * 25, time.Millisecond * 50,
+	time.Millisecond * 100, time.Millisecond * 250, time.Millisecond * 500, time.Second,
+	time.Second * 2, time.Second * 5, time.Second * 10, time.Second * 30, time.Minute,
+	time.Minute * 5, time.Minute * 15, time.Hour,
+}
+
+// Stats are the counts and rates of the jobs per task and per queue, across the servers and
+// clients recording stats (ServerOpts.Stats) onto the results store.
+type Stats struct {
+	Tasks  map[string]JobStats `json:"tasks"`
+	Queues map[string]JobStats `json:"queues"`
+}
+
+// JobStats are the counts and rates of the jobs of a task, or on a queue.
+type JobStats struct {
+	// Enqueued, Done, Failed and Retried count the jobs enqueued (or released from a gate),
+	// succeeded, failed and retried, since stats were recorded.
+	Enqueued int64 `json:"enqueued"`
+	Done     int64 `json:"done"`
+	Failed   int64 `json:"failed"`
+	Retried  int64 `json:"retried"`
+
+	// Rate1m, Rate5m and Rate15m are the attempts (succeeded, failed or retried) per second,
+	// over the last 1, 5 and 15 complete minutes.
+	Rate1m  float64 `json:"rate_1m"`
+	Rate5m  float64 `json:"rate_5m"`
+	Rate15m float64 `json:"rate_15m"`
+
+	// AvgDuration and P95Duration are the average and the 95th percentile of the durations of
+	// the attempts in the last 15 minutes. The percentile is estimated from a histogram, as
+	// the upper bound of its bucket.
+	AvgDuration time.Duration `json:"avg_duration"`
+	P95Duration time.Duration `json:"p95_duration"`
+}
+
+// countStat records a transition of the job onto the results store, with the attempt's
+// duration (if it's the outcome of an attempt), if stats are recorded. Failures are logged,
+// as they don't fail the job.
+func (s *Server) countStat(ctx context.Context, msg JobMessage, stat string, d time.Duration) {
+	if !s.stats || msg.Job == nil {
+		return
+	}
+	c, ok := s.results.(Counter)
+	if !ok {
+		return
+	}
+
+	var (
+		prefixes = []string{"task:" + msg.Job.Task + ":", "queue:" + msg.Queue + ":"}
+		total    = make(map[string]int64, 2)
+		minute   = make(map[string]int64, 6)
+	)
+	for _, p := range prefixes {
+		total[p+stat] = 1
+		minute[p+stat] = 1
+		if stat != statEnqueued {
+			minute[p+statDuration] = int64(d)
+			minute[p+statHistogram+strconv.Itoa(durationBucket(d))] = 1
+		}
+	}
+
+	if err := c.IncrCounters(ctx, statsKey, total, 0); err != nil {
+		s.log.Error("could not record job stats", "uuid", msg.UUID, "error", err)
+		return
+	}
+	if err := c.IncrCounters(ctx, statsMinuteKey(s.clock.Now()), minute, statsMinuteTTL); err != nil {
+		s.log.Error("could not record job stats", "uuid", msg.UUID, "error", err)
+	}
+}
+
+// durationBucket returns the bucket of the histogram the duration is counted in.
+func durationBucket(d time.Duration) int {
+	for i, b := range durationBounds {
+		if d <= b {
+			return i
+		}
+	}
+	return len(durationBounds)
+}
+
+func statsMinuteKey(t time.Time) string {
+	return statsMinutePrefix + t.UTC().Format("200601021504")
+}
+
+// Stats() returns the counts and rates of the jobs per task and per queue, eg: for dashboards.
+// It requires a results store that implements Counter (the redis and in-memory stores), or
+// else it returns ErrStatsUnsupported.
+func (s *Server) Stats(ctx context.Context) (Stats, error) {
+	if s.results == nil {
+		return Stats{}, ErrNoResults
+	}
+	c, ok := s.results.(Counter)
+	if !ok {
+		return Stats{}, ErrStatsUnsupported
+	}
+
+	total, err := c.GetCounters(ctx, statsKey)
+	if err != nil {
+		return Stats{}, err
+	}
+	out := Stats{Tasks: make(map[string]JobStats), Queues: make(map[string]JobStats)}
+	for f, n := range total {
+		st, name, stat, ok := out.field(f)
+		if !ok {
+			continue
+		}
+		switch stat {
+		case statEnqueued:
+			st.Enqueued = n
+		case statDone:
+			st.Done = n
+		case statFailed:
+			st.Failed = n
+		case statRetried:
+			st.Retried = n
+		}
+		out.set(f, name, st)
+	}
+
+	// The minutes are aggregated over the last 15 minutes, with the attempts of the last 1
+	// and 5 complete minutes counted separately.
+	type agg struct {
+		attempts [3]int64
+		duration int64
+		hist     []int64
+	}
+	var (
+		now  = s.clock.Now().Truncate(time.Minute)
+		aggs = make(map[string]*agg)
+	)
+	for i := 0; i <= 15; i++ {
+		m, err := c.GetCounters(ctx, statsMinuteKey(now.Add(-time.Minute*time.Duration(i))))
+		if err != nil {
+			return Stats{}, err
+		}
+		for f, n := range m {
+			// The series is the field without its stat, eg: task:<name>.
+			series, stat := f[:strings.LastIndex(f, ":")+1], f[strings.LastIndex(f, ":")+1:]
+			a, ok := aggs[series]
+			if !ok {
+				a = &agg{hist: make([]int64, len(durationBounds)+1)}
+				aggs[series] = a
+			}
+			switch {
+			case stat == statDone || stat == statFailed || stat == statRetried:
+				// The current minute isn't complete, hence it's only counted in the durations.
+				for j, w := range []int{1, 5, 15} {
+					if i > 0 && i <= w {
+						a.attempts[j] += n
+					}
+				}
+			case stat == statDuration:
+				a.duration += n
+			case strings.HasPrefix(stat, statHistogram):
+				if b, err := strconv.Atoi(stat[len(statHistogram):]); err == nil && b < len(a.hist) {
+					a.hist[b] += n
+				}
+			}
+		}
+	}
+	for series, a := range aggs {
+		st, name, _, ok := out.field(series)
+		if !ok {
+			continue
+		}
+		st.Rate1m = float64(a.attempts[0]) / 60
+		st.Rate5m = float64(a.attempts[1]) / (5 * 60)
+		st.Rate15m = float64(a.attempts[2]) / (15 * 60)
+		st.AvgDuration, st.P95Duration = durationStats(a.duration, a.hist)
+		out.set(series, name, st)
+	}
+
+	return out, nil
+}
+
+// field parses a counter's field (task:<name>:<stat>, or queue:<name>:<stat>) into the task's
+// or queue's stats, its name and the stat.
+func (st Stats) field(f string) (JobStats, string, string, bool) {
+	i, j := strings.Index(f, ":"), strings.LastIndex(f, ":")
+	if i < 0 || i == j {
+		return JobStats{}, "", "", false
+	}
+
+	name, stat := f[i+1:j], f[j+1:]
+	switch f[:i] {
+	case "task":
+		return st.Tasks[name], name, stat, true
+	case "queue":
+		return st.Queues[name], name, stat, true
+	}
+
+	return JobStats{}, "", "", false
+}
+
+// set sets the stats of the field's task or queue.
+func (st Stats) set(f, name string, js JobStats) {
+	if strings.HasPrefix(f, "task:") {
+		st.Tasks[name] = js
+		return
+	}
+	st.Queues[name] = js
+}
+
+// durationStats returns the average and the 95th percentile of the durations in the histogram.
+func durationStats(sum int64, hist []int64) (time.Duration, time.Duration) {
+	var n int64
+	for _, c := range hist {
+		n += c
+	}
+	if n == 0 {
+		return 0, 0
+	}
+
+	var (
+		rank = (n*95 + 99) / 100
+		seen int64
+		p95  = durationBounds[len(durationBounds)-1]
+	)
+	for i, c := range hist {
+		if seen += c; seen >= rank {
+			if i < len(durationBounds) {
+				p95 = durationBounds[i]
+			}
+			break
+		}
+	}
+
+	return time.Duration(sum / n), p95
+}
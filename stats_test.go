@@ -0,0 +1,69 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, Stats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+		if string(b) == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{MaxRetries: 1})
+
+	for _, p := range []string{"ok", "ok", "fail"} {
+		job, err := NewJob(taskName, []byte(p), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The failing job is retried once, and then fails.
+	for i := 0; i < 4; i++ {
+		srv.Process(ctx, <-broker.data)
+	}
+
+	// The rates are of the complete minutes.
+	clock.advance(time.Minute)
+	st, err := srv.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, js := range []JobStats{st.Tasks[taskName], st.Queues[DefaultQueue]} {
+		if js.Enqueued != 3 || js.Done != 2 || js.Failed != 1 || js.Retried != 1 {
+			t.Fatalf("expected 3 enqueued, 2 done, 1 failed and 1 retried, got %+v", js)
+		}
+		if js.Rate1m != float64(4)/60 || js.Rate15m != float64(4)/(15*60) {
+			t.Fatalf("expected 4 attempts in the last minute, got %+v", js)
+		}
+		if js.P95Duration != durationBounds[0] {
+			t.Fatalf("expected the attempts' durations in the first bucket, got %v", js.P95Duration)
+		}
+	}
+}
+
+func TestDurationStats(t *testing.T) {
+	hist := make([]int64, len(durationBounds)+1)
+	hist[durationBucket(time.Millisecond*30)] = 95
+	hist[durationBucket(time.Second*3)] = 5
+
+	avg, p95 := durationStats(int64(time.Second), hist)
+	if avg != time.Millisecond*10 || p95 != time.Millisecond*50 {
+		t.Fatalf("expected 10ms average and 50ms 95th percentile, got %v and %v", avg, p95)
+	}
+}
@@ -0,0 +1,89 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// EnqueueStream() enqueues the job with the payload read from r, which is offloaded onto the
+// blob store as it's read, instead of being held in memory, and returns the assigned UUID. The
+// job's Payload is ignored. The handlers of tasks with TaskOpts.StreamPayload read the payload
+// with JobCtx.PayloadReader(). The blob store has to implement BlobStreamer for the payload to
+// be streamed, else it's read fully before it's stored.
+func (s *Server) EnqueueStream(ctx context.Context, j Job, r io.Reader) (string, error) {
+	if s.blobs == nil {
+		return "", fmt.Errorf("could not enqueue job %s : blob store missing in options", j.Task)
+	}
+
+	j.Payload, j.stream = nil, r
+	return s.Enqueue(ctx, j)
+}
+
+// PayloadReader() returns a reader of the job's payload, which should be closed. If the task
+// streams its payloads (TaskOpts.StreamPayload), the payloads offloaded onto the blob store
+// aren't passed to the handler, and are instead streamed from the blob store by the reader.
+func (c *JobCtx) PayloadReader() (io.ReadCloser, error) {
+	if c.stream == "" {
+		return ioutil.NopCloser(bytes.NewReader(c.payload)), nil
+	}
+
+	r, err := getStream(c, c.blobs, c.stream)
+	if err != nil {
+		return nil, fmt.Errorf("could not stream offloaded payload : %w", err)
+	}
+	return r, nil
+}
+
+// streamed returns true if the job's payload is streamed to the task's handler, instead of
+// being decoded.
+func (t Task) streamed(msg JobMessage) bool {
+	return t.opts.StreamPayload && msg.PayloadEncoding == encodingBlob
+}
+
+// jobPayload returns the payload passed to the task's handler, setting it up on the job's
+// context to be read with PayloadReader().
+func (s *Server) jobPayload(ctx context.Context, msg JobMessage, task Task, c *JobCtx) ([]byte, error) {
+	if task.streamed(msg) {
+		c.blobs, c.stream = s.blobs, string(msg.Job.Payload)
+		return nil, nil
+	}
+
+	b, err := s.decodePayload(ctx, msg)
+	c.payload = b
+	return b, err
+}
+
+// putStream writes the blob from r, streaming it if the blob store implements BlobStreamer.
+func putStream(ctx context.Context, b BlobStore, key string, r io.Reader) error {
+	if b == nil {
+		return fmt.Errorf("blob store missing in options")
+	}
+	if bs, ok := b.(BlobStreamer); ok {
+		return bs.PutStream(ctx, key, r)
+	}
+
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return b.Put(ctx, key, d)
+}
+
+// getStream returns a reader of the blob, streaming it if the blob store implements BlobStreamer.
+func getStream(ctx context.Context, b BlobStore, key string) (io.ReadCloser, error) {
+	if b == nil {
+		return nil, fmt.Errorf("blob store missing in options")
+	}
+	if bs, ok := b.(BlobStreamer); ok {
+		return bs.GetStream(ctx, key)
+	}
+
+	d, err := b.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(d)), nil
+}
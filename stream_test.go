@@ -0,0 +1,89 @@
+package tasqueue
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	fs "github.com/kalbhor/tasqueue/blobs/fs"
+)
+
+func TestEnqueueStream(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		payload = strings.Repeat("tasqueue", 1024)
+	)
+	for _, stream := range []bool{false, true} {
+		blobs, err := fs.New(fs.Options{Dir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		broker := NewMockBroker()
+		srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), BlobStore: blobs})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got, passed []byte
+		srv.RegisterTask(taskName, func(b []byte, c JobCtx) error {
+			passed = b
+			r, err := c.PayloadReader()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			got, err = ioutil.ReadAll(r)
+			return err
+		}, TaskOpts{StreamPayload: stream})
+
+		job, err := NewJob(taskName, nil, JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.EnqueueStream(ctx, job, strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The payload is offloaded onto the blob store, and the job carries a reference to it.
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.PayloadEncoding != encodingBlob || len(msg.Job.Payload) > 64 {
+			t.Fatalf("expected the payload to be offloaded, got %d bytes with encoding %q", len(msg.Job.Payload), msg.PayloadEncoding)
+		}
+
+		srv.Process(ctx, <-broker.data)
+		if string(got) != payload {
+			t.Fatalf("expected the payload to be read, got %d bytes", len(got))
+		}
+		// Streamed payloads aren't passed to the handler.
+		if stream && passed != nil {
+			t.Fatal("expected the streamed payload to not be passed to the handler")
+		}
+		if !stream && !bytes.Equal(passed, []byte(payload)) {
+			t.Fatal("expected the payload to be passed to the handler")
+		}
+
+		msg, err = srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != StatusDone {
+			t.Fatalf("expected the job to succeed, got %s : %s", msg.Status, msg.PrevErr)
+		}
+	}
+
+	// A blob store is required.
+	srv, err := NewServer(ServerOpts{Broker: NewMockBroker(), Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _ := NewJob(taskName, nil, JobOpts{})
+	if _, err := srv.EnqueueStream(ctx, job, strings.NewReader(payload)); err == nil {
+		t.Fatal("expected streaming a payload without a blob store to fail")
+	}
+}
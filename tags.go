@@ -0,0 +1,121 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTagsUnsupported is returned by the operations which index jobs by tag (eg: tags, gates,
+// debouncing and deduplication), if the results store doesn't implement Tagger.
+var ErrTagsUnsupported = errors.New("results store doesn't support tags")
+
+// setTags adds the job to the index of each of its tags.
+func (s *Server) setTags(ctx context.Context, msg JobMessage) error {
+	if s.results == nil {
+		return nil
+	}
+
+	for _, tag := range msg.Tags {
+		if err := setTag(ctx, s.results, tag, msg.UUID); err != nil {
+			return fmt.Errorf("could not set job tag %s : %w", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// GetJobsByTag() returns the job messages of the jobs with the tag. If status is not
+// empty, only the jobs currently in that status are returned.
+func (s *Server) GetJobsByTag(ctx context.Context, tag, status string) ([]JobMessage, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
+
+	uuids, err := getTag(ctx, s.results, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []JobMessage
+	for _, uuid := range uuids {
+		msg, err := s.GetJob(ctx, uuid)
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || msg.Status == status {
+			out = append(out, msg)
+		}
+	}
+
+	return out, nil
+}
+
+// CancelByTag() cancels all the queued (or retrying) jobs with the tag and returns the number
+// of jobs cancelled. Jobs that are being processed or are complete, or that the caller isn't
+// authorized to cancel, are skipped.
+func (s *Server) CancelByTag(ctx context.Context, tag string) (int, error) {
+	msgs, err := s.GetJobsByTag(ctx, tag, "")
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, msg := range msgs {
+		if err := s.Cancel(ctx, msg.UUID); err != nil {
+			if errors.Is(err, ErrJobNotCancellable) || errors.Is(err, ErrUnauthorized) {
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// RetryByTag() re-enqueues all the failed jobs with the tag and returns the number of jobs retried.
+// Jobs that the caller isn't authorized to retry are skipped.
+func (s *Server) RetryByTag(ctx context.Context, tag string) (int, error) {
+	msgs, err := s.GetJobsByTag(ctx, tag, StatusFailed)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, msg := range msgs {
+		if err := s.Retry(ctx, msg.UUID); err != nil {
+			if errors.Is(err, ErrUnauthorized) {
+				continue
+			}
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// setTag adds the job to the tag's index, if the store implements Tagger.
+func setTag(ctx context.Context, r Results, tag, uuid string) error {
+	if t, ok := r.(Tagger); ok {
+		return t.SetTag(ctx, tag, uuid)
+	}
+	return ErrTagsUnsupported
+}
+
+// getTag returns the jobs in the tag's index, if the store implements Tagger.
+func getTag(ctx context.Context, r Results, tag string) ([]string, error) {
+	if t, ok := r.(Tagger); ok {
+		return t.GetTag(ctx, tag)
+	}
+	return nil, ErrTagsUnsupported
+}
+
+// deleteTag removes the job from the tag's index, if the store implements Tagger.
+func deleteTag(ctx context.Context, r Results, tag, uuid string) error {
+	if t, ok := r.(Tagger); ok {
+		return t.DeleteTag(ctx, tag, uuid)
+	}
+	return ErrTagsUnsupported
+}
@@ -0,0 +1,74 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJobsByTag(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+	)
+
+	for _, f := range []bool{true, true, false} {
+		job := makeJob(t, f)
+		job.Opts.Tags = []string{"import"}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cancel a job with a different tag before the server starts consuming.
+	job := makeJob(t, false)
+	job.Opts.Tags = []string{"export"}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := srv.CancelByTag(ctx, "export"); err != nil || n != 1 {
+		t.Fatalf("expected 1 job cancelled, got %d, err %v", n, err)
+	}
+
+	go srv.Start(ctx)
+	// Wait for jobs to be consumed & processed.
+	time.Sleep(time.Second)
+
+	failed, err := srv.GetJobsByTag(ctx, "import", StatusFailed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("expected 2 failed jobs, got %d", len(failed))
+	}
+
+	if n, err := srv.RetryByTag(ctx, "import"); err != nil || n != 2 {
+		t.Fatalf("expected 2 jobs retried, got %d, err %v", n, err)
+	}
+
+	// The retried jobs fail again, and are in the failed list and the tag once.
+	time.Sleep(time.Second)
+	uuids, err := srv.GetFailed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uuids) != 2 {
+		t.Fatalf("expected 2 failed jobs, got %v", uuids)
+	}
+	all, err := srv.GetJobsByTag(ctx, "import", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 jobs with the tag, got %d", len(all))
+	}
+
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusCancelled {
+		t.Fatalf("incorrect job status, expected %s, got %s", StatusCancelled, msg.Status)
+	}
+}
@@ -0,0 +1,188 @@
+package tasqueuetest
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const pollPeriod = time.Millisecond * 10
+
+// Message is a job message enqueued on the broker.
+type Message struct {
+	Queue string
+	Job   tasqueue.JobMessage
+
+	b []byte
+}
+
+// Broker is an in-memory broker which records the messages enqueued on it, so that
+// they can be asserted on, and consumed one by one.
+type Broker struct {
+	mu       sync.Mutex
+	pending  []Message
+	enqueued []Message
+	queues   map[string]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{queues: make(map[string]struct{})}
+}
+
+func (b *Broker) Enqueue(_ context.Context, msg []byte, queue string) error {
+	var j tasqueue.JobMessage
+	if err := msgpack.Unmarshal(msg, &j); err != nil {
+		return fmt.Errorf("could not decode job message: %w", err)
+	}
+
+	m := Message{Queue: queue, Job: j, b: msg}
+	b.mu.Lock()
+	b.pending = append(b.pending, m)
+	b.enqueued = append(b.enqueued, m)
+	b.queues[queue] = struct{}{}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Consume polls the queue for pending messages, for servers started with Start().
+func (b *Broker) Consume(ctx context.Context, work chan []byte, queue string) {
+	tk := time.NewTicker(pollPeriod)
+	defer tk.Stop()
+
+	for {
+		for {
+			m, ok := b.take(func(m Message) bool { return m.Queue == queue })
+			if !ok {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case work <- m.b:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tk.C:
+		}
+	}
+}
+
+func (b *Broker) Queues(_ context.Context, pattern string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []string
+	for q := range b.queues {
+		ok, err := path.Match(pattern, q)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, q)
+		}
+	}
+
+	return out, nil
+}
+
+func (b *Broker) GetPending(_ context.Context, queue string, n int) ([][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out [][]byte
+	for _, m := range b.pending {
+		if len(out) == n {
+			break
+		}
+		if m.Queue == queue {
+			out = append(out, m.b)
+		}
+	}
+
+	return out, nil
+}
+
+// Depth returns the number of the queue's pending messages.
+func (b *Broker) Depth(_ context.Context, queue string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var n int64
+	for _, m := range b.pending {
+		if m.Queue == queue {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Trim removes the queue's oldest pending messages, until it has n pending messages.
+func (b *Broker) Trim(_ context.Context, queue string, n int64) ([][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var count int64
+	for _, m := range b.pending {
+		if m.Queue == queue {
+			count++
+		}
+	}
+
+	var (
+		out     [][]byte
+		pending = b.pending[:0]
+	)
+	for _, m := range b.pending {
+		if m.Queue == queue && count > n {
+			out = append(out, m.b)
+			count--
+			continue
+		}
+		pending = append(pending, m)
+	}
+	b.pending = pending
+
+	return out, nil
+}
+
+// Enqueued returns all the messages enqueued on the broker, including the consumed ones,
+// in the order they were enqueued.
+func (b *Broker) Enqueued() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.enqueued...)
+}
+
+// Pending returns the messages waiting to be consumed.
+func (b *Broker) Pending() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Message(nil), b.pending...)
+}
+
+// take removes and returns the first pending message matching fn.
+func (b *Broker) take(fn func(Message) bool) (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, m := range b.pending {
+		if fn(m) {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			return m, true
+		}
+	}
+
+	return Message{}, false
+}
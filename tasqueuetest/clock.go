@@ -0,0 +1,95 @@
+package tasqueuetest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock, which only moves when it is advanced. It implements tasqueue.Clock,
+// so that the server's schedules, delays and intervals can be fast-forwarded.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock() returns a clock set to the time.
+func NewClock(t time.Time) *Clock {
+	c := &Clock{now: t}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel which receives the time once the clock is advanced by the duration.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+
+	return ch
+}
+
+// Advance() moves the clock forward by the duration, firing the timers that are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.set(c.now.Add(d))
+	c.mu.Unlock()
+}
+
+// Set() sets the clock to the time, firing the timers that are due.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.set(t)
+	c.mu.Unlock()
+}
+
+// BlockUntil() blocks until n timers are waiting on the clock, eg: to advance the clock
+// only after a scheduled job or a held back job is waiting.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+	c.mu.Unlock()
+}
+
+// set sets the time and fires the due timers in order. It should be called with the lock held.
+func (c *Clock) set(t time.Time) {
+	c.now = t
+
+	sort.Slice(c.waiters, func(i, j int) bool {
+		return c.waiters[i].at.Before(c.waiters[j].at)
+	})
+	n := 0
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			break
+		}
+		w.ch <- t
+		n++
+	}
+	c.waiters = c.waiters[n:]
+}
@@ -0,0 +1,159 @@
+// Package tasqueuetest provides a server backed by in-memory fakes (a broker, results store
+// and clock), with helpers to process jobs and assert on them, so that applications can
+// unit-test their tasks without a broker or results store.
+package tasqueuetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+type Options struct {
+	// Server configures the harness's server. Its broker, results store and clock are
+	// set by the harness.
+	Server tasqueue.ServerOpts
+
+	// Now is the initial time of the fake clock. Defaults to the current time.
+	Now time.Time
+}
+
+// Harness is a server with in-memory fakes. Jobs aren't consumed on enqueue, and are
+// processed synchronously with ProcessOne() or Drain().
+type Harness struct {
+	*tasqueue.Server
+
+	Broker  *Broker
+	Results tasqueue.Results
+	Clock   *Clock
+}
+
+// New() returns a harness. Tasks are registered on it as on a server.
+func New(t testing.TB, o Options) *Harness {
+	t.Helper()
+
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	h := &Harness{
+		Broker:  NewBroker(),
+		Results: rr.New(),
+		Clock:   NewClock(o.Now),
+	}
+
+	o.Server.Broker = h.Broker
+	o.Server.Results = h.Results
+	o.Server.Clock = h.Clock
+	srv, err := tasqueue.NewServer(o.Server)
+	if err != nil {
+		t.Fatalf("could not create server: %v", err)
+	}
+	h.Server = srv
+
+	return h
+}
+
+// ProcessOne() enqueues a job of the task with the payload, processes it with the
+// registered handler and returns the resulting job message.
+func (h *Harness) ProcessOne(t testing.TB, task string, payload []byte) tasqueue.JobMessage {
+	t.Helper()
+
+	j, err := tasqueue.NewJob(task, payload, tasqueue.JobOpts{})
+	if err != nil {
+		t.Fatalf("could not create job: %v", err)
+	}
+
+	return h.ProcessJob(t, j)
+}
+
+// ProcessJob() enqueues the job, processes it and returns the resulting job message.
+// Jobs enqueued by the handler (eg: the next job of a chain) are left pending.
+func (h *Harness) ProcessJob(t testing.TB, j tasqueue.Job) tasqueue.JobMessage {
+	t.Helper()
+
+	ctx := context.Background()
+	uuid, err := h.Enqueue(ctx, j)
+	if err != nil {
+		t.Fatalf("could not enqueue job: %v", err)
+	}
+	m, ok := h.Broker.take(func(m Message) bool { return m.Job.UUID == uuid })
+	if !ok {
+		t.Fatalf("job %s was not enqueued on the broker", uuid)
+	}
+	h.Process(ctx, m.b)
+
+	msg, err := h.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatalf("could not get job: %v", err)
+	}
+
+	return msg
+}
+
+// Drain() processes the pending jobs, including the ones enqueued while processing
+// (eg: retries and chains), until none are left. It returns the number of jobs processed.
+func (h *Harness) Drain(t testing.TB) int {
+	t.Helper()
+
+	n := 0
+	for {
+		m, ok := h.Broker.take(func(Message) bool { return true })
+		if !ok {
+			return n
+		}
+		h.Process(context.Background(), m.b)
+		n++
+	}
+}
+
+// AssertEnqueued() fails the test if no job of the task, matched by match (if it's
+// non-nil), has been enqueued.
+func (h *Harness) AssertEnqueued(t testing.TB, task string, match func(tasqueue.JobMessage) bool) {
+	t.Helper()
+
+	if len(h.enqueued(task, match)) == 0 {
+		t.Errorf("expected a matching job of task %q to be enqueued", task)
+	}
+}
+
+// AssertNotEnqueued() fails the test if a job of the task, matched by match (if it's
+// non-nil), has been enqueued.
+func (h *Harness) AssertNotEnqueued(t testing.TB, task string, match func(tasqueue.JobMessage) bool) {
+	t.Helper()
+
+	if n := len(h.enqueued(task, match)); n > 0 {
+		t.Errorf("expected no matching job of task %q to be enqueued, found %d", task, n)
+	}
+}
+
+// AssertStatus() fails the test if the job's status isn't the status.
+func (h *Harness) AssertStatus(t testing.TB, uuid, status string) {
+	t.Helper()
+
+	msg, err := h.GetJob(context.Background(), uuid)
+	if err != nil {
+		t.Errorf("could not get job %s: %v", uuid, err)
+		return
+	}
+	if msg.Status != status {
+		t.Errorf("expected job %s to be %s, got %s", uuid, status, msg.Status)
+	}
+}
+
+// enqueued returns the job messages of the task enqueued so far, matched by match.
+func (h *Harness) enqueued(task string, match func(tasqueue.JobMessage) bool) []tasqueue.JobMessage {
+	var out []tasqueue.JobMessage
+	for _, m := range h.Broker.Enqueued() {
+		if m.Job.Job.Task != task {
+			continue
+		}
+		if match == nil || match(m.Job) {
+			out = append(out, m.Job)
+		}
+	}
+
+	return out
+}
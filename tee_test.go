@@ -0,0 +1,71 @@
+package tasqueue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue"
+	bi "github.com/kalbhor/tasqueue/brokers/in-memory"
+	"github.com/kalbhor/tasqueue/brokers/tee"
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestTeeBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The shadow side fails the jobs with an odd payload, which the primary side processes.
+	var (
+		shadow = bi.New()
+		b      = tee.New(bi.New(), shadow, tee.Options{})
+		srvs   = make([]*tasqueue.Server, 2)
+	)
+	for i, br := range []tasqueue.Broker{b, shadow} {
+		i := i
+		srv, err := tasqueue.NewServer(tasqueue.ServerOpts{Broker: br, Results: rr.New()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.RegisterTask("tee", func(p []byte, _ tasqueue.JobCtx) error {
+			if i == 1 && p[0]%2 == 1 {
+				return errors.New("shadow failed")
+			}
+			return nil
+		}, tasqueue.TaskOpts{MaxRetries: 0})
+		go srv.Start(ctx)
+		srvs[i] = srv
+	}
+
+	for i := byte(0); i < 4; i++ {
+		j, err := tasqueue.NewJob("tee", []byte{i}, tasqueue.JobOpts{MaxRetries: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srvs[0].Enqueue(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		r   tee.Report
+		err error
+	)
+	for start := time.Now(); time.Since(start) < time.Second*5; time.Sleep(time.Millisecond * 50) {
+		if r, err = b.Compare(ctx, srvs[0], srvs[1]); err != nil {
+			t.Fatal(err)
+		}
+		if r.Compared == 4 {
+			break
+		}
+	}
+	if r.Enqueued != 4 || r.Compared != 4 || r.Matched != 2 || len(r.Mismatches) != 2 {
+		t.Fatalf("expected 2 of the 4 jobs to mismatch, got %+v", r)
+	}
+	for _, m := range r.Mismatches {
+		if m.Task != "tee" || m.Primary != tasqueue.StatusDone || m.Shadow != tasqueue.StatusFailed {
+			t.Fatalf("unexpected mismatch %+v", m)
+		}
+	}
+}
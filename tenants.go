@@ -0,0 +1,137 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// tenantRequeueDelay is the delay after which a job held back by its tenant's
+// concurrency quota is pushed back onto the queue.
+const tenantRequeueDelay = time.Millisecond * 100
+
+// TenantQuota limits the jobs of a tenant processed by a server. Jobs of a tenant that
+// is over its quota are pushed back onto the queue, so that they don't hold up the workers
+// processing other tenants' jobs.
+type TenantQuota struct {
+	// Concurrency is the maximum number of the tenant's jobs processed concurrently.
+	// If it is zero, the concurrency is not limited.
+	Concurrency uint32
+	// Rate is the maximum number of the tenant's jobs started per second.
+	// If it is zero, the rate is not limited.
+	Rate float64
+}
+
+// TenantQueue returns the name of a tenant's namespaced queue.
+func TenantQueue(queue, tenant string) string {
+	return queue + ":" + tenant
+}
+
+// tenantLimiter enforces a tenant's quota. The rate is enforced using a token bucket.
+type tenantLimiter struct {
+	quota TenantQuota
+	clock Clock
+
+	mu      sync.Mutex
+	running uint32
+	bucket  *bucket
+}
+
+func newTenantLimiter(q TenantQuota, c Clock) *tenantLimiter {
+	return &tenantLimiter{
+		quota:  q,
+		clock:  c,
+		bucket: newBucket(q.Rate, c.Now()),
+	}
+}
+
+// acquire reserves a slot for one of the tenant's jobs. If the quota is exhausted,
+// it returns false and the duration after which the job should be retried.
+func (l *tenantLimiter) acquire() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.quota.Concurrency > 0 && l.running >= l.quota.Concurrency {
+		return tenantRequeueDelay, false
+	}
+
+	if l.quota.Rate > 0 {
+		if wait, ok := l.bucket.take(l.quota.Rate, l.clock.Now()); !ok {
+			return wait, false
+		}
+	}
+
+	l.running++
+	return 0, true
+}
+
+// release frees the slot reserved by acquire.
+func (l *tenantLimiter) release() {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+}
+
+// requeueLater pushes a job message, held back by its tenant's quota or a rate limit, back
+// onto its queue after the delay. The job's status is left unchanged. Jobs held back when the
+// context is cancelled (ie: the server stops) are pushed back right away, and the server waits
+// for them to be pushed back before it stops.
+func (s *Server) requeueLater(ctx context.Context, b []byte, queue string, delay time.Duration) {
+	s.track(queue, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-ctx.Done():
+		case <-s.clock.After(delay):
+		}
+		s.pushBack(b, queue)
+		s.track(queue, -1)
+	}()
+}
+
+// pushBack pushes a job that was held back onto the queue. The server's context may be
+// cancelled, hence a new one is used to not lose the job. The job keeps its priority.
+func (s *Server) pushBack(b []byte, queue string) {
+	var k deadlineKey
+	msgpack.Unmarshal(b, &k)
+	if err := enqueuePriority(context.Background(), s.broker, b, queue, k.Priority); err != nil {
+		s.log.Error("could not requeue held back job", "error", err)
+	}
+}
+
+// GetTenantJobs() returns the uuid's of a tenant's jobs that either failed or were successful
+// (depending on the status), by filtering the failed or successful jobs in the results store.
+func (s *Server) GetTenantJobs(ctx context.Context, tenant, status string) ([]string, error) {
+	var (
+		uuids []string
+		err   error
+	)
+	switch status {
+	case StatusFailed:
+		uuids, err = s.GetFailed(ctx)
+	case StatusDone:
+		uuids, err = s.GetSuccess(ctx)
+	default:
+		return nil, fmt.Errorf("tenant jobs can only be filtered by status %s or %s", StatusFailed, StatusDone)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, uuid := range uuids {
+		msg, err := s.GetJob(ctx, uuid)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Tenant == tenant {
+			out = append(out, uuid)
+		}
+	}
+
+	return out, nil
+}
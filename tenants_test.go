@@ -0,0 +1,120 @@
+package tasqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestTenantQuota(t *testing.T) {
+	const tenant = "tenant-a"
+
+	srv, err := NewServer(ServerOpts{
+		Broker:       NewMockBroker(),
+		Results:      NewMockResults(),
+		TenantQueues: true,
+		TenantQuotas: map[string]TenantQuota{
+			tenant: {Concurrency: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	srv.RegisterTask(taskName, func(_ []byte, _ JobCtx) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 50)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, TaskOpts{Concurrency: 4, Tenants: []string{tenant}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Start(ctx)
+
+	var uuids []string
+	for i := 0; i < 4; i++ {
+		job, err := NewJob(taskName, nil, JobOpts{Tenant: tenant})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuid, err := srv.Enqueue(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	// Wait for the jobs to be consumed & processed.
+	time.Sleep(time.Second)
+	for _, uuid := range uuids {
+		msg, err := srv.GetJob(ctx, uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Status != StatusDone {
+			t.Fatalf("incorrect job status, expected %s, got %s", StatusDone, msg.Status)
+		}
+		if msg.Queue != TenantQueue(DefaultQueue, tenant) {
+			t.Fatalf("incorrect job queue, expected %s, got %s", TenantQueue(DefaultQueue, tenant), msg.Queue)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 1 {
+		t.Fatalf("tenant concurrency quota exceeded, expected 1, got %d", peak)
+	}
+
+	tenantJobs, err := srv.GetTenantJobs(ctx, tenant, StatusDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenantJobs) != len(uuids) {
+		t.Fatalf("incorrect tenant jobs, expected %d, got %d", len(uuids), len(tenantJobs))
+	}
+}
+
+func TestRequeueLater(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		broker      = &priorityBroker{MockBroker: NewMockBroker()}
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:  broker,
+		Results: NewMockResults(),
+		Clock:   newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := msgpack.Marshal(JobMessage{Meta: Meta{UUID: "a", Priority: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.requeueLater(ctx, b, DefaultQueue, time.Hour)
+
+	// The held back job is pushed back with its priority once the server stops.
+	cancel()
+	srv.wg.Wait()
+	if len(broker.data) != 1 || len(broker.priorities) != 1 || broker.priorities[0] != 5 {
+		t.Fatalf("expected the job to be requeued with priority 5, got %v", broker.priorities)
+	}
+}
@@ -0,0 +1,48 @@
+package tasqueue
+
+import "time"
+
+// Attempt is the timing of an attempt of a job, and its error if it failed.
+type Attempt struct {
+	StartedAt time.Time
+	EndedAt   time.Time
+	Error     string `msgpack:",omitempty"`
+}
+
+// Duration returns the duration for which the attempt ran, or zero if it hasn't ended.
+func (a Attempt) Duration() time.Duration {
+	if a.EndedAt.IsZero() {
+		return 0
+	}
+	return a.EndedAt.Sub(a.StartedAt)
+}
+
+// startAttempt records the start of an attempt of the job, and the time the job waited on
+// the queue for it since it was enqueued, or since the end of the previous attempt.
+func (m *JobMessage) startAttempt(now time.Time) {
+	since := m.EnqueuedAt
+	if n := len(m.Attempts); n > 0 {
+		since = m.Attempts[n-1].EndedAt
+	}
+	if m.PickedUpAt.IsZero() {
+		m.PickedUpAt = now
+	}
+	if !since.IsZero() && now.After(since) {
+		m.QueueWait += now.Sub(since)
+	}
+	m.Attempts = append(m.Attempts, Attempt{StartedAt: now})
+}
+
+// endAttempt records the end of the job's current attempt.
+func (m *JobMessage) endAttempt(now time.Time, err error) {
+	n := len(m.Attempts)
+	if n == 0 {
+		return
+	}
+	// The attempts are copied, so that the copies of the message passed by value don't share them.
+	m.Attempts = append([]Attempt(nil), m.Attempts...)
+	m.Attempts[n-1].EndedAt = now
+	if err != nil {
+		m.Attempts[n-1].Error = err.Error()
+	}
+}
@@ -0,0 +1,70 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobTimings(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		start  = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock  = newMockClock(start)
+		runs   int
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		runs++
+		clock.advance(time.Second)
+		if runs == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{})
+
+	job, err := NewJob(taskName, nil, JobOpts{MaxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job waits 5s for its first attempt, and 2s for its retry.
+	clock.advance(time.Second * 5)
+	srv.Process(ctx, <-broker.data)
+	clock.advance(time.Second * 2)
+	srv.Process(ctx, <-broker.data)
+
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone {
+		t.Fatalf("expected the job to be done, got %s", msg.Status)
+	}
+	if !msg.EnqueuedAt.Equal(start) || !msg.PickedUpAt.Equal(start.Add(time.Second*5)) {
+		t.Fatalf("unexpected enqueue and pickup times %v, %v", msg.EnqueuedAt, msg.PickedUpAt)
+	}
+	if msg.QueueWait != time.Second*7 {
+		t.Fatalf("expected a queue wait of 7s, got %v", msg.QueueWait)
+	}
+
+	if len(msg.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %v", msg.Attempts)
+	}
+	first, second := msg.Attempts[0], msg.Attempts[1]
+	if !first.StartedAt.Equal(start.Add(time.Second*5)) || first.Duration() != time.Second || first.Error != "failed" {
+		t.Fatalf("unexpected first attempt %+v", first)
+	}
+	if !second.StartedAt.Equal(start.Add(time.Second*8)) || second.Duration() != time.Second || second.Error != "" {
+		t.Fatalf("unexpected second attempt %+v", second)
+	}
+}
@@ -0,0 +1,111 @@
+package tasqueue
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	spans "go.opentelemetry.io/otel/trace"
+)
+
+// The attributes of the spans of a job.
+const (
+	attrTask    = attribute.Key("tasqueue.task")
+	attrQueue   = attribute.Key("tasqueue.queue")
+	attrUUID    = attribute.Key("tasqueue.uuid")
+	attrAttempt = attribute.Key("tasqueue.attempt")
+)
+
+// traceCarrier returns the span context of the context's span, in the W3C trace context
+// format, or nil if there's no span.
+func traceCarrier(ctx context.Context) map[string]string {
+	if !spans.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+
+	c := make(map[string]string)
+	propagation.TraceContext{}.Inject(ctx, propagation.MapCarrier(c))
+	return c
+}
+
+// jobAttributes returns the attributes of the spans of the job.
+func jobAttributes(msg JobMessage) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attrQueue.String(msg.Queue),
+		attrUUID.String(msg.UUID),
+		attrAttempt.Int(int(msg.Retried) + 1),
+	}
+	if msg.Job != nil {
+		attrs = append(attrs, attrTask.String(msg.Job.Task))
+	}
+
+	return attrs
+}
+
+// jobSpanOpts returns the options of the spans of an attempt of the job: its attributes, and
+// a link to the span the job was originally enqueued with. The attempts of a job (and the
+// jobs of a chain) run in different traces than the enqueue, hence the link.
+func jobSpanOpts(msg JobMessage) []spans.SpanStartOption {
+	opts := []spans.SpanStartOption{spans.WithAttributes(jobAttributes(msg)...)}
+
+	if len(msg.TraceLink) > 0 {
+		ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier(msg.TraceLink))
+		if sc := spans.SpanContextFromContext(ctx); sc.IsValid() {
+			opts = append(opts, spans.WithLinks(spans.Link{SpanContext: sc}))
+		}
+	}
+
+	return opts
+}
+
+// TraceSampling configures the fraction of the processed jobs that are traced, eg: to limit
+// the overhead of tracing on high throughput workers.
+type TraceSampling struct {
+	// Rate is the fraction (0 to 1) of the jobs traced. Defaults to 1, all the jobs.
+	Rate float64
+	// Tasks is a map of task -> rate, which overrides the Rate for the task's jobs.
+	Tasks map[string]float64
+}
+
+// rate returns the sampling rate of the task's jobs.
+func (t TraceSampling) rate(task string) float64 {
+	if r, ok := t.Tasks[task]; ok {
+		return r
+	}
+	if t.Rate == 0 {
+		return 1
+	}
+	return t.Rate
+}
+
+// unsampledKey marks the context of a job that isn't traced.
+type unsampledKey struct{}
+
+// tracing returns true if spans are created in the context.
+func (s *Server) tracing(ctx context.Context) bool {
+	return s.traceProv != nil && ctx.Value(unsampledKey{}) == nil
+}
+
+// sampleTrace returns the context the job is processed in, which is marked if the job isn't
+// sampled. Jobs are sampled by their UUID, so that all the attempts of a job are traced, or
+// none of them.
+func (s *Server) sampleTrace(ctx context.Context, msg JobMessage) context.Context {
+	if !s.tracing(ctx) || msg.Job == nil {
+		return ctx
+	}
+
+	rate := s.sampling.rate(msg.Job.Task)
+	if rate >= 1 {
+		return ctx
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(msg.UUID))
+	if float64(h.Sum64())/math.MaxUint64 < rate {
+		return ctx
+	}
+
+	return context.WithValue(ctx, unsampledKey{}, struct{}{})
+}
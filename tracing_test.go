@@ -0,0 +1,144 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceLinks(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		rec    = tracetest.NewSpanRecorder()
+		tp     = trace.NewTracerProvider(trace.WithSpanProcessor(rec))
+		runs   int
+	)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), TraceProvider: tp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		runs++
+		if runs == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}, TaskOpts{})
+
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	srv.Process(ctx, <-broker.data)
+
+	var (
+		enqueue trace.ReadOnlySpan
+		execs   []trace.ReadOnlySpan
+	)
+	for _, s := range rec.Ended() {
+		switch s.Name() {
+		case "enqueue_with_meta":
+			enqueue = s
+		case "exec_job":
+			execs = append(execs, s)
+		}
+	}
+	if enqueue == nil || len(execs) != 2 {
+		t.Fatalf("expected an enqueue span and 2 exec spans, got %v, %d", enqueue, len(execs))
+	}
+
+	// Both attempts are linked to the enqueue span, which is in a different trace.
+	for i, s := range execs {
+		if s.SpanContext().TraceID() == enqueue.SpanContext().TraceID() {
+			t.Fatalf("expected attempt %d to be in a different trace than the enqueue", i+1)
+		}
+		links := s.Links()
+		if len(links) != 1 || links[0].SpanContext.SpanID() != enqueue.SpanContext().SpanID() {
+			t.Fatalf("expected attempt %d to be linked to the enqueue span, got %v", i+1, links)
+		}
+
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, a := range s.Attributes() {
+			attrs[a.Key] = a.Value
+		}
+		if attrs[attrUUID].AsString() != uuid || attrs[attrTask].AsString() != taskName ||
+			attrs[attrQueue].AsString() != DefaultQueue || attrs[attrAttempt].AsInt64() != int64(i+1) {
+			t.Fatalf("unexpected attributes of attempt %d : %v", i+1, attrs)
+		}
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		rec    = tracetest.NewSpanRecorder()
+		tp     = trace.NewTracerProvider(trace.WithSpanProcessor(rec))
+	)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	srv, err := NewServer(ServerOpts{
+		Broker:        broker,
+		Results:       NewMockResults(),
+		TraceProvider: tp,
+		TraceSampling: TraceSampling{Rate: 0.5, Tasks: map[string]float64{"noisy": 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noop := func([]byte, JobCtx) error { return nil }
+	srv.RegisterTask(taskName, noop, TaskOpts{})
+	srv.RegisterTask("noisy", noop, TaskOpts{})
+
+	count := func(name string) int {
+		n := 0
+		for _, s := range rec.Ended() {
+			if s.Name() == name {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The jobs of a task with a zero rate aren't traced.
+	job, err := NewJob("noisy", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+	}
+	if n := count("process") + count("exec_job") + count("status_done"); n != 0 {
+		t.Fatalf("expected the jobs not to be traced, got %d spans", n)
+	}
+
+	// Other jobs are sampled at the server's rate.
+	for i := 0; i < 200; i++ {
+		if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+	}
+	if n := count("process"); n < 50 || n > 150 {
+		t.Fatalf("expected about half the jobs to be traced, got %d", n)
+	}
+	if count("process") != count("exec_job") {
+		t.Fatalf("expected the spans of a traced job to be traced together")
+	}
+}
@@ -0,0 +1,90 @@
+package tasqueue
+
+import (
+	"context"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// DefaultParkQueue is the queue on which jobs of unknown tasks are parked.
+	DefaultParkQueue = "tasqueue:parked"
+
+	defaultUnknownTaskDelay    = time.Second * 5
+	defaultUnknownTaskRequeues = 10
+)
+
+// UnknownTaskOpts configures the handling of jobs consumed by a server that doesn't have
+// their task registered, eg: when a new task is deployed to the producers before the workers,
+// or a task is unregistered while its jobs are queued. Such jobs are pushed back onto their
+// queue after the Delay (5s by default), upto MaxRequeues (10 by default) times, to be picked
+// up by a worker that has the task. They're then parked on the ParkQueue, from which they
+// can be released with ReleaseParked(). The job's status is left unchanged.
+type UnknownTaskOpts struct {
+	Delay       time.Duration
+	MaxRequeues uint32
+	ParkQueue   string
+}
+
+// requeueUnknown pushes a job of an unknown task back onto its queue, or parks it if
+// it has been requeued too many times.
+func (s *Server) requeueUnknown(ctx context.Context, msg JobMessage) {
+	queue := msg.Queue
+	if msg.Requeues >= s.unknown.MaxRequeues {
+		queue = s.unknown.ParkQueue
+	} else {
+		msg.Requeues++
+	}
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		s.log.Error("error marshalling job of unknown task", "error", err)
+		return
+	}
+
+	if queue == s.unknown.ParkQueue {
+		s.log.Info("parking job of unknown task", "uuid", msg.UUID, "task", msg.Job.Task)
+		if err := s.broker.Enqueue(ctx, b, queue); err != nil {
+			s.log.Error("could not park job", "uuid", msg.UUID, "error", err)
+			return
+		}
+		s.metrics.GetOrCreateCounter(metricJobsParked).Inc()
+		return
+	}
+
+	s.log.Debug("task not registered, requeuing job", "uuid", msg.UUID, "task", msg.Job.Task, "requeues", msg.Requeues)
+	s.requeueLater(ctx, b, queue, s.unknown.Delay)
+}
+
+// ReleaseParked() consumes the park queue, pushing the parked jobs back onto their queues
+// with their requeue count reset, eg: once the workers with the task are deployed. It is
+// a blocking function, which returns after the context is cancelled.
+func (s *Server) ReleaseParked(ctx context.Context) {
+	work := make(chan []byte)
+	go s.broker.Consume(ctx, work, s.unknown.ParkQueue)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b := <-work:
+			var msg JobMessage
+			if err := msgpack.Unmarshal(b, &msg); err != nil {
+				s.log.Error("error unmarshalling parked job", "error", err)
+				continue
+			}
+
+			msg.Requeues = 0
+			if err := s.enqueueMessage(ctx, msg); err != nil {
+				s.log.Error("could not release parked job", "uuid", msg.UUID, "error", err)
+				// Park the job again, so that it isn't lost.
+				if err := s.broker.Enqueue(ctx, b, s.unknown.ParkQueue); err != nil {
+					s.log.Error("could not park job", "uuid", msg.UUID, "error", err)
+				}
+				continue
+			}
+			s.log.Info("released parked job", "uuid", msg.UUID, "task", msg.Job.Task)
+		}
+	}
+}
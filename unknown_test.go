@@ -0,0 +1,79 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bi "github.com/kalbhor/tasqueue/brokers/in-memory"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestUnknownTask(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = bi.New()
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:      broker,
+		Results:     NewMockResults(),
+		UnknownTask: UnknownTaskOpts{Delay: time.Millisecond, MaxRequeues: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := NewJob("unknown", nil, JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, err := srv.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	work := make(chan []byte)
+	go broker.Consume(ctx, work, DefaultQueue)
+	receive := func() JobMessage {
+		t.Helper()
+		select {
+		case b := <-work:
+			var msg JobMessage
+			if err := msgpack.Unmarshal(b, &msg); err != nil {
+				t.Fatal(err)
+			}
+			srv.Process(ctx, b)
+			return msg
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for job")
+		}
+		return JobMessage{}
+	}
+
+	// The job is requeued upto MaxRequeues times, and then parked.
+	for i := uint32(0); i <= 2; i++ {
+		if msg := receive(); msg.Requeues != i {
+			t.Fatalf("expected job to be requeued %d times, got %d", i, msg.Requeues)
+		}
+	}
+	select {
+	case <-work:
+		t.Fatal("expected job to be parked and not requeued")
+	case <-time.After(time.Millisecond * 100):
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusStarted {
+		t.Fatalf("expected parked job status to be unchanged, got %s", msg.Status)
+	}
+
+	// Released jobs are pushed back onto their queue, with the requeue count reset.
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go srv.ReleaseParked(rctx)
+	if msg := receive(); msg.UUID != uuid || msg.Requeues != 0 {
+		t.Fatalf("expected job %s to be released with 0 requeues, got %s with %d", uuid, msg.UUID, msg.Requeues)
+	}
+}
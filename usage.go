@@ -0,0 +1,215 @@
+package tasqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	usagePrefix = "tasqueue:usage:"
+	// usageBucket is the granularity at which usage is stored, and hence reported.
+	usageBucket = time.Hour
+)
+
+// Usage is the resources used by the jobs of a task, on a queue, of a tenant.
+type Usage struct {
+	Task   string `json:"task"`
+	Queue  string `json:"queue"`
+	Tenant string `json:"tenant,omitempty"`
+
+	// Attempts is the number of times the jobs were run, including retries, of which
+	// Failures failed.
+	Attempts int64 `json:"attempts"`
+	Failures int64 `json:"failures"`
+	// Duration is the cumulative execution time of the attempts.
+	Duration time.Duration `json:"duration"`
+	// PayloadBytes is the cumulative size of the payloads of the attempts, as enqueued.
+	PayloadBytes int64 `json:"payload_bytes"`
+}
+
+type usageKey struct {
+	task, queue, tenant string
+}
+
+func (u Usage) key() usageKey {
+	return usageKey{task: u.Task, queue: u.Queue, tenant: u.Tenant}
+}
+
+func (u *Usage) add(o Usage) {
+	u.Attempts += o.Attempts
+	u.Failures += o.Failures
+	u.Duration += o.Duration
+	u.PayloadBytes += o.PayloadBytes
+}
+
+// usageTracker aggregates the usage of the attempts in memory, per hour, until it's flushed
+// onto the results store.
+type usageTracker struct {
+	mu      sync.Mutex
+	buckets map[int64]map[usageKey]*Usage
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{buckets: make(map[int64]map[usageKey]*Usage)}
+}
+
+func (t *usageTracker) add(at time.Time, u Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hour := at.Truncate(usageBucket).Unix()
+	b, ok := t.buckets[hour]
+	if !ok {
+		b = make(map[usageKey]*Usage)
+		t.buckets[hour] = b
+	}
+	if v, ok := b[u.key()]; ok {
+		v.add(u)
+		return
+	}
+	b[u.key()] = &u
+}
+
+// take returns the aggregated usage and resets it.
+func (t *usageTracker) take() map[int64]map[usageKey]*Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.buckets
+	t.buckets = make(map[int64]map[usageKey]*Usage)
+	return b
+}
+
+// recordUsage records the usage of an attempt of the job, if usage is tracked.
+func (s *Server) recordUsage(msg JobMessage, started time.Time, failed bool) {
+	if s.usage == nil {
+		return
+	}
+
+	u := Usage{Task: msg.Job.Task, Queue: msg.Queue, Tenant: msg.Tenant, Attempts: 1, PayloadBytes: int64(len(msg.Job.Payload))}
+	if failed {
+		u.Failures = 1
+	}
+	u.Duration = s.clock.Now().Sub(started)
+	s.usage.add(started, u)
+}
+
+// runUsage periodically flushes the tracked usage onto the results store until the context
+// is cancelled, after which the remaining usage is flushed. It is a blocking function.
+func (s *Server) runUsage(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			// The context is cancelled, hence a new one is used for the last flush.
+			if err := s.flushUsage(context.Background()); err != nil {
+				s.log.Error("error flushing usage", "error", err)
+			}
+			return
+		case <-s.clock.After(s.usagePeriod):
+		}
+
+		if err := s.flushUsage(ctx); err != nil {
+			s.log.Error("error flushing usage", "error", err)
+		}
+	}
+}
+
+// flushUsage appends the usage aggregated since the last flush to the hours' buckets. The
+// usage that couldn't be flushed is kept for the next flush.
+func (s *Server) flushUsage(ctx context.Context) error {
+	var ferr error
+	for hour, b := range s.usage.take() {
+		list := make([]Usage, 0, len(b))
+		for _, u := range b {
+			list = append(list, *u)
+		}
+		c, err := msgpack.Marshal(list)
+		if err != nil {
+			return err
+		}
+		if err := appendChunk(ctx, s.results, usageKeyAt(time.Unix(hour, 0)), c); err != nil {
+			for _, u := range list {
+				s.usage.add(time.Unix(hour, 0), u)
+			}
+			ferr = err
+		}
+	}
+
+	return ferr
+}
+
+func usageKeyAt(t time.Time) string {
+	return usagePrefix + t.UTC().Format("2006010215")
+}
+
+// UsageReport() returns the usage of the jobs run in the period before now, across the servers
+// tracking usage (ServerOpts.UsagePeriod) onto the results store, per task, queue and tenant.
+// Usage is stored per hour, hence the period is rounded up to the hour. Usage that hasn't
+// been flushed yet isn't reported.
+func (s *Server) UsageReport(ctx context.Context, period time.Duration) ([]Usage, error) {
+	if s.results == nil {
+		return nil, ErrNoResults
+	}
+
+	var (
+		now = s.clock.Now()
+		agg = make(map[usageKey]*Usage)
+	)
+	for t := now.Add(-period).Truncate(usageBucket); !t.After(now); t = t.Add(usageBucket) {
+		chunks, err := getChunks(ctx, s.results, usageKeyAt(t), 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			var list []Usage
+			if err := msgpack.Unmarshal(c, &list); err != nil {
+				return nil, err
+			}
+			for _, u := range list {
+				if v, ok := agg[u.key()]; ok {
+					v.add(u)
+					continue
+				}
+				u := u
+				agg[u.key()] = &u
+			}
+		}
+	}
+
+	out := make([]Usage, 0, len(agg))
+	for _, u := range agg {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Task != out[j].Task {
+			return out[i].Task < out[j].Task
+		}
+		if out[i].Queue != out[j].Queue {
+			return out[i].Queue < out[j].Queue
+		}
+		return out[i].Tenant < out[j].Tenant
+	})
+
+	return out, nil
+}
+
+// DeleteUsage() deletes the usage stored for the hours between from and to, eg: to prune
+// usage that has been reported. The hours are deleted one by one, hence the range should
+// be bounded.
+func (s *Server) DeleteUsage(ctx context.Context, from, to time.Time) error {
+	if s.results == nil {
+		return ErrNoResults
+	}
+
+	for t := from.Truncate(usageBucket); t.Before(to); t = t.Add(usageBucket) {
+		if err := s.results.Delete(ctx, usageKeyAt(t)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
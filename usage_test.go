@@ -0,0 +1,84 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageReport(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		clock  = newMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults(), Clock: clock, UsagePeriod: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		clock.advance(time.Second * 2)
+		if string(b) == "fail" {
+			return fmt.Errorf("failed : %w", ErrSkipRetry)
+		}
+		return nil
+	}, TaskOpts{})
+
+	for _, j := range []struct {
+		payload, tenant string
+	}{{"ok", ""}, {"fail", ""}, {"tenant", "acme"}} {
+		job, err := NewJob(taskName, []byte(j.payload), JobOpts{Tenant: j.tenant})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		srv.Process(ctx, <-broker.data)
+	}
+
+	// Usage isn't reported until it's flushed.
+	report, err := srv.UsageReport(ctx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 0 {
+		t.Fatalf("expected no usage before flushing, got %v", report)
+	}
+
+	if err := srv.flushUsage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	report, err = srv.UsageReport(ctx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Usage{
+		{Task: taskName, Queue: DefaultQueue, Attempts: 2, Failures: 1, Duration: time.Second * 4, PayloadBytes: 6},
+		{Task: taskName, Queue: DefaultQueue, Tenant: "acme", Attempts: 1, Duration: time.Second * 2, PayloadBytes: 6},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %v, got %v", expected, report)
+	}
+
+	// Usage outside the period isn't reported.
+	clock.advance(time.Hour * 3)
+	if report, err = srv.UsageReport(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 0 {
+		t.Fatalf("expected no usage in the last hour, got %v", report)
+	}
+
+	if err := srv.DeleteUsage(ctx, clock.Now().Add(-time.Hour*24), clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = srv.UsageReport(ctx, time.Hour*24); err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 0 {
+		t.Fatalf("expected the usage to be deleted, got %v", report)
+	}
+}
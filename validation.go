@@ -0,0 +1,77 @@
+package tasqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPayload is matched by the errors (*ValidationError) of the payloads that fail their
+// task's validation (TaskOpts.Validate).
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// ValidateMode is when the payloads of a task are validated.
+type ValidateMode uint8
+
+const (
+	// ValidateAll validates the payloads at enqueue and before the handler is called.
+	ValidateAll ValidateMode = iota
+	// ValidateEnqueue validates the payloads at enqueue, on the servers (or clients) with the
+	// task registered.
+	ValidateEnqueue
+	// ValidateProcess validates the payloads before the handler is called.
+	ValidateProcess
+)
+
+// ValidationError is the error of a payload which failed its task's validation. Jobs whose
+// payloads fail the validation before their handler is called fail without retries.
+type ValidationError struct {
+	Task string
+	// Err is the error returned by the task's validator, eg: describing the invalid fields.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid payload for task %s : %v", e.Task, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrInvalidPayload, and ErrSkipRetry so that invalid jobs aren't retried.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidPayload || target == ErrSkipRetry
+}
+
+// validate validates the payload with the task's validator, if the task's payloads are
+// validated in the mode.
+func (t Task) validate(payload []byte, mode ValidateMode) error {
+	if t.opts.Validate == nil || (t.opts.ValidateMode != ValidateAll && t.opts.ValidateMode != mode) {
+		return nil
+	}
+	if err := t.opts.Validate(payload); err != nil {
+		return &ValidationError{Task: t.name, Err: err}
+	}
+
+	return nil
+}
+
+// ValidateJSON returns a validator which decodes the payload (JSON) into T, rejecting unknown
+// fields, and validates the decoded value with its Validate() method, if *T has one.
+func ValidateJSON[T any]() func([]byte) error {
+	return func(b []byte) error {
+		var v T
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&v); err != nil {
+			return err
+		}
+		if vd, ok := any(&v).(interface{ Validate() error }); ok {
+			return vd.Validate()
+		}
+
+		return nil
+	}
+}
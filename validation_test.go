@@ -0,0 +1,99 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type addPayload struct {
+	A, B int
+}
+
+func (p *addPayload) Validate() error {
+	if p.A < 0 || p.B < 0 {
+		return errors.New("negative operand")
+	}
+	return nil
+}
+
+func TestPayloadValidation(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+		ran    bool
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		ran = true
+		return nil
+	}, TaskOpts{Validate: ValidateJSON[addPayload](), MaxRetries: 3})
+
+	// Invalid payloads are rejected at enqueue.
+	for _, p := range []string{`{"A": 1, "B": -1}`, `{"A": 1, "C": 2}`, `[]`} {
+		job, err := NewJob(taskName, []byte(p), JobOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = srv.Enqueue(ctx, job)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !errors.Is(err, ErrInvalidPayload) || verr.Task != taskName {
+			t.Fatalf("expected payload %s to be rejected, got %v", p, err)
+		}
+	}
+	job, err := NewJob(taskName, []byte(`{"A": 1, "B": 2}`), JobOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	srv.Process(ctx, <-broker.data)
+	if !ran {
+		t.Fatal("expected the valid job to be processed")
+	}
+
+	// Jobs enqueued by producers without the task registered are validated before the
+	// handler is called, and fail without retries.
+	producer, err := NewServer(ServerOpts{Broker: broker, Results: srv.results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.Payload = []byte(`{"A": -1}`)
+	uuid, err := producer.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran = false
+	srv.Process(ctx, <-broker.data)
+	if ran {
+		t.Fatal("expected the invalid job to not be processed")
+	}
+	msg, err := srv.GetJob(ctx, uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusFailed || msg.Retried != 0 {
+		t.Fatalf("expected the invalid job to fail without retries, got %s after %d retries", msg.Status, msg.Retried)
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	validate := func([]byte) error { return errors.New("invalid") }
+	for mode, expected := range map[ValidateMode][2]bool{
+		ValidateAll:     {true, true},
+		ValidateEnqueue: {true, false},
+		ValidateProcess: {false, true},
+	} {
+		task := Task{name: taskName, opts: TaskOpts{Validate: validate, ValidateMode: mode}}
+		if got := task.validate(nil, ValidateEnqueue) != nil; got != expected[0] {
+			t.Fatalf("mode %d: expected validation at enqueue to be %v", mode, expected[0])
+		}
+		if got := task.validate(nil, ValidateProcess) != nil; got != expected[1] {
+			t.Fatalf("mode %d: expected validation before processing to be %v", mode, expected[1])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tasqueue
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+// versionRequeueDelay is the delay after which a job of a handler version that isn't
+// registered on the server is pushed back onto the queue.
+const versionRequeueDelay = time.Second
+
+// errVersionNotFound is returned on getting the handler of a version of a task which isn't
+// registered, when other versions of the task are.
+var errVersionNotFound = errors.New("task version not registered")
+
+// taskKey returns the key of the task's version in the tasks map.
+func taskKey(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
+
+func (t Task) key() string {
+	return taskKey(t.name, t.opts.Version)
+}
+
+// versionLess compares versions, with the numeric parts compared by value (eg: v2 < v10).
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		var pa, pb string
+		pa, a = versionPart(a)
+		pb, b = versionPart(b)
+		if pa == pb {
+			continue
+		}
+
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		if errA == nil && errB == nil {
+			return na < nb
+		}
+		return pa < pb
+	}
+
+	return len(a) < len(b)
+}
+
+// versionPart splits the leading run of digits or non-digits off the version.
+func versionPart(v string) (string, string) {
+	digit := unicode.IsDigit(rune(v[0]))
+	for i, r := range v {
+		if unicode.IsDigit(r) != digit {
+			return v[:i], v[i:]
+		}
+	}
+
+	return v, ""
+}
+
+func contains(list []string, v string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+
+	return false
+}
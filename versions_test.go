@@ -0,0 +1,87 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaskVersions(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+		ran = make(chan string, 3)
+	)
+	for _, v := range []string{"v2", "v10"} {
+		v := v
+		srv.RegisterTask("versioned", func(b []byte, c JobCtx) error {
+			ran <- c.Meta.Version + ":" + v
+			return nil
+		}, TaskOpts{Version: v})
+	}
+	go srv.Start(ctx)
+
+	// Unversioned jobs are processed by the latest version.
+	for _, v := range []string{"v2", "v10", ""} {
+		job, err := NewJob("versioned", nil, JobOpts{Version: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := srv.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-ran:
+			got[r] = true
+		case <-time.After(time.Second):
+			t.Fatal("job was not processed")
+		}
+	}
+	for _, r := range []string{"v2:v2", "v10:v10", ":v10"} {
+		if !got[r] {
+			t.Fatalf("expected job version:handler version %s, got %v", r, got)
+		}
+	}
+
+	srv.UnregisterTask("versioned", "v10")
+	if _, err := srv.getHandler("versioned", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.getHandler("versioned", "v10"); err == nil {
+		t.Fatal("expected version v10 to be unregistered")
+	}
+}
+
+func TestTaskVersionFallback(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = newServer(t)
+		ran = make(chan string, 1)
+	)
+	srv.RegisterTask("versioned", func(b []byte, c JobCtx) error {
+		ran <- c.Meta.Version
+		return nil
+	}, TaskOpts{})
+	go srv.Start(ctx)
+
+	// A job of a version that isn't registered is processed by the unversioned handler.
+	job, err := NewJob("versioned", nil, JobOpts{Version: "v3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-ran:
+		if v != "v3" {
+			t.Fatalf("expected the v3 job, got %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job was not processed by the unversioned handler")
+	}
+}
@@ -0,0 +1,125 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// minWaitPoll and maxWaitPoll bound the interval at which the results store is polled for
+	// the status of a job being waited on, which backs off from the min to the max.
+	minWaitPoll = time.Millisecond * 10
+	maxWaitPoll = time.Second
+)
+
+// ErrWaitTimeout is returned by GetJobBlocking() if the job doesn't reach a final status within
+// the timeout.
+var ErrWaitTimeout = errors.New("timed out waiting for job")
+
+// watch returns a channel which receives on changes to the key, if the results store implements
+// Watcher. Otherwise, it returns a nil channel.
+func watch(ctx context.Context, r Results, key string) (<-chan struct{}, error) {
+	if w, ok := r.(Watcher); ok {
+		return w.Watch(ctx, key)
+	}
+	return nil, nil
+}
+
+// GetJobBlocking() waits upto the timeout (or until the context is done, if it is zero) for the
+// job to reach a final status, and returns its job message, eg: to call a task like an RPC.
+// The wait is woken by the job's status changes on this server, and by the results store's
+// notifications if it implements Watcher. Otherwise, the results store is polled with a backoff
+// upto a second. If the job doesn't complete in time, its last job message is returned with
+// ErrWaitTimeout.
+func (s *Server) GetJobBlocking(ctx context.Context, uuid string, timeout time.Duration) (JobMessage, error) {
+	if s.results == nil {
+		return JobMessage{}, ErrNoResults
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	local := s.addWaiter(uuid)
+	defer s.removeWaiter(uuid, local)
+
+	poll := minWaitPoll
+	changes, err := watch(ctx, s.results, uuid)
+	if err != nil {
+		s.log.Error("error watching job, polling instead", "uuid", uuid, "error", err)
+	} else if changes != nil {
+		// Polling only guards against missed notifications.
+		poll = maxWaitPoll
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = s.clock.After(timeout)
+	}
+
+	for {
+		msg, err := s.getJob(ctx, uuid, false)
+		if err != nil {
+			return JobMessage{}, err
+		}
+		if IsFinal(msg.Status) {
+			return msg, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return msg, ctx.Err()
+		case <-deadline:
+			return msg, fmt.Errorf("job %s is %s : %w", uuid, msg.Status, ErrWaitTimeout)
+		case <-local:
+		case <-changes:
+		case <-s.clock.After(poll):
+			if poll *= 2; poll > maxWaitPoll {
+				poll = maxWaitPoll
+			}
+		}
+	}
+}
+
+// addWaiter returns a channel which receives on the job's status changes on this server.
+func (s *Server) addWaiter(uuid string) chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	s.wmu.Lock()
+	s.waiters[uuid] = append(s.waiters[uuid], ch)
+	s.wmu.Unlock()
+
+	return ch
+}
+
+func (s *Server) removeWaiter(uuid string, ch chan struct{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	ws := s.waiters[uuid]
+	for i, w := range ws {
+		if w == ch {
+			ws = append(ws[:i:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(ws) == 0 {
+		delete(s.waiters, uuid)
+		return
+	}
+	s.waiters[uuid] = ws
+}
+
+// wake wakes the waiters of the job.
+func (s *Server) wake(uuid string) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	for _, ch := range s.waiters[uuid] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package tasqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rr "github.com/kalbhor/tasqueue/results/in-memory"
+)
+
+func TestGetJobBlocking(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		broker = NewMockBroker()
+	)
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: NewMockResults()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	// The job isn't processed within the timeout.
+	uuid, err := srv.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := srv.GetJobBlocking(ctx, uuid, time.Millisecond*50)
+	if !errors.Is(err, ErrWaitTimeout) || msg.Status != StatusStarted {
+		t.Fatalf("expected ErrWaitTimeout with the queued job, got %v, %s", err, msg.Status)
+	}
+
+	// The waiter is woken by the job completing on the server.
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		srv.Process(ctx, <-broker.data)
+	}()
+	if msg, err = srv.GetJobBlocking(ctx, uuid, time.Second*5); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone {
+		t.Fatalf("expected status %s, got %s", StatusDone, msg.Status)
+	}
+	srv.wmu.Lock()
+	n := len(srv.waiters)
+	srv.wmu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected the waiters to be removed, got %d", n)
+	}
+}
+
+func TestGetJobBlockingWatch(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		broker  = NewMockBroker()
+		results = rr.New()
+	)
+	// The job is enqueued by a client, and processed by a server sharing the results store.
+	cl, err := NewClient(ClientOpts{Broker: broker, Results: results, Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewServer(ServerOpts{Broker: broker, Results: results, Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, MockHandler, TaskOpts{})
+
+	uuid, err := cl.Enqueue(ctx, makeJob(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client polls every second once it watches the job, hence a faster return is
+	// woken by the results store.
+	var processed time.Time
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		processed = time.Now()
+		srv.Process(ctx, <-broker.data)
+	}()
+	msg, err := cl.GetJobBlocking(ctx, uuid, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != StatusDone {
+		t.Fatalf("expected status %s, got %s", StatusDone, msg.Status)
+	}
+	if d := time.Since(processed); d > time.Millisecond*500 {
+		t.Fatalf("expected the wait to be woken by the results store, took %s", d)
+	}
+}
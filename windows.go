@@ -0,0 +1,120 @@
+package tasqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring window of time during which a queue isn't consumed, eg: to not run
+// heavy reporting jobs during business hours. It opens on the cron Spec (the standard 5 field
+// syntax, or a descriptor like @daily), and stays open for the Duration. Jobs enqueued during
+// the window accumulate in the queue, and are processed after it closes.
+type Window struct {
+	Spec     string
+	Duration time.Duration
+}
+
+// window is a parsed Window.
+type window struct {
+	sch cron.Schedule
+	dur time.Duration
+}
+
+// parseWindows parses the windows of each queue.
+func parseWindows(qw map[string][]Window) (map[string][]window, error) {
+	out := make(map[string][]window, len(qw))
+	for q, ws := range qw {
+		for _, w := range ws {
+			sch, err := parseSchedule(w.Spec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid window %q of queue %s : %w", w.Spec, q, err)
+			}
+			if w.Duration <= 0 {
+				return nil, fmt.Errorf("invalid duration of window %q of queue %s", w.Spec, q)
+			}
+			out[q] = append(out[q], window{sch: sch, dur: w.Duration})
+		}
+	}
+
+	return out, nil
+}
+
+// closedUntil returns the time at which the open windows close, or false if none of the
+// windows are open at the time. Windows which overlap are treated as one.
+func closedUntil(ws []window, now time.Time) (time.Time, bool) {
+	var (
+		end    = now
+		closed = false
+	)
+	for {
+		extended := false
+		for _, w := range ws {
+			// A window is open if it was opened within its duration before the time.
+			start := w.sch.Next(end.Add(-w.dur))
+			if start.IsZero() || start.After(end) {
+				continue
+			}
+			if e := start.Add(w.dur); e.After(end) {
+				end, closed, extended = e, true, true
+			}
+		}
+		if !extended {
+			return end, closed
+		}
+	}
+}
+
+// nextOpen returns the time at which the next of the windows opens, or the zero time if
+// none of them open again.
+func nextOpen(ws []window, now time.Time) time.Time {
+	var next time.Time
+	for _, w := range ws {
+		t := w.sch.Next(now)
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// consumeWindowed consumes the queue outside of its windows. The consumer is stopped when a
+// window opens, and restarted after it closes. It returns after the context is cancelled.
+func (s *Server) consumeWindowed(ctx context.Context, work chan []byte, queue string, ws []window) {
+	for {
+		now := s.clock.Now()
+		if end, ok := closedUntil(ws, now); ok {
+			s.log.Info("queue window open, pausing consumer", "queue", queue, "until", end)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(end.Sub(now)):
+			}
+			continue
+		}
+
+		next := nextOpen(ws, now)
+		if next.IsZero() {
+			s.broker.Consume(ctx, work, queue)
+			return
+		}
+
+		cctx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-cctx.Done():
+			case <-s.clock.After(next.Sub(now)):
+				cancel()
+			}
+		}()
+		s.broker.Consume(cctx, work, queue)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
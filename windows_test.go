@@ -0,0 +1,87 @@
+package tasqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWindows(t *testing.T) {
+	var (
+		clock = newMockClock(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+		done  = make(chan struct{}, 1)
+	)
+	srv, err := NewServer(ServerOpts{
+		Broker:  NewMockBroker(),
+		Results: NewMockResults(),
+		Clock:   clock,
+		Windows: map[string][]Window{
+			DefaultQueue: {{Spec: "0 9 * * *", Duration: time.Hour * 8}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.RegisterTask(taskName, func(b []byte, _ JobCtx) error {
+		done <- struct{}{}
+		return nil
+	}, TaskOpts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := srv.Enqueue(ctx, makeJob(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start(ctx)
+
+	// The job accumulates in the queue while the window is open.
+	clock.wait(t)
+	select {
+	case <-done:
+		t.Fatal("expected job to not be processed during the window")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// The job is processed after the window closes.
+	clock.advance(time.Hour * 7)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected job to be processed after the window closed")
+	}
+}
+
+func TestClosedUntil(t *testing.T) {
+	ws, err := parseWindows(map[string][]Window{
+		DefaultQueue: {
+			{Spec: "0 9 * * *", Duration: time.Hour * 2},
+			{Spec: "0 10 * * *", Duration: time.Hour * 3},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		now    time.Duration
+		end    time.Duration
+		closed bool
+	}{
+		{now: time.Hour * 8},
+		// Overlapping windows close with the last of them.
+		{now: time.Hour * 9, end: time.Hour * 13, closed: true},
+		{now: time.Hour * 12, end: time.Hour * 13, closed: true},
+		{now: time.Hour * 13},
+	} {
+		end, closed := closedUntil(ws[DefaultQueue], day.Add(c.now))
+		if closed != c.closed || (closed && !end.Equal(day.Add(c.end))) {
+			t.Fatalf("at %v expected (%v, %v), got (%v, %v)", c.now, c.end, c.closed, end.Sub(day), closed)
+		}
+	}
+
+	if _, err := parseWindows(map[string][]Window{DefaultQueue: {{Spec: "bad", Duration: time.Hour}}}); err == nil {
+		t.Fatal("expected error parsing an invalid window spec")
+	}
+}